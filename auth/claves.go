@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Algoritmos de firma soportados
+const (
+	AlgoritmoHS256 = "HS256" // Clave secreta compartida (clave_jwt)
+	AlgoritmoRS256 = "RS256" // RSA de 2048 bits
+	AlgoritmoEdDSA = "EdDSA" // Ed25519
+)
+
+// Formato del kid: la fecha de creación de la clave más un sufijo aleatorio,
+// así ordenar los kid alfabéticamente es ordenarlos por antigüedad
+const formatoKid = "20060102T150405Z"
+
+// Cada cuánto se revisa si toca rotar y se relee el directorio (otras instancias
+// pueden haber agregado claves), y el margen extra que se mantiene cada clave retirada
+const (
+	intervaloRevisionClaves = time.Minute
+	margenClavesRetiradas   = 5 * time.Minute
+	intervaloRelectura      = 10 * time.Second // Para que un kid inventado no haga leer el disco en cada pedido
+	// Una clave nueva se publica en el JWKS este tiempo antes de empezar a firmar, para
+	// que los servicios que guardan el JWKS en cache ya la conozcan (ver ObtenerJWKS)
+	anticipacionClaves = 10 * time.Minute
+)
+
+// claveFirma es un par de claves cargado de <directorio>/<kid>.pem
+type claveFirma struct {
+	kid      string
+	creadaEn time.Time
+	metodo   jwt.SigningMethod
+	privada  crypto.Signer
+}
+
+// ConjuntoClaves guarda las claves de firma. La más nueva que ya cumplió la anticipación
+// firma los tokens; las anteriores solo se usan para validar hasta que expiren los tokens
+// que firmaron.
+type ConjuntoClaves struct {
+	mu         sync.RWMutex
+	directorio string
+	algoritmo  string        // Algoritmo de las claves nuevas
+	rotacion   time.Duration // Antigüedad a partir de la cual se genera una clave nueva
+	claves     []claveFirma  // Ordenadas de la más vieja a la más nueva
+	releidoEn  time.Time     // Última vez que buscar releyó el directorio
+}
+
+// claves es nil cuando se firma con HS256 y la clave compartida
+var claves *ConjuntoClaves
+
+// ConfigurarClaves hace que los tokens se firmen con el conjunto de claves asimétricas
+func ConfigurarClaves(conjunto *ConjuntoClaves) {
+	claves = conjunto
+}
+
+// CargarClaves lee las claves del directorio (lo crea si no existe) y genera una
+// clave nueva si no hay ninguna o si la más nueva ya cumplió el período de rotación
+func CargarClaves(directorio, algoritmo string, rotacion time.Duration) (*ConjuntoClaves, error) {
+	if _, err := metodoDeAlgoritmo(algoritmo); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(directorio, 0o700); err != nil {
+		return nil, fmt.Errorf("no se pudo crear el directorio de claves: %w", err)
+	}
+	conjunto := &ConjuntoClaves{directorio: directorio, algoritmo: algoritmo, rotacion: rotacion}
+	if err := conjunto.Revisar(); err != nil {
+		return nil, err
+	}
+	return conjunto, nil
+}
+
+// Revisar relee el directorio, rota la clave activa si corresponde y borra las
+// claves retiradas que ya no pueden validar ningún token vigente
+func (c *ConjuntoClaves) Revisar() error {
+	cargadas, err := leerClaves(c.directorio)
+	if err != nil {
+		return err
+	}
+
+	ahora := time.Now().UTC()
+	if len(cargadas) == 0 || ahora.Sub(cargadas[len(cargadas)-1].creadaEn) >= c.rotacion {
+		nueva, err := c.generar(ahora)
+		if err != nil {
+			return err
+		}
+		log.Printf("Nueva clave de firma %s (%s)", nueva.kid, nueva.metodo.Alg())
+		cargadas = append(cargadas, *nueva)
+	}
+
+	// Una clave se retira cuando la siguiente empieza a firmar; sigue validando
+	// mientras pueda existir algún token firmado con ella
+	vigentes := cargadas[:0]
+	for i, clave := range cargadas {
+		if i < len(cargadas)-1 && ahora.After(cargadas[i+1].creadaEn.Add(anticipacionClaves+duracionToken+margenClavesRetiradas)) {
+			log.Printf("Se borra la clave de firma retirada %s", clave.kid)
+			if err := os.Remove(filepath.Join(c.directorio, clave.kid+".pem")); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Println("No se pudo borrar la clave:", err)
+			}
+			continue
+		}
+		vigentes = append(vigentes, clave)
+	}
+
+	c.mu.Lock()
+	c.claves = vigentes
+	c.mu.Unlock()
+	return nil
+}
+
+// IniciarRotacion revisa las claves periódicamente en segundo plano
+func (c *ConjuntoClaves) IniciarRotacion() {
+	go func() {
+		for range time.Tick(intervaloRevisionClaves) {
+			if err := c.Revisar(); err != nil {
+				log.Println("Error al revisar las claves de firma:", err)
+			}
+		}
+	}()
+}
+
+// activa devuelve la clave con la que se firman los tokens nuevos: la más nueva que ya
+// estuvo publicada el tiempo de anticipación. Al arrancar por primera vez no hay ninguna
+// así y se usa la única que hay.
+func (c *ConjuntoClaves) activa() claveFirma {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ahora := time.Now()
+	for i := len(c.claves) - 1; i >= 0; i-- {
+		if ahora.Sub(c.claves[i].creadaEn) >= anticipacionClaves {
+			return c.claves[i]
+		}
+	}
+	return c.claves[0]
+}
+
+// buscar devuelve la clave con ese kid. Si no la conoce relee el directorio una vez,
+// porque otra instancia puede haberla creado después de la última revisión.
+func (c *ConjuntoClaves) buscar(kid string) (claveFirma, bool) {
+	if clave, ok := c.buscarCargada(kid); ok {
+		return clave, true
+	}
+
+	c.mu.Lock()
+	releer := time.Since(c.releidoEn) >= intervaloRelectura
+	if releer {
+		c.releidoEn = time.Now()
+	}
+	c.mu.Unlock()
+	if !releer {
+		return claveFirma{}, false
+	}
+	if cargadas, err := leerClaves(c.directorio); err == nil {
+		for _, clave := range cargadas {
+			if clave.kid == kid {
+				return clave, true
+			}
+		}
+	}
+	return claveFirma{}, false
+}
+
+// buscarCargada busca el kid entre las claves en memoria
+func (c *ConjuntoClaves) buscarCargada(kid string) (claveFirma, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, clave := range c.claves {
+		if clave.kid == kid {
+			return clave, true
+		}
+	}
+	return claveFirma{}, false
+}
+
+// generar crea una clave del algoritmo configurado y la guarda en el directorio
+func (c *ConjuntoClaves) generar(ahora time.Time) (*claveFirma, error) {
+	metodo, _ := metodoDeAlgoritmo(c.algoritmo)
+	var privada crypto.Signer
+	var err error
+	if c.algoritmo == AlgoritmoRS256 {
+		privada, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		_, privada, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sufijo := make([]byte, 4)
+	if _, err := rand.Read(sufijo); err != nil {
+		return nil, err
+	}
+	kid := ahora.Format(formatoKid) + "-" + hex.EncodeToString(sufijo)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privada)
+	if err != nil {
+		return nil, err
+	}
+	// Escribimos en un temporal y renombramos, para que otra instancia nunca lea un archivo a medias
+	ruta := filepath.Join(c.directorio, kid+".pem")
+	temporal := ruta + ".tmp"
+	if err := os.WriteFile(temporal, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(temporal, ruta); err != nil {
+		return nil, err
+	}
+	return &claveFirma{kid: kid, creadaEn: ahora.Truncate(time.Second), metodo: metodo, privada: privada}, nil
+}
+
+// leerClaves carga todos los <kid>.pem del directorio, ordenados por antigüedad
+func leerClaves(directorio string) ([]claveFirma, error) {
+	rutas, err := filepath.Glob(filepath.Join(directorio, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rutas)
+
+	var cargadas []claveFirma
+	for _, ruta := range rutas {
+		kid := strings.TrimSuffix(filepath.Base(ruta), ".pem")
+		fecha, _, _ := strings.Cut(kid, "-")
+		creadaEn, err := time.Parse(formatoKid, fecha)
+		if err != nil {
+			return nil, fmt.Errorf("clave %s: el nombre debe empezar con la fecha de creación (%s)", ruta, formatoKid)
+		}
+
+		contenido, err := os.ReadFile(ruta)
+		if err != nil {
+			return nil, err
+		}
+		bloque, _ := pem.Decode(contenido)
+		if bloque == nil {
+			return nil, fmt.Errorf("clave %s: no es un archivo PEM", ruta)
+		}
+		privada, err := x509.ParsePKCS8PrivateKey(bloque.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("clave %s: %w", ruta, err)
+		}
+
+		// El algoritmo de cada clave sale de su tipo, así conviven claves de los dos
+		// algoritmos mientras se cambia de uno a otro
+		clave := claveFirma{kid: kid, creadaEn: creadaEn}
+		switch p := privada.(type) {
+		case *rsa.PrivateKey:
+			clave.metodo, clave.privada = jwt.SigningMethodRS256, p
+		case ed25519.PrivateKey:
+			clave.metodo, clave.privada = jwt.SigningMethodEdDSA, p
+		default:
+			return nil, fmt.Errorf("clave %s: tipo de clave no soportado %T", ruta, privada)
+		}
+		cargadas = append(cargadas, clave)
+	}
+	return cargadas, nil
+}
+
+// metodoDeAlgoritmo traduce el nombre del algoritmo asimétrico al método de firma
+func metodoDeAlgoritmo(algoritmo string) (jwt.SigningMethod, error) {
+	switch algoritmo {
+	case AlgoritmoRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgoritmoEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("algoritmo de firma asimétrico desconocido: %q", algoritmo)
+}
+
+// ClaveJWK es una clave pública en formato JWK (RFC 7517)
+type ClaveJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA: módulo
+	E   string `json:"e,omitempty"`   // RSA: exponente
+	Crv string `json:"crv,omitempty"` // OKP: curva
+	X   string `json:"x,omitempty"`   // OKP: clave pública
+}
+
+// JWKS devuelve las claves públicas con las que se pueden validar los tokens vigentes.
+// Con HS256 la lista está vacía: la clave compartida nunca se publica.
+func JWKS() []ClaveJWK {
+	publicas := []ClaveJWK{}
+	if claves == nil {
+		return publicas
+	}
+	claves.mu.RLock()
+	defer claves.mu.RUnlock()
+
+	codificar := base64.RawURLEncoding.EncodeToString
+	for _, clave := range claves.claves {
+		jwk := ClaveJWK{Kid: clave.kid, Use: "sig", Alg: clave.metodo.Alg()}
+		switch publica := clave.privada.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = codificar(publica.N.Bytes())
+			jwk.E = codificar(big.NewInt(int64(publica.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", codificar(publica)
+		}
+		publicas = append(publicas, jwk)
+	}
+	return publicas
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Duración de los tokens en las pruebas de claves: una clave retirada se borra
+// anticipacionClaves+duracionClavesPrueba+margenClavesRetiradas (30 minutos) después
+// de que la siguiente se creó
+const duracionClavesPrueba = 15 * time.Minute
+
+// prepararClaves configura la duración de los tokens y deja HS256 al terminar
+func prepararClaves(t *testing.T) {
+	t.Helper()
+	Configurar("clave-de-prueba", duracionClavesPrueba)
+	t.Cleanup(func() { ConfigurarClaves(nil) })
+}
+
+// escribirClave guarda en el directorio una clave Ed25519 creada en el momento indicado,
+// como si la hubiera generado otra instancia; devuelve su kid
+func escribirClave(t *testing.T, directorio string, creadaEn time.Time) string {
+	t.Helper()
+	_, privada, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privada)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sufijo := make([]byte, 4)
+	rand.Read(sufijo)
+	kid := creadaEn.UTC().Format(formatoKid) + "-" + hex.EncodeToString(sufijo)
+	if err := os.WriteFile(filepath.Join(directorio, kid+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return kid
+}
+
+// kids devuelve los kid cargados, del más viejo al más nuevo
+func kids(conjunto *ConjuntoClaves) []string {
+	conjunto.mu.RLock()
+	defer conjunto.mu.RUnlock()
+	var lista []string
+	for _, clave := range conjunto.claves {
+		lista = append(lista, clave.kid)
+	}
+	return lista
+}
+
+// existe indica si el archivo de la clave sigue en el directorio
+func existe(directorio, kid string) bool {
+	_, err := os.Stat(filepath.Join(directorio, kid+".pem"))
+	return err == nil
+}
+
+func TestCargarClavesGeneraLaPrimera(t *testing.T) {
+	prepararClaves(t)
+	directorio := filepath.Join(t.TempDir(), "claves")
+	conjunto, err := CargarClaves(directorio, AlgoritmoEdDSA, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lista := kids(conjunto)
+	if len(lista) != 1 {
+		t.Fatalf("se cargaron %d claves, se esperaba 1", len(lista))
+	}
+	info, err := os.Stat(filepath.Join(directorio, lista[0]+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permisos := info.Mode().Perm(); permisos != 0o600 {
+		t.Errorf("la clave tiene permisos %o, se esperaba 600", permisos)
+	}
+	// Al arrancar por primera vez no hay una clave con la anticipación cumplida y firma la única
+	if activa := conjunto.activa(); activa.kid != lista[0] {
+		t.Errorf("firma %s, se esperaba %s", activa.kid, lista[0])
+	}
+
+	// Otra instancia con el mismo directorio usa la misma clave
+	otro, err := CargarClaves(directorio, AlgoritmoEdDSA, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otros := kids(otro); len(otros) != 1 || otros[0] != lista[0] {
+		t.Errorf("la segunda carga dio %v, se esperaba %v", otros, lista)
+	}
+
+	if _, err := CargarClaves(directorio, AlgoritmoHS256, time.Hour); err == nil {
+		t.Error("se aceptó HS256 como algoritmo de las claves del directorio")
+	}
+}
+
+func TestRevisarRotaYBorraLasRetiradas(t *testing.T) {
+	prepararClaves(t)
+	ahora := time.Now()
+	casos := []struct {
+		nombre   string
+		creadas  []time.Duration // Antigüedad de las claves del directorio, de la más vieja a la más nueva
+		rota     bool            // Se espera una clave nueva
+		firma    int             // Índice de la clave que firma entre las cargadas (con la nueva al final)
+		borradas int             // Cuántas de las más viejas se borran
+	}{
+		{"sin rotar", []time.Duration{time.Hour}, false, 0, 0},
+		// La nueva se publica pero todavía no firma
+		{"rota", []time.Duration{25 * time.Hour}, true, 0, 0},
+		{"la nueva todavía no cumplió la anticipación", []time.Duration{30 * time.Hour, 9 * time.Minute}, false, 0, 0},
+		{"la nueva ya cumplió la anticipación", []time.Duration{30 * time.Hour, 11 * time.Minute}, false, 1, 0},
+		// La anterior sigue mientras puedan existir tokens firmados con ella
+		{"la anterior todavía valida", []time.Duration{30 * time.Hour, 29 * time.Minute}, false, 1, 0},
+		{"la anterior ya no valida", []time.Duration{30 * time.Hour, 31 * time.Minute}, false, 0, 1},
+		{"varias retiradas", []time.Duration{80 * time.Hour, 50 * time.Hour, 26 * time.Hour, 2 * time.Hour}, false, 0, 3},
+		{"rota y la anterior sigue", []time.Duration{50 * time.Hour, 25 * time.Hour}, true, 0, 1},
+	}
+	for _, caso := range casos {
+		directorio := t.TempDir()
+		var escritas []string
+		for _, antiguedad := range caso.creadas {
+			escritas = append(escritas, escribirClave(t, directorio, ahora.Add(-antiguedad)))
+		}
+		conjunto, err := CargarClaves(directorio, AlgoritmoEdDSA, 24*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lista := kids(conjunto)
+
+		esperadas := len(escritas) - caso.borradas
+		if caso.rota {
+			esperadas++
+		}
+		if len(lista) != esperadas {
+			t.Errorf("%s: se cargaron %v, se esperaban %d claves", caso.nombre, lista, esperadas)
+			continue
+		}
+		for i, kid := range escritas {
+			if borrada := i < caso.borradas; borrada == existe(directorio, kid) {
+				t.Errorf("%s: la clave %d existe=%v, se esperaba borrada=%v", caso.nombre, i, !borrada, borrada)
+			}
+		}
+		if caso.rota && lista[len(lista)-1] == escritas[len(escritas)-1] {
+			t.Errorf("%s: no se generó una clave nueva", caso.nombre)
+		}
+		if activa := conjunto.activa(); activa.kid != lista[caso.firma] {
+			t.Errorf("%s: firma %s, se esperaba %s", caso.nombre, activa.kid, lista[caso.firma])
+		}
+	}
+}
+
+func TestBuscarReleeElDirectorio(t *testing.T) {
+	prepararClaves(t)
+	directorio := t.TempDir()
+	conjunto, err := CargarClaves(directorio, AlgoritmoEdDSA, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Otra instancia agrega una clave después de la carga
+	kid := escribirClave(t, directorio, time.Now())
+	if _, ok := conjunto.buscar(kid); !ok {
+		t.Fatal("no se encontró la clave que agregó otra instancia")
+	}
+	// Un kid inventado no vuelve a leer el disco hasta pasado intervaloRelectura
+	inventado := escribirClave(t, directorio, time.Now())
+	if _, ok := conjunto.buscar(inventado); ok {
+		t.Error("se releyó el directorio antes de intervaloRelectura")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	casos := []struct {
+		algoritmo string
+		kty       string
+	}{
+		{AlgoritmoEdDSA, "OKP"},
+		{AlgoritmoRS256, "RSA"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.algoritmo, func(t *testing.T) {
+			prepararClaves(t)
+			conjunto, err := CargarClaves(t.TempDir(), caso.algoritmo, 24*time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ConfigurarClaves(conjunto)
+
+			jwks := JWKS()
+			if len(jwks) != 1 {
+				t.Fatalf("el JWKS tiene %d claves, se esperaba 1", len(jwks))
+			}
+			jwk := jwks[0]
+			if jwk.Kty != caso.kty || jwk.Alg != caso.algoritmo || jwk.Use != "sig" || jwk.Kid != kids(conjunto)[0] {
+				t.Errorf("la clave publicada es %+v", jwk)
+			}
+
+			// Un servicio que solo tiene el JWKS valida los tokens
+			publica := publicaDeJWK(t, jwk)
+			token, err := GenerarToken(7, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			validado, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+				if token.Header["kid"] != jwk.Kid {
+					t.Errorf("el token tiene kid %v, se esperaba %s", token.Header["kid"], jwk.Kid)
+				}
+				return publica, nil
+			}, jwt.WithValidMethods([]string{caso.algoritmo}))
+			if err != nil || !validado.Valid {
+				t.Errorf("el token no se validó con la clave del JWKS: %v", err)
+			}
+		})
+	}
+
+	// Con HS256 no se publica nada
+	ConfigurarClaves(nil)
+	if jwks := JWKS(); jwks == nil || len(jwks) != 0 {
+		t.Errorf("con HS256 el JWKS es %v, se esperaba una lista vacía", jwks)
+	}
+}
+
+// publicaDeJWK reconstruye la clave pública a partir de sus campos en el JWK
+func publicaDeJWK(t *testing.T, jwk ClaveJWK) crypto.PublicKey {
+	t.Helper()
+	decodificar := func(campo string) []byte {
+		valor, err := base64.RawURLEncoding.DecodeString(campo)
+		if err != nil {
+			t.Fatalf("campo del JWK inválido %q: %v", campo, err)
+		}
+		return valor
+	}
+	switch jwk.Kty {
+	case "RSA":
+		if jwk.Crv != "" || jwk.X != "" {
+			t.Errorf("la clave RSA tiene campos de OKP: %+v", jwk)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(decodificar(jwk.N)), E: int(new(big.Int).SetBytes(decodificar(jwk.E)).Int64())}
+	case "OKP":
+		if jwk.Crv != "Ed25519" || jwk.N != "" || jwk.E != "" {
+			t.Errorf("la clave OKP es %+v", jwk)
+		}
+		x := decodificar(jwk.X)
+		if len(x) != ed25519.PublicKeySize {
+			t.Fatalf("la clave Ed25519 tiene %d bytes", len(x))
+		}
+		return ed25519.PublicKey(x)
+	}
+	t.Fatalf("tipo de clave desconocido %q", jwk.Kty)
+	return nil
+}
@@ -6,13 +6,21 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// CORSMiddleware configura los encabezados de CORS
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware configura los encabezados de CORS.
+// Si "origenes" está vacío o contiene "*" se acepta cualquier origen.
+func CORSMiddleware(origenes []string) gin.HandlerFunc {
+	permitidos := make(map[string]bool, len(origenes))
+	for _, origen := range origenes {
+		permitidos[origen] = true
+	}
+	cualquiera := len(origenes) == 0 || permitidos["*"]
+
 	return func(c *gin.Context) {
-		// Permitir el origen de la solicitud
+		// Permitir el origen de la solicitud solo si está en la lista
 		origin := c.Request.Header.Get("Origin")
-		if origin != "" {
+		if origin != "" && (cualquiera || permitidos[origin]) {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
 		}
 
 		// Configuración de CORS
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Cantidad de decisiones recientes que se guardan en memoria
+const capacidadDecisiones = 500
+
+// Decision es el resultado de una verificación de permisos sobre una ruta
+type Decision struct {
+	Momento   time.Time `json:"momento"`
+	UsuarioID uint      `json:"usuario_id"`
+	Roles     []string  `json:"roles"`
+	Metodo    string    `json:"metodo"`
+	Ruta      string    `json:"ruta"`
+	Permisos  []string  `json:"permisos"` // Permisos que exigía la ruta
+	Permitido bool      `json:"permitido"`
+	Faltante  string    `json:"faltante,omitempty"` // Primer permiso que faltó, si se denegó
+}
+
+// RegistroDecisiones guarda las últimas decisiones de autorización y las escribe en el log
+type RegistroDecisiones struct {
+	mu         sync.Mutex
+	decisiones []Decision // Buffer circular
+	siguiente  int
+	lleno      bool
+}
+
+// Decisiones es el registro que usa RequierePermiso
+var Decisiones = NuevoRegistroDecisiones(capacidadDecisiones)
+
+// NuevoRegistroDecisiones crea un registro que conserva las últimas "capacidad" decisiones
+func NuevoRegistroDecisiones(capacidad int) *RegistroDecisiones {
+	return &RegistroDecisiones{decisiones: make([]Decision, capacidad)}
+}
+
+// Registrar agrega una decisión al registro y la escribe en el log
+func (r *RegistroDecisiones) Registrar(d Decision) {
+	resultado := "PERMITIDO"
+	if !d.Permitido {
+		resultado = "DENEGADO (falta " + d.Faltante + ")"
+	}
+	log.Printf("[autorizacion] %s %s usuario=%d roles=%v permisos=%v: %s", d.Metodo, d.Ruta, d.UsuarioID, d.Roles, d.Permisos, resultado)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decisiones[r.siguiente] = d
+	r.siguiente = (r.siguiente + 1) % len(r.decisiones)
+	if r.siguiente == 0 {
+		r.lleno = true
+	}
+}
+
+// Recientes devuelve las decisiones guardadas, de la más nueva a la más vieja
+func (r *RegistroDecisiones) Recientes() []Decision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cantidad := r.siguiente
+	if r.lleno {
+		cantidad = len(r.decisiones)
+	}
+	recientes := make([]Decision, 0, cantidad)
+	for i := 1; i <= cantidad; i++ {
+		indice := (r.siguiente - i + len(r.decisiones)) % len(r.decisiones)
+		recientes = append(recientes, r.decisiones[indice])
+	}
+	return recientes
+}
@@ -5,12 +5,24 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // RequiereAutenticacion es un middleware que verifica que el usuario tenga un token válido
 func RequiereAutenticacion() gin.HandlerFunc {
+	return autenticarCon(ValidarToken)
+}
+
+// RequiereAutenticacionUserInfo es RequiereAutenticacion para /userinfo, que también
+// acepta los tokens que reciben los clientes OIDC
+func RequiereAutenticacionUserInfo() gin.HandlerFunc {
+	return autenticarCon(ValidarTokenUserInfo)
+}
+
+// autenticarCon arma el middleware de autenticación con la función que valida el token
+func autenticarCon(validarToken func(string) (*Reclamos, error)) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Obtenemos el token del header Authorization
 		tokenString := c.GetHeader("Authorization")
@@ -36,7 +48,7 @@ func RequiereAutenticacion() gin.HandlerFunc {
 		log.Println("Token recibido:", tokenString)
 
 		// Validamos el token
-		usuario, err := ValidarToken(tokenString)
+		usuario, err := validarToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
 			c.Abort()
@@ -48,15 +60,51 @@ func RequiereAutenticacion() gin.HandlerFunc {
 
 		// Guardamos el id del usuario en el contexto para futuras solicitudes
 		c.Set("id_usuario", strconv.Itoa(int(usuario.Id))) // Convertir uint a int y luego a string
+		// Guardamos los reclamos completos para que RequierePermiso pueda consultar los permisos
+		c.Set("reclamos", usuario)
+
+		c.Next() // Continuamos la ejecución si el token es válido
+	}
+}
 
-		// Verificamos si el usuario es administrador (ID 1)
-		if usuario.Id == 1 {
-			log.Println("Acceso otorgado al usuario administrador")
-			c.Set("es_admin", true) // Guardamos una marca en el contexto de que es admin
-		} else {
-			c.Set("es_admin", false)
+// RequierePermiso es un middleware que exige que el token tenga todos los permisos indicados
+// y responde 403 si falta alguno. Cada decisión queda en el registro Decisiones.
+// Debe usarse después de RequiereAutenticacion.
+func RequierePermiso(permisos ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		valor, existe := c.Get("reclamos")
+		reclamos, ok := valor.(*Reclamos)
+		if !existe || !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token no proporcionado"})
+			c.Abort()
+			return
 		}
 
-		c.Next() // Continuamos la ejecución si el token es válido
+		decision := Decision{
+			Momento:   time.Now(),
+			UsuarioID: reclamos.Id,
+			Roles:     reclamos.Roles,
+			Metodo:    c.Request.Method,
+			Ruta:      c.FullPath(),
+			Permisos:  permisos,
+			Permitido: true,
+		}
+		for _, permiso := range permisos {
+			if !reclamos.TienePermiso(permiso) {
+				decision.Permitido = false
+				decision.Faltante = permiso
+				break
+			}
+		}
+		Decisiones.Registrar(decision)
+
+		if !decision.Permitido {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No tiene permiso para realizar esta acción"})
+			c.Abort()
+			return
+		}
+		// Marca que los manejadores usan para no actuar sobre otros usuarios sin verificación
+		c.Set("permiso_verificado", true)
+		c.Next()
 	}
 }
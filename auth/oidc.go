@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"taller6/modelos"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ReclamosID son los reclamos del ID token de OpenID Connect
+type ReclamosID struct {
+	NombreUsuario string           `json:"nombre_usuario"`
+	Correo        string           `json:"correo"`
+	Nonce         string           `json:"nonce,omitempty"`
+	AuthTime      *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerarIDToken crea el ID token que recibe el cliente en /token. Se firma con la
+// misma clave que los tokens de acceso, así el cliente lo valida con el JWKS.
+func GenerarIDToken(emisor, clienteID string, usuario *modelos.Usuario, nonce string, autenticadoEn time.Time) (string, error) {
+	ahora := time.Now()
+	reclamos := ReclamosID{
+		NombreUsuario: usuario.NombreUsuario,
+		Correo:        usuario.Correo,
+		Nonce:         nonce,
+		AuthTime:      jwt.NewNumericDate(autenticadoEn),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    emisor,
+			Subject:   strconv.FormatUint(uint64(usuario.ID), 10),
+			Audience:  jwt.ClaimStrings{clienteID},
+			IssuedAt:  jwt.NewNumericDate(ahora),
+			ExpiresAt: jwt.NewNumericDate(ahora.Add(duracionToken)),
+		},
+	}
+	return firmar(reclamos)
+}
+
+// GenerarTokenCliente crea el token de acceso que recibe un cliente OIDC en /token. Lleva
+// solo los scopes otorgados, sin roles ni permisos: el cliente puede consultar /userinfo
+// pero no usar la API en nombre del usuario.
+func GenerarTokenCliente(id_usuario uint, clienteID, scope string) (string, error) {
+	jti, err := generarJTI()
+	if err != nil {
+		return "", err
+	}
+	ahora := time.Now()
+	reclamos := Reclamos{Id: id_usuario, Scope: scope}
+	reclamos.ID = jti
+	reclamos.Audience = jwt.ClaimStrings{clienteID}
+	reclamos.IssuedAt = jwt.NewNumericDate(ahora)
+	reclamos.ExpiresAt = jwt.NewNumericDate(ahora.Add(duracionToken))
+	return firmar(reclamos)
+}
+
+// ValidarTokenUserInfo verifica un token para /userinfo: uno de acceso de la API o uno
+// de GenerarTokenCliente que incluya el scope openid
+func ValidarTokenUserInfo(tokenString string) (*Reclamos, error) {
+	reclamos, err := validar(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if reclamos.MFAPendiente {
+		return nil, ErrTokenMFAPendiente
+	}
+	if reclamos.Scope != "" && !slices.Contains(strings.Fields(reclamos.Scope), "openid") {
+		return nil, errors.New("ValidarTokenUserInfo: falta el scope openid")
+	}
+	return reclamos, nil
+}
+
+// DuracionToken devuelve el tiempo de vida configurado de los tokens de acceso (expires_in)
+func DuracionToken() time.Duration {
+	return duracionToken
+}
+
+// AlgoritmoFirma devuelve el algoritmo con el que se firman los tokens nuevos
+func AlgoritmoFirma() string {
+	if claves == nil {
+		return AlgoritmoHS256
+	}
+	return claves.activa().metodo.Alg()
+}
+
+// Formato del code_verifier según RFC 7636
+var patronVerificadorPKCE = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// VerificarPKCE comprueba que el code_verifier corresponde al code_challenge (método S256)
+func VerificarPKCE(verificador, desafio string) bool {
+	if !patronVerificadorPKCE.MatchString(verificador) {
+		return false
+	}
+	suma := sha256.Sum256([]byte(verificador))
+	calculado := base64.RawURLEncoding.EncodeToString(suma[:])
+	return subtle.ConstantTimeCompare([]byte(calculado), []byte(desafio)) == 1
+}
+
+// GenerarClienteID crea el client_id de un cliente nuevo
+func GenerarClienteID() (string, error) {
+	return GenerarFamilia()
+}
+
+// GenerarSecreto crea un valor opaco de un solo uso o de larga vida (código de autorización,
+// secreto de cliente) y su hash, con el mismo formato que los tokens de refresco
+func GenerarSecreto() (valor string, hash string, err error) {
+	return GenerarTokenRefresco()
+}
+
+// HashSecreto calcula el hash con el que se guarda y se busca un valor de GenerarSecreto
+func HashSecreto(valor string) string {
+	return HashTokenRefresco(valor)
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestVerificarPKCE(t *testing.T) {
+	// Apéndice B de RFC 7636
+	const (
+		verificador = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		desafio     = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	// Un verificador de 42 caracteres, uno menos que el mínimo
+	corto := verificador[:42]
+
+	casos := []struct {
+		nombre      string
+		verificador string
+		desafio     string
+		valido      bool
+	}{
+		{"vector del RFC", verificador, desafio, true},
+		{"otro verificador", strings.Replace(verificador, "d", "e", 1), desafio, false},
+		{"desafío con relleno", verificador, desafio + "=", false},
+		{"desafío en base64 estándar", verificador, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw+cM", false},
+		{"método plain", verificador, verificador, false},
+		{"verificador vacío", "", desafio, false},
+		{"desafío vacío", verificador, "", false},
+		{"verificador corto", corto, hashPKCE(corto), false},
+		{"verificador de 43 caracteres", verificador[:43], hashPKCE(verificador[:43]), true},
+		{"verificador de 128 caracteres", strings.Repeat("a", 128), hashPKCE(strings.Repeat("a", 128)), true},
+		{"verificador de 129 caracteres", strings.Repeat("a", 129), hashPKCE(strings.Repeat("a", 129)), false},
+		{"caracteres no permitidos", verificador[:42] + "+", hashPKCE(verificador[:42] + "+"), false},
+	}
+	for _, caso := range casos {
+		if valido := VerificarPKCE(caso.verificador, caso.desafio); valido != caso.valido {
+			t.Errorf("%s: se obtuvo %v, se esperaba %v", caso.nombre, valido, caso.valido)
+		}
+	}
+}
+
+// hashPKCE calcula el code_challenge S256 de un verificador sin controlar su formato
+func hashPKCE(verificador string) string {
+	suma := sha256.Sum256([]byte(verificador))
+	return base64.RawURLEncoding.EncodeToString(suma[:])
+}
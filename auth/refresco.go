@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Bytes aleatorios de cada token de refresco y de cada identificador de familia
+const (
+	bytesTokenRefresco = 32
+	bytesFamilia       = 16
+)
+
+// GenerarTokenRefresco crea un token de refresco opaco y devuelve también su hash,
+// que es lo único que se guarda en la base
+func GenerarTokenRefresco() (token string, hash string, err error) {
+	aleatorio := make([]byte, bytesTokenRefresco)
+	if _, err := rand.Read(aleatorio); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(aleatorio)
+	return token, HashTokenRefresco(token), nil
+}
+
+// HashTokenRefresco calcula el sha256 (en hexadecimal) con el que se busca el token en la base
+func HashTokenRefresco(token string) string {
+	suma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(suma[:])
+}
+
+// GenerarFamilia crea el identificador que comparten todos los tokens de refresco
+// que salen de un mismo login
+func GenerarFamilia() (string, error) {
+	aleatorio := make([]byte, bytesFamilia)
+	if _, err := rand.Read(aleatorio); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(aleatorio), nil
+}
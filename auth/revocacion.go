@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Bytes aleatorios del identificador (jti) de cada token de acceso
+const bytesJTI = 16
+
+// AlmacenRevocaciones es donde se consultan los tokens revocados. Lo implementa
+// repositorio.RevocacionRepositorio; se define acá para que auth no dependa del repositorio.
+type AlmacenRevocaciones interface {
+	TokenAccesoRevocado(jti string) (bool, error)
+	TokensAccesoRevocadosDesde(usuarioID uint) (time.Time, error)
+}
+
+// entradaCache guarda una respuesta del almacén hasta que vence
+type entradaCache[T any] struct {
+	valor   T
+	venceEn time.Time
+}
+
+// cacheRevocaciones evita ir a la base en cada pedido. Las revocaciones hechas en
+// esta instancia se ven al instante; las de otras instancias, como mucho después de ttl.
+type cacheRevocaciones struct {
+	mu             sync.Mutex
+	almacen        AlmacenRevocaciones
+	ttl            time.Duration
+	jtis           map[string]entradaCache[bool]
+	usuarios       map[uint]entradaCache[time.Time]
+	ultimaLimpieza time.Time
+}
+
+// revocaciones es nil hasta que se llama a ConfigurarRevocaciones; sin almacén no se revoca nada
+var revocaciones *cacheRevocaciones
+
+// ConfigurarRevocaciones indica dónde consultar los tokens revocados y cuánto tiempo
+// recordar cada respuesta. Se llama una vez al arrancar.
+func ConfigurarRevocaciones(almacen AlmacenRevocaciones, ttl time.Duration) {
+	revocaciones = &cacheRevocaciones{
+		almacen:  almacen,
+		ttl:      ttl,
+		jtis:     make(map[string]entradaCache[bool]),
+		usuarios: make(map[uint]entradaCache[time.Time]),
+	}
+}
+
+// NotificarTokenRevocado anota en el cache un jti que se acaba de revocar en el almacén
+func NotificarTokenRevocado(jti string, expiraEn time.Time) {
+	if revocaciones == nil {
+		return
+	}
+	revocaciones.mu.Lock()
+	defer revocaciones.mu.Unlock()
+	// Un token revocado no vuelve a valer: se recuerda hasta que expire
+	revocaciones.jtis[jti] = entradaCache[bool]{valor: true, venceEn: expiraEn}
+}
+
+// NotificarUsuarioRevocado anota en el cache que se revocaron todos los tokens del usuario
+func NotificarUsuarioRevocado(usuarioID uint, momento time.Time) {
+	if revocaciones == nil {
+		return
+	}
+	revocaciones.mu.Lock()
+	defer revocaciones.mu.Unlock()
+	revocaciones.usuarios[usuarioID] = entradaCache[time.Time]{valor: momento, venceEn: time.Now().Add(revocaciones.ttl)}
+}
+
+// tokenRevocado indica si los reclamos corresponden a un token revocado, ya sea por
+// su jti (logout) o porque se revocaron todos los tokens del usuario después de emitirlo
+func tokenRevocado(reclamos *Reclamos) (bool, error) {
+	if revocaciones == nil {
+		return false, nil
+	}
+	revocado, err := revocaciones.jtiRevocado(reclamos.ID)
+	if err != nil || revocado {
+		return revocado, err
+	}
+
+	desde, err := revocaciones.revocadoDesde(reclamos.Id)
+	if err != nil || desde.IsZero() {
+		return false, err
+	}
+	// iat tiene precisión de segundos: un token emitido en el mismo segundo que la
+	// revocación también queda revocado
+	return !reclamos.IssuedAt.Time.After(desde), nil
+}
+
+// jtiRevocado consulta el cache y, si no sabe la respuesta, el almacén
+func (c *cacheRevocaciones) jtiRevocado(jti string) (bool, error) {
+	ahora := time.Now()
+	c.mu.Lock()
+	entrada, existe := c.jtis[jti]
+	c.mu.Unlock()
+	if existe && ahora.Before(entrada.venceEn) {
+		return entrada.valor, nil
+	}
+
+	revocado, err := c.almacen.TokenAccesoRevocado(jti)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// No pisamos una revocación que se haya notificado mientras consultábamos el almacén
+	if actual, existe := c.jtis[jti]; !existe || !actual.valor || !ahora.Before(actual.venceEn) {
+		c.jtis[jti] = entradaCache[bool]{valor: revocado, venceEn: ahora.Add(c.ttl)}
+	}
+	c.limpiar(ahora)
+	return revocado, nil
+}
+
+// revocadoDesde consulta el cache y, si no sabe la respuesta, el almacén
+func (c *cacheRevocaciones) revocadoDesde(usuarioID uint) (time.Time, error) {
+	ahora := time.Now()
+	c.mu.Lock()
+	entrada, existe := c.usuarios[usuarioID]
+	c.mu.Unlock()
+	if existe && ahora.Before(entrada.venceEn) {
+		return entrada.valor, nil
+	}
+
+	desde, err := c.almacen.TokensAccesoRevocadosDesde(usuarioID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usuarios[usuarioID] = entradaCache[time.Time]{valor: desde, venceEn: ahora.Add(c.ttl)}
+	c.limpiar(ahora)
+	return desde, nil
+}
+
+// limpiar borra las entradas vencidas, como mucho una vez por ttl. Se llama con el mutex tomado.
+func (c *cacheRevocaciones) limpiar(ahora time.Time) {
+	if ahora.Sub(c.ultimaLimpieza) < c.ttl {
+		return
+	}
+	c.ultimaLimpieza = ahora
+	for jti, entrada := range c.jtis {
+		if !ahora.Before(entrada.venceEn) {
+			delete(c.jtis, jti)
+		}
+	}
+	for id, entrada := range c.usuarios {
+		if !ahora.Before(entrada.venceEn) {
+			delete(c.usuarios, id)
+		}
+	}
+}
+
+// generarJTI crea el identificador único de un token de acceso
+func generarJTI() (string, error) {
+	aleatorio := make([]byte, bytesJTI)
+	if _, err := rand.Read(aleatorio); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(aleatorio), nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+)
+
+var (
+	claveSenuelo     []byte
+	claveSenueloOnce sync.Once
+)
+
+// Senuelo devuelve 32 bytes que dependen solo del valor y de la clave del servidor. Sirve
+// para responder a un usuario que no existe con datos que parecen reales (por ejemplo, el
+// ID de una llave de acceso) y que no cambian de un pedido a otro.
+func Senuelo(valor string) []byte {
+	claveSenueloOnce.Do(func() {
+		// Con la clave de HS256 todas las instancias dan el mismo señuelo; sin ella, uno por proceso
+		if len(claveJWT) > 0 {
+			claveSenuelo = claveJWT
+			return
+		}
+		claveSenuelo = make([]byte, 32)
+		if _, err := rand.Read(claveSenuelo); err != nil {
+			panic(err)
+		}
+	})
+	mac := hmac.New(sha256.New, claveSenuelo)
+	mac.Write([]byte("senuelo:" + valor))
+	return mac.Sum(nil)
+}
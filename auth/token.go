@@ -2,58 +2,185 @@ package auth
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"taller6/modelos"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var claveJWT = []byte("irso2024") // Clave secreta para firmar los tokens
-const adminID = 1                 // Asumimos que el ID del admin es 1
+// ErrTokenRevocado se devuelve al validar un token que se revocó antes de expirar
+var ErrTokenRevocado = errors.New("ValidarToken: token revocado")
+
+// ErrTokenMFAPendiente se devuelve al usar como token de acceso uno que solo sirve
+// para completar el login con el segundo factor
+var ErrTokenMFAPendiente = errors.New("ValidarToken: falta el segundo factor")
+
+// ErrTokenDeCliente se devuelve al usar en la API un token que /token le entregó a un
+// cliente OIDC; esos tokens solo sirven para /userinfo
+var ErrTokenDeCliente = errors.New("ValidarToken: token de un cliente OIDC")
+
+// Tiempo que tiene el usuario para ingresar el código del segundo factor después de la contraseña
+const duracionTokenMFA = 5 * time.Minute
+
+var claveJWT []byte                  // Clave secreta para firmar los tokens con HS256
+var duracionToken = 15 * time.Minute // Tiempo de vida de los tokens de acceso
+
+// Configurar establece la clave secreta y la duración de los tokens de acceso. Se llama una vez al arrancar.
+func Configurar(clave string, duracion time.Duration) {
+	claveJWT = []byte(clave)
+	duracionToken = duracion
+}
 
 // Reclamos define lo que contendrá el token
 type Reclamos struct {
-	Id uint `json:"Id"`
+	Id       uint     `json:"Id"`
+	Roles    []string `json:"roles,omitempty"`
+	Permisos []string `json:"permisos,omitempty"`
+	// MFAPendiente marca el token que entrega Login cuando falta el segundo factor;
+	// no sirve como token de acceso, solo para POST /login/2fa
+	MFAPendiente bool `json:"mfa_pendiente,omitempty"`
+	// Scope marca el token que /token entrega a un cliente OIDC, con los scopes que se le
+	// otorgaron; no lleva roles ni permisos y solo sirve para /userinfo
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerarToken crea un token para el usuario
-func GenerarToken(id_usuario uint) (string, error) {
+// TienePermiso indica si el token incluye el permiso
+func (r *Reclamos) TienePermiso(permiso string) bool {
+	for _, p := range r.Permisos {
+		if p == permiso {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerarToken crea un token para el usuario con sus roles y los permisos que le otorgan
+func GenerarToken(id_usuario uint, roles []modelos.Rol) (string, error) {
 	// Definimos los reclamos del token (información que contendrá)
+	nombres, permisos := modelos.NombresYPermisos(roles)
 	reclamos := Reclamos{
-		Id: id_usuario,
+		Id:       id_usuario,
+		Roles:    nombres,
+		Permisos: permisos,
 	}
 
-	// Si el usuario no es "admin", establecer tiempo de expiración
-	if id_usuario != adminID {
-		reclamos.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)) // El token expira en 1 semana
+	// El jti identifica al token para poder revocarlo (logout) antes de que expire
+	jti, err := generarJTI()
+	if err != nil {
+		return "", err
 	}
+	ahora := time.Now()
+	reclamos.ID = jti
+	reclamos.IssuedAt = jwt.NewNumericDate(ahora)
+	// Todos los tokens expiran, incluidos los de administradores
+	reclamos.ExpiresAt = jwt.NewNumericDate(ahora.Add(duracionToken)) // El token expira según la configuración
 
-	// Creamos el token con el método de firma HS256
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, reclamos)
+	return firmar(reclamos)
+}
 
-	// Firmamos el token con la clave secreta
-	tokenFirmado, err := token.SignedString(claveJWT)
+// GenerarTokenMFA crea el token de corta duración que se cambia por los tokens de
+// acceso presentando un código válido del segundo factor
+func GenerarTokenMFA(id_usuario uint) (string, time.Time, error) {
+	jti, err := generarJTI()
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
+	}
+	ahora := time.Now()
+	expira := ahora.Add(duracionTokenMFA)
+	reclamos := Reclamos{Id: id_usuario, MFAPendiente: true}
+	reclamos.ID = jti
+	reclamos.IssuedAt = jwt.NewNumericDate(ahora)
+	reclamos.ExpiresAt = jwt.NewNumericDate(expira)
+	token, err := firmar(reclamos)
+	return token, expira, err
+}
+
+// firmar firma los reclamos con la clave activa del conjunto de claves (indicando su kid
+// en el encabezado) o, si no se configuró ninguno, con HS256 y la clave secreta
+func firmar(reclamos jwt.Claims) (string, error) {
+	if claves == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, reclamos).SignedString(claveJWT)
+	}
+	clave := claves.activa()
+	token := jwt.NewWithClaims(clave.metodo, reclamos)
+	token.Header["kid"] = clave.kid
+	return token.SignedString(clave.privada)
+}
+
+// claveDeValidacion elige la clave con la que se valida el token según su encabezado.
+// El algoritmo tiene que ser el de la clave: así no se acepta, por ejemplo, un token
+// HS256 firmado usando la clave pública como secreto.
+func claveDeValidacion(token *jwt.Token) (interface{}, error) {
+	if claves == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("algoritmo de firma inesperado: %v", token.Header["alg"])
+		}
+		return claveJWT, nil
 	}
 
-	return tokenFirmado, nil
+	kid, _ := token.Header["kid"].(string)
+	clave, ok := claves.buscar(kid)
+	if !ok {
+		return nil, fmt.Errorf("clave de firma desconocida: %q", kid)
+	}
+	if token.Method.Alg() != clave.metodo.Alg() {
+		return nil, fmt.Errorf("algoritmo de firma inesperado: %v", token.Header["alg"])
+	}
+	return clave.privada.Public(), nil
 }
 
-// ValidarToken verifica que el token es válido
+// ValidarToken verifica que el token es válido y es un token de acceso
 func ValidarToken(tokenString string) (*Reclamos, error) {
+	reclamos, err := validar(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if reclamos.MFAPendiente {
+		return nil, ErrTokenMFAPendiente
+	}
+	if reclamos.Scope != "" {
+		return nil, ErrTokenDeCliente
+	}
+	return reclamos, nil
+}
+
+// ValidarTokenMFA verifica un token de GenerarTokenMFA
+func ValidarTokenMFA(tokenString string) (*Reclamos, error) {
+	reclamos, err := validar(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if !reclamos.MFAPendiente {
+		return nil, errors.New("ValidarTokenMFA: no es un token de segundo factor")
+	}
+	return reclamos, nil
+}
+
+// validar comprueba la firma, los reclamos obligatorios y la revocación
+func validar(tokenString string) (*Reclamos, error) {
 	// Parseamos el token y lo validamos
-	token, err := jwt.ParseWithClaims(tokenString, &Reclamos{}, func(token *jwt.Token) (interface{}, error) {
-		return claveJWT, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Reclamos{}, claveDeValidacion)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Verificamos que el token sea válido y que los reclamos estén presentes
-	if reclamos, ok := token.Claims.(*Reclamos); ok && token.Valid {
+	// Verificamos que el token sea válido y que los reclamos estén presentes.
+	// Sin jti, iat y exp no se podría revocar, así que tampoco se acepta.
+	if reclamos, ok := token.Claims.(*Reclamos); ok && token.Valid &&
+		reclamos.ID != "" && reclamos.IssuedAt != nil && reclamos.ExpiresAt != nil {
+		// Consultamos si el token fue revocado (logout o revocación de todo el usuario)
+		revocado, err := tokenRevocado(reclamos)
+		if err != nil {
+			log.Println("ValidarToken: no se pudo consultar la revocación:", err)
+			return nil, err
+		}
+		if revocado {
+			return nil, ErrTokenRevocado
+		}
 		return reclamos, nil
 	}
 
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parámetros del TOTP: los que usan por defecto todas las aplicaciones autenticadoras
+const (
+	pasoTOTP         = 30 * time.Second
+	digitosTOTP      = 6
+	bytesSecretoTOTP = 20 // 160 bits, lo que recomienda RFC 4226 para HMAC-SHA1
+	// Pasos de tolerancia hacia cada lado, por la diferencia de hora con el teléfono
+	toleranciaTOTP = 1
+)
+
+// Los códigos de recuperación son 10 caracteres en base32 (50 bits) separados en dos grupos
+const (
+	CantidadCodigosRecuperacion = 10
+	bytesCodigoRecuperacion     = 7 // Se usan los primeros 10 caracteres de su base32
+)
+
+// codificacionTOTP es base32 sin relleno, como lo esperan las URIs otpauth://
+var codificacionTOTP = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerarSecretoTOTP crea un secreto aleatorio en base32
+func GenerarSecretoTOTP() (string, error) {
+	aleatorio := make([]byte, bytesSecretoTOTP)
+	if _, err := rand.Read(aleatorio); err != nil {
+		return "", err
+	}
+	return codificacionTOTP.EncodeToString(aleatorio), nil
+}
+
+// URITOTP arma la URI otpauth:// que las aplicaciones autenticadoras leen del código QR
+func URITOTP(emisor, cuenta, secreto string) string {
+	parametros := url.Values{}
+	parametros.Set("secret", secreto)
+	parametros.Set("issuer", emisor)
+	parametros.Set("algorithm", "SHA1")
+	parametros.Set("digits", fmt.Sprint(digitosTOTP))
+	parametros.Set("period", fmt.Sprint(int(pasoTOTP.Seconds())))
+	uri := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + emisor + ":" + cuenta,
+		// Algunas aplicaciones muestran el "+" tal cual, así que los espacios van como %20
+		RawQuery: strings.ReplaceAll(parametros.Encode(), "+", "%20"),
+	}
+	return uri.String()
+}
+
+// VerificarTOTP comprueba el código contra el secreto en el momento indicado, con la
+// tolerancia de un paso hacia cada lado. Devuelve el paso que coincidió, para que quien
+// llama pueda rechazar un código ya usado (RFC 6238, sección 5.2).
+func VerificarTOTP(secreto, codigo string, momento time.Time) (int64, bool) {
+	clave, err := codificacionTOTP.DecodeString(strings.ToUpper(secreto))
+	if err != nil || len(codigo) != digitosTOTP {
+		return 0, false
+	}
+	actual := momento.Unix() / int64(pasoTOTP.Seconds())
+	for paso := actual - toleranciaTOTP; paso <= actual+toleranciaTOTP; paso++ {
+		if subtle.ConstantTimeCompare([]byte(codigoTOTP(clave, paso)), []byte(codigo)) == 1 {
+			return paso, true
+		}
+	}
+	return 0, false
+}
+
+// codigoTOTP calcula el código de un paso (HOTP de RFC 4226 con el paso como contador)
+func codigoTOTP(clave []byte, paso int64) string {
+	contador := make([]byte, 8)
+	binary.BigEndian.PutUint64(contador, uint64(paso))
+	mac := hmac.New(sha1.New, clave)
+	mac.Write(contador)
+	suma := mac.Sum(nil)
+
+	desplazamiento := suma[len(suma)-1] & 0x0f
+	valor := binary.BigEndian.Uint32(suma[desplazamiento:desplazamiento+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digitosTOTP, valor%1_000_000)
+}
+
+// GenerarCodigosRecuperacion crea los códigos que se muestran una sola vez al usuario
+// y sus hashes, que es lo único que se guarda
+func GenerarCodigosRecuperacion() (codigos []string, hashes []string, err error) {
+	for i := 0; i < CantidadCodigosRecuperacion; i++ {
+		aleatorio := make([]byte, bytesCodigoRecuperacion)
+		if _, err := rand.Read(aleatorio); err != nil {
+			return nil, nil, err
+		}
+		texto := strings.ToLower(codificacionTOTP.EncodeToString(aleatorio)[:10])
+		codigo := texto[:5] + "-" + texto[5:]
+		codigos = append(codigos, codigo)
+		hashes = append(hashes, HashCodigoRecuperacion(codigo))
+	}
+	return codigos, hashes, nil
+}
+
+// HashCodigoRecuperacion calcula el hash de un código tal como lo escribe el usuario:
+// no importan las mayúsculas, los espacios ni el guion
+func HashCodigoRecuperacion(codigo string) string {
+	normalizado := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(codigo))
+	return HashSecreto(normalizado)
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// secretoRFC6238 es el secreto SHA-1 del apéndice B de RFC 6238 ("12345678901234567890") en base32
+const secretoRFC6238 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestVerificarTOTPVectoresRFC6238(t *testing.T) {
+	// Los códigos del RFC son de 8 dígitos; los de 6 son sus últimos 6
+	vectores := []struct {
+		segundos int64
+		codigo   string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, vector := range vectores {
+		momento := time.Unix(vector.segundos, 0)
+		paso, ok := VerificarTOTP(secretoRFC6238, vector.codigo, momento)
+		if !ok {
+			t.Errorf("en %d el código %s no se aceptó", vector.segundos, vector.codigo)
+			continue
+		}
+		if esperado := vector.segundos / 30; paso != esperado {
+			t.Errorf("en %d el código coincidió con el paso %d, se esperaba %d", vector.segundos, paso, esperado)
+		}
+		// El secreto se acepta también en minúsculas
+		if _, ok := VerificarTOTP("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", vector.codigo, momento); !ok {
+			t.Errorf("en %d el código no se aceptó con el secreto en minúsculas", vector.segundos)
+		}
+	}
+}
+
+func TestVerificarTOTPTolerancia(t *testing.T) {
+	const codigo = "081804" // Paso 37037036
+	emitido := time.Unix(1111111109, 0)
+
+	casos := []struct {
+		nombre string
+		desde  time.Duration
+		paso   int64 // 0 si no se tiene que aceptar
+	}{
+		{"el mismo paso", 0, 37037036},
+		{"un paso después", 30 * time.Second, 37037036},
+		{"un paso antes", -30 * time.Second, 37037036},
+		{"dos pasos después", 61 * time.Second, 0},
+		{"dos pasos antes", -60 * time.Second, 0},
+	}
+	for _, caso := range casos {
+		paso, ok := VerificarTOTP(secretoRFC6238, codigo, emitido.Add(caso.desde))
+		if ok != (caso.paso != 0) || paso != caso.paso {
+			t.Errorf("%s: se obtuvo el paso %d (%v), se esperaba %d", caso.nombre, paso, ok, caso.paso)
+		}
+	}
+}
+
+func TestVerificarTOTPRechazaFormatos(t *testing.T) {
+	momento := time.Unix(59, 0)
+	casos := []struct {
+		nombre, secreto, codigo string
+	}{
+		{"código corto", secretoRFC6238, "28708"},
+		{"código de 8 dígitos", secretoRFC6238, "94287082"},
+		{"código vacío", secretoRFC6238, ""},
+		{"secreto que no es base32", "no-es-base32!", "287082"},
+		{"otro secreto", "JBSWY3DPEHPK3PXP", "287082"},
+	}
+	for _, caso := range casos {
+		if _, ok := VerificarTOTP(caso.secreto, caso.codigo, momento); ok {
+			t.Errorf("%s: se aceptó", caso.nombre)
+		}
+	}
+}
+
+// TestVerificarTOTPPasoParaRepeticion verifica que el mismo código dé el mismo paso en
+// toda su ventana: con ese paso quien llama rechaza un código ya usado
+func TestVerificarTOTPPasoParaRepeticion(t *testing.T) {
+	momento := time.Unix(1234567890, 0)
+	primero, ok := VerificarTOTP(secretoRFC6238, "005924", momento)
+	if !ok {
+		t.Fatal("el código no se aceptó")
+	}
+	segundo, ok := VerificarTOTP(secretoRFC6238, "005924", momento.Add(25*time.Second))
+	if !ok || segundo != primero {
+		t.Errorf("repetido dentro de la tolerancia dio el paso %d (%v), se esperaba %d", segundo, ok, primero)
+	}
+}
+
+func TestGenerarSecretoTOTP(t *testing.T) {
+	secreto, err := GenerarSecretoTOTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20 bytes en base32 sin relleno son 32 caracteres
+	if !regexp.MustCompile(`^[A-Z2-7]{32}$`).MatchString(secreto) {
+		t.Errorf("el secreto %q no es base32 de 20 bytes", secreto)
+	}
+	otro, _ := GenerarSecretoTOTP()
+	if otro == secreto {
+		t.Error("dos secretos seguidos son iguales")
+	}
+}
+
+func TestURITOTP(t *testing.T) {
+	uri := URITOTP("Taller 6", "ana", secretoRFC6238)
+	esperada := "otpauth://totp/Taller%206:ana?algorithm=SHA1&digits=6&issuer=Taller%206&period=30&secret=" + secretoRFC6238
+	if uri != esperada {
+		t.Errorf("se obtuvo %s, se esperaba %s", uri, esperada)
+	}
+}
+
+func TestCodigosRecuperacion(t *testing.T) {
+	codigos, hashes, err := GenerarCodigosRecuperacion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codigos) != CantidadCodigosRecuperacion || len(hashes) != CantidadCodigosRecuperacion {
+		t.Fatalf("se generaron %d códigos y %d hashes, se esperaban %d", len(codigos), len(hashes), CantidadCodigosRecuperacion)
+	}
+	formato := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	vistos := map[string]bool{}
+	for i, codigo := range codigos {
+		if !formato.MatchString(codigo) {
+			t.Errorf("el código %q no tiene el formato xxxxx-xxxxx", codigo)
+		}
+		if vistos[codigo] {
+			t.Errorf("el código %q está repetido", codigo)
+		}
+		vistos[codigo] = true
+		if HashCodigoRecuperacion(codigo) != hashes[i] {
+			t.Errorf("el hash del código %q no es el que se devolvió", codigo)
+		}
+	}
+}
+
+func TestHashCodigoRecuperacionNormaliza(t *testing.T) {
+	esperado := HashCodigoRecuperacion("abcde-fghij")
+	for _, escrito := range []string{"abcdefghij", "ABCDE-FGHIJ", "abcde fghij", " AbCdE-fGhIj ", "ab-cde-fg hij"} {
+		if HashCodigoRecuperacion(escrito) != esperado {
+			t.Errorf("%q no se normalizó como abcde-fghij", escrito)
+		}
+	}
+	if HashCodigoRecuperacion("abcde-fghik") == esperado {
+		t.Error("dos códigos distintos dan el mismo hash")
+	}
+}
@@ -0,0 +1,238 @@
+// Package webauthntest tiene un autenticador WebAuthn por software para probar las
+// ceremonias de registro e inicio de sesión sin navegador ni llave física, al estilo
+// de repositorio/repositoriotest. Genera una clave ES256 por credencial y arma las
+// respuestas que devolverían navigator.credentials.create() y navigator.credentials.get(),
+// con atestación "none".
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// Flags de los datos del autenticador (WebAuthn §6.1)
+const (
+	flagPresencia       = 0x01 // UP: el usuario tocó la llave
+	flagVerificacion    = 0x04 // UV: el usuario ingresó el PIN o la huella
+	flagDatosCredencial = 0x40 // AT: hay una credencial nueva en los datos
+)
+
+// Autenticador guarda las credenciales que creó. No es seguro para uso concurrente.
+type Autenticador struct {
+	Origen          string // Origen que informa el "navegador" en clientDataJSON, como https://ejemplo.com
+	VerificaUsuario bool   // Marca el flag UV, como una llave con PIN o un teléfono con huella
+	SinContador     bool   // Deja el contador de firmas en cero, como muchas passkeys sincronizadas
+
+	credenciales []*credencial
+}
+
+// credencial es una clave creada para un sitio y un usuario
+type credencial struct {
+	id       []byte
+	clave    *ecdsa.PrivateKey
+	rpID     string
+	usuario  []byte
+	contador uint32
+}
+
+// Nuevo crea un autenticador sin credenciales que verifica al usuario
+func Nuevo(origen string) *Autenticador {
+	return &Autenticador{Origen: origen, VerificaUsuario: true}
+}
+
+// opcionesRegistro son los campos de PublicKeyCredentialCreationOptions que se usan
+type opcionesRegistro struct {
+	Challenge protocol.URLEncodedBase64 `json:"challenge"`
+	RP        struct {
+		ID string `json:"id"`
+	} `json:"rp"`
+	User struct {
+		ID protocol.URLEncodedBase64 `json:"id"`
+	} `json:"user"`
+	ExcludeCredentials []struct {
+		ID protocol.URLEncodedBase64 `json:"id"`
+	} `json:"excludeCredentials"`
+}
+
+// opcionesLogin son los campos de PublicKeyCredentialRequestOptions que se usan
+type opcionesLogin struct {
+	Challenge        protocol.URLEncodedBase64 `json:"challenge"`
+	RPID             string                    `json:"rpId"`
+	AllowCredentials []struct {
+		ID protocol.URLEncodedBase64 `json:"id"`
+	} `json:"allowCredentials"`
+}
+
+// Registrar recibe las opciones de creación (el objeto publicKey) y devuelve la
+// credencial nueva tal como la enviaría el navegador
+func (a *Autenticador) Registrar(opcionesJSON []byte) (json.RawMessage, error) {
+	var opciones opcionesRegistro
+	if err := json.Unmarshal(opcionesJSON, &opciones); err != nil {
+		return nil, err
+	}
+	// Igual que un autenticador real: no crea otra credencial para un sitio en el que ya tiene una excluida
+	for _, excluida := range opciones.ExcludeCredentials {
+		if a.buscar(excluida.ID) != nil {
+			return nil, errors.New("webauthntest: la credencial ya está registrada (InvalidStateError)")
+		}
+	}
+
+	clave, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	nueva := &credencial{id: make([]byte, 16), clave: clave, rpID: opciones.RP.ID, usuario: opciones.User.ID}
+	if _, err := rand.Read(nueva.id); err != nil {
+		return nil, err
+	}
+	a.credenciales = append(a.credenciales, nueva)
+
+	clavePublica, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         1, // P-256
+		XCoord:        clave.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord:        clave.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Datos de la credencial: AAGUID (todo en cero, sin atestación), largo del ID, ID y clave pública
+	datos := a.datosAutenticador(nueva, flagDatosCredencial)
+	datos = append(datos, make([]byte, 16)...)
+	datos = binary.BigEndian.AppendUint16(datos, uint16(len(nueva.id)))
+	datos = append(datos, nueva.id...)
+	datos = append(datos, clavePublica...)
+
+	atestacion, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": datos,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cliente, err := a.datosCliente(protocol.CreateCeremony, opciones.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    protocol.URLEncodedBase64(nueva.id),
+		"rawId": protocol.URLEncodedBase64(nueva.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    protocol.URLEncodedBase64(cliente),
+			"attestationObject": protocol.URLEncodedBase64(atestacion),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// Firmar recibe las opciones de inicio de sesión (el objeto publicKey) y devuelve la
+// aserción firmada con la primera credencial permitida que tenga para ese sitio
+func (a *Autenticador) Firmar(opcionesJSON []byte) (json.RawMessage, error) {
+	var opciones opcionesLogin
+	if err := json.Unmarshal(opcionesJSON, &opciones); err != nil {
+		return nil, err
+	}
+	var elegida *credencial
+	for _, c := range a.credenciales {
+		if c.rpID != opciones.RPID {
+			continue
+		}
+		if len(opciones.AllowCredentials) == 0 {
+			elegida = c
+			break
+		}
+		for _, permitida := range opciones.AllowCredentials {
+			if bytes.Equal(permitida.ID, c.id) {
+				elegida = c
+				break
+			}
+		}
+		if elegida != nil {
+			break
+		}
+	}
+	if elegida == nil {
+		return nil, fmt.Errorf("webauthntest: no hay credenciales para %q (NotAllowedError)", opciones.RPID)
+	}
+
+	if !a.SinContador {
+		elegida.contador++
+	}
+	datos := a.datosAutenticador(elegida, 0)
+	cliente, err := a.datosCliente(protocol.AssertCeremony, opciones.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	// La firma cubre los datos del autenticador y el hash de clientDataJSON
+	hashCliente := sha256.Sum256(cliente)
+	resumen := sha256.Sum256(append(append([]byte(nil), datos...), hashCliente[:]...))
+	firma, err := ecdsa.SignASN1(rand.Reader, elegida.clave, resumen[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    protocol.URLEncodedBase64(elegida.id),
+		"rawId": protocol.URLEncodedBase64(elegida.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    protocol.URLEncodedBase64(cliente),
+			"authenticatorData": protocol.URLEncodedBase64(datos),
+			"signature":         protocol.URLEncodedBase64(firma),
+			"userHandle":        protocol.URLEncodedBase64(elegida.usuario),
+		},
+	})
+}
+
+// RetrocederContador simula una llave clonada: la próxima firma usa un contador que ya se vio
+func (a *Autenticador) RetrocederContador() {
+	for _, c := range a.credenciales {
+		if c.contador > 0 {
+			c.contador--
+		}
+	}
+}
+
+// buscar devuelve la credencial con ese ID o nil
+func (a *Autenticador) buscar(id []byte) *credencial {
+	for _, c := range a.credenciales {
+		if bytes.Equal(c.id, id) {
+			return c
+		}
+	}
+	return nil
+}
+
+// datosAutenticador arma el hash del RP ID, los flags y el contador
+func (a *Autenticador) datosAutenticador(c *credencial, flags byte) []byte {
+	flags |= flagPresencia
+	if a.VerificaUsuario {
+		flags |= flagVerificacion
+	}
+	hashRP := sha256.Sum256([]byte(c.rpID))
+	datos := append(hashRP[:], flags)
+	return binary.BigEndian.AppendUint32(datos, c.contador)
+}
+
+// datosCliente arma el clientDataJSON que firmaría el navegador
+func (a *Autenticador) datosCliente(tipo protocol.CeremonyType, desafio []byte) ([]byte, error) {
+	return json.Marshal(protocol.CollectedClientData{
+		Type:      tipo,
+		Challenge: protocol.URLEncodedBase64(desafio).String(),
+		Origin:    a.Origen,
+	})
+}
@@ -0,0 +1,123 @@
+// Package busqueda busca texto sin importar mayúsculas ni tildes: normaliza las
+// consultas en términos, guarda un índice de trigramas en memoria para los backends que
+// no tienen búsqueda de texto propia y resalta en el texto original los fragmentos que
+// coinciden. El índice es de un solo proceso; los repositorios lo mantienen al día con
+// sus propias escrituras.
+package busqueda
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// LargoMinimoTermino es lo más corto que se busca; una sola letra aparece en casi todo
+// y el índice FULLTEXT de MySQL (ngram) tampoco la indexa
+const LargoMinimoTermino = 2
+
+// Marcas con las que Resaltar rodea los fragmentos que coinciden
+const (
+	InicioResaltado = "<mark>"
+	FinResaltado    = "</mark>"
+)
+
+// plegar pasa un caracter a minúsculas y le saca las tildes y diéresis ("Á" → "a",
+// "ñ" → "n"). Puede devolver más de un caracter o ninguno.
+func plegar(caracter rune) []rune {
+	var plegado []rune
+	for _, parte := range norm.NFD.String(string(caracter)) {
+		if unicode.Is(unicode.Mn, parte) {
+			continue
+		}
+		plegado = append(plegado, unicode.ToLower(parte))
+	}
+	return plegado
+}
+
+// Normalizar devuelve el texto en minúsculas y sin tildes
+func Normalizar(texto string) string {
+	var normalizado strings.Builder
+	for _, caracter := range texto {
+		for _, plegado := range plegar(caracter) {
+			normalizado.WriteRune(plegado)
+		}
+	}
+	return normalizado.String()
+}
+
+// esSeparador indica si el caracter separa palabras: todo lo que no es letra ni dígito,
+// así "ana.perez@correo.com" son las palabras "ana", "perez", "correo" y "com"
+func esSeparador(caracter rune) bool {
+	return !unicode.IsLetter(caracter) && !unicode.IsDigit(caracter)
+}
+
+// Terminos separa la consulta en palabras normalizadas, sin repetir y descartando las
+// de menos de LargoMinimoTermino caracteres. Un resultado tiene que coincidir con todas.
+func Terminos(consulta string) []string {
+	var terminos []string
+	vistos := make(map[string]bool)
+	for _, palabra := range strings.FieldsFunc(Normalizar(consulta), esSeparador) {
+		if len([]rune(palabra)) < LargoMinimoTermino || vistos[palabra] {
+			continue
+		}
+		vistos[palabra] = true
+		terminos = append(terminos, palabra)
+	}
+	return terminos
+}
+
+// Resaltar devuelve el texto con cada aparición de los términos entre InicioResaltado y
+// FinResaltado, comparando sin mayúsculas ni tildes pero respetando el texto original.
+// El resto se escapa como HTML para que se pueda mostrar tal cual. Devuelve false si no
+// apareció ningún término (la coincidencia fue aproximada).
+func Resaltar(texto string, terminos []string) (string, bool) {
+	originales := []rune(texto)
+	// Cada caracter normalizado recuerda de qué caracter original salió
+	var normalizado []rune
+	var origen []int
+	for i, caracter := range originales {
+		for _, plegado := range plegar(caracter) {
+			normalizado = append(normalizado, plegado)
+			origen = append(origen, i)
+		}
+	}
+
+	marcado := make([]bool, len(originales))
+	alguno := false
+	for _, termino := range terminos {
+		buscado := []rune(termino)
+		for inicio := 0; inicio+len(buscado) <= len(normalizado); inicio++ {
+			if !coincideDesde(normalizado, buscado, inicio) {
+				continue
+			}
+			for j := inicio; j < inicio+len(buscado); j++ {
+				marcado[origen[j]] = true
+			}
+			alguno = true
+		}
+	}
+
+	var resaltado strings.Builder
+	for i, caracter := range originales {
+		if marcado[i] && (i == 0 || !marcado[i-1]) {
+			resaltado.WriteString(InicioResaltado)
+		}
+		resaltado.WriteString(html.EscapeString(string(caracter)))
+		if marcado[i] && (i == len(originales)-1 || !marcado[i+1]) {
+			resaltado.WriteString(FinResaltado)
+		}
+	}
+	return resaltado.String(), alguno
+}
+
+// coincideDesde indica si el término aparece en el texto a partir de la posición
+func coincideDesde(texto, termino []rune, inicio int) bool {
+	for j, caracter := range termino {
+		if texto[inicio+j] != caracter {
+			return false
+		}
+	}
+	return true
+}
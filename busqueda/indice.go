@@ -0,0 +1,162 @@
+package busqueda
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UmbralSimilitud es la parte de los trigramas de un término que tiene que aparecer en
+// un campo para que cuente como coincidencia. Con 0,5 "ana" encuentra "mariana" y
+// "perz" encuentra "perez", pero "gmial" no encuentra "gmail".
+const UmbralSimilitud = 0.5
+
+// Resultado es un documento encontrado con su puntaje, entre UmbralSimilitud y 1
+type Resultado struct {
+	ID      uint
+	Puntaje float64
+}
+
+// conjunto es un conjunto de trigramas
+type conjunto map[string]struct{}
+
+// Indice es un índice invertido de trigramas. Cada documento tiene varios campos (por
+// ejemplo el nombre de usuario y el correo) y se busca en todos a la vez. Se puede
+// usar desde varias goroutines.
+type Indice struct {
+	mu         sync.RWMutex
+	documentos map[uint][]conjunto          // ID -> trigramas de cada campo
+	trigramas  map[string]map[uint]struct{} // Trigrama -> IDs de los documentos que lo tienen
+}
+
+// NuevoIndice crea un índice vacío
+func NuevoIndice() *Indice {
+	return &Indice{
+		documentos: make(map[uint][]conjunto),
+		trigramas:  make(map[string]map[uint]struct{}),
+	}
+}
+
+// trigramasDe arma los trigramas de cada palabra del texto normalizado, con dos espacios
+// antes y uno después como pg_trgm: así las coincidencias al principio de una palabra
+// suman más que las del medio
+func trigramasDe(texto string) conjunto {
+	trigramas := make(conjunto)
+	for _, palabra := range strings.FieldsFunc(texto, esSeparador) {
+		caracteres := []rune("  " + palabra + " ")
+		for i := 0; i+3 <= len(caracteres); i++ {
+			trigramas[string(caracteres[i:i+3])] = struct{}{}
+		}
+	}
+	return trigramas
+}
+
+// Agregar indexa el documento con esos campos; si ya estaba, lo reemplaza
+func (i *Indice) Agregar(id uint, campos ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.quitar(id)
+	documento := make([]conjunto, len(campos))
+	for n, campo := range campos {
+		documento[n] = trigramasDe(Normalizar(campo))
+		for trigrama := range documento[n] {
+			if i.trigramas[trigrama] == nil {
+				i.trigramas[trigrama] = make(map[uint]struct{})
+			}
+			i.trigramas[trigrama][id] = struct{}{}
+		}
+	}
+	i.documentos[id] = documento
+}
+
+// Quitar saca el documento del índice
+func (i *Indice) Quitar(id uint) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.quitar(id)
+}
+
+// quitar es Quitar con el lock ya tomado
+func (i *Indice) quitar(id uint) {
+	for _, campo := range i.documentos[id] {
+		for trigrama := range campo {
+			delete(i.trigramas[trigrama], id)
+			if len(i.trigramas[trigrama]) == 0 {
+				delete(i.trigramas, trigrama)
+			}
+		}
+	}
+	delete(i.documentos, id)
+}
+
+// Buscar devuelve los documentos que coinciden con todos los términos (ya normalizados,
+// ver Terminos), del mayor puntaje al menor y, si empatan, por ID. El puntaje de un
+// término es la parte de sus trigramas que tiene el campo que más se le parece, y el del
+// documento es el promedio de los términos. Con limite <= 0 devuelve todos.
+func (i *Indice) Buscar(terminos []string, limite int) []Resultado {
+	if len(terminos) == 0 {
+		return nil
+	}
+	buscados := make([]conjunto, len(terminos))
+	for n, termino := range terminos {
+		buscados[n] = trigramasDe(termino)
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	// Candidatos: los documentos que comparten algún trigrama con el primer término,
+	// porque un resultado tiene que coincidir con todos
+	candidatos := make(map[uint]struct{})
+	for trigrama := range buscados[0] {
+		for id := range i.trigramas[trigrama] {
+			candidatos[id] = struct{}{}
+		}
+	}
+
+	var resultados []Resultado
+	for id := range candidatos {
+		total := 0.0
+		coincide := true
+		for _, buscado := range buscados {
+			mejor := 0.0
+			for _, campo := range i.documentos[id] {
+				mejor = max(mejor, similitud(buscado, campo))
+			}
+			if mejor < UmbralSimilitud {
+				coincide = false
+				break
+			}
+			total += mejor
+		}
+		if coincide {
+			resultados = append(resultados, Resultado{ID: id, Puntaje: total / float64(len(buscados))})
+		}
+	}
+
+	sort.Slice(resultados, func(a, b int) bool {
+		if resultados[a].Puntaje != resultados[b].Puntaje {
+			return resultados[a].Puntaje > resultados[b].Puntaje
+		}
+		return resultados[a].ID < resultados[b].ID
+	})
+	if limite > 0 && len(resultados) > limite {
+		resultados = resultados[:limite]
+	}
+	return resultados
+}
+
+// similitud es la parte de los trigramas buscados que aparecen en el campo
+func similitud(buscado, campo conjunto) float64 {
+	if len(buscado) == 0 {
+		return 0
+	}
+	comunes := 0
+	for trigrama := range buscado {
+		if _, existe := campo[trigrama]; existe {
+			comunes++
+		}
+	}
+	return float64(comunes) / float64(len(buscado))
+}
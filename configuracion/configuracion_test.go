@@ -0,0 +1,288 @@
+package configuracion
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"taller6/auth"
+	"taller6/limite"
+	"testing"
+	"time"
+)
+
+// limpiarEntorno borra las variables TALLER6_* del proceso durante la prueba y define las indicadas
+func limpiarEntorno(t *testing.T, variables map[string]string) {
+	t.Helper()
+	for _, variable := range os.Environ() {
+		nombre, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(nombre, prefijoEntorno) {
+			t.Setenv(nombre, "") // Para que se restaure al terminar
+			os.Unsetenv(nombre)
+		}
+	}
+	for nombre, valor := range variables {
+		t.Setenv(prefijoEntorno+nombre, valor)
+	}
+}
+
+// escribirConfiguracion guarda el contenido en un archivo temporal con ese nombre
+func escribirConfiguracion(t *testing.T, nombre, contenido string) string {
+	t.Helper()
+	ruta := filepath.Join(t.TempDir(), nombre)
+	if err := os.WriteFile(ruta, []byte(contenido), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ruta
+}
+
+// Los mismos valores en YAML y en TOML
+const (
+	configuracionYAML = `
+almacenamiento: memoria
+contrasena_admin: del-archivo
+direccion: "127.0.0.1:9000"
+duracion_token: 5m
+max_intentos_login: 7
+requiere_correo_verificado: true
+limite_api: 100/1m
+origenes_cors: ["https://a.ejemplo.com", "https://b.ejemplo.com"]
+`
+	configuracionTOML = `
+almacenamiento = "memoria"
+contrasena_admin = "del-archivo"
+direccion = "127.0.0.1:9000"
+duracion_token = "5m"
+max_intentos_login = 7
+requiere_correo_verificado = true
+limite_api = "100/1m"
+origenes_cors = ["https://a.ejemplo.com", "https://b.ejemplo.com"]
+`
+)
+
+func TestCargarArchivo(t *testing.T) {
+	casos := []struct {
+		nombre, contenido string
+	}{
+		{"config.yaml", configuracionYAML},
+		{"config.yml", configuracionYAML},
+		{"config.toml", configuracionTOML},
+		{"CONFIG.TOML", configuracionTOML},
+	}
+	for _, caso := range casos {
+		limpiarEntorno(t, nil)
+		config, err := Cargar(escribirConfiguracion(t, caso.nombre, caso.contenido))
+		if err != nil {
+			t.Errorf("%s: %v", caso.nombre, err)
+			continue
+		}
+
+		esperada := PorDefecto()
+		esperada.Almacenamiento = "memoria"
+		esperada.ContrasenaAdmin = "del-archivo"
+		esperada.Direccion = "127.0.0.1:9000"
+		esperada.DuracionToken = 5 * time.Minute
+		esperada.MaxIntentosLogin = 7
+		esperada.RequiereCorreoVerificado = true
+		esperada.LimiteAPI = limite.Tasa{Cantidad: 100, Periodo: time.Minute}
+		esperada.OrigenesCORS = []string{"https://a.ejemplo.com", "https://b.ejemplo.com"}
+		if !reflect.DeepEqual(*config, esperada) {
+			t.Errorf("%s: se cargó\n%+v\nse esperaba\n%+v", caso.nombre, *config, esperada)
+		}
+	}
+}
+
+func TestCargarArchivoInvalido(t *testing.T) {
+	casos := []struct {
+		nombre, contenido string
+		mensaje           string // Parte del error esperado
+	}{
+		{"config.json", `{}`, "formato de configuración no soportado"},
+		{"config.yaml", "direccion: [sin cerrar", "inválido"},
+		{"config.toml", "direccion = ", "inválido"},
+		{"config.yaml", "max_intentos_login: muchos", "inválido"},
+		{"config.yaml", "duracion_token: quince", "duracion_token inválida"},
+		{"config.toml", `rotacion_claves = "1 mes"`, "rotacion_claves inválida"},
+		{"config.yaml", "limite_login: 30", "limite_login inválido"},
+	}
+	for _, caso := range casos {
+		limpiarEntorno(t, map[string]string{"ALMACENAMIENTO": "memoria", "CONTRASENA_ADMIN": "secreta"})
+		_, err := Cargar(escribirConfiguracion(t, caso.nombre, caso.contenido))
+		if err == nil || !strings.Contains(err.Error(), caso.mensaje) {
+			t.Errorf("%s %q: error %v, se esperaba uno con %q", caso.nombre, caso.contenido, err, caso.mensaje)
+		}
+	}
+
+	limpiarEntorno(t, nil)
+	if _, err := Cargar(filepath.Join(t.TempDir(), "no-existe.yaml")); err == nil {
+		t.Error("un archivo que no existe no dio error")
+	}
+}
+
+// TestCargarPrioridad verifica el orden: valores por defecto, archivo y entorno
+func TestCargarPrioridad(t *testing.T) {
+	ruta := escribirConfiguracion(t, "config.yaml", configuracionYAML)
+	limpiarEntorno(t, map[string]string{
+		"DIRECCION":                  "0.0.0.0:7000",
+		"DURACION_TOKEN":             "2m",
+		"MAX_INTENTOS_LOGIN":         "0",
+		"REQUIERE_CORREO_VERIFICADO": "false",
+		"LIMITE_API":                 "0",
+		"ORIGENES_CORS":              " https://c.ejemplo.com, ,https://d.ejemplo.com ",
+	})
+	config, err := Cargar(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nombre           string
+		obtenido, espera any
+	}{
+		// El entorno pisa al archivo, aunque sea con un valor vacío o cero
+		{"direccion", config.Direccion, "0.0.0.0:7000"},
+		{"duracion_token", config.DuracionToken, 2 * time.Minute},
+		{"max_intentos_login", config.MaxIntentosLogin, 0},
+		{"requiere_correo_verificado", config.RequiereCorreoVerificado, false},
+		{"limite_api", config.LimiteAPI, limite.Tasa{}},
+		{"origenes_cors", config.OrigenesCORS, []string{"https://c.ejemplo.com", "https://d.ejemplo.com"}},
+		// El archivo pisa a los valores por defecto
+		{"contrasena_admin", config.ContrasenaAdmin, "del-archivo"},
+		{"almacenamiento", config.Almacenamiento, "memoria"},
+		// Lo que no está en ninguno queda por defecto
+		{"duracion_refresco", config.DuracionRefresco, PorDefecto().DuracionRefresco},
+		{"limite_login", config.LimiteLogin, PorDefecto().LimiteLogin},
+	}
+	for _, caso := range casos {
+		if !reflect.DeepEqual(caso.obtenido, caso.espera) {
+			t.Errorf("%s es %v, se esperaba %v", caso.nombre, caso.obtenido, caso.espera)
+		}
+	}
+}
+
+func TestCargarEntornoInvalido(t *testing.T) {
+	casos := map[string]string{
+		"DURACION_TOKEN":             "quince",
+		"DURACION_BLOQUEO_LOGIN":     "-",
+		"LIMITE_REGISTRO":            "5",
+		"COSTO_BCRYPT":               "alto",
+		"MAX_INTENTOS_LOGIN":         "5.5",
+		"MIGRAR_AL_INICIAR":          "quizás",
+		"REQUIERE_CORREO_VERIFICADO": "si",
+	}
+	for nombre, valor := range casos {
+		limpiarEntorno(t, map[string]string{"ALMACENAMIENTO": "memoria", "CONTRASENA_ADMIN": "secreta", nombre: valor})
+		_, err := Cargar("")
+		if err == nil || !strings.Contains(err.Error(), prefijoEntorno+nombre) {
+			t.Errorf("%s=%q: error %v, se esperaba uno que nombre la variable", nombre, valor, err)
+		}
+	}
+}
+
+// errores separa los errores que junta Validar
+func errores(err error) []error {
+	var junto interface{ Unwrap() []error }
+	if !errors.As(err, &junto) {
+		return []error{err}
+	}
+	return junto.Unwrap()
+}
+
+func TestValidarJuntaTodosLosErrores(t *testing.T) {
+	limpiarEntorno(t, nil)
+	config := PorDefecto()
+	config.Almacenamiento = "sql"             // Sin DSN
+	config.AlgoritmoJWT = auth.AlgoritmoHS256 // Sin clave_jwt
+	config.DuracionToken = 0
+	config.AlmacenLimites = "redis" // Sin REDIS_URL
+	config.ClaveLimitePublico = "usuario"
+	config.CostoBcrypt = 99
+	config.ContrasenaClasesMinimas = 5
+
+	err := config.Validar()
+	if err == nil {
+		t.Fatal("la configuración inválida pasó la validación")
+	}
+	mensajes := []string{
+		"TALLER6_DSN",
+		"TALLER6_CLAVE_JWT",
+		"TALLER6_CONTRASENA_ADMIN",
+		"la duración del token debe ser mayor a cero",
+		"TALLER6_REDIS_URL",
+		"clave de límite público inválida",
+		"el costo de bcrypt",
+		"las clases mínimas",
+	}
+	for _, mensaje := range mensajes {
+		if !strings.Contains(err.Error(), mensaje) {
+			t.Errorf("falta el error %q en:\n%v", mensaje, err)
+		}
+	}
+	if cantidad := len(errores(err)); cantidad != len(mensajes) {
+		t.Errorf("se juntaron %d errores, se esperaban %d:\n%v", cantidad, len(mensajes), err)
+	}
+}
+
+func TestValidar(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		modificar func(c *Configuracion)
+		mensaje   string // Vacío si tiene que ser válida
+	}{
+		{"por defecto con los secretos", func(c *Configuracion) {}, ""},
+		{"HS256 con clave", func(c *Configuracion) { c.AlgoritmoJWT, c.ClaveJWT = auth.AlgoritmoHS256, strings.Repeat("k", 16) }, ""},
+		{"HS256 con clave corta", func(c *Configuracion) { c.AlgoritmoJWT, c.ClaveJWT = auth.AlgoritmoHS256, "corta" }, "al menos 16 caracteres"},
+		{"algoritmo desconocido", func(c *Configuracion) { c.AlgoritmoJWT = "ES256" }, "algoritmo JWT desconocido"},
+		{"rotación más corta que el token", func(c *Configuracion) { c.RotacionClaves = c.DuracionToken }, "la rotación de claves"},
+		{"refresco más corto que el token", func(c *Configuracion) { c.DuracionRefresco = time.Minute }, "token de refresco"},
+		{"DSN de SQLite", func(c *Configuracion) { c.Almacenamiento, c.DSN = "sql", "sqlite://taller6.db" }, ""},
+		{"DSN con un esquema desconocido", func(c *Configuracion) { c.Almacenamiento, c.DSN = "sql", "oracle://base" }, "esquema de dsn no soportado"},
+		{"emisor OIDC con / final", func(c *Configuracion) { c.EmisorOIDC = "https://auth.ejemplo.com/" }, "emisor OIDC inválido"},
+		{"emisor OIDC válido", func(c *Configuracion) { c.EmisorOIDC = "https://auth.ejemplo.com" }, ""},
+		{"emisor TOTP con dos puntos", func(c *Configuracion) { c.EmisorTOTP = "a:b" }, "emisor TOTP inválido"},
+		{"origen WebAuthn de otro dominio", func(c *Configuracion) {
+			c.DominioWebAuthn, c.OrigenesWebAuthn = "ejemplo.com", []string{"https://otro.com"}
+		}, "origen WebAuthn inválido"},
+		{"origen WebAuthn de un subdominio", func(c *Configuracion) {
+			c.DominioWebAuthn, c.OrigenesWebAuthn = "ejemplo.com", []string{"https://app.ejemplo.com"}
+		}, ""},
+		{"proxy inválido", func(c *Configuracion) { c.ProxiesConfiables = []string{"10.0.0.0/33"} }, "proxy confiable inválido"},
+		{"proxy por rango", func(c *Configuracion) { c.ProxiesConfiables = []string{"10.0.0.0/8", "::1"} }, ""},
+		{"clave_api sin claves", func(c *Configuracion) { c.ClaveLimitePublico = "clave_api" }, "TALLER6_CLAVES_API"},
+		{"clave_api en las rutas con token sin claves", func(c *Configuracion) { c.ClaveLimiteAPI = "clave_api" }, "TALLER6_CLAVES_API"},
+		{"clave_api con claves", func(c *Configuracion) {
+			c.ClaveLimitePublico, c.ClavesAPI = "clave_api", []string{"clave-de-api"}
+		}, ""},
+		{"clave de límite desconocida", func(c *Configuracion) { c.ClaveLimiteAPI = "correo" }, "clave de límite inválida"},
+		{"bloqueo sin duración", func(c *Configuracion) { c.DuracionBloqueoLogin = 0 }, "duración del bloqueo"},
+		{"sin bloqueo ni duración", func(c *Configuracion) {
+			c.MaxIntentosLogin, c.MaxIntentosLoginIP, c.DuracionBloqueoLogin = 0, 0, 0
+		}, ""},
+		{"poca memoria de Argon2", func(c *Configuracion) { c.Argon2Memoria = 7 }, "memoria de Argon2"},
+		{"largo máximo de bcrypt", func(c *Configuracion) { c.AlgoritmoContrasenas = "bcrypt"; c.ContrasenaLargoMaximo = 100 }, "largo máximo"},
+		{"largo máximo de Argon2id", func(c *Configuracion) { c.ContrasenaLargoMaximo = 100 }, ""},
+		{"algoritmo de contraseñas desconocido", func(c *Configuracion) { c.AlgoritmoContrasenas = "md5" }, "algoritmo de contraseñas desconocido"},
+		{"lista de filtradas que no existe", func(c *Configuracion) { c.ContrasenasFiltradas = "/no/existe" }, "contraseñas filtradas"},
+	}
+	for _, caso := range casos {
+		config := PorDefecto()
+		config.Almacenamiento, config.ContrasenaAdmin = "memoria", "secreta"
+		caso.modificar(&config)
+		err := config.Validar()
+		switch {
+		case caso.mensaje == "" && err != nil:
+			t.Errorf("%s: %v", caso.nombre, err)
+		case caso.mensaje != "" && (err == nil || !strings.Contains(err.Error(), caso.mensaje)):
+			t.Errorf("%s: error %v, se esperaba uno con %q", caso.nombre, err, caso.mensaje)
+		}
+	}
+}
+
+func TestConfiguracionEjemplo(t *testing.T) {
+	// El archivo de ejemplo es válido con solo los secretos por entorno
+	limpiarEntorno(t, map[string]string{"DSN": "sqlite://taller6.db", "CONTRASENA_ADMIN": "secreta"})
+	if _, err := Cargar(filepath.Join("..", "config.ejemplo.yaml")); err != nil {
+		t.Errorf("config.ejemplo.yaml no es válido: %v", err)
+	}
+}
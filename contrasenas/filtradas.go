@@ -0,0 +1,183 @@
+package contrasenas
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Los hashes se buscan por los primeros 5 caracteres del SHA-1, como en la API de rangos
+// (k-anonimidad) de Have I Been Pwned
+const largoPrefijo = 5
+
+// Bytes que se leen para encontrar una línea del archivo ordenado; cada línea es
+// "<40 caracteres de hash>:<cantidad>", mucho más corta
+const largoLectura = 256
+
+// Filtradas dice cuántas veces apareció una contraseña en filtraciones conocidas
+type Filtradas interface {
+	Apariciones(contrasena string) (int, error)
+}
+
+// NuevaFiltradas abre la lista que está en la ruta, que puede ser un directorio con un
+// archivo por prefijo (DirectorioFiltradas) o un solo archivo ordenado (ArchivoFiltradas)
+func NuevaFiltradas(ruta string) (Filtradas, error) {
+	info, err := os.Stat(ruta)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir la lista de contraseñas filtradas: %w", err)
+	}
+	if info.IsDir() {
+		return DirectorioFiltradas{Directorio: ruta}, nil
+	}
+	return ArchivoFiltradas{Ruta: ruta}, nil
+}
+
+// hashSHA1 devuelve el SHA-1 de la contraseña en hexadecimal en mayúsculas, como en las listas
+func hashSHA1(contrasena string) string {
+	suma := sha1.Sum([]byte(contrasena))
+	return strings.ToUpper(hex.EncodeToString(suma[:]))
+}
+
+// DirectorioFiltradas es la lista partida en archivos <PREFIJO>.txt (por ejemplo 21BD1.txt)
+// con líneas "<SUFIJO>:<cantidad>", el mismo formato que responde la API de rangos.
+// Así se descarga y se actualiza por partes, y cada consulta lee un archivo chico.
+type DirectorioFiltradas struct {
+	Directorio string
+}
+
+// Apariciones busca el sufijo del hash en el archivo de su prefijo
+func (d DirectorioFiltradas) Apariciones(contrasena string) (int, error) {
+	hash := hashSHA1(contrasena)
+	archivo, err := os.Open(filepath.Join(d.Directorio, hash[:largoPrefijo]+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		// Un prefijo sin archivo no tiene contraseñas filtradas
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer archivo.Close()
+
+	lector := bufio.NewScanner(archivo)
+	for lector.Scan() {
+		sufijo, cantidad, ok := strings.Cut(strings.TrimSpace(lector.Text()), ":")
+		if ok && strings.EqualFold(sufijo, hash[largoPrefijo:]) {
+			return parsearCantidad(cantidad)
+		}
+	}
+	return 0, lector.Err()
+}
+
+// ArchivoFiltradas es la lista completa en un solo archivo con líneas "<HASH>:<cantidad>"
+// ordenadas por hash, como la genera el descargador oficial. Puede pesar decenas de GB,
+// así que no se carga: cada consulta hace una búsqueda binaria sobre el archivo.
+type ArchivoFiltradas struct {
+	Ruta string
+}
+
+// Apariciones busca el hash completo en el archivo ordenado
+func (a ArchivoFiltradas) Apariciones(contrasena string) (int, error) {
+	hash := hashSHA1(contrasena)
+	archivo, err := os.Open(a.Ruta)
+	if err != nil {
+		return 0, err
+	}
+	defer archivo.Close()
+	info, err := archivo.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// Buscamos la primera posición cuya línea siguiente tiene un hash >= al buscado
+	inicio, fin := int64(0), info.Size()
+	for inicio < fin {
+		medio := inicio + (fin-inicio)/2
+		linea, err := lineaDesde(archivo, medio)
+		if err != nil {
+			return 0, err
+		}
+		if linea == "" || compararHash(linea, hash) >= 0 {
+			fin = medio
+		} else {
+			inicio = medio + 1
+		}
+	}
+
+	linea, err := lineaDesde(archivo, inicio)
+	if err != nil || linea == "" {
+		return 0, err
+	}
+	encontrado, cantidad, _ := strings.Cut(linea, ":")
+	if !strings.EqualFold(encontrado, hash) {
+		return 0, nil
+	}
+	return parsearCantidad(cantidad)
+}
+
+// lineaDesde devuelve la primera línea completa que empieza en la posición o después;
+// vacío si no hay más líneas
+func lineaDesde(archivo *os.File, posicion int64) (string, error) {
+	comienzo := posicion
+	if posicion > 0 {
+		// El byte anterior a la posición dice si ahí empieza una línea; si no, se salta hasta la próxima
+		anterior, err := leerDesde(archivo, posicion-1)
+		if err != nil {
+			return "", err
+		}
+		salto := bytes.IndexByte(anterior, '\n')
+		if salto < 0 {
+			if len(anterior) < largoLectura {
+				return "", nil
+			}
+			return "", fmt.Errorf("línea demasiado larga en la lista de contraseñas filtradas (posición %d)", posicion)
+		}
+		comienzo = posicion + int64(salto)
+	}
+
+	linea, err := leerDesde(archivo, comienzo)
+	if err != nil {
+		return "", err
+	}
+	if fin := bytes.IndexByte(linea, '\n'); fin >= 0 {
+		linea = linea[:fin]
+	} else if len(linea) == largoLectura {
+		return "", fmt.Errorf("línea demasiado larga en la lista de contraseñas filtradas (posición %d)", comienzo)
+	}
+	return strings.TrimSpace(string(linea)), nil
+}
+
+// leerDesde lee hasta largoLectura bytes a partir de la posición
+func leerDesde(archivo *os.File, posicion int64) ([]byte, error) {
+	buffer := make([]byte, largoLectura)
+	leidos, err := archivo.ReadAt(buffer, posicion)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buffer[:leidos], nil
+}
+
+// compararHash compara el hash de la línea con el buscado sin importar mayúsculas
+func compararHash(linea, hash string) int {
+	encontrado, _, _ := strings.Cut(linea, ":")
+	return strings.Compare(strings.ToUpper(encontrado), hash)
+}
+
+// parsearCantidad lee la cantidad de apariciones; si falta, cuenta una
+func parsearCantidad(texto string) (int, error) {
+	if texto == "" {
+		return 1, nil
+	}
+	cantidad, err := strconv.Atoi(strings.TrimSpace(texto))
+	if err != nil {
+		return 0, fmt.Errorf("cantidad inválida en la lista de contraseñas filtradas: %q", texto)
+	}
+	return cantidad, nil
+}
@@ -0,0 +1,170 @@
+package contrasenas
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Contraseñas de testdata/filtradas.txt con sus apariciones. superman es la primera
+// línea del archivo y bienvenido la última.
+var filtradasPrueba = map[string]int{
+	"superman":   592,
+	"princesa":   555,
+	"111111":     185,
+	"futbol":     444,
+	"password":   37,
+	"abc123":     333,
+	"123456":     74,
+	"contraseña": 148,
+	"sunshine":   407,
+	"monkey":     296,
+	"dragon":     222,
+	"qwerty":     111,
+	"letmein":    259,
+	"admin":      481,
+	"iloveyou":   370,
+	"bienvenido": 518,
+}
+
+// Contraseñas que no están en testdata/filtradas.txt: segura-4 va antes de la primera
+// línea, segura-26 después de la última y las otras caen entre dos líneas
+var noFiltradasPrueba = []string{"segura-4", "segura-26", "Password", "superman!", ""}
+
+func TestArchivoFiltradas(t *testing.T) {
+	filtradas := ArchivoFiltradas{Ruta: filepath.Join("testdata", "filtradas.txt")}
+	for contrasena, esperadas := range filtradasPrueba {
+		apariciones, err := filtradas.Apariciones(contrasena)
+		if err != nil || apariciones != esperadas {
+			t.Errorf("%q: se obtuvo %d (%v), se esperaba %d", contrasena, apariciones, err, esperadas)
+		}
+	}
+	for _, contrasena := range noFiltradasPrueba {
+		if apariciones, err := filtradas.Apariciones(contrasena); err != nil || apariciones != 0 {
+			t.Errorf("%q: se obtuvo %d (%v), se esperaba 0", contrasena, apariciones, err)
+		}
+	}
+}
+
+// escribirArchivo crea un archivo temporal con las líneas indicadas
+func escribirArchivo(t *testing.T, lineas []string, final string) string {
+	t.Helper()
+	ruta := filepath.Join(t.TempDir(), "filtradas.txt")
+	if err := os.WriteFile(ruta, []byte(strings.Join(lineas, final)+final), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ruta
+}
+
+// TestArchivoFiltradasGrande busca en un archivo de muchas lecturas, donde la búsqueda
+// binaria cae en cualquier parte de una línea
+func TestArchivoFiltradasGrande(t *testing.T) {
+	const cantidad = 2000
+	lineas := make([]string, cantidad)
+	// La contraseña de cada hash; clave-N aparece N+1 veces
+	contrasenas := make(map[string]string, cantidad)
+	for i := range lineas {
+		contrasena := fmt.Sprintf("clave-%d", i)
+		hash := hashSHA1(contrasena)
+		lineas[i] = fmt.Sprintf("%s:%d", hash, i+1)
+		contrasenas[hash] = contrasena
+	}
+	sort.Strings(lineas)
+
+	casos := []struct {
+		nombre string
+		final  string
+		lineas func([]string) []string
+	}{
+		{"LF", "\n", func(l []string) []string { return l }},
+		{"CRLF", "\r\n", func(l []string) []string { return l }},
+		{"hashes en minúsculas", "\n", func(l []string) []string {
+			minusculas := make([]string, len(l))
+			for i, linea := range l {
+				minusculas[i] = strings.ToLower(linea)
+			}
+			return minusculas
+		}},
+	}
+	for _, caso := range casos {
+		filtradas := ArchivoFiltradas{Ruta: escribirArchivo(t, caso.lineas(lineas), caso.final)}
+		// La primera, la última y varias del medio
+		for _, i := range []int{0, 1, 97, cantidad / 2, cantidad - 2, cantidad - 1} {
+			hash, texto, _ := strings.Cut(lineas[i], ":")
+			esperadas, _ := strconv.Atoi(texto)
+			if apariciones, err := filtradas.Apariciones(contrasenas[hash]); err != nil || apariciones != esperadas {
+				t.Errorf("%s, línea %d: se obtuvo %d (%v), se esperaba %d", caso.nombre, i, apariciones, err, esperadas)
+			}
+		}
+		for _, contrasena := range []string{"clave-2000", "clave--1", "otra"} {
+			if apariciones, err := filtradas.Apariciones(contrasena); err != nil || apariciones != 0 {
+				t.Errorf("%s, %q: se obtuvo %d (%v), se esperaba 0", caso.nombre, contrasena, apariciones, err)
+			}
+		}
+	}
+}
+
+func TestArchivoFiltradasBordes(t *testing.T) {
+	hash := hashSHA1("password")
+	casos := []struct {
+		nombre     string
+		contenido  string
+		esperadas  int
+		conErrores bool
+	}{
+		{"archivo vacío", "", 0, false},
+		{"una sola línea", hash + ":7\n", 7, false},
+		{"sin salto al final", hash + ":7", 7, false},
+		{"sin cantidad", hash + "\n", 1, false},
+		{"cantidad inválida", hash + ":muchas\n", 0, true},
+		{"línea demasiado larga", "0" + strings.Repeat("A", 2*largoLectura) + ":1\n" + hash + ":7\n", 0, true},
+	}
+	for _, caso := range casos {
+		ruta := filepath.Join(t.TempDir(), "filtradas.txt")
+		if err := os.WriteFile(ruta, []byte(caso.contenido), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		apariciones, err := ArchivoFiltradas{Ruta: ruta}.Apariciones("password")
+		if (err != nil) != caso.conErrores || apariciones != caso.esperadas {
+			t.Errorf("%s: se obtuvo %d (%v), se esperaba %d", caso.nombre, apariciones, err, caso.esperadas)
+		}
+	}
+}
+
+func TestDirectorioFiltradas(t *testing.T) {
+	directorio := t.TempDir()
+	hash := hashSHA1("password")
+	contenido := "0000000000000000000000000000000000A:3\r\n" + strings.ToLower(hash[largoPrefijo:]) + ":37\r\n"
+	if err := os.WriteFile(filepath.Join(directorio, hash[:largoPrefijo]+".txt"), []byte(contenido), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	filtradas, err := NuevaFiltradas(directorio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apariciones, err := filtradas.Apariciones("password"); err != nil || apariciones != 37 {
+		t.Errorf("se obtuvo %d (%v), se esperaba 37", apariciones, err)
+	}
+	// Un prefijo sin archivo no tiene contraseñas filtradas
+	if apariciones, err := filtradas.Apariciones("segura-4"); err != nil || apariciones != 0 {
+		t.Errorf("se obtuvo %d (%v), se esperaba 0", apariciones, err)
+	}
+}
+
+func TestNuevaFiltradas(t *testing.T) {
+	ruta := filepath.Join("testdata", "filtradas.txt")
+	filtradas, err := NuevaFiltradas(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := filtradas.(ArchivoFiltradas); !ok {
+		t.Errorf("un archivo dio %T, se esperaba ArchivoFiltradas", filtradas)
+	}
+	if _, err := NuevaFiltradas(filepath.Join(t.TempDir(), "no-existe")); err == nil {
+		t.Error("una ruta que no existe no dio error")
+	}
+}
@@ -0,0 +1,133 @@
+package contrasenas
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algoritmos con los que se pueden encriptar las contraseñas nuevas
+const (
+	AlgoritmoArgon2id = "argon2id"
+	AlgoritmoBcrypt   = "bcrypt"
+)
+
+// LargoMaximoBcrypt es lo más largo que acepta bcrypt; lo que pasa de 72 bytes lo rechaza
+const LargoMaximoBcrypt = 72
+
+// Bytes de sal y de hash de Argon2id, los recomendados por el RFC 9106
+const (
+	largoSalArgon2   = 16
+	largoClaveArgon2 = 32
+)
+
+// ErrHashDesconocido es un hash guardado que no es de ningún algoritmo conocido
+var ErrHashDesconocido = errors.New("formato de hash de contraseña desconocido")
+
+// ParametrosArgon2 son los costos de Argon2id
+type ParametrosArgon2 struct {
+	Memoria     uint32 // En KiB
+	Iteraciones uint32
+	Paralelismo uint8
+}
+
+// ParametrosArgon2PorDefecto son los mínimos que recomienda OWASP: 19 MiB, 2 pasadas
+// y un hilo. Cada inicio de sesión simultáneo ocupa esa memoria mientras dura.
+func ParametrosArgon2PorDefecto() ParametrosArgon2 {
+	return ParametrosArgon2{Memoria: 19 * 1024, Iteraciones: 2, Paralelismo: 1}
+}
+
+// Hasheador encripta las contraseñas nuevas con el algoritmo configurado y verifica
+// las guardadas con cualquiera de los dos. Los hashes se guardan en formato PHC
+// ($argon2id$v=19$m=...,t=...,p=...$sal$hash) o en el de bcrypt ($2a$10$...), así
+// cada uno dice con qué algoritmo y qué costos se generó y se pueden subir los costos
+// sin invalidar los hashes anteriores.
+type Hasheador struct {
+	Algoritmo   string // AlgoritmoArgon2id o AlgoritmoBcrypt
+	Argon2      ParametrosArgon2
+	CostoBcrypt int
+}
+
+// Hashear encripta la contraseña con el algoritmo y los costos actuales
+func (h Hasheador) Hashear(contrasena string) (string, error) {
+	if h.Algoritmo == AlgoritmoBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(contrasena), h.CostoBcrypt)
+		return string(hash), err
+	}
+
+	sal := make([]byte, largoSalArgon2)
+	if _, err := rand.Read(sal); err != nil {
+		return "", err
+	}
+	p := h.Argon2
+	clave := argon2.IDKey([]byte(contrasena), sal, p.Iteraciones, p.Memoria, p.Paralelismo, largoClaveArgon2)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memoria, p.Iteraciones, p.Paralelismo,
+		base64.RawStdEncoding.EncodeToString(sal), base64.RawStdEncoding.EncodeToString(clave)), nil
+}
+
+// Verificar compara la contraseña con el hash guardado. Si coincide, rehashear indica
+// que el hash es de otro algoritmo o de otros costos y conviene reemplazarlo por uno
+// nuevo ahora que se tiene la contraseña.
+func (h Hasheador) Verificar(hash, contrasena string) (coincide, rehashear bool, err error) {
+	if esBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(contrasena))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		costo, _ := bcrypt.Cost([]byte(hash))
+		return true, h.Algoritmo != AlgoritmoBcrypt || costo != h.CostoBcrypt, nil
+	}
+
+	p, sal, clave, err := parsearArgon2(hash)
+	if err != nil {
+		return false, false, err
+	}
+	calculada := argon2.IDKey([]byte(contrasena), sal, p.Iteraciones, p.Memoria, p.Paralelismo, uint32(len(clave)))
+	if subtle.ConstantTimeCompare(calculada, clave) != 1 {
+		return false, false, nil
+	}
+	return true, h.Algoritmo != AlgoritmoArgon2id || p != h.Argon2 || len(clave) != largoClaveArgon2, nil
+}
+
+// esBcrypt reconoce los prefijos de bcrypt ($2a$, $2b$ y $2y$)
+func esBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// parsearArgon2 separa un hash PHC de Argon2id en sus costos, la sal y la clave derivada
+func parsearArgon2(hash string) (ParametrosArgon2, []byte, []byte, error) {
+	var p ParametrosArgon2
+	partes := strings.Split(hash, "$")
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", sal, clave
+	if len(partes) != 6 || partes[0] != "" || partes[1] != AlgoritmoArgon2id {
+		return p, nil, nil, ErrHashDesconocido
+	}
+	var version int
+	if _, err := fmt.Sscanf(partes[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("versión de Argon2 no soportada: %q", partes[2])
+	}
+	if _, err := fmt.Sscanf(partes[3], "m=%d,t=%d,p=%d", &p.Memoria, &p.Iteraciones, &p.Paralelismo); err != nil {
+		return p, nil, nil, fmt.Errorf("parámetros de Argon2 inválidos: %q", partes[3])
+	}
+	if p.Iteraciones == 0 || p.Paralelismo == 0 {
+		return p, nil, nil, fmt.Errorf("parámetros de Argon2 inválidos: %q", partes[3])
+	}
+	sal, err := base64.RawStdEncoding.DecodeString(partes[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("sal de Argon2 inválida: %w", err)
+	}
+	clave, err := base64.RawStdEncoding.DecodeString(partes[5])
+	if err != nil || len(clave) == 0 {
+		return p, nil, nil, errors.New("hash de Argon2 inválido")
+	}
+	return p, sal, clave, nil
+}
@@ -0,0 +1,149 @@
+package contrasenas
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Costos bajos para que las pruebas no tarden
+var argon2Prueba = ParametrosArgon2{Memoria: 64, Iteraciones: 1, Paralelismo: 1}
+
+func TestHashearArgon2id(t *testing.T) {
+	h := Hasheador{Algoritmo: AlgoritmoArgon2id, Argon2: argon2Prueba, CostoBcrypt: bcrypt.MinCost}
+	hash, err := h.Hashear("Caballo-Bateria-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sal de 16 bytes y clave de 32 en base64 sin relleno
+	formato := regexp.MustCompile(`^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+	if !formato.MatchString(hash) {
+		t.Fatalf("el hash %q no está en formato PHC", hash)
+	}
+
+	p, sal, clave, err := parsearArgon2(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != argon2Prueba || len(sal) != largoSalArgon2 || len(clave) != largoClaveArgon2 {
+		t.Errorf("se leyó %+v con %d bytes de sal y %d de clave", p, len(sal), len(clave))
+	}
+
+	if coincide, rehashear, err := h.Verificar(hash, "Caballo-Bateria-7"); err != nil || !coincide || rehashear {
+		t.Errorf("con la contraseña correcta: coincide=%v rehashear=%v (%v)", coincide, rehashear, err)
+	}
+	if coincide, _, err := h.Verificar(hash, "caballo-bateria-7"); err != nil || coincide {
+		t.Errorf("con otra contraseña: coincide=%v (%v)", coincide, err)
+	}
+	// Cada hash lleva su propia sal
+	if otro, _ := h.Hashear("Caballo-Bateria-7"); otro == hash {
+		t.Error("dos hashes de la misma contraseña son iguales")
+	}
+}
+
+func TestParsearArgon2RechazaHashesMalformados(t *testing.T) {
+	h := Hasheador{Algoritmo: AlgoritmoArgon2id, Argon2: argon2Prueba}
+	valido, err := h.Hashear("secreta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	partes := strings.Split(valido, "$")
+	reemplazar := func(indice int, valor string) string {
+		copia := append([]string(nil), partes...)
+		copia[indice] = valor
+		return strings.Join(copia, "$")
+	}
+
+	casos := []struct {
+		nombre      string
+		hash        string
+		desconocido bool // Se espera ErrHashDesconocido
+	}{
+		{"vacío", "", true},
+		{"texto plano", "secreta", true},
+		{"argon2i", reemplazar(1, "argon2i"), true},
+		{"faltan partes", strings.Join(partes[:5], "$"), true},
+		{"sobran partes", valido + "$extra", true},
+		{"otra versión", reemplazar(2, "v=16"), false},
+		{"versión ilegible", reemplazar(2, "version"), false},
+		{"parámetros ilegibles", reemplazar(3, "m=64,t=1"), false},
+		{"sin iteraciones", reemplazar(3, "m=64,t=0,p=1"), false},
+		{"sin paralelismo", reemplazar(3, "m=64,t=1,p=0"), false},
+		{"sal que no es base64", reemplazar(4, "no es base64!"), false},
+		{"clave que no es base64", reemplazar(5, "no es base64!"), false},
+		{"clave vacía", reemplazar(5, ""), false},
+	}
+	for _, caso := range casos {
+		if _, _, _, err := parsearArgon2(caso.hash); err == nil || errors.Is(err, ErrHashDesconocido) != caso.desconocido {
+			t.Errorf("%s: error %v", caso.nombre, err)
+		}
+		// Verificar no lo toma como una contraseña que no coincide
+		if coincide, _, err := h.Verificar(caso.hash, "secreta"); err == nil || coincide {
+			t.Errorf("%s: Verificar dio coincide=%v (%v)", caso.nombre, coincide, err)
+		}
+	}
+}
+
+func TestVerificarRehashear(t *testing.T) {
+	hashBcrypt := func(costo int) string {
+		hash, err := bcrypt.GenerateFromPassword([]byte("secreta"), costo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(hash)
+	}
+	hashArgon2 := func(p ParametrosArgon2) string {
+		hash, err := Hasheador{Algoritmo: AlgoritmoArgon2id, Argon2: p}.Hashear("secreta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	argon2 := Hasheador{Algoritmo: AlgoritmoArgon2id, Argon2: argon2Prueba, CostoBcrypt: bcrypt.MinCost}
+	conBcrypt := Hasheador{Algoritmo: AlgoritmoBcrypt, Argon2: argon2Prueba, CostoBcrypt: bcrypt.MinCost}
+
+	casos := []struct {
+		nombre    string
+		hasheador Hasheador
+		hash      string
+		rehashear bool
+	}{
+		{"argon2id con los mismos costos", argon2, hashArgon2(argon2Prueba), false},
+		{"bcrypt viejo con argon2id configurado", argon2, hashBcrypt(bcrypt.MinCost), true},
+		{"más memoria", argon2, hashArgon2(ParametrosArgon2{Memoria: 32, Iteraciones: 1, Paralelismo: 1}), true},
+		{"más iteraciones", argon2, hashArgon2(ParametrosArgon2{Memoria: 64, Iteraciones: 2, Paralelismo: 1}), true},
+		{"más paralelismo", argon2, hashArgon2(ParametrosArgon2{Memoria: 64, Iteraciones: 1, Paralelismo: 2}), true},
+		{"bcrypt con el mismo costo", conBcrypt, hashBcrypt(bcrypt.MinCost), false},
+		{"bcrypt con otro costo", conBcrypt, hashBcrypt(bcrypt.MinCost + 1), true},
+		{"argon2id con bcrypt configurado", conBcrypt, hashArgon2(argon2Prueba), true},
+		{"bcrypt $2y$", conBcrypt, "$2y$" + strings.TrimPrefix(hashBcrypt(bcrypt.MinCost), "$2a$"), false},
+	}
+	for _, caso := range casos {
+		coincide, rehashear, err := caso.hasheador.Verificar(caso.hash, "secreta")
+		if err != nil || !coincide || rehashear != caso.rehashear {
+			t.Errorf("%s: coincide=%v rehashear=%v (%v), se esperaba rehashear=%v", caso.nombre, coincide, rehashear, err, caso.rehashear)
+		}
+		// Una contraseña incorrecta nunca pide rehashear
+		if coincide, rehashear, err := caso.hasheador.Verificar(caso.hash, "otra"); err != nil || coincide || rehashear {
+			t.Errorf("%s, contraseña incorrecta: coincide=%v rehashear=%v (%v)", caso.nombre, coincide, rehashear, err)
+		}
+	}
+}
+
+func TestHashearBcrypt(t *testing.T) {
+	h := Hasheador{Algoritmo: AlgoritmoBcrypt, CostoBcrypt: bcrypt.MinCost}
+	hash, err := h.Hashear("secreta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if costo, err := bcrypt.Cost([]byte(hash)); err != nil || costo != bcrypt.MinCost {
+		t.Errorf("el hash %q tiene costo %d (%v)", hash, costo, err)
+	}
+	// bcrypt no acepta más de LargoMaximoBcrypt bytes
+	if _, err := h.Hashear(strings.Repeat("a", LargoMaximoBcrypt+1)); err == nil {
+		t.Error("se aceptó una contraseña más larga de lo que admite bcrypt")
+	}
+}
@@ -0,0 +1,111 @@
+// Package contrasenas decide qué contraseñas se aceptan y cómo se guardan: una Politica
+// con reglas de largo, tipos de caracteres y datos de la cuenta, una lista local de
+// contraseñas filtradas en otras brechas y el Hasheador que las encripta. El historial
+// de cada usuario lo controlan los manejadores, que son los que tienen el repositorio.
+package contrasenas
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Largo mínimo de un nombre de usuario o de un correo para buscarlo dentro de la
+// contraseña; con menos, "ana" rechazaría "banana123"
+const largoMinimoDato = 3
+
+// Politica son las reglas que tiene que cumplir una contraseña nueva
+type Politica struct {
+	LargoMinimo   int       // En caracteres
+	LargoMaximo   int       // En bytes, porque bcrypt no acepta más de 72
+	ClasesMinimas int       // Cuántos tipos distintos: minúsculas, mayúsculas, dígitos y símbolos
+	Filtradas     Filtradas // Contraseñas conocidas por brechas; nil no las controla
+}
+
+// Incumplimiento es una regla que la contraseña no cumple
+type Incumplimiento struct {
+	Regla   string `json:"regla"`
+	Mensaje string `json:"mensaje"`
+}
+
+// Datos de la cuenta que no pueden aparecer dentro de la contraseña
+type Datos struct {
+	NombreUsuario string
+	Correo        string
+}
+
+// Validar revisa todas las reglas y devuelve todas las que no se cumplen, así el
+// usuario las corrige de una vez. Solo devuelve error si falla la lista de filtradas.
+func (p Politica) Validar(contrasena string, datos Datos) ([]Incumplimiento, error) {
+	if contrasena == "" {
+		return []Incumplimiento{{Regla: "requerida", Mensaje: "La contraseña no puede estar vacía"}}, nil
+	}
+
+	var incumplidas []Incumplimiento
+	if largo := utf8.RuneCountInString(contrasena); largo < p.LargoMinimo {
+		incumplidas = append(incumplidas, Incumplimiento{Regla: "largo_minimo",
+			Mensaje: fmt.Sprintf("La contraseña tiene que tener al menos %d caracteres", p.LargoMinimo)})
+	}
+	if p.LargoMaximo > 0 && len(contrasena) > p.LargoMaximo {
+		incumplidas = append(incumplidas, Incumplimiento{Regla: "largo_maximo",
+			Mensaje: fmt.Sprintf("La contraseña no puede ocupar más de %d bytes", p.LargoMaximo)})
+	}
+	if clases := contarClases(contrasena); clases < p.ClasesMinimas {
+		incumplidas = append(incumplidas, Incumplimiento{Regla: "clases",
+			Mensaje: fmt.Sprintf("La contraseña tiene que combinar al menos %d de estos tipos: minúsculas, mayúsculas, dígitos y símbolos", p.ClasesMinimas)})
+	}
+
+	minusculas := strings.ToLower(contrasena)
+	if contiene(minusculas, datos.NombreUsuario) {
+		incumplidas = append(incumplidas, Incumplimiento{Regla: "contiene_usuario",
+			Mensaje: "La contraseña no puede contener el nombre de usuario"})
+	}
+	// Se busca la parte antes de la @, que es lo que suele copiarse
+	local, _, _ := strings.Cut(datos.Correo, "@")
+	if contiene(minusculas, datos.Correo) || contiene(minusculas, local) {
+		incumplidas = append(incumplidas, Incumplimiento{Regla: "contiene_correo",
+			Mensaje: "La contraseña no puede contener el correo"})
+	}
+
+	if p.Filtradas != nil {
+		apariciones, err := p.Filtradas.Apariciones(contrasena)
+		if err != nil {
+			return nil, err
+		}
+		if apariciones > 0 {
+			incumplidas = append(incumplidas, Incumplimiento{Regla: "filtrada",
+				Mensaje: "La contraseña apareció en filtraciones de otros sitios; elija otra"})
+		}
+	}
+	return incumplidas, nil
+}
+
+// contiene indica si el dato (de largo suficiente) aparece en la contraseña, sin importar mayúsculas
+func contiene(contrasenaMinusculas, dato string) bool {
+	return utf8.RuneCountInString(dato) >= largoMinimoDato && strings.Contains(contrasenaMinusculas, strings.ToLower(dato))
+}
+
+// contarClases cuenta cuántos tipos de caracteres distintos tiene la contraseña
+func contarClases(contrasena string) int {
+	var minuscula, mayuscula, digito, simbolo bool
+	for _, caracter := range contrasena {
+		switch {
+		case unicode.IsLower(caracter):
+			minuscula = true
+		case unicode.IsUpper(caracter):
+			mayuscula = true
+		case unicode.IsDigit(caracter):
+			digito = true
+		default:
+			simbolo = true
+		}
+	}
+	clases := 0
+	for _, presente := range []bool{minuscula, mayuscula, digito, simbolo} {
+		if presente {
+			clases++
+		}
+	}
+	return clases
+}
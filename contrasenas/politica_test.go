@@ -0,0 +1,95 @@
+package contrasenas
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// filtradasFalla simula una lista de filtradas que no se puede leer
+type filtradasFalla struct{}
+
+func (filtradasFalla) Apariciones(string) (int, error) {
+	return 0, errors.New("no se pudo leer la lista")
+}
+
+func TestPoliticaValidar(t *testing.T) {
+	politica := Politica{
+		LargoMinimo:   10,
+		LargoMaximo:   20,
+		ClasesMinimas: 3,
+		Filtradas:     ArchivoFiltradas{Ruta: filepath.Join("testdata", "filtradas.txt")},
+	}
+	datos := Datos{NombreUsuario: "Ana.Perez", Correo: "aperez@ejemplo.com"}
+
+	casos := []struct {
+		nombre     string
+		contrasena string
+		datos      Datos
+		reglas     []string
+	}{
+		{"cumple todo", "Caballo-Bateria-7", datos, nil},
+		{"vacía", "", datos, []string{"requerida"}},
+		{"corta", "Ab1-x", datos, []string{"largo_minimo"}},
+		{"el largo mínimo cuenta caracteres", "Ñandú-ñandú-1", datos, nil},
+		{"el largo máximo cuenta bytes", "Ñandú-ñandú-ñandú-1", datos, []string{"largo_maximo"}},
+		{"pocas clases", "caballobateria", datos, []string{"clases"}},
+		{"contiene el usuario", "xx-ana.PEREZ-77", datos, []string{"contiene_usuario"}},
+		{"contiene la parte local del correo", "Mi-APerez-2024", datos, []string{"contiene_correo"}},
+		{"un dato corto no se busca", "Banana-Split-9", Datos{NombreUsuario: "an", Correo: "an@ejemplo.com"}, nil},
+		{"filtrada", "contraseña", Datos{}, []string{"clases", "filtrada"}},
+		// Se devuelven todas las reglas incumplidas, no solo la primera
+		{"varias reglas", "aperez", datos, []string{"largo_minimo", "clases", "contiene_correo"}},
+		{"todas las reglas", "ana.perez@ejemplo.com-aperez", datos, []string{"largo_maximo", "clases", "contiene_usuario", "contiene_correo"}},
+	}
+	for _, caso := range casos {
+		incumplidas, err := politica.Validar(caso.contrasena, caso.datos)
+		if err != nil {
+			t.Errorf("%s: %v", caso.nombre, err)
+			continue
+		}
+		var reglas []string
+		for _, incumplida := range incumplidas {
+			if incumplida.Mensaje == "" {
+				t.Errorf("%s: la regla %s no tiene mensaje", caso.nombre, incumplida.Regla)
+			}
+			reglas = append(reglas, incumplida.Regla)
+		}
+		if !reflect.DeepEqual(reglas, caso.reglas) {
+			t.Errorf("%s: se incumplieron %v, se esperaba %v", caso.nombre, reglas, caso.reglas)
+		}
+	}
+}
+
+func TestPoliticaValidarErrorFiltradas(t *testing.T) {
+	politica := Politica{LargoMinimo: 1, Filtradas: filtradasFalla{}}
+	if _, err := politica.Validar("Caballo-Bateria-7", Datos{}); err == nil {
+		t.Error("el error de la lista de filtradas no se devolvió")
+	}
+	// Sin lista no se consulta
+	politica.Filtradas = nil
+	if incumplidas, err := politica.Validar("Caballo-Bateria-7", Datos{}); err != nil || len(incumplidas) != 0 {
+		t.Errorf("sin lista se obtuvo %v (%v)", incumplidas, err)
+	}
+}
+
+func TestContarClases(t *testing.T) {
+	casos := map[string]int{
+		"abc":      1,
+		"ABC":      1,
+		"123":      1,
+		"-_!":      1,
+		"abcABC":   2,
+		"aB3":      3,
+		"aB3 ":     4,
+		"ñÑ٣€":     4, // Letras y dígitos de otros alfabetos también cuentan
+		"":         0,
+		"a1a1a1a1": 2,
+	}
+	for contrasena, esperadas := range casos {
+		if clases := contarClases(contrasena); clases != esperadas {
+			t.Errorf("%q: %d clases, se esperaban %d", contrasena, clases, esperadas)
+		}
+	}
+}
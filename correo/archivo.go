@@ -0,0 +1,88 @@
+package correo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EnviadorDirectorio guarda cada mensaje como un archivo .eml en un directorio.
+// Sirve para probar los correos sin un servidor SMTP: los .eml se abren con cualquier cliente.
+type EnviadorDirectorio struct {
+	Directorio string
+	Remitente  string
+}
+
+// Enviar escribe el mensaje en <directorio>/<fecha>-<destinatario>.eml
+func (e EnviadorDirectorio) Enviar(mensaje Mensaje) error {
+	ahora := time.Now()
+	contenido, err := componer(mensaje, e.Remitente, ahora)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(e.Directorio, 0o700); err != nil {
+		return fmt.Errorf("no se pudo crear el directorio de correos: %w", err)
+	}
+	// Los nanosegundos evitan pisar otro mensaje al mismo destinatario
+	nombre := fmt.Sprintf("%s-%s.eml", ahora.UTC().Format("20060102T150405.000000000Z"), nombreSeguro(mensaje.Para))
+	return os.WriteFile(filepath.Join(e.Directorio, nombre), contenido, 0o600)
+}
+
+// nombreSeguro deja solo los caracteres que se pueden usar en un nombre de archivo
+func nombreSeguro(valor string) string {
+	seguro := []rune(valor)
+	for i, caracter := range seguro {
+		if !(caracter >= 'a' && caracter <= 'z' || caracter >= 'A' && caracter <= 'Z' || caracter >= '0' && caracter <= '9' ||
+			caracter == '@' || caracter == '.' || caracter == '-' || caracter == '_') {
+			seguro[i] = '_'
+		}
+	}
+	return string(seguro)
+}
+
+// EnviadorMbox agrega los mensajes al final de un archivo mbox (formato mboxrd)
+type EnviadorMbox struct {
+	Ruta      string
+	Remitente string
+
+	mu sync.Mutex // Dos mensajes a la vez no pueden intercalarse en el archivo
+}
+
+// Enviar agrega el mensaje al mbox, creando el archivo si no existe
+func (e *EnviadorMbox) Enviar(mensaje Mensaje) error {
+	ahora := time.Now()
+	contenido, err := componer(mensaje, e.Remitente, ahora)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From %s %s\n", direccionSola(e.Remitente), ahora.UTC().Format(time.ANSIC))
+	// En mboxrd toda línea que empieza con ">*From " lleva un ">" más, y las líneas terminan en LF
+	lineas := bufio.NewScanner(bytes.NewReader(contenido))
+	for lineas.Scan() {
+		linea := bytes.TrimSuffix(lineas.Bytes(), []byte("\r"))
+		if bytes.HasPrefix(bytes.TrimLeft(linea, ">"), []byte("From ")) {
+			buf.WriteByte('>')
+		}
+		buf.Write(linea)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	archivo, err := os.OpenFile(e.Ruta, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("no se pudo abrir el mbox: %w", err)
+	}
+	if _, err := archivo.Write(buf.Bytes()); err != nil {
+		archivo.Close()
+		return err
+	}
+	return archivo.Close()
+}
@@ -0,0 +1,125 @@
+package correo
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrColaLlena indica que hay demasiados mensajes pendientes y el nuevo se descartó
+var ErrColaLlena = errors.New("la cola de correos está llena")
+
+// Cola envía los mensajes en segundo plano y reintenta los que fallan, esperando cada
+// vez el doble. Así un servidor SMTP lento o caído no frena a los manejadores.
+// Los mensajes pendientes viven en memoria: si el servicio se reinicia, se pierden.
+type Cola struct {
+	enviador   Enviador
+	pendientes chan envio
+	reintentos int           // Reintentos después del primer intento
+	espera     time.Duration // Espera antes del primer reintento
+
+	mu      sync.Mutex
+	cerrada bool
+	activos sync.WaitGroup // Mensajes encolados o esperando un reintento
+}
+
+// envio es un mensaje de la cola con la cantidad de intentos que lleva
+type envio struct {
+	mensaje  Mensaje
+	intentos int
+}
+
+// NuevaCola arranca "trabajadores" goroutines que envían con "enviador". Caben
+// "capacidad" mensajes esperando su turno; si se llena, Enviar devuelve ErrColaLlena.
+func NuevaCola(enviador Enviador, trabajadores, capacidad, reintentos int, espera time.Duration) *Cola {
+	cola := &Cola{
+		enviador:   enviador,
+		pendientes: make(chan envio, capacidad),
+		reintentos: reintentos,
+		espera:     espera,
+	}
+	for i := 0; i < trabajadores; i++ {
+		go cola.trabajar()
+	}
+	return cola
+}
+
+// Enviar encola el mensaje y vuelve enseguida; el resultado del envío solo queda en el log
+func (c *Cola) Enviar(mensaje Mensaje) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cerrada {
+		return errors.New("la cola de correos está cerrada")
+	}
+	return c.encolar(envio{mensaje: mensaje})
+}
+
+// encolar agrega el envío sin bloquear. Los reintentos se encolan aunque la cola
+// esté cerrándose, para no perderlos mientras Cerrar espera.
+func (c *Cola) encolar(e envio) error {
+	// Se cuenta antes de encolar: un trabajador puede terminarlo antes de que volvamos del select
+	c.activos.Add(1)
+	select {
+	case c.pendientes <- e:
+		return nil
+	default:
+		c.activos.Done()
+		return ErrColaLlena
+	}
+}
+
+// trabajar envía los mensajes de la cola hasta que se cierre
+func (c *Cola) trabajar() {
+	for e := range c.pendientes {
+		c.procesar(e)
+	}
+}
+
+// procesar hace un intento y, si falla por algo pasajero, programa el siguiente
+// sin ocupar al trabajador mientras espera
+func (c *Cola) procesar(e envio) {
+	defer c.activos.Done()
+
+	err := c.enviador.Enviar(e.mensaje)
+	if err == nil {
+		return
+	}
+	e.intentos++
+	if errorPermanente(err) || e.intentos > c.reintentos {
+		log.Printf("Se descarta el correo a %s (%q) después de %d intentos: %v", e.mensaje.Para, e.mensaje.Asunto, e.intentos, err)
+		return
+	}
+
+	espera := c.espera << (e.intentos - 1)
+	log.Printf("Error al enviar el correo a %s, se reintenta en %v: %v", e.mensaje.Para, espera, err)
+	c.activos.Add(1)
+	time.AfterFunc(espera, func() {
+		defer c.activos.Done()
+		if err := c.encolar(e); err != nil {
+			log.Printf("Se descarta el correo a %s (%q): %v", e.mensaje.Para, e.mensaje.Asunto, err)
+		}
+	})
+}
+
+// Cerrar deja de aceptar mensajes y espera a que se envíen los pendientes, incluidos
+// los reintentos programados. Devuelve false si se cumplió "limite" antes de terminar;
+// lo que quede pendiente se pierde al salir.
+func (c *Cola) Cerrar(limite time.Duration) bool {
+	c.mu.Lock()
+	c.cerrada = true
+	c.mu.Unlock()
+
+	terminado := make(chan struct{})
+	go func() {
+		c.activos.Wait()
+		close(c.pendientes)
+		close(terminado)
+	}()
+	select {
+	case <-terminado:
+		return true
+	case <-time.After(limite):
+		return false
+	}
+}
@@ -0,0 +1,118 @@
+// Package correo envía los correos de las cuentas (restablecer la contraseña, etc.).
+// Los manejadores dependen de la interfaz Enviador y no de una forma de envío concreta:
+// SMTP, un directorio o un mbox para revisar los correos a mano, o el log para desarrollo.
+// Cola envuelve cualquiera de ellos para que un servidor lento no frene los pedidos.
+package correo
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mensaje es un correo listo para enviar
+type Mensaje struct {
+	Para   string
+	Asunto string
+	Texto  string
+	HTML   string // Opcional; si está se envía junto con el texto (multipart/alternative)
+}
+
+// Enviador entrega mensajes; hay una implementación por cada forma de envío
+type Enviador interface {
+	Enviar(mensaje Mensaje) error
+}
+
+// EnviadorLog no envía nada: escribe los mensajes en el log. Sirve para desarrollo y pruebas.
+type EnviadorLog struct{}
+
+// Enviar escribe el mensaje en el log (solo el texto, el HTML dice lo mismo)
+func (EnviadorLog) Enviar(mensaje Mensaje) error {
+	log.Printf("[correo] Para: %s | Asunto: %s\n%s", mensaje.Para, mensaje.Asunto, mensaje.Texto)
+	return nil
+}
+
+// componer arma el mensaje en formato RFC 5322 con las cabeceras MIME, tal como
+// se entrega por SMTP o se guarda en un archivo .eml
+func componer(mensaje Mensaje, remitente string, ahora time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	cabecera := func(nombre, valor string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", nombre, valor)
+	}
+	idMensaje, err := generarIDMensaje(remitente)
+	if err != nil {
+		return nil, err
+	}
+	cabecera("From", remitente)
+	cabecera("To", mensaje.Para)
+	cabecera("Subject", mime.QEncoding.Encode("utf-8", mensaje.Asunto))
+	cabecera("Date", ahora.Format(time.RFC1123Z))
+	cabecera("Message-ID", idMensaje)
+	cabecera("MIME-Version", "1.0")
+
+	if mensaje.HTML == "" {
+		cabecera("Content-Type", "text/plain; charset=utf-8")
+		cabecera("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := escribirQP(&buf, mensaje.Texto); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	partes := multipart.NewWriter(&buf)
+	cabecera("Content-Type", "multipart/alternative; boundary="+partes.Boundary())
+	buf.WriteString("\r\n")
+	// El orden importa: los clientes muestran la última parte que entienden
+	for _, parte := range []struct{ tipo, contenido string }{
+		{"text/plain; charset=utf-8", mensaje.Texto},
+		{"text/html; charset=utf-8", mensaje.HTML},
+	} {
+		escritor, err := partes.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {parte.tipo},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := escribirQP(escritor, parte.contenido); err != nil {
+			return nil, err
+		}
+	}
+	if err := partes.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escribirQP codifica el contenido en quoted-printable con saltos de línea CRLF
+func escribirQP(destino io.Writer, contenido string) error {
+	qp := quotedprintable.NewWriter(destino)
+	contenido = strings.ReplaceAll(contenido, "\r\n", "\n")
+	if _, err := qp.Write([]byte(strings.ReplaceAll(contenido, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// generarIDMensaje arma un Message-ID único con el dominio del remitente
+func generarIDMensaje(remitente string) (string, error) {
+	aleatorio := make([]byte, 12)
+	if _, err := rand.Read(aleatorio); err != nil {
+		return "", err
+	}
+	dominio := "localhost"
+	if _, despues, ok := strings.Cut(remitente, "@"); ok {
+		dominio = strings.TrimRight(despues, "> ")
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(aleatorio), dominio), nil
+}
@@ -0,0 +1,155 @@
+package correo
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Plantillas de cada idioma: plantillas/<idioma>/<nombre>.txt define el asunto y el
+// texto, y plantillas/<idioma>/<nombre>.html el contenido HTML, que se arma dentro de
+// plantillas/base.html
+//
+//go:embed plantillas
+var archivosPlantillas embed.FS
+
+// Nombres de las plantillas
+const (
+	PlantillaRestablecer        = "restablecer"         // Enlace para restablecer la contraseña
+	PlantillaVerificar          = "verificar"           // Enlace para verificar el correo
+	PlantillaContrasenaCambiada = "contrasena_cambiada" // Aviso de que la contraseña cambió
+)
+
+// Idiomas en los que hay plantillas
+const (
+	IdiomaEspanol = "es"
+	IdiomaIngles  = "en"
+)
+
+// IdiomaPredeterminado se usa cuando el cliente no pide ninguno de los disponibles
+const IdiomaPredeterminado = IdiomaEspanol
+
+// Datos son los valores que pueden usar las plantillas
+type Datos struct {
+	NombreUsuario string
+	Enlace        string        // URL o, si no hay página configurada, el token solo
+	Validez       time.Duration // Cuánto vale el enlace; se muestra con {{duracion .Validez}}
+}
+
+// plantilla es el par texto/HTML de un correo en un idioma
+type plantilla struct {
+	texto *texttemplate.Template
+	html  *htmltemplate.Template
+}
+
+// plantillas[idioma][nombre]; se cargan al iniciar y un error en los archivos es un
+// error de programación, así que entra en pánico como template.Must
+var plantillas = cargarPlantillas()
+
+// Componer arma el mensaje de la plantilla en el idioma pedido (o el predeterminado)
+func Componer(nombre, idioma, para string, datos Datos) (Mensaje, error) {
+	delIdioma, ok := plantillas[idioma]
+	if !ok {
+		delIdioma = plantillas[IdiomaPredeterminado]
+	}
+	p, ok := delIdioma[nombre]
+	if !ok {
+		return Mensaje{}, fmt.Errorf("plantilla de correo desconocida: %q", nombre)
+	}
+
+	var asunto, texto, html bytes.Buffer
+	if err := p.texto.ExecuteTemplate(&asunto, "asunto", datos); err != nil {
+		return Mensaje{}, err
+	}
+	if err := p.texto.Execute(&texto, datos); err != nil {
+		return Mensaje{}, err
+	}
+	conAsunto := struct {
+		Datos
+		Asunto string
+	}{datos, asunto.String()}
+	if err := p.html.ExecuteTemplate(&html, "base", conAsunto); err != nil {
+		return Mensaje{}, err
+	}
+	return Mensaje{Para: para, Asunto: asunto.String(), Texto: texto.String(), HTML: html.String()}, nil
+}
+
+// ElegirIdioma devuelve el idioma disponible que el cliente prefiere según la
+// cabecera Accept-Language (por ejemplo "en-US,en;q=0.9,es;q=0.8")
+func ElegirIdioma(acceptLanguage string) string {
+	type preferencia struct {
+		idioma string
+		peso   float64
+	}
+	var preferencias []preferencia
+	for _, parte := range strings.Split(acceptLanguage, ",") {
+		etiqueta, parametros, _ := strings.Cut(strings.TrimSpace(parte), ";")
+		peso := 1.0
+		if valor, ok := strings.CutPrefix(strings.TrimSpace(parametros), "q="); ok {
+			if p, err := strconv.ParseFloat(valor, 64); err == nil {
+				peso = p
+			}
+		}
+		// Solo nos importa el idioma, no la región: "es-AR" cuenta como "es"
+		idioma, _, _ := strings.Cut(strings.ToLower(etiqueta), "-")
+		if _, ok := plantillas[idioma]; ok && peso > 0 {
+			preferencias = append(preferencias, preferencia{idioma, peso})
+		}
+	}
+	if len(preferencias) == 0 {
+		return IdiomaPredeterminado
+	}
+	// Estable: a igual peso gana el que vino primero
+	sort.SliceStable(preferencias, func(i, j int) bool { return preferencias[i].peso > preferencias[j].peso })
+	return preferencias[0].idioma
+}
+
+// cargarPlantillas lee y compila las plantillas de todos los idiomas
+func cargarPlantillas() map[string]map[string]plantilla {
+	base, err := archivosPlantillas.ReadFile("plantillas/base.html")
+	if err != nil {
+		panic(err)
+	}
+	cargadas := map[string]map[string]plantilla{}
+	for _, idioma := range []string{IdiomaEspanol, IdiomaIngles} {
+		funciones := map[string]interface{}{
+			"duracion": func(d time.Duration) string { return formatearDuracion(idioma, d) },
+			"idioma":   func() string { return idioma },
+			"esEnlace": func(enlace string) bool {
+				return strings.HasPrefix(enlace, "https://") || strings.HasPrefix(enlace, "http://")
+			},
+		}
+		cargadas[idioma] = map[string]plantilla{}
+		for _, nombre := range []string{PlantillaRestablecer, PlantillaVerificar, PlantillaContrasenaCambiada} {
+			ruta := "plantillas/" + idioma + "/" + nombre
+			// ParseFS usa el nombre del archivo como nombre del template principal
+			texto := texttemplate.Must(texttemplate.New(nombre+".txt").Funcs(funciones).ParseFS(archivosPlantillas, ruta+".txt"))
+			html := htmltemplate.Must(htmltemplate.New("base.html").Funcs(funciones).Parse(string(base)))
+			html = htmltemplate.Must(html.ParseFS(archivosPlantillas, ruta+".html"))
+			cargadas[idioma][nombre] = plantilla{texto: texto, html: html}
+		}
+	}
+	return cargadas
+}
+
+// formatearDuracion escribe la validez de un enlace en horas o minutos
+func formatearDuracion(idioma string, d time.Duration) string {
+	unidades := map[string][4]string{
+		IdiomaEspanol: {"hora", "horas", "minuto", "minutos"},
+		IdiomaIngles:  {"hour", "hours", "minute", "minutes"},
+	}[idioma]
+	cantidad, singular, plural := int(d.Minutes()), unidades[2], unidades[3]
+	if d >= time.Hour && d%time.Hour == 0 {
+		cantidad, singular, plural = int(d.Hours()), unidades[0], unidades[1]
+	}
+	if cantidad == 1 {
+		return "1 " + singular
+	}
+	return strconv.Itoa(cantidad) + " " + plural
+}
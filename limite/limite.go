@@ -0,0 +1,187 @@
+// Package limite limita la cantidad de pedidos con un balde de fichas (token bucket):
+// cada clave (una IP, un usuario o una clave de API) tiene un balde que se llena a
+// ritmo constante, y cada pedido gasta una ficha. El middleware depende de la interfaz
+// Almacen y no de dónde se guardan los baldes: en memoria para una sola instancia, o en
+// Redis (o algo compatible) para que todas las instancias compartan los mismos.
+package limite
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tasa es la cantidad de pedidos permitidos por período. El balde tiene lugar para
+// Cantidad fichas (la ráfaga máxima) y se llena por completo en Periodo.
+type Tasa struct {
+	Cantidad int
+	Periodo  time.Duration
+}
+
+// ParsearTasa interpreta "5/1h" o "100/1m". Vacío o "0" es una tasa sin límite.
+func ParsearTasa(texto string) (Tasa, error) {
+	texto = strings.TrimSpace(texto)
+	if texto == "" || texto == "0" {
+		return Tasa{}, nil
+	}
+	cantidad, periodo, ok := strings.Cut(texto, "/")
+	if !ok {
+		return Tasa{}, fmt.Errorf("tasa inválida %q (usar cantidad/período, como 5/1h)", texto)
+	}
+	var tasa Tasa
+	var err error
+	if tasa.Cantidad, err = strconv.Atoi(cantidad); err != nil || tasa.Cantidad <= 0 {
+		return Tasa{}, fmt.Errorf("tasa inválida %q: la cantidad tiene que ser un número positivo", texto)
+	}
+	if tasa.Periodo, err = time.ParseDuration(periodo); err != nil || tasa.Periodo <= 0 {
+		return Tasa{}, fmt.Errorf("tasa inválida %q: el período tiene que ser una duración positiva, como 1m", texto)
+	}
+	return tasa, nil
+}
+
+// Activa indica si la tasa limita algo
+func (t Tasa) Activa() bool {
+	return t.Cantidad > 0 && t.Periodo > 0
+}
+
+// String devuelve la tasa en el mismo formato que ParsearTasa
+func (t Tasa) String() string {
+	if !t.Activa() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", t.Cantidad, t.Periodo)
+}
+
+// porSegundo es el ritmo al que se recargan las fichas
+func (t Tasa) porSegundo() float64 {
+	return float64(t.Cantidad) / t.Periodo.Seconds()
+}
+
+// Resultado es lo que queda del balde después de un pedido
+type Resultado struct {
+	Permitido bool
+	Restantes int           // Fichas enteras que quedan
+	Espera    time.Duration // Si no se permitió, cuánto falta para la próxima ficha
+	Reinicio  time.Duration // Cuánto falta para que el balde vuelva a estar lleno
+}
+
+// resultado arma el Resultado a partir de las fichas que quedaron en el balde
+func resultado(tasa Tasa, permitido bool, fichas float64) Resultado {
+	ritmo := tasa.porSegundo()
+	r := Resultado{
+		Permitido: permitido,
+		Restantes: int(math.Floor(fichas)),
+		Reinicio:  time.Duration((float64(tasa.Cantidad) - fichas) / ritmo * float64(time.Second)),
+	}
+	if !permitido {
+		r.Espera = time.Duration((1 - fichas) / ritmo * float64(time.Second))
+	}
+	return r
+}
+
+// Almacen guarda los baldes; hay una implementación por cada lugar donde se guardan
+type Almacen interface {
+	// Tomar recarga el balde de la clave según el tiempo pasado y, si tiene una ficha, la gasta
+	Tomar(ctx context.Context, clave string, tasa Tasa) (Resultado, error)
+}
+
+// Clave elige de quién es el balde de un pedido
+type Clave func(c *gin.Context) string
+
+// PorIP usa la IP del cliente (ver proxies_confiables en la configuración)
+func PorIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// PorUsuario usa el ID del usuario del token; tiene que ir después de
+// auth.RequiereAutenticacion. Sin usuario autenticado usa la IP.
+func PorUsuario(c *gin.Context) string {
+	if id := c.GetString("id_usuario"); id != "" {
+		return "usuario:" + id
+	}
+	return PorIP(c)
+}
+
+// PorClaveAPI usa el encabezado X-API-Key si es una de las claves dadas (su hash, para
+// no guardar la clave) y, si no viene o no es ninguna de ellas, la IP. Así una clave
+// inventada no consigue un balde propio.
+func PorClaveAPI(claves []string) Clave {
+	validas := make(map[string]bool, len(claves))
+	for _, clave := range claves {
+		validas[hashClaveAPI(clave)] = true
+	}
+	return func(c *gin.Context) string {
+		if clave := c.GetHeader("X-API-Key"); clave != "" {
+			if hash := hashClaveAPI(clave); validas[hash] {
+				return "api:" + hash
+			}
+		}
+		return PorIP(c)
+	}
+}
+
+// hashClaveAPI identifica una clave de API sin guardarla
+func hashClaveAPI(clave string) string {
+	suma := sha256.Sum256([]byte(clave))
+	return hex.EncodeToString(suma[:16])
+}
+
+// ClavePorNombre devuelve la Clave que corresponde al nombre usado en la configuración.
+// clavesAPI son las claves que se aceptan en X-API-Key con "clave_api".
+func ClavePorNombre(nombre string, clavesAPI []string) (Clave, error) {
+	switch nombre {
+	case "ip":
+		return PorIP, nil
+	case "usuario":
+		return PorUsuario, nil
+	case "clave_api":
+		return PorClaveAPI(clavesAPI), nil
+	}
+	return nil, fmt.Errorf("clave de límite inválida %q (usar ip, usuario o clave_api)", nombre)
+}
+
+// Middleware limita los pedidos de un grupo de rutas. El nombre separa los baldes de
+// cada grupo, así la misma IP tiene uno para el registro y otro para el login. Si la
+// tasa no está activa deja pasar todo. Si el almacén falla (por ejemplo, Redis caído)
+// también deja pasar el pedido: es preferible a dejar el servicio sin responder.
+func Middleware(almacen Almacen, nombre string, tasa Tasa, clave Clave) gin.HandlerFunc {
+	if !tasa.Activa() {
+		return func(c *gin.Context) { c.Next() }
+	}
+	politica := fmt.Sprintf("%d;w=%d", tasa.Cantidad, int(math.Ceil(tasa.Periodo.Seconds())))
+
+	return func(c *gin.Context) {
+		r, err := almacen.Tomar(c.Request.Context(), nombre+":"+clave(c), tasa)
+		if err != nil {
+			log.Printf("Error al consultar el límite %q: %v", nombre, err)
+			c.Next()
+			return
+		}
+
+		// Encabezados del borrador de IETF "RateLimit header fields for HTTP"
+		c.Header("RateLimit-Policy", politica)
+		c.Header("RateLimit-Limit", strconv.Itoa(tasa.Cantidad))
+		c.Header("RateLimit-Remaining", strconv.Itoa(r.Restantes))
+		c.Header("RateLimit-Reset", strconv.Itoa(segundos(r.Reinicio)))
+		if !r.Permitido {
+			c.Header("Retry-After", strconv.Itoa(max(segundos(r.Espera), 1)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Demasiados pedidos, intente de nuevo más tarde"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// segundos redondea hacia arriba, para que quien espere lo indicado encuentre la ficha
+func segundos(duracion time.Duration) int {
+	return int(math.Ceil(duracion.Seconds()))
+}
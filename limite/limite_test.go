@@ -0,0 +1,238 @@
+package limite
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsearTasa(t *testing.T) {
+	casos := []struct {
+		texto  string
+		tasa   Tasa
+		valida bool
+	}{
+		{"5/1h", Tasa{Cantidad: 5, Periodo: time.Hour}, true},
+		{" 100/1m ", Tasa{Cantidad: 100, Periodo: time.Minute}, true},
+		{"3/1m30s", Tasa{Cantidad: 3, Periodo: 90 * time.Second}, true},
+		{"", Tasa{}, true},
+		{"0", Tasa{}, true},
+		{"5", Tasa{}, false},
+		{"5/", Tasa{}, false},
+		{"/1m", Tasa{}, false},
+		{"0/1m", Tasa{}, false},
+		{"-1/1m", Tasa{}, false},
+		{"cinco/1m", Tasa{}, false},
+		{"5/una hora", Tasa{}, false},
+		{"5/0s", Tasa{}, false},
+		{"5/-1m", Tasa{}, false},
+	}
+	for _, caso := range casos {
+		tasa, err := ParsearTasa(caso.texto)
+		if (err == nil) != caso.valida {
+			t.Errorf("%q: error %v, se esperaba válida=%v", caso.texto, err, caso.valida)
+			continue
+		}
+		if tasa != caso.tasa {
+			t.Errorf("%q: se obtuvo %+v, se esperaba %+v", caso.texto, tasa, caso.tasa)
+		}
+	}
+}
+
+func TestTasaString(t *testing.T) {
+	for _, texto := range []string{"5/1h0m0s", "100/1m0s", "0"} {
+		tasa, err := ParsearTasa(texto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tasa.String() != texto {
+			t.Errorf("se obtuvo %q, se esperaba %q", tasa.String(), texto)
+		}
+	}
+}
+
+func TestAlmacenMemoriaGastaYRecarga(t *testing.T) {
+	almacen := NuevoAlmacenMemoria()
+	// Dos fichas que se recargan en 200ms, una cada 100ms
+	tasa := Tasa{Cantidad: 2, Periodo: 200 * time.Millisecond}
+	tomar := func() Resultado {
+		t.Helper()
+		r, err := almacen.Tomar(context.Background(), "prueba", tasa)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	// Un balde nuevo empieza lleno
+	if r := tomar(); !r.Permitido || r.Restantes != 1 {
+		t.Fatalf("el primer pedido dio %+v", r)
+	}
+	if r := tomar(); !r.Permitido || r.Restantes != 0 {
+		t.Fatalf("el segundo pedido dio %+v", r)
+	}
+	r := tomar()
+	if r.Permitido {
+		t.Fatal("con el balde vacío se permitió el pedido")
+	}
+	if r.Espera <= 0 || r.Espera > 100*time.Millisecond {
+		t.Errorf("la espera es %s, se esperaba hasta 100ms", r.Espera)
+	}
+	if r.Reinicio <= r.Espera || r.Reinicio > 200*time.Millisecond {
+		t.Errorf("el reinicio es %s, se esperaba entre la espera y 200ms", r.Reinicio)
+	}
+
+	// Otra clave tiene su propio balde
+	if otro, _ := almacen.Tomar(context.Background(), "otra", tasa); !otro.Permitido {
+		t.Error("otra clave no tiene fichas")
+	}
+
+	time.Sleep(r.Espera + 10*time.Millisecond)
+	if r := tomar(); !r.Permitido {
+		t.Errorf("después de la espera no se recargó una ficha: %+v", r)
+	}
+	// La recarga no pasa de la capacidad
+	time.Sleep(300 * time.Millisecond)
+	if r := tomar(); !r.Permitido || r.Restantes != 1 {
+		t.Errorf("con el balde lleno quedaron %d fichas, se esperaba 1", r.Restantes)
+	}
+}
+
+// almacenFalla simula un almacén caído
+type almacenFalla struct{}
+
+func (almacenFalla) Tomar(context.Context, string, Tasa) (Resultado, error) {
+	return Resultado{}, context.DeadlineExceeded
+}
+
+// servidorPrueba limita GET /recurso con la tasa y la clave indicadas
+func servidorPrueba(almacen Almacen, tasa Tasa, clave Clave) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	servidor := gin.New()
+	servidor.GET("/recurso", Middleware(almacen, "prueba", tasa, clave), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return servidor
+}
+
+// pedir hace GET /recurso desde la IP con los encabezados indicados
+func pedir(servidor *gin.Engine, ip string, encabezados map[string]string) *httptest.ResponseRecorder {
+	pedido := httptest.NewRequest(http.MethodGet, "/recurso", nil)
+	pedido.RemoteAddr = ip + ":1234"
+	for nombre, valor := range encabezados {
+		pedido.Header.Set(nombre, valor)
+	}
+	respuesta := httptest.NewRecorder()
+	servidor.ServeHTTP(respuesta, pedido)
+	return respuesta
+}
+
+func TestMiddlewareEncabezados(t *testing.T) {
+	servidor := servidorPrueba(NuevoAlmacenMemoria(), Tasa{Cantidad: 2, Periodo: time.Minute}, PorIP)
+
+	casos := []struct {
+		estado    int
+		restantes string
+	}{
+		{http.StatusOK, "1"},
+		{http.StatusOK, "0"},
+		{http.StatusTooManyRequests, "0"},
+	}
+	for i, caso := range casos {
+		respuesta := pedir(servidor, "192.0.2.1", nil)
+		if respuesta.Code != caso.estado {
+			t.Fatalf("el pedido %d devolvió %d, se esperaba %d", i+1, respuesta.Code, caso.estado)
+		}
+		encabezados := map[string]string{
+			"RateLimit-Policy":    "2;w=60",
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": caso.restantes,
+		}
+		for nombre, esperado := range encabezados {
+			if valor := respuesta.Header().Get(nombre); valor != esperado {
+				t.Errorf("pedido %d: %s es %q, se esperaba %q", i+1, nombre, valor, esperado)
+			}
+		}
+		// Con una ficha cada 30s, el balde se llena a lo sumo en 60s
+		if reinicio, err := strconv.Atoi(respuesta.Header().Get("RateLimit-Reset")); err != nil || reinicio < 1 || reinicio > 60 {
+			t.Errorf("pedido %d: RateLimit-Reset es %q", i+1, respuesta.Header().Get("RateLimit-Reset"))
+		}
+		espera := respuesta.Header().Get("Retry-After")
+		if caso.estado == http.StatusOK && espera != "" {
+			t.Errorf("pedido %d: un pedido permitido tiene Retry-After %q", i+1, espera)
+		}
+		if caso.estado == http.StatusTooManyRequests {
+			if segundos, err := strconv.Atoi(espera); err != nil || segundos < 1 || segundos > 30 {
+				t.Errorf("Retry-After es %q, se esperaba entre 1 y 30", espera)
+			}
+		}
+	}
+
+	// Otra IP tiene su propio balde
+	if respuesta := pedir(servidor, "192.0.2.2", nil); respuesta.Code != http.StatusOK {
+		t.Errorf("otra IP devolvió %d", respuesta.Code)
+	}
+}
+
+func TestMiddlewareSinLimiteOAlmacenCaido(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		almacen Almacen
+		tasa    Tasa
+	}{
+		{"tasa inactiva", NuevoAlmacenMemoria(), Tasa{}},
+		{"almacén caído", almacenFalla{}, Tasa{Cantidad: 1, Periodo: time.Minute}},
+	}
+	for _, caso := range casos {
+		servidor := servidorPrueba(caso.almacen, caso.tasa, PorIP)
+		for i := 0; i < 3; i++ {
+			respuesta := pedir(servidor, "192.0.2.1", nil)
+			if respuesta.Code != http.StatusOK || respuesta.Header().Get("RateLimit-Limit") != "" {
+				t.Errorf("%s: el pedido %d devolvió %d con RateLimit-Limit %q", caso.nombre, i+1, respuesta.Code, respuesta.Header().Get("RateLimit-Limit"))
+			}
+		}
+	}
+}
+
+func TestPorClaveAPI(t *testing.T) {
+	clave, err := ClavePorNombre("clave_api", []string{"clave-buena", "otra-clave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	servidor := servidorPrueba(NuevoAlmacenMemoria(), Tasa{Cantidad: 1, Periodo: time.Minute}, clave)
+
+	// Sin clave, la IP gasta su ficha
+	if respuesta := pedir(servidor, "192.0.2.1", nil); respuesta.Code != http.StatusOK {
+		t.Fatalf("el primer pedido devolvió %d", respuesta.Code)
+	}
+	// Una clave inventada cuenta por la IP y no consigue un balde nuevo
+	if respuesta := pedir(servidor, "192.0.2.1", map[string]string{"X-API-Key": "inventada"}); respuesta.Code != http.StatusTooManyRequests {
+		t.Errorf("con una clave inventada se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+	}
+	// Una clave configurada tiene su propio balde, desde cualquier IP
+	if respuesta := pedir(servidor, "192.0.2.1", map[string]string{"X-API-Key": "clave-buena"}); respuesta.Code != http.StatusOK {
+		t.Errorf("con una clave configurada se obtuvo %d", respuesta.Code)
+	}
+	if respuesta := pedir(servidor, "192.0.2.2", map[string]string{"X-API-Key": "clave-buena"}); respuesta.Code != http.StatusTooManyRequests {
+		t.Errorf("la misma clave desde otra IP devolvió %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+	}
+	if respuesta := pedir(servidor, "192.0.2.2", map[string]string{"X-API-Key": "otra-clave"}); respuesta.Code != http.StatusOK {
+		t.Errorf("con la otra clave configurada se obtuvo %d", respuesta.Code)
+	}
+}
+
+func TestClavePorNombre(t *testing.T) {
+	for _, nombre := range []string{"ip", "usuario", "clave_api"} {
+		if _, err := ClavePorNombre(nombre, nil); err != nil {
+			t.Errorf("%q: %v", nombre, err)
+		}
+	}
+	if _, err := ClavePorNombre("correo", nil); err == nil {
+		t.Error("se aceptó una clave de límite desconocida")
+	}
+}
@@ -0,0 +1,68 @@
+package limite
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cada cuánto se borran los baldes que ya se llenaron (y por lo tanto no hace falta recordar)
+const intervaloLimpieza = time.Minute
+
+// balde son las fichas de una clave en el último pedido
+type balde struct {
+	fichas     float64
+	actualizo  time.Time
+	llenoDesde time.Time // A partir de este momento el balde está lleno y se puede olvidar
+}
+
+// AlmacenMemoria guarda los baldes en memoria. Con varias instancias cada una lleva
+// su propia cuenta, así que el límite real es la tasa por la cantidad de instancias.
+type AlmacenMemoria struct {
+	mu             sync.Mutex
+	baldes         map[string]*balde
+	ultimaLimpieza time.Time
+}
+
+// NuevoAlmacenMemoria crea un almacén en memoria vacío
+func NuevoAlmacenMemoria() *AlmacenMemoria {
+	return &AlmacenMemoria{baldes: make(map[string]*balde), ultimaLimpieza: time.Now()}
+}
+
+// Tomar recarga y gasta la ficha bajo el lock
+func (a *AlmacenMemoria) Tomar(_ context.Context, clave string, tasa Tasa) (Resultado, error) {
+	ahora := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.limpiar(ahora)
+	b, existe := a.baldes[clave]
+	if !existe {
+		// Un balde nuevo empieza lleno
+		b = &balde{fichas: float64(tasa.Cantidad), actualizo: ahora}
+		a.baldes[clave] = b
+	}
+	b.fichas = min(float64(tasa.Cantidad), b.fichas+ahora.Sub(b.actualizo).Seconds()*tasa.porSegundo())
+	b.actualizo = ahora
+
+	permitido := b.fichas >= 1
+	if permitido {
+		b.fichas--
+	}
+	r := resultado(tasa, permitido, b.fichas)
+	b.llenoDesde = ahora.Add(r.Reinicio)
+	return r, nil
+}
+
+// limpiar borra los baldes llenos, como mucho una vez por intervaloLimpieza. Se llama con el mutex tomado.
+func (a *AlmacenMemoria) limpiar(ahora time.Time) {
+	if ahora.Sub(a.ultimaLimpieza) < intervaloLimpieza {
+		return
+	}
+	a.ultimaLimpieza = ahora
+	for clave, b := range a.baldes {
+		if !ahora.Before(b.llenoDesde) {
+			delete(a.baldes, clave)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package limite
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefijo de las claves que se guardan en Redis, por si la base se comparte con otros servicios
+const prefijoRedis = "taller6:limite:"
+
+// Tiempo máximo para conectar al arrancar y para cada consulta; si Redis tarda más,
+// el pedido pasa sin límite en lugar de quedar esperando
+const timeoutRedis = time.Second
+
+// scriptBalde recarga y gasta la ficha en Redis, todo en un paso para que dos instancias
+// no se pisen. Usa la hora de Redis (TIME) y no la de cada instancia, que puede diferir.
+// Las fichas van como texto porque Redis convierte los números de Lua a enteros.
+var scriptBalde = redis.NewScript(`
+local capacidad = tonumber(ARGV[1])
+local ritmo = tonumber(ARGV[2])
+local hora = redis.call('TIME')
+local ahora = tonumber(hora[1]) + tonumber(hora[2]) / 1000000
+
+local balde = redis.call('HMGET', KEYS[1], 'fichas', 'actualizo')
+local fichas = capacidad
+if balde[1] then
+  fichas = math.min(capacidad, tonumber(balde[1]) + math.max(0, ahora - tonumber(balde[2])) * ritmo)
+end
+local permitido = 0
+if fichas >= 1 then
+  fichas = fichas - 1
+  permitido = 1
+end
+
+redis.call('HSET', KEYS[1], 'fichas', tostring(fichas), 'actualizo', tostring(ahora))
+-- Cuando el balde vuelve a estar lleno ya no hace falta recordarlo
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacidad - fichas) / ritmo * 1000) + 1000)
+return {permitido, tostring(fichas)}
+`)
+
+// AlmacenRedis guarda los baldes en Redis (5 o posterior, o un servidor compatible),
+// así todas las instancias comparten los mismos límites
+type AlmacenRedis struct {
+	cliente *redis.Client
+}
+
+// NuevoAlmacenRedis se conecta a una URL como redis://:clave@localhost:6379/0 o
+// rediss://... (con TLS). Falla si el servidor no responde.
+func NuevoAlmacenRedis(direccion string) (*AlmacenRedis, error) {
+	opciones, err := redis.ParseURL(direccion)
+	if err != nil {
+		return nil, fmt.Errorf("url de Redis inválida: %w", err)
+	}
+	cliente := redis.NewClient(opciones)
+
+	ctx, cancelar := context.WithTimeout(context.Background(), timeoutRedis)
+	defer cancelar()
+	if err := cliente.Ping(ctx).Err(); err != nil {
+		cliente.Close()
+		return nil, fmt.Errorf("no se pudo conectar a Redis: %w", err)
+	}
+	return &AlmacenRedis{cliente: cliente}, nil
+}
+
+// Tomar ejecuta el script del balde
+func (a *AlmacenRedis) Tomar(ctx context.Context, clave string, tasa Tasa) (Resultado, error) {
+	ctx, cancelar := context.WithTimeout(ctx, timeoutRedis)
+	defer cancelar()
+
+	valores, err := scriptBalde.Run(ctx, a.cliente, []string{prefijoRedis + clave}, tasa.Cantidad, tasa.porSegundo()).Slice()
+	if err != nil {
+		return Resultado{}, err
+	}
+	if len(valores) != 2 {
+		return Resultado{}, fmt.Errorf("respuesta inesperada de Redis: %v", valores)
+	}
+	permitido, ok := valores[0].(int64)
+	texto, okTexto := valores[1].(string)
+	if !ok || !okTexto {
+		return Resultado{}, fmt.Errorf("respuesta inesperada de Redis: %v", valores)
+	}
+	fichas, err := strconv.ParseFloat(texto, 64)
+	if err != nil {
+		return Resultado{}, fmt.Errorf("respuesta inesperada de Redis: %w", err)
+	}
+	return resultado(tasa, permitido == 1, fichas), nil
+}
+
+// Cerrar cierra las conexiones con Redis
+func (a *AlmacenRedis) Cerrar() error {
+	return a.cliente.Close()
+}
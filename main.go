@@ -1,50 +1,313 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"taller6/auth"
 	"taller6/base_datos"
+	"taller6/configuracion"
+	"taller6/contrasenas"
+	"taller6/correo"
+	"taller6/limite"
 	"taller6/manejadores"
 	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Parámetros de la cola de correos y del cierre ordenado
+const (
+	trabajadoresCorreo    = 2
+	capacidadColaCorreo   = 100
+	reintentosCorreo      = 5
+	esperaReintentoCorreo = 30 * time.Second // Se duplica en cada reintento: 30s, 1m, 2m, 4m y 8m
+	limiteCierre          = 10 * time.Second // Cuánto se espera a los pedidos y correos pendientes al cerrar
 )
 
 func main() {
-	// Tratativas con la base de datos
-	base_datos.ConectarBD()
-	defer base_datos.CerrarBD() // Aseguramos que la base de datos se cierre solo cuando el programa termine
+	// Cargamos la configuración desde el archivo (opcional) y las variables TALLER6_*
+	rutaConfig := flag.String("config", os.Getenv("TALLER6_CONFIG"), "archivo de configuración .yaml o .toml (opcional)")
+	flag.Parse()
+
+	config, err := configuracion.Cargar(*rutaConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.Configurar(config.ClaveJWT, config.DuracionToken)
+
+	// "taller6 migrar ..." solo ejecuta las migraciones y termina
+	if flag.Arg(0) == "migrar" {
+		if config.Almacenamiento != "sql" {
+			log.Fatal("Las migraciones solo aplican al almacenamiento sql")
+		}
+		base_datos.ConectarBD(config.DSN)
+		defer base_datos.CerrarBD()
+		ejecutarComandoMigrar(base_datos.BD, flag.Args()[1:])
+		return
+	}
+
+	// Con EdDSA o RS256 los tokens se firman con las claves del directorio, que rotan solas
+	if config.AlgoritmoJWT != auth.AlgoritmoHS256 {
+		claves, err := auth.CargarClaves(config.DirectorioClaves, config.AlgoritmoJWT, config.RotacionClaves)
+		if err != nil {
+			log.Fatal(err)
+		}
+		auth.ConfigurarClaves(claves)
+		claves.IniciarRotacion()
+	}
+
+	var repo repositorio.Repositorio
+	switch config.Almacenamiento {
+	case "sql":
+		// Tratativas con la base de datos (el esquema del dsn elige MySQL, SQLite o PostgreSQL)
+		base_datos.ConectarBD(config.DSN)
+		defer base_datos.CerrarBD() // Aseguramos que la base de datos se cierre solo cuando el programa termine
+
+		// Aplicamos las migraciones pendientes (crea la tabla "usuarios" si no existe)
+		if config.MigrarAlIniciar {
+			migrarAlIniciar(base_datos.BD)
+		}
+		switch base_datos.Motor {
+		case base_datos.MotorSQLite:
+			repo = repositorio.NuevoUsuarioRepositorioSQLite(base_datos.BD)
+		case base_datos.MotorPostgres:
+			repo = repositorio.NuevoUsuarioRepositorioPostgres(base_datos.BD)
+		default:
+			repo = repositorio.NuevoUsuarioRepositorioMySQL(base_datos.BD)
+		}
+	case "memoria":
+		// Sin base de datos: los usuarios se pierden al cerrar el servidor
+		log.Println("Usando almacenamiento en memoria")
+		repo = repositorio.NuevoUsuarioRepositorioMemoria()
+	}
+
+	// Los correos salen por una cola que reintenta, así un servidor SMTP lento no frena los pedidos
+	var enviador correo.Enviador
+	switch config.EnvioCorreo {
+	case "smtp":
+		enviador, err = correo.NuevoEnviadorSMTP(config.SMTPURL, config.RemitenteCorreo)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "directorio":
+		enviador = correo.EnviadorDirectorio{Directorio: config.RutaCorreo, Remitente: config.RemitenteCorreo}
+	case "mbox":
+		enviador = &correo.EnviadorMbox{Ruta: config.RutaCorreo, Remitente: config.RemitenteCorreo}
+	default:
+		enviador = correo.EnviadorLog{}
+	}
+	colaCorreos := correo.NuevaCola(enviador, trabajadoresCorreo, capacidadColaCorreo, reintentosCorreo, esperaReintentoCorreo)
+
+	// Llaves de acceso (WebAuthn), solo si se configuró el dominio
+	var relyingParty *webauthn.WebAuthn
+	if config.DominioWebAuthn != "" {
+		ceremonia := webauthn.TimeoutConfig{Enforce: true, Timeout: manejadores.DuracionCeremoniaWebAuthn, TimeoutUVD: manejadores.DuracionCeremoniaWebAuthn}
+		relyingParty, err = webauthn.New(&webauthn.Config{
+			RPID:                  config.DominioWebAuthn,
+			RPDisplayName:         config.EmisorTOTP,
+			RPOrigins:             config.OrigenesWebAuthnEfectivos(),
+			AttestationPreference: protocol.PreferNoAttestation,
+			Timeouts:              webauthn.TimeoutsConfig{Login: ceremonia, Registration: ceremonia},
+		})
+		if err != nil {
+			log.Fatal("Error al configurar WebAuthn: ", err)
+		}
+	}
+
+	// Las contraseñas nuevas se encriptan con el algoritmo configurado; las guardadas con
+	// otro algoritmo o costo se vuelven a encriptar cuando el usuario inicia sesión
+	hasheador := contrasenas.Hasheador{
+		Algoritmo: config.AlgoritmoContrasenas,
+		Argon2: contrasenas.ParametrosArgon2{
+			Memoria:     uint32(config.Argon2Memoria),
+			Iteraciones: uint32(config.Argon2Iteraciones),
+			Paralelismo: uint8(config.Argon2Paralelismo),
+		},
+		CostoBcrypt: config.CostoBcrypt,
+	}
+
+	// Política para las contraseñas nuevas y, si se configuró, la lista de filtradas
+	politica := contrasenas.Politica{
+		LargoMinimo:   config.ContrasenaLargoMinimo,
+		LargoMaximo:   config.ContrasenaLargoMaximo,
+		ClasesMinimas: config.ContrasenaClasesMinimas,
+	}
+	if config.ContrasenasFiltradas != "" {
+		politica.Filtradas, err = contrasenas.NuevaFiltradas(config.ContrasenasFiltradas)
+		if err != nil {
+			log.Fatal("Error al abrir las contraseñas filtradas: ", err)
+		}
+	}
+
+	// Manejadores del CRUD de usuarios sobre el repositorio elegido
+	usuarios := manejadores.NuevoManejadorUsuarios(repo, manejadores.Opciones{
+		Hasheador:        hasheador,
+		DuracionRefresco: config.DuracionRefresco,
+		EmisorOIDC:       config.EmisorOIDC,
+
+		DuracionRestablecimiento: config.DuracionRestablecimiento,
+		URLRestablecimiento:      config.URLRestablecimiento,
+		DuracionVerificacion:     config.DuracionVerificacion,
+		URLVerificacion:          config.URLVerificacion,
+		RequiereCorreoVerificado: config.RequiereCorreoVerificado,
+		Enviador:                 colaCorreos,
+		EmisorTOTP:               config.EmisorTOTP,
+		WebAuthn:                 relyingParty,
+		MaxIntentosLogin:         config.MaxIntentosLogin,
+		MaxIntentosLoginIP:       config.MaxIntentosLoginIP,
+		DuracionBloqueoLogin:     config.DuracionBloqueoLogin,
+		PoliticaContrasena:       politica,
+		HistorialContrasenas:     config.ContrasenaHistorial,
+		RetencionEliminados:      config.RetencionEliminados,
+	})
+	// Borra de verdad los usuarios eliminados hace más de la retención
+	usuarios.IniciarPurgaEliminados()
+
+	// Los tokens revocados (logout) se consultan en el repositorio, con un cache en memoria
+	auth.ConfigurarRevocaciones(repo, config.CacheRevocacion)
 
-	// Creamos la tabla "usuarios" si no existe
-	base_datos.CrearTabla(modelos.UsuariosSchema, "usuarios")
 	// Creamos el usuario "admin" si no existe
-	base_datos.CrearUsuarioAdmin()
+	repositorio.CrearUsuarioAdmin(repo, config.ContrasenaAdmin, hasheador)
 
 	// Creamos la instancia del servidor de Gin
 	servidor := gin.Default()
+	// La IP del cliente (para los intentos de inicio de sesión) sale de X-Forwarded-For
+	// solo si la conexión viene de un proxy confiable; si no, cualquiera podría inventarla
+	if err := servidor.SetTrustedProxies(config.ProxiesConfiables); err != nil {
+		log.Fatal("Error al configurar los proxies confiables: ", err)
+	}
 
 	// Aplicar el middleware de CORS a todas las rutas
-	servidor.Use(auth.CORSMiddleware())
+	servidor.Use(auth.CORSMiddleware(config.OrigenesCORS))
+
+	// Límites de pedidos por grupo de rutas; los baldes se cuentan en memoria o en Redis
+	var almacenLimites limite.Almacen = limite.NuevoAlmacenMemoria()
+	if config.AlmacenLimites == "redis" {
+		almacenRedis, err := limite.NuevoAlmacenRedis(config.RedisURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer almacenRedis.Cerrar()
+		almacenLimites = almacenRedis
+	}
+	// Los nombres ya los revisó config.Validar
+	clavePublica, _ := limite.ClavePorNombre(config.ClaveLimitePublico, config.ClavesAPI)
+	claveAPI, _ := limite.ClavePorNombre(config.ClaveLimiteAPI, config.ClavesAPI)
+	limiteRegistro := limite.Middleware(almacenLimites, "registro", config.LimiteRegistro, clavePublica)
+	limiteAPI := limite.Middleware(almacenLimites, "api", config.LimiteAPI, claveAPI)
 
 	// Definimos las rutas para el CRUD de usuarios
-	servidor.POST("/usuarios", manejadores.CrearUsuario) // Ruta pública para crear usuario (sin autenticación)
-	servidor.POST("/login", manejadores.Login)           // Ruta pública para login (sin autenticación)
-	servidor.GET("/usuarios", manejadores.ObtenerUsuarios)
+	servidor.POST("/usuarios", limiteRegistro, usuarios.CrearUsuario) // Ruta pública para crear usuario (sin autenticación)
+	servidor.GET("/.well-known/jwks.json", manejadores.ObtenerJWKS)   // Claves públicas para validar los tokens
+	servidor.GET("/verificar", usuarios.VerificarCorreo)              // Enlace del correo de verificación
 
-	// Grupo de rutas protegidas por el middleware de autenticación
+	// Rutas públicas que prueban credenciales o envían correos; comparten el límite de login
+	rutasLogin := servidor.Group("/", limite.Middleware(almacenLimites, "login", config.LimiteLogin, clavePublica))
+	rutasLogin.POST("/login", usuarios.Login)                  // Ruta pública para login (sin autenticación)
+	rutasLogin.POST("/login/2fa", usuarios.LoginSegundoFactor) // Cambia el token_mfa y el código por los tokens
+	rutasLogin.POST("/token/refresh", usuarios.RefrescarToken) // Rota el token de refresco por uno nuevo
+	rutasLogin.POST("/password/olvido", usuarios.OlvidoContrasena)
+	rutasLogin.POST("/password/restablecer", usuarios.RestablecerContrasena)
+	rutasLogin.POST("/verificar/reenviar", usuarios.ReenviarVerificacion) // Vuelve a enviar el enlace
+
+	// Proveedor OpenID Connect (código de autorización con PKCE) para el inicio de sesión único
+	if config.EmisorOIDC != "" {
+		servidor.GET("/.well-known/openid-configuration", usuarios.ConfiguracionOIDC)
+		servidor.GET("/authorize", usuarios.Autorizar)
+		rutasLogin.POST("/authorize", usuarios.AutorizarLogin)
+		rutasLogin.POST("/token", usuarios.Token)
+		servidor.GET("/userinfo", auth.RequiereAutenticacionUserInfo(), limiteAPI, usuarios.UserInfo)
+	}
+	// Login con llave de acceso, si se configuró dominio_webauthn
+	if relyingParty != nil {
+		rutasLogin.POST("/login/webauthn", usuarios.IniciarLoginWebAuthn)
+		rutasLogin.POST("/login/webauthn/completar", usuarios.CompletarLoginWebAuthn)
+	}
+
+	// Grupo de rutas protegidas por el middleware de autenticación. El límite va después,
+	// así puede contar los pedidos por usuario.
 	rutasProtegidas := servidor.Group("/")
-	rutasProtegidas.Use(auth.RequiereAutenticacion()) // Aplica el middleware solo a estas rutas
+	rutasProtegidas.Use(auth.RequiereAutenticacion(), limiteAPI) // Aplica los middlewares solo a estas rutas
 	{
 		// Ruta para obtener y actualizar el propio perfil
-		rutasProtegidas.GET("/me", manejadores.ObtenerUsuario)
-		rutasProtegidas.PATCH("/me", manejadores.ActualizarUsuario)
-		// Rutas solo accesibles por admin
-		rutasProtegidas.GET("/usuarios/:id", manejadores.ObtenerUsuario)
-		rutasProtegidas.PATCH("/usuarios/:id", manejadores.ActualizarUsuario)
-		rutasProtegidas.DELETE("/usuarios/:id", manejadores.EliminarUsuario)
-		//rutasProtegidas.GET("/usuarios", manejadores.ObtenerUsuarios)
+		rutasProtegidas.GET("/me", usuarios.ObtenerUsuario)
+		rutasProtegidas.PATCH("/me", usuarios.ActualizarUsuario)
+		// Cierra la sesión revocando el token con el que se llama
+		rutasProtegidas.POST("/logout", usuarios.Logout)
+		// Segundo factor (TOTP) del propio usuario
+		rutasProtegidas.GET("/me/2fa", usuarios.ObtenerSegundoFactor)
+		rutasProtegidas.POST("/me/2fa", usuarios.InscribirSegundoFactor)
+		rutasProtegidas.POST("/me/2fa/confirmar", usuarios.ConfirmarSegundoFactor)
+		rutasProtegidas.POST("/me/2fa/codigos-recuperacion", usuarios.RegenerarCodigosRecuperacion)
+		rutasProtegidas.DELETE("/me/2fa", usuarios.DesactivarSegundoFactor)
+		// Llaves de acceso (WebAuthn) del propio usuario
+		if relyingParty != nil {
+			rutasProtegidas.GET("/me/webauthn", usuarios.ListarCredencialesWebAuthn)
+			rutasProtegidas.POST("/me/webauthn/registro", usuarios.IniciarRegistroWebAuthn)
+			rutasProtegidas.POST("/me/webauthn/registro/completar", usuarios.CompletarRegistroWebAuthn)
+			rutasProtegidas.DELETE("/me/webauthn/:id", usuarios.EliminarCredencialWebAuthn)
+		}
+		// Listado de usuarios: sin el permiso usuarios:leer solo se ven los datos públicos
+		rutasProtegidas.GET("/usuarios", usuarios.ObtenerUsuarios)
+		// Búsqueda por partes del nombre o del correo, para soporte
+		rutasProtegidas.GET("/usuarios/buscar", auth.RequierePermiso(modelos.PermisoUsuariosLeer), usuarios.BuscarUsuarios)
+		// Rutas sobre otros usuarios, cada una exige su permiso
+		registrarRutasUsuarios(rutasProtegidas, usuarios)
+		// Administración de roles
+		rutasProtegidas.GET("/roles", auth.RequierePermiso(modelos.PermisoRolesAdministrar), usuarios.ListarRoles)
+		rutasProtegidas.PUT("/usuarios/:id/roles", auth.RequierePermiso(modelos.PermisoRolesAdministrar), usuarios.AsignarRoles)
+		// Cierra todas las sesiones de un usuario
+		rutasProtegidas.POST("/usuarios/:id/revocar-tokens", auth.RequierePermiso(modelos.PermisoSesionesRevocar), usuarios.RevocarTokensDeUsuario)
+		// Desactiva el segundo factor de quien perdió el teléfono y los códigos de recuperación
+		rutasProtegidas.DELETE("/usuarios/:id/2fa", auth.RequierePermiso(modelos.PermisoUsuariosEscribir), usuarios.EliminarSegundoFactorDeUsuario)
+		// Levanta el bloqueo por inicios de sesión fallidos
+		rutasProtegidas.DELETE("/usuarios/:id/bloqueo", auth.RequierePermiso(modelos.PermisoUsuariosEscribir), usuarios.DesbloquearUsuario)
+		// Registro de decisiones de autorización
+		rutasProtegidas.GET("/autorizacion/decisiones", auth.RequierePermiso(modelos.PermisoRolesAdministrar), manejadores.ObtenerDecisiones)
+		// Clientes de OpenID Connect
+		rutasProtegidas.GET("/oidc/clientes", auth.RequierePermiso(modelos.PermisoClientesAdministrar), usuarios.ListarClientesOIDC)
+		rutasProtegidas.POST("/oidc/clientes", auth.RequierePermiso(modelos.PermisoClientesAdministrar), usuarios.CrearClienteOIDC)
+		rutasProtegidas.DELETE("/oidc/clientes/:client_id", auth.RequierePermiso(modelos.PermisoClientesAdministrar), usuarios.EliminarClienteOIDC)
+	}
+
+	// Arrancamos el servidor en la dirección configurada (por defecto 0.0.0.0:8080)
+	servidorHTTP := &http.Server{Addr: config.Direccion, Handler: servidor}
+	go func() {
+		if err := servidorHTTP.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
+	// Al recibir Ctrl+C o SIGTERM terminamos los pedidos en curso y los correos pendientes
+	senal, parar := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-senal.Done()
+	parar()
+	log.Println("Cerrando el servidor...")
+	contexto, cancelar := context.WithTimeout(context.Background(), limiteCierre)
+	defer cancelar()
+	if err := servidorHTTP.Shutdown(contexto); err != nil {
+		log.Println("Error al cerrar el servidor:", err)
 	}
+	if !colaCorreos.Cerrar(limiteCierre) {
+		log.Println("Quedaron correos sin enviar")
+	}
+}
 
-	// Arrancamos el servidor en el puerto 8080
-	servidor.Run("0.0.0.0:8080")
+// registrarRutasUsuarios agrega las rutas para leer, modificar, eliminar y restaurar a
+// otros usuarios. Van en un grupo que ya exige autenticación; cada una exige su permiso.
+func registrarRutasUsuarios(rutas gin.IRoutes, usuarios *manejadores.ManejadorUsuarios) {
+	rutas.GET("/usuarios/:id", auth.RequierePermiso(modelos.PermisoUsuariosLeer), usuarios.ObtenerUsuario)
+	rutas.PATCH("/usuarios/:id", auth.RequierePermiso(modelos.PermisoUsuariosEscribir), usuarios.ActualizarUsuario)
+	rutas.DELETE("/usuarios/:id", auth.RequierePermiso(modelos.PermisoUsuariosEliminar), usuarios.EliminarUsuario)
+	rutas.POST("/usuarios/:id/restaurar", auth.RequierePermiso(modelos.PermisoUsuariosEliminar), usuarios.RestaurarUsuario)
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller6/auth"
+	"taller6/manejadores"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Quién llama en cada caso de TestPermisosRutasUsuarios
+type llamador struct {
+	nombre string
+	token  bool          // Sin token el pedido no pasa de RequiereAutenticacion
+	roles  []modelos.Rol // Los roles van en el token
+}
+
+// rolPorNombre devuelve el rol por defecto con ese nombre
+func rolPorNombre(t *testing.T, nombre string) modelos.Rol {
+	t.Helper()
+	for _, rol := range modelos.RolesPorDefecto() {
+		if rol.Nombre == nombre {
+			return rol
+		}
+	}
+	t.Fatalf("no existe el rol %q", nombre)
+	return modelos.Rol{}
+}
+
+// TestPermisosRutasUsuarios prueba cada ruta sobre otros usuarios con cada tipo de
+// usuario, y que cada decisión de RequierePermiso quede en auth.Decisiones
+func TestPermisosRutasUsuarios(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth.Configurar("clave-de-prueba", time.Minute)
+
+	sinToken := llamador{nombre: "sin token"}
+	comun := llamador{nombre: "usuario común", token: true}
+	soporte := llamador{nombre: "soporte", token: true, roles: []modelos.Rol{rolPorNombre(t, modelos.RolSoporte)}}
+	admin := llamador{nombre: "admin", token: true, roles: []modelos.Rol{rolPorNombre(t, modelos.RolAdmin)}}
+
+	rutas := []struct {
+		metodo    string
+		ruta      string // Con :id en el lugar del ID
+		cuerpo    string
+		eliminado bool // El usuario objetivo ya está eliminado (para restaurarlo)
+		esperados map[string]int
+	}{
+		{
+			metodo: http.MethodGet, ruta: "/usuarios/:id",
+			esperados: map[string]int{sinToken.nombre: http.StatusUnauthorized, comun.nombre: http.StatusForbidden, soporte.nombre: http.StatusOK, admin.nombre: http.StatusOK},
+		},
+		{
+			metodo: http.MethodPatch, ruta: "/usuarios/:id", cuerpo: `{"nombre_usuario": "renombrado"}`,
+			esperados: map[string]int{sinToken.nombre: http.StatusUnauthorized, comun.nombre: http.StatusForbidden, soporte.nombre: http.StatusForbidden, admin.nombre: http.StatusOK},
+		},
+		{
+			metodo: http.MethodDelete, ruta: "/usuarios/:id",
+			esperados: map[string]int{sinToken.nombre: http.StatusUnauthorized, comun.nombre: http.StatusForbidden, soporte.nombre: http.StatusForbidden, admin.nombre: http.StatusNoContent},
+		},
+		{
+			metodo: http.MethodPost, ruta: "/usuarios/:id/restaurar", eliminado: true,
+			esperados: map[string]int{sinToken.nombre: http.StatusUnauthorized, comun.nombre: http.StatusForbidden, soporte.nombre: http.StatusForbidden, admin.nombre: http.StatusOK},
+		},
+	}
+
+	for _, ruta := range rutas {
+		for _, quien := range []llamador{sinToken, comun, soporte, admin} {
+			t.Run(ruta.metodo+" "+ruta.ruta+" como "+quien.nombre, func(t *testing.T) {
+				// Cada caso tiene su repositorio, así eliminar no afecta al siguiente
+				repo := repositorio.NuevoUsuarioRepositorioMemoria()
+				objetivo := &modelos.Usuario{NombreUsuario: "objetivo", Correo: "objetivo@ejemplo.com", Contrasena: "hash"}
+				if err := repo.Crear(objetivo); err != nil {
+					t.Fatal(err)
+				}
+				if ruta.eliminado {
+					if err := repo.Eliminar(objetivo.ID); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				servidor := gin.New()
+				rutasProtegidas := servidor.Group("/", auth.RequiereAutenticacion())
+				registrarRutasUsuarios(rutasProtegidas, manejadores.NuevoManejadorUsuarios(repo, manejadores.Opciones{}))
+
+				url := strings.Replace(ruta.ruta, ":id", fmt.Sprint(objetivo.ID), 1)
+				pedido := httptest.NewRequest(ruta.metodo, url, strings.NewReader(ruta.cuerpo))
+				pedido.Header.Set("Content-Type", "application/json")
+				// El que llama es otro usuario, que no hace falta que exista en el repositorio
+				const idLlamador = 1000
+				if quien.token {
+					token, err := auth.GenerarToken(idLlamador, quien.roles)
+					if err != nil {
+						t.Fatal(err)
+					}
+					pedido.Header.Set("Authorization", "Bearer "+token)
+				}
+
+				antes := len(auth.Decisiones.Recientes())
+				respuesta := httptest.NewRecorder()
+				servidor.ServeHTTP(respuesta, pedido)
+
+				if esperado := ruta.esperados[quien.nombre]; respuesta.Code != esperado {
+					t.Fatalf("se obtuvo %d (%s), se esperaba %d", respuesta.Code, respuesta.Body, esperado)
+				}
+
+				decisiones := auth.Decisiones.Recientes()
+				if !quien.token {
+					// Sin token no se llega a verificar permisos
+					if len(decisiones) != antes {
+						t.Fatalf("se registraron %d decisiones sin token", len(decisiones)-antes)
+					}
+					return
+				}
+				if len(decisiones) != antes+1 {
+					t.Fatalf("se registraron %d decisiones, se esperaba 1", len(decisiones)-antes)
+				}
+				decision := decisiones[0]
+				permitido := respuesta.Code != http.StatusForbidden
+				if decision.UsuarioID != idLlamador || decision.Metodo != ruta.metodo || decision.Ruta != ruta.ruta || decision.Permitido != permitido {
+					t.Fatalf("decisión registrada %+v, se esperaba usuario %d, %s %s y permitido=%v", decision, idLlamador, ruta.metodo, ruta.ruta, permitido)
+				}
+				if !permitido && decision.Faltante == "" {
+					t.Fatal("la decisión denegada no indica el permiso que faltó")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package manejadores
+
+import (
+	"net/http"
+	"taller6/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ObtenerDecisiones devuelve las últimas decisiones de autorización, de la más nueva a la más vieja.
+// Con ?denegadas=true solo devuelve los accesos rechazados.
+func ObtenerDecisiones(c *gin.Context) {
+	decisiones := auth.Decisiones.Recientes()
+	if c.Query("denegadas") == "true" {
+		denegadas := make([]auth.Decision, 0, len(decisiones))
+		for _, decision := range decisiones {
+			if !decision.Permitido {
+				denegadas = append(denegadas, decision)
+			}
+		}
+		decisiones = denegadas
+	}
+	c.JSON(http.StatusOK, decisiones)
+}
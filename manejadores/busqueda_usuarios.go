@@ -0,0 +1,75 @@
+package manejadores
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"taller6/busqueda"
+	"taller6/modelos"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cantidad de resultados de GET /usuarios/buscar. No hay páginas: se piden los más
+// parecidos y, si no está, se afina la búsqueda.
+const (
+	limiteBusquedaPorDefecto = 20
+	limiteBusquedaMaximo     = 100
+)
+
+// UsuarioEncontrado es un resultado de GET /usuarios/buscar
+type UsuarioEncontrado struct {
+	Usuario modelos.UsuarioSinContrasena `json:"usuario"`
+	Puntaje float64                      `json:"puntaje"` // Solo sirve para comparar resultados de la misma búsqueda
+	// Resaltado tiene los campos en los que apareció algún término, con los fragmentos
+	// entre <mark> y </mark> y el resto escapado como HTML. Si la coincidencia fue
+	// aproximada (por ejemplo "perz" por "perez") no hay nada que resaltar.
+	Resaltado map[string]string `json:"resaltado,omitempty"`
+}
+
+// BuscarUsuarios busca usuarios por partes del nombre de usuario o del correo, sin
+// importar mayúsculas ni tildes. Parámetros:
+//   - q: las palabras a buscar; cada resultado coincide con todas
+//   - limit: cantidad de resultados (por defecto 20, como mucho 100)
+//
+// Los resultados van del más parecido al menos. Exige el permiso usuarios:leer, porque
+// busca en los correos.
+func (m *ManejadorUsuarios) BuscarUsuarios(c *gin.Context) {
+	terminos := busqueda.Terminos(c.Query("q"))
+	if len(terminos) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("q tiene que tener al menos una palabra de %d caracteres", busqueda.LargoMinimoTermino)})
+		return
+	}
+	limite := limiteBusquedaPorDefecto
+	if texto := c.Query("limit"); texto != "" {
+		valor, err := strconv.Atoi(texto)
+		if err != nil || valor < 1 || valor > limiteBusquedaMaximo {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit tiene que ser un número entre 1 y %d", limiteBusquedaMaximo)})
+			return
+		}
+		limite = valor
+	}
+
+	encontrados, err := m.repo.BuscarTexto(terminos, limite)
+	if err != nil {
+		log.Println("Error al buscar usuarios:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al buscar los usuarios"})
+		return
+	}
+
+	resultados := make([]UsuarioEncontrado, 0, len(encontrados))
+	for _, encontrado := range encontrados {
+		resultado := UsuarioEncontrado{Usuario: encontrado.Usuario.SinContrasena(), Puntaje: encontrado.Puntaje}
+		for campo, texto := range map[string]string{"nombre_usuario": encontrado.Usuario.NombreUsuario, "correo": encontrado.Usuario.Correo} {
+			if resaltado, coincide := busqueda.Resaltar(texto, terminos); coincide {
+				if resultado.Resaltado == nil {
+					resultado.Resaltado = make(map[string]string)
+				}
+				resultado.Resaltado[campo] = resaltado
+			}
+		}
+		resultados = append(resultados, resultado)
+	}
+	c.JSON(http.StatusOK, gin.H{"datos": resultados})
+}
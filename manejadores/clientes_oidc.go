@@ -0,0 +1,110 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"taller6/auth"
+	"taller6/modelos"
+	"taller6/repositorio"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CrearClienteOIDC registra una aplicación que usará el inicio de sesión único.
+// El secreto de los clientes confidenciales se muestra solo en esta respuesta.
+func (m *ManejadorUsuarios) CrearClienteOIDC(c *gin.Context) {
+	var datos struct {
+		Nombre       string   `json:"nombre" binding:"required"`
+		RedirectURIs []string `json:"redirect_uris" binding:"required"`
+		Confidencial bool     `json:"confidencial"` // false para SPA y apps móviles, que no pueden guardar un secreto
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil || len(datos.RedirectURIs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+	for _, uri := range datos.RedirectURIs {
+		if err := validarRedirectURI(uri); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	clienteID, err := auth.GenerarClienteID()
+	if err != nil {
+		log.Println("Error al generar el client_id:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el cliente"})
+		return
+	}
+	cliente := modelos.ClienteOIDC{ClienteID: clienteID, Nombre: datos.Nombre, RedirectURIs: datos.RedirectURIs}
+	var secreto string
+	if datos.Confidencial {
+		if secreto, cliente.SecretoHash, err = auth.GenerarSecreto(); err != nil {
+			log.Println("Error al generar el secreto del cliente:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el cliente"})
+			return
+		}
+	}
+	if err := m.repo.CrearClienteOIDC(&cliente); err != nil {
+		log.Println("Error al crear el cliente OIDC:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el cliente"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		modelos.ClienteOIDC
+		Confidencial bool   `json:"confidencial"`
+		Secreto      string `json:"client_secret,omitempty"`
+	}{cliente, cliente.Confidencial(), secreto})
+}
+
+// ListarClientesOIDC devuelve los clientes registrados (sin sus secretos)
+func (m *ManejadorUsuarios) ListarClientesOIDC(c *gin.Context) {
+	clientes, err := m.repo.ListarClientesOIDC()
+	if err != nil {
+		log.Println("Error al consultar los clientes OIDC:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar los clientes"})
+		return
+	}
+	if clientes == nil {
+		clientes = []modelos.ClienteOIDC{}
+	}
+	c.JSON(http.StatusOK, clientes)
+}
+
+// EliminarClienteOIDC borra un cliente; los tokens que ya recibió siguen valiendo hasta expirar
+func (m *ManejadorUsuarios) EliminarClienteOIDC(c *gin.Context) {
+	if err := m.repo.EliminarClienteOIDC(c.Param("client_id")); err != nil {
+		if errors.Is(err, repositorio.ErrClienteNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cliente no encontrado"})
+			return
+		}
+		log.Println("Error al eliminar el cliente OIDC:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo eliminar el cliente"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// validarRedirectURI exige una URI absoluta, sin fragmento y con https
+// (se permite http solo para localhost, para desarrollo)
+func validarRedirectURI(uri string) error {
+	destino, err := url.Parse(uri)
+	if err != nil || !destino.IsAbs() || destino.Host == "" {
+		return errors.New("redirect_uri inválida: " + uri)
+	}
+	if destino.Fragment != "" {
+		return errors.New("la redirect_uri no puede tener fragmento: " + uri)
+	}
+	switch destino.Scheme {
+	case "https":
+	case "http":
+		if host := destino.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return errors.New("la redirect_uri debe usar https: " + uri)
+		}
+	default:
+		return errors.New("la redirect_uri debe usar https: " + uri)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"taller6/auth"
+	"taller6/contrasenas"
+	"taller6/correo"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OlvidoContrasena envía por correo un token para restablecer la contraseña.
+// Responde siempre lo mismo, exista o no el correo, y hace el trabajo en segundo
+// plano para que el tiempo de respuesta tampoco revele qué correos están registrados.
+func (m *ManejadorUsuarios) OlvidoContrasena(c *gin.Context) {
+	var datos struct {
+		Correo string `json:"correo" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	go m.enviarRestablecimiento(datos.Correo, idiomaCorreo(c))
+
+	c.JSON(http.StatusAccepted, gin.H{"mensaje": "Si el correo está registrado, recibirá las instrucciones para restablecer la contraseña"})
+}
+
+// enviarRestablecimiento genera el token, invalida los anteriores y envía el correo
+func (m *ManejadorUsuarios) enviarRestablecimiento(direccion, idioma string) {
+	usuario, err := m.repo.BuscarPorCorreo(direccion)
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			log.Println("Error al buscar el usuario por correo:", err)
+		}
+		return
+	}
+
+	ahora := time.Now()
+	// Solo vale el último token pedido
+	if err := m.repo.InvalidarTokensUnUso(usuario.ID, modelos.PropositoRestablecer, ahora); err != nil {
+		log.Println("Error al invalidar los tokens de restablecimiento:", err)
+		return
+	}
+	token, hash, err := auth.GenerarSecreto()
+	if err != nil {
+		log.Println("Error al generar el token de restablecimiento:", err)
+		return
+	}
+	err = m.repo.GuardarTokenUnUso(&modelos.TokenUnUso{
+		UsuarioID: usuario.ID,
+		Proposito: modelos.PropositoRestablecer,
+		Hash:      hash,
+		CreadoEn:  ahora,
+		ExpiraEn:  ahora.Add(m.opciones.DuracionRestablecimiento),
+	})
+	if err != nil {
+		log.Println("Error al guardar el token de restablecimiento:", err)
+		return
+	}
+
+	m.enviarCorreo(correo.PlantillaRestablecer, idioma, *usuario, correo.Datos{
+		Enlace:  enlaceConToken(m.opciones.URLRestablecimiento, token),
+		Validez: m.opciones.DuracionRestablecimiento,
+	})
+}
+
+// enlaceConToken arma el enlace de un correo: la página indicada con el token
+// en la query o, si no hay página, el token solo
+func enlaceConToken(pagina, token string) string {
+	if pagina == "" {
+		return token
+	}
+	enlace, err := url.Parse(pagina)
+	if err != nil {
+		return token
+	}
+	query := enlace.Query()
+	query.Set("token", token)
+	enlace.RawQuery = query.Encode()
+	return enlace.String()
+}
+
+// RestablecerContrasena consume el token del correo, guarda la contraseña nueva y
+// cierra todas las sesiones abiertas del usuario
+func (m *ManejadorUsuarios) RestablecerContrasena(c *gin.Context) {
+	var datos struct {
+		Token      string `json:"token" binding:"required"`
+		Contrasena string `json:"contrasena" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	token, err := m.repo.BuscarTokenUnUso(modelos.PropositoRestablecer, auth.HashSecreto(datos.Token))
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrTokenNoEncontrado) {
+			log.Println("Error al buscar el token de restablecimiento:", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+	ahora := time.Now()
+	if token.UsadoEn != nil || ahora.After(token.ExpiraEn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+
+	// La política se controla antes de gastar el token, así puede reintentar con otra contraseña
+	usuario, err := m.repo.BuscarPorID(token.UsuarioID)
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			log.Println("Error al buscar el usuario del token de restablecimiento:", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+	datosCuenta := contrasenas.Datos{NombreUsuario: usuario.NombreUsuario, Correo: usuario.Correo}
+	if !m.validarContrasenaNueva(c, datos.Contrasena, datosCuenta, usuario) {
+		return
+	}
+
+	// Primero lo marcamos como usado, así dos pedidos con el mismo token no pasan los dos
+	usado, err := m.repo.MarcarTokenUnUsoUsado(token.ID, ahora)
+	if err != nil {
+		log.Println("Error al marcar el token de restablecimiento:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo restablecer la contraseña"})
+		return
+	}
+	if !usado {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+
+	contrasenaEncriptada, err := m.opciones.Hasheador.Hashear(datos.Contrasena)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al encriptar la contraseña"})
+		return
+	}
+	if err := m.repo.Actualizar(token.UsuarioID, modelos.Usuario{Contrasena: contrasenaEncriptada}); err != nil {
+		log.Println("Error al guardar la contraseña restablecida:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo restablecer la contraseña"})
+		return
+	}
+	m.guardarContrasenaAnterior(usuario, ahora)
+
+	// Quien tuviera la contraseña anterior deja de tener acceso
+	if err := m.revocarSesiones(token.UsuarioID, ahora); err != nil {
+		log.Println("Error al revocar las sesiones:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "La contraseña cambió pero no se pudieron cerrar las sesiones abiertas"})
+		return
+	}
+
+	log.Printf("Usuario ID %d restableció su contraseña", token.UsuarioID)
+	// Avisamos por correo, por si el cambio no lo pidió el dueño de la cuenta
+	if usuario.Correo != "" {
+		go m.enviarCorreo(correo.PlantillaContrasenaCambiada, idiomaCorreo(c), *usuario, correo.Datos{})
+	}
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Contraseña restablecida"})
+}
@@ -0,0 +1,28 @@
+package manejadores
+
+import (
+	"log"
+	"taller6/correo"
+	"taller6/modelos"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idiomaCorreo elige el idioma de los correos según el Accept-Language del pedido
+func idiomaCorreo(c *gin.Context) string {
+	return correo.ElegirIdioma(c.GetHeader("Accept-Language"))
+}
+
+// enviarCorreo arma la plantilla para el usuario y la entrega al Enviador. Los errores
+// solo quedan en el log: el pedido que originó el correo ya respondió.
+func (m *ManejadorUsuarios) enviarCorreo(plantilla, idioma string, usuario modelos.Usuario, datos correo.Datos) {
+	datos.NombreUsuario = usuario.NombreUsuario
+	mensaje, err := correo.Componer(plantilla, idioma, usuario.Correo, datos)
+	if err != nil {
+		log.Printf("Error al armar el correo %q: %v", plantilla, err)
+		return
+	}
+	if err := m.opciones.Enviador.Enviar(mensaje); err != nil {
+		log.Printf("Error al enviar el correo %q al usuario ID %d: %v", plantilla, usuario.ID, err)
+	}
+}
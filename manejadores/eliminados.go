@@ -0,0 +1,71 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cada cuánto se buscan usuarios eliminados para purgar. La retención se mide en días,
+// así que una hora de más no cambia nada.
+const intervaloPurgaEliminados = time.Hour
+
+// RestaurarUsuario vuelve a activar un usuario eliminado con sus datos y sus roles.
+// Las sesiones que tenía se cerraron al eliminarlo, así que tiene que iniciar sesión de nuevo.
+func (m *ManejadorUsuarios) RestaurarUsuario(c *gin.Context) {
+	idInt, ok := idObjetivo(c)
+	if !ok {
+		return
+	}
+	id := uint(idInt)
+
+	if err := m.repo.Restaurar(id); err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No hay un usuario eliminado con ese ID"})
+			return
+		}
+		log.Println("Error al restaurar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo restaurar el usuario"})
+		return
+	}
+
+	usuario, err := m.repo.BuscarPorID(id)
+	if err != nil {
+		log.Println("Error al consultar el usuario restaurado:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		return
+	}
+	log.Printf("Usuario ID %s restauró al usuario ID %d", c.GetString("id_usuario"), id)
+	c.JSON(http.StatusOK, usuario.SinContrasena())
+}
+
+// IniciarPurgaEliminados borra en segundo plano, al arrancar y después cada hora, los
+// usuarios eliminados hace más de la retención. Con varias instancias cada una purga
+// por su cuenta, lo que no es un problema: la segunda no encuentra nada.
+func (m *ManejadorUsuarios) IniciarPurgaEliminados() {
+	if m.opciones.RetencionEliminados <= 0 {
+		return
+	}
+	go func() {
+		m.purgarEliminados()
+		for range time.Tick(intervaloPurgaEliminados) {
+			m.purgarEliminados()
+		}
+	}()
+}
+
+// purgarEliminados hace una pasada de la purga
+func (m *ManejadorUsuarios) purgarEliminados() {
+	purgados, err := m.repo.PurgarEliminados(time.Now().Add(-m.opciones.RetencionEliminados))
+	if err != nil {
+		log.Println("Error al purgar los usuarios eliminados:", err)
+		return
+	}
+	if purgados > 0 {
+		log.Printf("Se purgaron %d usuarios eliminados hace más de %s", purgados, m.opciones.RetencionEliminados)
+	}
+}
@@ -0,0 +1,156 @@
+package manejadores
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"taller6/auth"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Espera entre intentos: hasta la mitad del máximo los fallos no demoran a nadie (son
+// errores de tipeo, o muchos usuarios detrás de la misma IP); desde ahí cada intento espera
+// el doble que el anterior, hasta esperaMaximaLogin. Al llegar al máximo de fallos la
+// clave queda bloqueada por DuracionBloqueoLogin.
+const (
+	esperaBaseLogin   = time.Second
+	esperaMaximaLogin = 30 * time.Second
+)
+
+// errorBloqueo indica que la cuenta o la IP tienen que esperar antes de volver a intentar
+type errorBloqueo struct {
+	hasta time.Time
+}
+
+func (e *errorBloqueo) Error() string {
+	return fmt.Sprintf("demasiados intentos fallidos, esperar hasta %s", e.hasta.Format(time.RFC3339))
+}
+
+// claveIntentos es una de las claves que se controlan en cada inicio de sesión
+type claveIntentos struct {
+	clave  string
+	maximo int
+}
+
+// claveCuenta identifica la cuenta por el nombre que se escribió, exista o no: así una
+// cuenta inexistente se bloquea igual que una real y no se distingue una de la otra.
+// Se usa el hash para no guardar los nombres que prueba un atacante.
+func claveCuenta(nombreUsuario string) string {
+	return "cuenta:" + auth.HashSecreto(strings.ToLower(nombreUsuario))
+}
+
+// clavesIntentos arma las claves de la cuenta y de la IP; un máximo de 0 desactiva la suya
+func (m *ManejadorUsuarios) clavesIntentos(nombreUsuario, ip string) (cuenta, porIP *claveIntentos) {
+	if m.opciones.MaxIntentosLogin > 0 {
+		cuenta = &claveIntentos{clave: claveCuenta(nombreUsuario), maximo: m.opciones.MaxIntentosLogin}
+	}
+	if m.opciones.MaxIntentosLoginIP > 0 && ip != "" {
+		porIP = &claveIntentos{clave: "ip:" + ip, maximo: m.opciones.MaxIntentosLoginIP}
+	}
+	return cuenta, porIP
+}
+
+// esperaHasta calcula hasta cuándo la clave no puede volver a intentar según sus fallos
+func (m *ManejadorUsuarios) esperaHasta(intentos *modelos.IntentosLogin, maximo int) time.Time {
+	if intentos.Fallos >= maximo {
+		return intentos.UltimoFallo.Add(m.opciones.DuracionBloqueoLogin)
+	}
+	sinEspera := maximo / 2
+	if intentos.Fallos <= sinEspera {
+		return time.Time{}
+	}
+	espera := esperaBaseLogin
+	for i := sinEspera + 1; i < intentos.Fallos && espera < esperaMaximaLogin; i++ {
+		espera *= 2
+	}
+	return intentos.UltimoFallo.Add(min(espera, esperaMaximaLogin))
+}
+
+// controlarIntentos devuelve un errorBloqueo si alguna de las claves todavía tiene que esperar
+func (m *ManejadorUsuarios) controlarIntentos(ahora time.Time, claves ...*claveIntentos) error {
+	var hasta time.Time
+	for _, clave := range claves {
+		if clave == nil {
+			continue
+		}
+		intentos, err := m.repo.BuscarIntentosLogin(clave.clave)
+		if err != nil {
+			return err
+		}
+		if espera := m.esperaHasta(intentos, clave.maximo); espera.After(ahora) && espera.After(hasta) {
+			hasta = espera
+		}
+	}
+	if !hasta.IsZero() {
+		return &errorBloqueo{hasta: hasta}
+	}
+	return nil
+}
+
+// registrarFallo suma un fallo a la clave y avisa en el log cuando queda bloqueada
+func (m *ManejadorUsuarios) registrarFallo(clave *claveIntentos, ahora time.Time) (*modelos.IntentosLogin, error) {
+	intentos, err := m.repo.RegistrarFalloLogin(clave.clave, ahora, ahora.Add(-m.opciones.DuracionBloqueoLogin))
+	if err != nil {
+		return nil, err
+	}
+	if intentos.Fallos == clave.maximo {
+		log.Printf("Se bloqueó %s por %d inicios de sesión fallidos", clave.clave, intentos.Fallos)
+	}
+	return intentos, nil
+}
+
+// compararContrasenaFicticia compara contra un hash que no es de nadie cuando el usuario
+// no existe, para que la respuesta tarde lo mismo que con una contraseña incorrecta
+func (m *ManejadorUsuarios) compararContrasenaFicticia(contrasena string) {
+	m.unaVezHashFicticio.Do(func() {
+		hash, err := m.opciones.Hasheador.Hashear("contraseña ficticia")
+		if err != nil {
+			log.Println("Error al generar el hash ficticio:", err)
+		}
+		m.hashFicticio = hash
+	})
+	m.opciones.Hasheador.Verificar(m.hashFicticio, contrasena)
+}
+
+// responderBloqueo contesta 429 con el tiempo de espera en Retry-After
+func responderBloqueo(c *gin.Context, bloqueo *errorBloqueo) {
+	segundos := math.Ceil(time.Until(bloqueo.hasta).Seconds())
+	c.Header("Retry-After", fmt.Sprint(max(int(segundos), 1)))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Demasiados intentos fallidos, intente de nuevo más tarde"})
+}
+
+// DesbloquearUsuario borra los inicios de sesión fallidos de la cuenta, por ejemplo
+// cuando su dueño quedó bloqueado por los intentos de otro (DELETE /usuarios/:id/bloqueo)
+func (m *ManejadorUsuarios) DesbloquearUsuario(c *gin.Context) {
+	idInt, ok := idObjetivo(c)
+	if !ok {
+		return
+	}
+	id := uint(idInt)
+
+	usuario, err := m.repo.BuscarPorID(id)
+	if err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		log.Println("Error al consultar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		return
+	}
+	if err := m.repo.ReiniciarIntentosLogin(claveCuenta(usuario.NombreUsuario)); err != nil {
+		log.Println("Error al reiniciar los intentos de inicio de sesión:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo desbloquear la cuenta"})
+		return
+	}
+
+	log.Printf("Usuario ID %s desbloqueó la cuenta del usuario ID %d", c.GetString("id_usuario"), id)
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Cuenta desbloqueada"})
+}
@@ -0,0 +1,252 @@
+package manejadores
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"taller6/auth"
+	"taller6/contrasenas"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Contraseña de los usuarios de las pruebas de inicio de sesión
+const contrasenaPrueba = "contraseña-correcta"
+
+// hasheadorPrueba usa el costo mínimo de bcrypt, así las pruebas no tardan
+var hasheadorPrueba = contrasenas.Hasheador{Algoritmo: contrasenas.AlgoritmoBcrypt, CostoBcrypt: bcrypt.MinCost}
+
+// pruebaLogin es un servidor con las rutas de inicio de sesión sobre un repositorio en memoria
+type pruebaLogin struct {
+	servidor *gin.Engine
+	repo     *repositorio.UsuarioRepositorioMemoria
+	usuarios *ManejadorUsuarios
+}
+
+// nuevaPruebaLogin arma el servidor con las opciones indicadas (sin Hasheador ni
+// DuracionRefresco, que se completan acá) y las rutas como en main.go
+func nuevaPruebaLogin(t *testing.T, opciones Opciones) *pruebaLogin {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	auth.Configurar("clave-de-prueba", time.Minute)
+
+	opciones.Hasheador = hasheadorPrueba
+	opciones.DuracionRefresco = time.Hour
+	repo := repositorio.NuevoUsuarioRepositorioMemoria()
+	usuarios := NuevoManejadorUsuarios(repo, opciones)
+
+	servidor := gin.New()
+	servidor.POST("/login", usuarios.Login)
+	servidor.POST("/login/2fa", usuarios.LoginSegundoFactor)
+	servidor.GET("/authorize", usuarios.Autorizar)
+	servidor.POST("/authorize", usuarios.AutorizarLogin)
+	servidor.POST("/token", usuarios.Token)
+	servidor.GET("/userinfo", auth.RequiereAutenticacionUserInfo(), usuarios.UserInfo)
+	rutasProtegidas := servidor.Group("/", auth.RequiereAutenticacion())
+	rutasProtegidas.GET("/me/2fa", usuarios.ObtenerSegundoFactor)
+	rutasProtegidas.POST("/me/2fa", usuarios.InscribirSegundoFactor)
+	rutasProtegidas.POST("/me/2fa/confirmar", usuarios.ConfirmarSegundoFactor)
+	rutasProtegidas.POST("/me/2fa/codigos-recuperacion", usuarios.RegenerarCodigosRecuperacion)
+	rutasProtegidas.DELETE("/me/2fa", usuarios.DesactivarSegundoFactor)
+	rutasProtegidas.DELETE("/usuarios/:id/bloqueo", auth.RequierePermiso(modelos.PermisoUsuariosEscribir), usuarios.DesbloquearUsuario)
+
+	return &pruebaLogin{servidor: servidor, repo: repo, usuarios: usuarios}
+}
+
+// crearUsuario guarda un usuario con contrasenaPrueba
+func (p *pruebaLogin) crearUsuario(t *testing.T, nombre string) *modelos.Usuario {
+	t.Helper()
+	hash, err := hasheadorPrueba.Hashear(contrasenaPrueba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usuario := &modelos.Usuario{NombreUsuario: nombre, Correo: nombre + "@ejemplo.com", Contrasena: hash}
+	if err := p.repo.Crear(usuario); err != nil {
+		t.Fatal(err)
+	}
+	return usuario
+}
+
+// pedir hace el pedido con el cuerpo en JSON desde la IP indicada y, si se indica, con el token
+func (p *pruebaLogin) pedir(t *testing.T, metodo, ruta, ip, token string, cuerpo interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	contenido, err := json.Marshal(cuerpo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pedido := httptest.NewRequest(metodo, ruta, bytes.NewReader(contenido))
+	pedido.Header.Set("Content-Type", "application/json")
+	pedido.RemoteAddr = ip + ":40000"
+	if token != "" {
+		pedido.Header.Set("Authorization", "Bearer "+token)
+	}
+	respuesta := httptest.NewRecorder()
+	p.servidor.ServeHTTP(respuesta, pedido)
+	return respuesta
+}
+
+// loguear hace POST /login desde la IP indicada
+func (p *pruebaLogin) loguear(t *testing.T, ip, nombre, contrasena string) *httptest.ResponseRecorder {
+	t.Helper()
+	return p.pedir(t, http.MethodPost, "/login", ip, "", gin.H{"nombre_usuario": nombre, "contrasena": contrasena})
+}
+
+func TestEsperaHasta(t *testing.T) {
+	m := NuevoManejadorUsuarios(nil, Opciones{DuracionBloqueoLogin: 15 * time.Minute})
+	ultimo := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	casos := []struct {
+		maximo int
+		fallos int
+		espera time.Duration // 0 es que no tiene que esperar
+	}{
+		// Hasta la mitad del máximo no se espera
+		{maximo: 6, fallos: 0, espera: 0},
+		{maximo: 6, fallos: 3, espera: 0},
+		// Desde ahí el doble cada vez
+		{maximo: 6, fallos: 4, espera: time.Second},
+		{maximo: 6, fallos: 5, espera: 2 * time.Second},
+		// Al llegar al máximo, el bloqueo completo
+		{maximo: 6, fallos: 6, espera: 15 * time.Minute},
+		{maximo: 6, fallos: 9, espera: 15 * time.Minute},
+		// Con un máximo alto la espera no pasa de esperaMaximaLogin
+		{maximo: 20, fallos: 11, espera: time.Second},
+		{maximo: 20, fallos: 15, espera: 16 * time.Second},
+		{maximo: 20, fallos: 16, espera: esperaMaximaLogin},
+		{maximo: 20, fallos: 19, espera: esperaMaximaLogin},
+		{maximo: 20, fallos: 20, espera: 15 * time.Minute},
+		// Con un máximo de 1 el primer fallo ya bloquea
+		{maximo: 1, fallos: 1, espera: 15 * time.Minute},
+	}
+	for _, caso := range casos {
+		intentos := &modelos.IntentosLogin{Fallos: caso.fallos, UltimoFallo: ultimo}
+		hasta := m.esperaHasta(intentos, caso.maximo)
+		var esperado time.Time
+		if caso.espera > 0 {
+			esperado = ultimo.Add(caso.espera)
+		}
+		if !hasta.Equal(esperado) {
+			t.Errorf("máximo %d con %d fallos: se obtuvo %v, se esperaba %v", caso.maximo, caso.fallos, hasta, esperado)
+		}
+	}
+}
+
+func TestLoginBloqueaCuenta(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{MaxIntentosLogin: 2, DuracionBloqueoLogin: time.Minute})
+	p.crearUsuario(t, "ana")
+
+	// Cada fallo viene de otra IP: el bloqueo es de la cuenta
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if respuesta := p.loguear(t, ip, "ana", "incorrecta"); respuesta.Code != http.StatusUnauthorized {
+			t.Fatalf("el fallo %d devolvió %d, se esperaba %d", i+1, respuesta.Code, http.StatusUnauthorized)
+		}
+	}
+	// Bloqueada, ni la contraseña correcta entra
+	respuesta := p.loguear(t, "192.0.2.3", "ana", contrasenaPrueba)
+	if respuesta.Code != http.StatusTooManyRequests {
+		t.Fatalf("con la cuenta bloqueada se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+	}
+	if segundos, err := strconv.Atoi(respuesta.Header().Get("Retry-After")); err != nil || segundos < 1 || segundos > 60 {
+		t.Errorf("Retry-After es %q, se esperaba entre 1 y 60 segundos", respuesta.Header().Get("Retry-After"))
+	}
+	// Las mayúsculas no esquivan el bloqueo
+	if respuesta := p.loguear(t, "192.0.2.3", "ANA", contrasenaPrueba); respuesta.Code != http.StatusTooManyRequests {
+		t.Errorf("con el nombre en mayúsculas se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestLoginDesbloqueaDespuesDelBloqueo(t *testing.T) {
+	const duracion = time.Minute
+	p := nuevaPruebaLogin(t, Opciones{MaxIntentosLogin: 2, DuracionBloqueoLogin: duracion})
+	p.crearUsuario(t, "ana")
+
+	// Los fallos que bloquearon la cuenta fueron hace más que la duración del bloqueo
+	antes := time.Now().Add(-duracion - time.Second)
+	for i := 0; i < 2; i++ {
+		if _, err := p.repo.RegistrarFalloLogin(claveCuenta("ana"), antes, antes.Add(-duracion)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusCreated {
+		t.Fatalf("vencido el bloqueo se obtuvo %d: %s", respuesta.Code, respuesta.Body)
+	}
+	// El login correcto borra los fallos
+	if intentos, err := p.repo.BuscarIntentosLogin(claveCuenta("ana")); err != nil || intentos.Fallos != 0 {
+		t.Errorf("después del login quedaron los fallos %+v (%v)", intentos, err)
+	}
+}
+
+func TestLoginBloqueaIP(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{MaxIntentosLoginIP: 2, DuracionBloqueoLogin: time.Minute})
+	p.crearUsuario(t, "ana")
+
+	// Una IP que prueba contraseñas en varias cuentas, existan o no
+	const atacante = "198.51.100.7"
+	for _, nombre := range []string{"beto", "inexistente"} {
+		if respuesta := p.loguear(t, atacante, nombre, "incorrecta"); respuesta.Code != http.StatusUnauthorized {
+			t.Fatalf("el fallo con %q devolvió %d, se esperaba %d", nombre, respuesta.Code, http.StatusUnauthorized)
+		}
+	}
+	if respuesta := p.loguear(t, atacante, "ana", contrasenaPrueba); respuesta.Code != http.StatusTooManyRequests {
+		t.Errorf("desde la IP bloqueada se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+	}
+	// La cuenta no quedó bloqueada: desde otra IP entra
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusCreated {
+		t.Errorf("desde otra IP se obtuvo %d: %s", respuesta.Code, respuesta.Body)
+	}
+}
+
+func TestLoginNoRevelaUsuarios(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{MaxIntentosLogin: 5, DuracionBloqueoLogin: time.Minute})
+	p.crearUsuario(t, "ana")
+
+	contrasenaIncorrecta := p.loguear(t, "192.0.2.1", "ana", "incorrecta")
+	usuarioInexistente := p.loguear(t, "192.0.2.1", "nadie", "incorrecta")
+	if contrasenaIncorrecta.Code != http.StatusUnauthorized || usuarioInexistente.Code != http.StatusUnauthorized {
+		t.Fatalf("se obtuvo %d y %d, se esperaba %d", contrasenaIncorrecta.Code, usuarioInexistente.Code, http.StatusUnauthorized)
+	}
+	if contrasenaIncorrecta.Body.String() != usuarioInexistente.Body.String() {
+		t.Errorf("las respuestas son distintas: %s y %s", contrasenaIncorrecta.Body, usuarioInexistente.Body)
+	}
+}
+
+func TestDesbloquearUsuario(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{MaxIntentosLogin: 2, DuracionBloqueoLogin: time.Hour})
+	usuario := p.crearUsuario(t, "ana")
+	admin, err := auth.GenerarToken(usuario.ID+100, modelos.RolesPorDefecto())
+	if err != nil {
+		t.Fatal(err)
+	}
+	comun, err := auth.GenerarToken(usuario.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		p.loguear(t, "192.0.2.1", "ana", "incorrecta")
+	}
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusTooManyRequests {
+		t.Fatalf("la cuenta no quedó bloqueada: %d", respuesta.Code)
+	}
+
+	ruta := "/usuarios/" + strconv.Itoa(int(usuario.ID)) + "/bloqueo"
+	if respuesta := p.pedir(t, http.MethodDelete, ruta, "192.0.2.9", comun, nil); respuesta.Code != http.StatusForbidden {
+		t.Errorf("sin permiso se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusForbidden)
+	}
+	if respuesta := p.pedir(t, http.MethodDelete, "/usuarios/9999/bloqueo", "192.0.2.9", admin, nil); respuesta.Code != http.StatusNotFound {
+		t.Errorf("con un usuario inexistente se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusNotFound)
+	}
+	if respuesta := p.pedir(t, http.MethodDelete, ruta, "192.0.2.9", admin, nil); respuesta.Code != http.StatusOK {
+		t.Fatalf("DesbloquearUsuario devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusCreated {
+		t.Errorf("después de desbloquear se obtuvo %d: %s", respuesta.Code, respuesta.Body)
+	}
+}
@@ -0,0 +1,16 @@
+package manejadores
+
+import (
+	"net/http"
+	"taller6/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ObtenerJWKS publica las claves con las que otros servicios pueden validar nuestros
+// tokens sin consultarnos. Incluye las claves retiradas mientras sigan vigentes.
+func ObtenerJWKS(c *gin.Context) {
+	// Un cache corto: una clave nueva tiene que aparecer antes de que firme muchos tokens
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": auth.JWKS()})
+}
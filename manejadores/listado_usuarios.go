@@ -0,0 +1,256 @@
+package manejadores
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"taller6/auth"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tamaño de las páginas de GET /usuarios
+const (
+	limiteUsuariosPorDefecto = 50
+	limiteUsuariosMaximo     = 200
+)
+
+// cursorUsuarios es lo que viaja dentro del parámetro "after": el orden con el que se
+// pidió la página y la posición del último usuario. Para el cliente es opaco.
+type cursorUsuarios struct {
+	Orden       string     `json:"o"`
+	Descendente bool       `json:"d,omitempty"`
+	ID          uint       `json:"i"`
+	Texto       string     `json:"t,omitempty"` // Nombre de usuario o correo, según el orden
+	Fecha       *time.Time `json:"f,omitempty"` // creado_en, si se ordena por fecha
+}
+
+// PaginaUsuarios es la respuesta de GET /usuarios
+type PaginaUsuarios struct {
+	// Datos son modelos.UsuarioSinContrasena o modelos.UsuarioPublico, según los
+	// permisos, o solo los campos pedidos con fields=
+	Datos           []interface{} `json:"datos"`
+	Total           int           `json:"total"` // Usuarios que cumplen el filtro, en todas las páginas
+	Limite          int           `json:"limite"`
+	CursorSiguiente string        `json:"cursor_siguiente,omitempty"` // Vacío en la última página
+	Enlaces         EnlacesPagina `json:"enlaces"`
+}
+
+// EnlacesPagina son las URLs de la página actual y de la siguiente, con los mismos filtros
+type EnlacesPagina struct {
+	Actual    string `json:"actual"`
+	Siguiente string `json:"siguiente,omitempty"`
+}
+
+// ObtenerUsuarios lista los usuarios de a páginas. Parámetros:
+//   - limit: tamaño de la página (por defecto 50, como mucho 200)
+//   - after: el cursor_siguiente de la página anterior
+//   - sort: nombre_usuario, correo, creado_en o id (por defecto); con "-" adelante, descendente
+//   - nombre_usuario, correo: prefijo, sin importar mayúsculas
+//   - creado_desde, creado_hasta: fechas RFC 3339 (desde inclusive, hasta exclusive)
+//   - fields: los campos que se quieren de cada usuario, separados por coma
+//   - eliminados: true lista solo los usuarios dados de baja, para restaurarlos; exige
+//     el permiso usuarios:eliminar
+//
+// Quien tiene el permiso usuarios:leer ve todo menos la contraseña; el resto ve la
+// proyección pública (sin correo) y no puede filtrar ni ordenar por correo, porque
+// con los resultados podría averiguar el correo de cualquiera.
+func (m *ManejadorUsuarios) ObtenerUsuarios(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	completo := reclamos.TienePermiso(modelos.PermisoUsuariosLeer)
+
+	consulta, err := leerConsultaUsuarios(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !completo && (consulta.Filtro.PrefijoCorreo != "" || consulta.Orden == repositorio.OrdenCorreo) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tiene permiso para filtrar ni ordenar por correo"})
+		return
+	}
+	if consulta.Filtro.Eliminados && !reclamos.TienePermiso(modelos.PermisoUsuariosEliminar) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tiene permiso para ver los usuarios eliminados"})
+		return
+	}
+	proyeccion := func(usuario modelos.Usuario) interface{} { return usuario.Publico() }
+	if completo {
+		proyeccion = func(usuario modelos.Usuario) interface{} { return usuario.SinContrasena() }
+	}
+	campos, err := leerCampos(c, proyeccion(modelos.Usuario{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Se pide uno más para saber si hay otra página
+	limite := consulta.Limite
+	consulta.Limite++
+	lista, err := m.repo.ListarPagina(consulta)
+	if err != nil {
+		log.Println("Error al consultar los usuarios:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar los usuarios"})
+		return
+	}
+	total, err := m.repo.Contar(consulta.Filtro)
+	if err != nil {
+		log.Println("Error al contar los usuarios:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar los usuarios"})
+		return
+	}
+
+	pagina := PaginaUsuarios{
+		Datos:   make([]interface{}, 0, min(len(lista), limite)),
+		Total:   total,
+		Limite:  limite,
+		Enlaces: EnlacesPagina{Actual: c.Request.URL.RequestURI()},
+	}
+	if len(lista) > limite {
+		lista = lista[:limite]
+		pagina.CursorSiguiente = codificarCursor(consulta, lista[limite-1])
+		query := c.Request.URL.Query()
+		query.Set("after", pagina.CursorSiguiente)
+		pagina.Enlaces.Siguiente = c.Request.URL.Path + "?" + query.Encode()
+	}
+	for _, usuario := range lista {
+		if campos == nil {
+			pagina.Datos = append(pagina.Datos, proyeccion(usuario))
+		} else {
+			pagina.Datos = append(pagina.Datos, modelos.Proyectar(proyeccion(usuario), campos))
+		}
+	}
+
+	c.JSON(http.StatusOK, pagina)
+}
+
+// leerConsultaUsuarios arma la consulta a partir de los parámetros; el error es el
+// mensaje para el cliente
+func leerConsultaUsuarios(c *gin.Context) (repositorio.ConsultaUsuarios, error) {
+	consulta := repositorio.ConsultaUsuarios{Orden: repositorio.OrdenID, Limite: limiteUsuariosPorDefecto}
+
+	if texto := c.Query("limit"); texto != "" {
+		limite, err := strconv.Atoi(texto)
+		if err != nil || limite < 1 || limite > limiteUsuariosMaximo {
+			return consulta, fmt.Errorf("limit tiene que ser un número entre 1 y %d", limiteUsuariosMaximo)
+		}
+		consulta.Limite = limite
+	}
+
+	if orden := c.Query("sort"); orden != "" {
+		consulta.Descendente = strings.HasPrefix(orden, "-")
+		consulta.Orden = strings.TrimPrefix(orden, "-")
+		// Solo columnas de la lista: el campo termina en el ORDER BY
+		if !repositorio.OrdenValido(consulta.Orden) {
+			return consulta, fmt.Errorf("no se puede ordenar por %q (usar nombre_usuario, correo, creado_en o id)", consulta.Orden)
+		}
+	}
+
+	if texto := c.Query("eliminados"); texto != "" {
+		eliminados, err := strconv.ParseBool(texto)
+		if err != nil {
+			return consulta, errors.New("eliminados tiene que ser true o false")
+		}
+		consulta.Filtro.Eliminados = eliminados
+	}
+
+	consulta.Filtro.PrefijoNombre = c.Query("nombre_usuario")
+	consulta.Filtro.PrefijoCorreo = c.Query("correo")
+	for _, fecha := range []struct {
+		parametro string
+		destino   *time.Time
+	}{
+		{"creado_desde", &consulta.Filtro.CreadoDesde},
+		{"creado_hasta", &consulta.Filtro.CreadoHasta},
+	} {
+		if texto := c.Query(fecha.parametro); texto != "" {
+			valor, err := time.Parse(time.RFC3339, texto)
+			if err != nil {
+				return consulta, fmt.Errorf("%s tiene que ser una fecha RFC 3339, como 2024-01-31T00:00:00Z", fecha.parametro)
+			}
+			// Las fechas se guardan con la hora local del servidor (time.Now() al crear)
+			*fecha.destino = valor.Local()
+		}
+	}
+
+	if texto := c.Query("after"); texto != "" {
+		despues, err := decodificarCursor(texto, consulta)
+		if err != nil {
+			return consulta, err
+		}
+		consulta.Despues = despues
+	}
+	return consulta, nil
+}
+
+// leerCampos valida el parámetro fields contra los campos JSON de la proyección que
+// corresponde; nil si no se pidió, y entonces va la proyección entera
+func leerCampos(c *gin.Context, proyeccion interface{}) ([]string, error) {
+	texto := c.Query("fields")
+	if texto == "" {
+		return nil, nil
+	}
+	permitidos := modelos.CamposJSON(proyeccion)
+	var campos []string
+	for _, campo := range strings.Split(texto, ",") {
+		campo = strings.TrimSpace(campo)
+		if !slices.Contains(permitidos, campo) {
+			return nil, fmt.Errorf("campo desconocido en fields: %q (usar %s)", campo, strings.Join(permitidos, ", "))
+		}
+		campos = append(campos, campo)
+	}
+	return campos, nil
+}
+
+// codificarCursor guarda la posición del usuario en el orden de la consulta
+func codificarCursor(consulta repositorio.ConsultaUsuarios, usuario modelos.Usuario) string {
+	cursor := cursorUsuarios{Orden: consulta.Orden, Descendente: consulta.Descendente, ID: usuario.ID}
+	switch consulta.Orden {
+	case repositorio.OrdenNombreUsuario:
+		cursor.Texto = usuario.NombreUsuario
+	case repositorio.OrdenCorreo:
+		cursor.Texto = usuario.Correo
+	case repositorio.OrdenCreadoEn:
+		cursor.Fecha = &usuario.CreadoEn
+	}
+	datos, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(datos)
+}
+
+// decodificarCursor rearma el último usuario de la página anterior. El cursor tiene
+// que ser del mismo orden que la consulta: la posición no significa nada en otro.
+func decodificarCursor(texto string, consulta repositorio.ConsultaUsuarios) (*modelos.Usuario, error) {
+	errCursor := errors.New("el cursor after es inválido")
+	datos, err := base64.RawURLEncoding.DecodeString(texto)
+	if err != nil {
+		return nil, errCursor
+	}
+	var cursor cursorUsuarios
+	if err := json.Unmarshal(datos, &cursor); err != nil {
+		return nil, errCursor
+	}
+	if cursor.Orden != consulta.Orden || cursor.Descendente != consulta.Descendente {
+		return nil, fmt.Errorf("el cursor after es de otro orden; pedir la primera página con el sort nuevo")
+	}
+
+	usuario := &modelos.Usuario{ID: cursor.ID}
+	switch cursor.Orden {
+	case repositorio.OrdenNombreUsuario:
+		usuario.NombreUsuario = cursor.Texto
+	case repositorio.OrdenCorreo:
+		usuario.Correo = cursor.Texto
+	case repositorio.OrdenCreadoEn:
+		if cursor.Fecha == nil {
+			return nil, errCursor
+		}
+		usuario.CreadoEn = *cursor.Fecha
+	}
+	return usuario, nil
+}
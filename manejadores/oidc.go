@@ -0,0 +1,412 @@
+package manejadores
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"taller6/auth"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tiempo que tiene el cliente para cambiar el código de autorización por tokens
+const duracionCodigoAutorizacion = time.Minute
+
+// solicitudAutorizacion son los parámetros de /authorize. Llegan en la query del GET
+// y como campos ocultos del formulario en el POST.
+type solicitudAutorizacion struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// datosFormulario es lo que muestra la plantilla de inicio de sesión
+type datosFormulario struct {
+	Solicitud     solicitudAutorizacion
+	Cliente       string
+	NombreUsuario string
+	TokenMFA      string // Si hay, se pide el código del segundo factor en lugar de la contraseña
+	Error         string
+}
+
+// formularioLogin es la página de inicio de sesión de OpenID Connect
+var formularioLogin = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head><meta charset="utf-8"><title>Iniciar sesión</title></head>
+<body>
+{{if .Cliente}}<h1>Iniciar sesión en {{.Cliente}}</h1>{{end}}
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+{{if .Cliente}}
+<form method="post" action="authorize">
+{{if .TokenMFA}}
+  <label>Código de verificación <input name="codigo" autocomplete="one-time-code" required autofocus></label>
+  <input type="hidden" name="token_mfa" value="{{.TokenMFA}}">
+{{else}}
+  <label>Usuario <input name="nombre_usuario" value="{{.NombreUsuario}}" autocomplete="username" required></label>
+  <label>Contraseña <input name="contrasena" type="password" autocomplete="current-password" required></label>
+{{end}}
+  <input type="hidden" name="response_type" value="{{.Solicitud.ResponseType}}">
+  <input type="hidden" name="client_id" value="{{.Solicitud.ClientID}}">
+  <input type="hidden" name="redirect_uri" value="{{.Solicitud.RedirectURI}}">
+  <input type="hidden" name="scope" value="{{.Solicitud.Scope}}">
+  <input type="hidden" name="state" value="{{.Solicitud.State}}">
+  <input type="hidden" name="nonce" value="{{.Solicitud.Nonce}}">
+  <input type="hidden" name="code_challenge" value="{{.Solicitud.CodeChallenge}}">
+  <input type="hidden" name="code_challenge_method" value="{{.Solicitud.CodeChallengeMethod}}">
+  <button type="submit">Ingresar</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// mostrarFormulario responde con la página de inicio de sesión
+func mostrarFormulario(c *gin.Context, estado int, datos datosFormulario) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	// La página no se puede mostrar dentro de un iframe de otro sitio (clickjacking)
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Cache-Control", "no-store")
+	c.Status(estado)
+	if err := formularioLogin.Execute(c.Writer, datos); err != nil {
+		log.Println("Error al mostrar el formulario de inicio de sesión:", err)
+	}
+}
+
+// validarSolicitud revisa los parámetros de /authorize. Si el cliente o la redirect_uri
+// no son válidos muestra el error acá mismo (nunca se redirige a una URI sin registrar);
+// el resto de los errores vuelven al cliente por la redirect_uri. Si devuelve false ya respondió.
+func (m *ManejadorUsuarios) validarSolicitud(c *gin.Context, solicitud solicitudAutorizacion) (*modelos.ClienteOIDC, bool) {
+	cliente, err := m.repo.BuscarClienteOIDC(solicitud.ClientID)
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrClienteNoEncontrado) {
+			log.Println("Error al buscar el cliente OIDC:", err)
+		}
+		mostrarFormulario(c, http.StatusBadRequest, datosFormulario{Error: "Cliente desconocido"})
+		return nil, false
+	}
+	if !cliente.AceptaRedireccion(solicitud.RedirectURI) {
+		mostrarFormulario(c, http.StatusBadRequest, datosFormulario{Error: "La redirect_uri no está registrada para este cliente"})
+		return nil, false
+	}
+
+	switch {
+	case solicitud.ResponseType != "code":
+		redirigirConError(c, solicitud, "unsupported_response_type", "Solo se admite response_type=code")
+	case !contieneScope(solicitud.Scope, "openid"):
+		redirigirConError(c, solicitud, "invalid_scope", "Falta el scope openid")
+	case solicitud.CodeChallenge == "" || solicitud.CodeChallengeMethod != "S256":
+		// PKCE es obligatorio para todos los clientes, incluso los confidenciales
+		redirigirConError(c, solicitud, "invalid_request", "Se requiere PKCE con code_challenge_method=S256")
+	default:
+		return cliente, true
+	}
+	return nil, false
+}
+
+// Autorizar muestra el formulario de inicio de sesión (GET /authorize)
+func (m *ManejadorUsuarios) Autorizar(c *gin.Context) {
+	var solicitud solicitudAutorizacion
+	if err := c.ShouldBindQuery(&solicitud); err != nil {
+		mostrarFormulario(c, http.StatusBadRequest, datosFormulario{Error: "Solicitud inválida"})
+		return
+	}
+	cliente, ok := m.validarSolicitud(c, solicitud)
+	if !ok {
+		return
+	}
+	mostrarFormulario(c, http.StatusOK, datosFormulario{Solicitud: solicitud, Cliente: cliente.Nombre})
+}
+
+// AutorizarLogin recibe el formulario (POST /authorize): verifica las credenciales igual
+// que Login y redirige al cliente con un código de autorización. Si el usuario tiene el
+// segundo factor activo, antes vuelve a mostrar el formulario pidiendo el código.
+func (m *ManejadorUsuarios) AutorizarLogin(c *gin.Context) {
+	var solicitud solicitudAutorizacion
+	if err := c.ShouldBind(&solicitud); err != nil {
+		mostrarFormulario(c, http.StatusBadRequest, datosFormulario{Error: "Solicitud inválida"})
+		return
+	}
+	cliente, ok := m.validarSolicitud(c, solicitud)
+	if !ok {
+		return
+	}
+
+	// Segundo paso: llega el token_mfa del primero junto con el código
+	if tokenMFA := c.PostForm("token_mfa"); tokenMFA != "" {
+		usuarioID, err := m.completarSegundoFactor(tokenMFA, c.PostForm("codigo"))
+		if err != nil {
+			datos := datosFormulario{Solicitud: solicitud, Cliente: cliente.Nombre}
+			estado := http.StatusUnauthorized
+			var bloqueo *errorBloqueo
+			switch {
+			case errors.As(err, &bloqueo):
+				estado = http.StatusTooManyRequests
+				datos.TokenMFA, datos.Error = tokenMFA, "Demasiados intentos fallidos, intente de nuevo más tarde"
+			case errors.Is(err, errCodigoIncorrecto):
+				// Si se agotaron los intentos el token ya no sirve y el próximo envío pide la contraseña
+				datos.TokenMFA, datos.Error = tokenMFA, "Código incorrecto"
+			case errors.Is(err, errTokenMFAInvalido):
+				datos.Error = "Se venció el tiempo para ingresar el código, vuelva a iniciar sesión"
+			default:
+				log.Println("Error al verificar el segundo factor:", err)
+				datos.Error = "No se pudo iniciar sesión"
+			}
+			mostrarFormulario(c, estado, datos)
+			return
+		}
+		m.emitirCodigoAutorizacion(c, solicitud, cliente, usuarioID)
+		return
+	}
+
+	nombreUsuario := c.PostForm("nombre_usuario")
+	usuario, err := m.verificarCredenciales(nombreUsuario, c.PostForm("contrasena"), c.ClientIP())
+	if err != nil {
+		estado, mensaje := http.StatusUnauthorized, "Usuario o contraseña incorrectos"
+		var bloqueo *errorBloqueo
+		switch {
+		case errors.As(err, &bloqueo):
+			estado, mensaje = http.StatusTooManyRequests, "Demasiados intentos fallidos, intente de nuevo más tarde"
+		case errors.Is(err, errCorreoSinVerificar):
+			mensaje = "Debe verificar su correo antes de iniciar sesión"
+		case !errors.Is(err, errCredencialesIncorrectas):
+			log.Println("Error al verificar las credenciales:", err)
+		}
+		mostrarFormulario(c, estado, datosFormulario{
+			Solicitud:     solicitud,
+			Cliente:       cliente.Nombre,
+			NombreUsuario: nombreUsuario,
+			Error:         mensaje,
+		})
+		return
+	}
+
+	activo, err := m.segundoFactorActivo(usuario.ID)
+	if err != nil {
+		log.Println("Error al buscar el segundo factor:", err)
+		redirigirConError(c, solicitud, "server_error", "")
+		return
+	}
+	if activo {
+		tokenMFA, _, err := auth.GenerarTokenMFA(usuario.ID)
+		if err != nil {
+			log.Println("Error al generar el token_mfa:", err)
+			redirigirConError(c, solicitud, "server_error", "")
+			return
+		}
+		mostrarFormulario(c, http.StatusOK, datosFormulario{Solicitud: solicitud, Cliente: cliente.Nombre, TokenMFA: tokenMFA})
+		return
+	}
+
+	m.emitirCodigoAutorizacion(c, solicitud, cliente, usuario.ID)
+}
+
+// emitirCodigoAutorizacion guarda un código de autorización para el usuario ya
+// autenticado y redirige al cliente con él
+func (m *ManejadorUsuarios) emitirCodigoAutorizacion(c *gin.Context, solicitud solicitudAutorizacion, cliente *modelos.ClienteOIDC, usuarioID uint) {
+	codigo, hash, err := auth.GenerarSecreto()
+	if err != nil {
+		log.Println("Error al generar el código de autorización:", err)
+		redirigirConError(c, solicitud, "server_error", "")
+		return
+	}
+	ahora := time.Now()
+	err = m.repo.GuardarCodigoAutorizacion(&modelos.CodigoAutorizacion{
+		Hash:          hash,
+		ClienteID:     cliente.ClienteID,
+		UsuarioID:     usuarioID,
+		RedirectURI:   solicitud.RedirectURI,
+		Scope:         solicitud.Scope,
+		Nonce:         solicitud.Nonce,
+		DesafioPKCE:   solicitud.CodeChallenge,
+		AutenticadoEn: ahora,
+		ExpiraEn:      ahora.Add(duracionCodigoAutorizacion),
+	})
+	if err != nil {
+		log.Println("Error al guardar el código de autorización:", err)
+		redirigirConError(c, solicitud, "server_error", "")
+		return
+	}
+
+	redirigir(c, solicitud.RedirectURI, url.Values{"code": {codigo}, "state": {solicitud.State}})
+}
+
+// redirigirConError devuelve un error de OAuth 2.0 al cliente por su redirect_uri
+func redirigirConError(c *gin.Context, solicitud solicitudAutorizacion, codigo, descripcion string) {
+	parametros := url.Values{"error": {codigo}, "state": {solicitud.State}}
+	if descripcion != "" {
+		parametros.Set("error_description", descripcion)
+	}
+	redirigir(c, solicitud.RedirectURI, parametros)
+}
+
+// redirigir agrega los parámetros a la query de la URI (ya validada) y redirige con 302
+func redirigir(c *gin.Context, uri string, parametros url.Values) {
+	destino, err := url.Parse(uri)
+	if err != nil {
+		mostrarFormulario(c, http.StatusBadRequest, datosFormulario{Error: "redirect_uri inválida"})
+		return
+	}
+	query := destino.Query()
+	for clave, valores := range parametros {
+		if valores[0] != "" {
+			query[clave] = valores
+		}
+	}
+	destino.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, destino.String())
+}
+
+// errorToken responde un error del endpoint /token con el formato de OAuth 2.0
+func errorToken(c *gin.Context, estado int, codigo, descripcion string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(estado, gin.H{"error": codigo, "error_description": descripcion})
+}
+
+// Token cambia un código de autorización por un token de acceso que solo sirve para
+// /userinfo y el ID token (POST /token, grant_type=authorization_code)
+func (m *ManejadorUsuarios) Token(c *gin.Context) {
+	if c.PostForm("grant_type") != "authorization_code" {
+		errorToken(c, http.StatusBadRequest, "unsupported_grant_type", "Solo se admite grant_type=authorization_code")
+		return
+	}
+
+	// El cliente se identifica con HTTP Basic o con client_id (y client_secret) en el formulario
+	clienteID, secreto, basico := c.Request.BasicAuth()
+	if !basico {
+		clienteID, secreto = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	cliente, err := m.repo.BuscarClienteOIDC(clienteID)
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrClienteNoEncontrado) {
+			log.Println("Error al buscar el cliente OIDC:", err)
+		}
+		errorToken(c, http.StatusUnauthorized, "invalid_client", "Cliente desconocido")
+		return
+	}
+	if cliente.Confidencial() &&
+		subtle.ConstantTimeCompare([]byte(auth.HashSecreto(secreto)), []byte(cliente.SecretoHash)) != 1 {
+		errorToken(c, http.StatusUnauthorized, "invalid_client", "Secreto de cliente incorrecto")
+		return
+	}
+
+	// Cualquier problema con el código es invalid_grant, sin dar más detalles
+	codigo, err := m.repo.BuscarCodigoAutorizacion(auth.HashSecreto(c.PostForm("code")))
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrCodigoNoEncontrado) {
+			log.Println("Error al buscar el código de autorización:", err)
+		}
+		errorToken(c, http.StatusBadRequest, "invalid_grant", "Código de autorización inválido")
+		return
+	}
+	ahora := time.Now()
+	if codigo.ClienteID != cliente.ClienteID || codigo.RedirectURI != c.PostForm("redirect_uri") ||
+		ahora.After(codigo.ExpiraEn) || !auth.VerificarPKCE(c.PostForm("code_verifier"), codigo.DesafioPKCE) {
+		errorToken(c, http.StatusBadRequest, "invalid_grant", "Código de autorización inválido")
+		return
+	}
+	usado, err := m.repo.MarcarCodigoAutorizacionUsado(codigo.ID, ahora)
+	if err != nil {
+		log.Println("Error al marcar el código de autorización:", err)
+		errorToken(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if !usado {
+		log.Printf("Reutilización del código de autorización del cliente %s (usuario ID %d)", cliente.ClienteID, codigo.UsuarioID)
+		errorToken(c, http.StatusBadRequest, "invalid_grant", "Código de autorización inválido")
+		return
+	}
+
+	usuario, err := m.repo.BuscarPorID(codigo.UsuarioID)
+	if err != nil {
+		log.Println("Error al buscar el usuario del código de autorización:", err)
+		errorToken(c, http.StatusBadRequest, "invalid_grant", "Código de autorización inválido")
+		return
+	}
+	// El cliente recibe un token limitado al scope otorgado y sin token de refresco: los
+	// tokens de la API, con todos los permisos del usuario, son solo para el propio usuario
+	token, err := auth.GenerarTokenCliente(usuario.ID, cliente.ClienteID, codigo.Scope)
+	if err != nil {
+		log.Println("Error al generar el token de acceso:", err)
+		errorToken(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	idToken, err := auth.GenerarIDToken(m.opciones.EmisorOIDC, cliente.ClienteID, usuario, codigo.Nonce, codigo.AutenticadoEn)
+	if err != nil {
+		log.Println("Error al generar el ID token:", err)
+		errorToken(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(auth.DuracionToken().Seconds()),
+		"id_token":     idToken,
+		"scope":        codigo.Scope,
+	})
+}
+
+// UserInfo devuelve los datos del usuario dueño del token de acceso (GET /userinfo)
+func (m *ManejadorUsuarios) UserInfo(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	usuario, err := m.repo.BuscarPorID(reclamos.Id)
+	if err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+			return
+		}
+		log.Println("Error al consultar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"sub":            strconv.FormatUint(uint64(usuario.ID), 10),
+		"nombre_usuario": usuario.NombreUsuario,
+		"correo":         usuario.Correo,
+	})
+}
+
+// ConfiguracionOIDC publica el documento de descubrimiento (/.well-known/openid-configuration)
+func (m *ManejadorUsuarios) ConfiguracionOIDC(c *gin.Context) {
+	emisor := m.opciones.EmisorOIDC
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                emisor,
+		"authorization_endpoint":                emisor + "/authorize",
+		"token_endpoint":                        emisor + "/token",
+		"userinfo_endpoint":                     emisor + "/userinfo",
+		"jwks_uri":                              emisor + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{auth.AlgoritmoFirma()},
+		"scopes_supported":                      []string{"openid"},
+		"token_endpoint_auth_methods_supported": []string{"none", "client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "nombre_usuario", "correo"},
+	})
+}
+
+// contieneScope indica si la lista de scopes separada por espacios incluye el indicado
+func contieneScope(scopes, buscado string) bool {
+	for _, scope := range strings.Fields(scopes) {
+		if scope == buscado {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+package manejadores
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"taller6/auth"
+	"taller6/modelos"
+	"testing"
+)
+
+// Emisor y redirect_uri de las pruebas de OpenID Connect
+const (
+	emisorPrueba   = "https://auth.ejemplo.com"
+	redirectPrueba = "https://app.ejemplo.com/callback"
+)
+
+// Verificador y desafío del apéndice B de RFC 7636
+const (
+	verificadorPrueba = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	desafioPrueba     = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+// crearCliente registra un cliente OIDC con redirectPrueba; si es confidencial devuelve su secreto
+func (p *pruebaLogin) crearCliente(t *testing.T, confidencial bool) (*modelos.ClienteOIDC, string) {
+	t.Helper()
+	clienteID, err := auth.GenerarClienteID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliente := &modelos.ClienteOIDC{ClienteID: clienteID, Nombre: "App", RedirectURIs: []string{redirectPrueba, redirectPrueba + "/otra"}}
+	var secreto string
+	if confidencial {
+		if secreto, cliente.SecretoHash, err = auth.GenerarSecreto(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.repo.CrearClienteOIDC(cliente); err != nil {
+		t.Fatal(err)
+	}
+	return cliente, secreto
+}
+
+// enviarFormulario hace un POST con el formulario y, si se indica, con HTTP Basic
+func (p *pruebaLogin) enviarFormulario(ruta string, formulario url.Values, usuario, clave string) *httptest.ResponseRecorder {
+	pedido := httptest.NewRequest(http.MethodPost, ruta, strings.NewReader(formulario.Encode()))
+	pedido.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	pedido.RemoteAddr = "192.0.2.1:40000"
+	if usuario != "" {
+		pedido.SetBasicAuth(usuario, clave)
+	}
+	respuesta := httptest.NewRecorder()
+	p.servidor.ServeHTTP(respuesta, pedido)
+	return respuesta
+}
+
+// solicitudPrueba son los parámetros de /authorize para el cliente
+func solicitudPrueba(clienteID string) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clienteID},
+		"redirect_uri":          {redirectPrueba},
+		"scope":                 {"openid"},
+		"state":                 {"estado-123"},
+		"nonce":                 {"nonce-456"},
+		"code_challenge":        {desafioPrueba},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// autorizar inicia sesión en POST /authorize como "ana" y devuelve el código de autorización
+func (p *pruebaLogin) autorizar(t *testing.T, solicitud url.Values) string {
+	t.Helper()
+	formulario := url.Values{"nombre_usuario": {"ana"}, "contrasena": {contrasenaPrueba}}
+	for clave, valores := range solicitud {
+		formulario[clave] = valores
+	}
+	respuesta := p.enviarFormulario("/authorize", formulario, "", "")
+	if respuesta.Code != http.StatusFound {
+		t.Fatalf("POST /authorize devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+	destino, err := url.Parse(respuesta.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base := destino.Scheme + "://" + destino.Host + destino.Path; base != solicitud.Get("redirect_uri") {
+		t.Fatalf("se redirigió a %s, se esperaba %s", base, solicitud.Get("redirect_uri"))
+	}
+	if estado := destino.Query().Get("state"); estado != solicitud.Get("state") {
+		t.Errorf("el state es %q, se esperaba %q", estado, solicitud.Get("state"))
+	}
+	codigo := destino.Query().Get("code")
+	if codigo == "" {
+		t.Fatalf("la redirección no trae el código: %s", destino)
+	}
+	return codigo
+}
+
+// canjear hace POST /token con el código; los campos de extra pisan o agregan los del formulario
+func (p *pruebaLogin) canjear(codigo string, extra url.Values, usuario, clave string) *httptest.ResponseRecorder {
+	formulario := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {codigo},
+		"redirect_uri":  {redirectPrueba},
+		"code_verifier": {verificadorPrueba},
+	}
+	for campo, valores := range extra {
+		formulario[campo] = valores
+	}
+	return p.enviarFormulario("/token", formulario, usuario, clave)
+}
+
+// errorOAuth devuelve el campo error de una respuesta de /token
+func errorOAuth(t *testing.T, respuesta *httptest.ResponseRecorder) string {
+	t.Helper()
+	var cuerpo struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(respuesta.Body.Bytes(), &cuerpo); err != nil {
+		t.Fatal(err)
+	}
+	return cuerpo.Error
+}
+
+func TestFlujoOIDC(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{EmisorOIDC: emisorPrueba})
+	usuario := p.crearUsuario(t, "ana")
+	cliente, _ := p.crearCliente(t, false)
+
+	codigo := p.autorizar(t, solicitudPrueba(cliente.ClienteID))
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		IDToken     string `json:"id_token"`
+		Scope       string `json:"scope"`
+	}
+	respuesta := p.canjear(codigo, url.Values{"client_id": {cliente.ClienteID}}, "", "")
+	decodificar(t, respuesta, http.StatusOK, &tokens)
+	if tokens.TokenType != "Bearer" || tokens.Scope != "openid" || tokens.AccessToken == "" {
+		t.Errorf("la respuesta de /token es %+v", tokens)
+	}
+	if respuesta.Header().Get("Cache-Control") != "no-store" {
+		t.Error("la respuesta de /token se puede guardar en cache")
+	}
+
+	// El ID token es para el cliente, con el nonce de la solicitud
+	partes := strings.Split(tokens.IDToken, ".")
+	if len(partes) != 3 {
+		t.Fatalf("el ID token %q no es un JWT", tokens.IDToken)
+	}
+	contenido, err := base64.RawURLEncoding.DecodeString(partes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reclamos struct {
+		Iss   string `json:"iss"`
+		Sub   string `json:"sub"`
+		Aud   any    `json:"aud"`
+		Nonce string `json:"nonce"`
+	}
+	if err := json.Unmarshal(contenido, &reclamos); err != nil {
+		t.Fatal(err)
+	}
+	audiencia, _ := json.Marshal(reclamos.Aud)
+	if reclamos.Iss != emisorPrueba || reclamos.Sub != strconv.FormatUint(uint64(usuario.ID), 10) ||
+		reclamos.Nonce != "nonce-456" || !strings.Contains(string(audiencia), cliente.ClienteID) {
+		t.Errorf("los reclamos del ID token son %+v", reclamos)
+	}
+
+	// El token de acceso sirve para /userinfo
+	var datos map[string]string
+	respuesta = p.pedir(t, http.MethodGet, "/userinfo", "192.0.2.1", tokens.AccessToken, nil)
+	decodificar(t, respuesta, http.StatusOK, &datos)
+	if datos["nombre_usuario"] != "ana" {
+		t.Errorf("/userinfo devolvió %v", datos)
+	}
+
+	// El código es de un solo uso
+	respuesta = p.canjear(codigo, url.Values{"client_id": {cliente.ClienteID}}, "", "")
+	if respuesta.Code != http.StatusBadRequest || errorOAuth(t, respuesta) != "invalid_grant" {
+		t.Errorf("reusar el código devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+}
+
+func TestTokenRechazaCodigo(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{EmisorOIDC: emisorPrueba})
+	p.crearUsuario(t, "ana")
+	cliente, _ := p.crearCliente(t, false)
+	otro, _ := p.crearCliente(t, false)
+
+	// Un canje fallido no gasta el código: después el canje correcto todavía funciona
+	casos := []struct {
+		nombre string
+		extra  url.Values
+	}{
+		{"otra redirect_uri registrada", url.Values{"redirect_uri": {redirectPrueba + "/otra"}}},
+		{"sin redirect_uri", url.Values{"redirect_uri": {""}}},
+		{"otro verificador", url.Values{"code_verifier": {strings.Repeat("a", 43)}}},
+		{"sin verificador", url.Values{"code_verifier": {""}}},
+		{"el código de otro cliente", url.Values{"client_id": {otro.ClienteID}}},
+		{"código inventado", url.Values{"code": {"inventado"}}},
+	}
+	for _, caso := range casos {
+		codigo := p.autorizar(t, solicitudPrueba(cliente.ClienteID))
+		extra := url.Values{"client_id": {cliente.ClienteID}}
+		for campo, valores := range caso.extra {
+			extra[campo] = valores
+		}
+		respuesta := p.canjear(codigo, extra, "", "")
+		if respuesta.Code != http.StatusBadRequest || errorOAuth(t, respuesta) != "invalid_grant" {
+			t.Errorf("%s: se obtuvo %d: %s", caso.nombre, respuesta.Code, respuesta.Body)
+		}
+		if respuesta := p.canjear(codigo, url.Values{"client_id": {cliente.ClienteID}}, "", ""); respuesta.Code != http.StatusOK {
+			t.Errorf("%s: después, el canje correcto devolvió %d", caso.nombre, respuesta.Code)
+		}
+	}
+}
+
+func TestAutorizarRechazaRedirectURI(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{EmisorOIDC: emisorPrueba})
+	p.crearUsuario(t, "ana")
+	cliente, _ := p.crearCliente(t, false)
+
+	// Una redirect_uri sin registrar nunca recibe una redirección, ni siquiera con un error
+	for _, uri := range []string{"https://atacante.ejemplo.com/callback", redirectPrueba + "/", redirectPrueba + "?x=1", "https://APP.ejemplo.com/callback"} {
+		solicitud := solicitudPrueba(cliente.ClienteID)
+		solicitud.Set("redirect_uri", uri)
+		pedido := httptest.NewRequest(http.MethodGet, "/authorize?"+solicitud.Encode(), nil)
+		respuesta := httptest.NewRecorder()
+		p.servidor.ServeHTTP(respuesta, pedido)
+		if respuesta.Code != http.StatusBadRequest || respuesta.Header().Get("Location") != "" {
+			t.Errorf("GET /authorize con %s devolvió %d (Location %q)", uri, respuesta.Code, respuesta.Header().Get("Location"))
+		}
+
+		formulario := url.Values{"nombre_usuario": {"ana"}, "contrasena": {contrasenaPrueba}}
+		for clave, valores := range solicitud {
+			formulario[clave] = valores
+		}
+		if respuesta := p.enviarFormulario("/authorize", formulario, "", ""); respuesta.Code != http.StatusBadRequest || respuesta.Header().Get("Location") != "" {
+			t.Errorf("POST /authorize con %s devolvió %d (Location %q)", uri, respuesta.Code, respuesta.Header().Get("Location"))
+		}
+	}
+
+	// Sin PKCE el error vuelve al cliente por su redirect_uri
+	solicitud := solicitudPrueba(cliente.ClienteID)
+	solicitud.Del("code_challenge")
+	pedido := httptest.NewRequest(http.MethodGet, "/authorize?"+solicitud.Encode(), nil)
+	respuesta := httptest.NewRecorder()
+	p.servidor.ServeHTTP(respuesta, pedido)
+	destino, _ := url.Parse(respuesta.Header().Get("Location"))
+	if respuesta.Code != http.StatusFound || destino.Query().Get("error") != "invalid_request" {
+		t.Errorf("sin PKCE se obtuvo %d (Location %q)", respuesta.Code, respuesta.Header().Get("Location"))
+	}
+}
+
+func TestTokenClienteConfidencial(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{EmisorOIDC: emisorPrueba})
+	p.crearUsuario(t, "ana")
+	cliente, secreto := p.crearCliente(t, true)
+
+	casos := []struct {
+		nombre         string
+		extra          url.Values
+		usuario, clave string
+		estado         int
+	}{
+		{"sin secreto", url.Values{"client_id": {cliente.ClienteID}}, "", "", http.StatusUnauthorized},
+		{"secreto incorrecto en el formulario", url.Values{"client_id": {cliente.ClienteID}, "client_secret": {"incorrecto"}}, "", "", http.StatusUnauthorized},
+		{"secreto incorrecto por HTTP Basic", nil, cliente.ClienteID, "incorrecto", http.StatusUnauthorized},
+		{"cliente desconocido", nil, "desconocido", secreto, http.StatusUnauthorized},
+		{"secreto en el formulario", url.Values{"client_id": {cliente.ClienteID}, "client_secret": {secreto}}, "", "", http.StatusOK},
+		{"secreto por HTTP Basic", nil, cliente.ClienteID, secreto, http.StatusOK},
+	}
+	for _, caso := range casos {
+		codigo := p.autorizar(t, solicitudPrueba(cliente.ClienteID))
+		respuesta := p.canjear(codigo, caso.extra, caso.usuario, caso.clave)
+		if respuesta.Code != caso.estado {
+			t.Errorf("%s: se obtuvo %d, se esperaba %d: %s", caso.nombre, respuesta.Code, caso.estado, respuesta.Body)
+			continue
+		}
+		if caso.estado == http.StatusUnauthorized && errorOAuth(t, respuesta) != "invalid_client" {
+			t.Errorf("%s: el error es %s, se esperaba invalid_client", caso.nombre, respuesta.Body)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package manejadores
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"taller6/contrasenas"
+	"taller6/modelos"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validarContrasenaNueva controla la política de contraseñas y, si el usuario ya existe
+// (anterior no es nil), que no repita la actual ni las guardadas en el historial. Si algo
+// no se cumple responde 400 con todas las reglas incumplidas y devuelve false.
+func (m *ManejadorUsuarios) validarContrasenaNueva(c *gin.Context, contrasena string, datos contrasenas.Datos, anterior *modelos.Usuario) bool {
+	incumplidas, err := m.opciones.PoliticaContrasena.Validar(contrasena, datos)
+	if err != nil {
+		log.Println("Error al consultar las contraseñas filtradas:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo validar la contraseña"})
+		return false
+	}
+
+	if anterior != nil && len(incumplidas) == 0 {
+		repetida, err := m.contrasenaRepetida(anterior, contrasena)
+		if err != nil {
+			log.Println("Error al buscar el historial de contraseñas:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo validar la contraseña"})
+			return false
+		}
+		if repetida {
+			incumplidas = append(incumplidas, contrasenas.Incumplimiento{Regla: "historial",
+				Mensaje: fmt.Sprintf("La contraseña no puede ser igual a ninguna de las últimas %d", m.opciones.HistorialContrasenas)})
+		}
+	}
+
+	if len(incumplidas) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La contraseña no cumple la política", "reglas": incumplidas})
+		return false
+	}
+	return true
+}
+
+// contrasenaRepetida compara la contraseña contra la actual y las anteriores. El historial
+// se controla solo si la contraseña cumple el resto, porque cada comparación es lenta a propósito.
+func (m *ManejadorUsuarios) contrasenaRepetida(usuario *modelos.Usuario, contrasena string) (bool, error) {
+	if m.opciones.HistorialContrasenas <= 0 {
+		return false, nil
+	}
+	// La actual cuenta como una de las últimas N
+	anteriores, err := m.repo.ContrasenasAnteriores(usuario.ID, m.opciones.HistorialContrasenas-1)
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range append([]string{usuario.Contrasena}, anteriores...) {
+		// Un hash que no se puede leer no impide el cambio
+		if coincide, _, _ := m.opciones.Hasheador.Verificar(hash, contrasena); coincide {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// guardarContrasenaAnterior pasa el hash que el usuario dejó de usar al historial. Si
+// falla solo se registra: la contraseña nueva ya quedó guardada.
+func (m *ManejadorUsuarios) guardarContrasenaAnterior(anterior *modelos.Usuario, momento time.Time) {
+	if m.opciones.HistorialContrasenas <= 0 || anterior.Contrasena == "" {
+		return
+	}
+	if err := m.repo.AgregarContrasenaAnterior(anterior.ID, anterior.Contrasena, momento, m.opciones.HistorialContrasenas-1); err != nil {
+		log.Println("Error al guardar el historial de contraseñas:", err)
+	}
+}
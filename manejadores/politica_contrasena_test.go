@@ -0,0 +1,50 @@
+package manejadores
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContrasenaRepetida(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{})
+	usuario := p.crearUsuario(t, "ana")
+	// Historial del más viejo al más nuevo; un hash que no se puede leer no impide nada
+	for _, anterior := range []string{"vieja-1", "vieja-2", "", "vieja-3"} {
+		hash := "hash-ilegible"
+		if anterior != "" {
+			var err error
+			if hash, err = hasheadorPrueba.Hashear(anterior); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.repo.AgregarContrasenaAnterior(usuario.ID, hash, time.Now(), 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	casos := []struct {
+		historial  int
+		contrasena string
+		repetida   bool
+	}{
+		{0, contrasenaPrueba, false},
+		{1, contrasenaPrueba, true},
+		{1, "vieja-3", false},
+		{2, "vieja-3", true},
+		// La actual cuenta como una de las últimas N: con 4 entran la actual y 3 del historial
+		{4, "vieja-2", true},
+		{4, "vieja-1", false},
+		{5, "vieja-1", true},
+		{5, "nueva", false},
+	}
+	for _, caso := range casos {
+		p.usuarios.opciones.HistorialContrasenas = caso.historial
+		repetida, err := p.usuarios.contrasenaRepetida(usuario, caso.contrasena)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repetida != caso.repetida {
+			t.Errorf("historial %d, %q: repetida=%v, se esperaba %v", caso.historial, caso.contrasena, repetida, caso.repetida)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"taller6/modelos"
+	"taller6/repositorio"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListarRoles devuelve todos los roles con sus permisos
+func (m *ManejadorUsuarios) ListarRoles(c *gin.Context) {
+	roles, err := m.repo.ListarRoles()
+	if err != nil {
+		log.Println("Error al consultar los roles:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar los roles"})
+		return
+	}
+	if roles == nil {
+		roles = []modelos.Rol{}
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// AsignarRoles reemplaza los roles de un usuario. Los cambios se ven en el próximo login.
+func (m *ManejadorUsuarios) AsignarRoles(c *gin.Context) {
+	idInt, ok := idObjetivo(c)
+	if !ok {
+		return
+	}
+
+	var datos struct {
+		Roles []string `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil || datos.Roles == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	if err := m.repo.AsignarRoles(uint(idInt), datos.Roles); err != nil {
+		switch {
+		case errors.Is(err, repositorio.ErrUsuarioNoEncontrado):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		case errors.Is(err, repositorio.ErrRolNoEncontrado):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Rol inexistente"})
+		default:
+			log.Println("Error al asignar los roles:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron asignar los roles"})
+		}
+		return
+	}
+
+	roles, err := m.repo.RolesDeUsuario(uint(idInt))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar los roles"})
+		return
+	}
+	if roles == nil {
+		roles = []modelos.Rol{}
+	}
+	c.JSON(http.StatusOK, roles)
+}
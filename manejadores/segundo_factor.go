@@ -0,0 +1,458 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"taller6/auth"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Códigos incorrectos que se aceptan por token_mfa antes de revocarlo; después hay
+// que volver a ingresar la contraseña
+const maxIntentosSegundoFactor = 5
+
+// intentosMFA cuenta los códigos incorrectos de cada token_mfa (por su jti). Vive en
+// memoria: con varias instancias cada una cuenta los suyos, pero el token dura pocos minutos.
+type intentosMFA struct {
+	fallos int
+	expira time.Time
+}
+
+// Errores de completarSegundoFactor
+var (
+	errTokenMFAInvalido = errors.New("el token_mfa no es válido")
+	errCodigoIncorrecto = errors.New("el código no es válido")
+)
+
+// ObtenerSegundoFactor informa si el usuario tiene el segundo factor activo (GET /me/2fa)
+func (m *ManejadorUsuarios) ObtenerSegundoFactor(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+
+	factor, err := m.repo.BuscarSegundoFactor(reclamos.Id)
+	if errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado) {
+		c.JSON(http.StatusOK, gin.H{"activado": false, "pendiente": false})
+		return
+	}
+	if err != nil {
+		log.Println("Error al buscar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el segundo factor"})
+		return
+	}
+	restantes, err := m.repo.CodigosRecuperacionRestantes(reclamos.Id)
+	if err != nil {
+		log.Println("Error al contar los códigos de recuperación:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el segundo factor"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"activado":                       factor.Activo(),
+		"pendiente":                      !factor.Activo(),
+		"codigos_recuperacion_restantes": restantes,
+	})
+}
+
+// InscribirSegundoFactor genera un secreto TOTP nuevo (POST /me/2fa). Queda pendiente
+// hasta que el usuario lo confirme con un código, así un error al cargarlo en la
+// aplicación no lo deja afuera de su cuenta.
+func (m *ManejadorUsuarios) InscribirSegundoFactor(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+
+	usuario, err := m.repo.BuscarPorID(reclamos.Id)
+	if err != nil {
+		log.Println("Error al consultar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		return
+	}
+	factor, err := m.repo.BuscarSegundoFactor(usuario.ID)
+	switch {
+	case err == nil && factor.Activo():
+		// Para cambiar de teléfono hay que desactivarlo primero, con un código del actual
+		c.JSON(http.StatusConflict, gin.H{"error": "El segundo factor ya está activado"})
+		return
+	case err != nil && !errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado):
+		log.Println("Error al buscar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el segundo factor"})
+		return
+	}
+
+	secreto, err := auth.GenerarSecretoTOTP()
+	if err != nil {
+		log.Println("Error al generar el secreto TOTP:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo inscribir el segundo factor"})
+		return
+	}
+	err = m.repo.GuardarSegundoFactor(&modelos.SegundoFactor{
+		UsuarioID: usuario.ID,
+		Secreto:   secreto,
+		CreadoEn:  time.Now(),
+	})
+	if err != nil {
+		log.Println("Error al guardar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo inscribir el segundo factor"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"secreto": secreto,
+		"uri":     auth.URITOTP(m.opciones.EmisorTOTP, usuario.NombreUsuario, secreto),
+	})
+}
+
+// ConfirmarSegundoFactor activa el segundo factor con un código de la aplicación
+// (POST /me/2fa/confirmar) y devuelve los códigos de recuperación, que no se vuelven a mostrar
+func (m *ManejadorUsuarios) ConfirmarSegundoFactor(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	var datos struct {
+		Codigo string `json:"codigo" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	factor, err := m.repo.BuscarSegundoFactor(reclamos.Id)
+	if err != nil {
+		if errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No hay un segundo factor para confirmar"})
+			return
+		}
+		log.Println("Error al buscar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el segundo factor"})
+		return
+	}
+	if factor.Activo() {
+		c.JSON(http.StatusConflict, gin.H{"error": "El segundo factor ya está activado"})
+		return
+	}
+
+	ahora := time.Now()
+	paso, ok := auth.VerificarTOTP(factor.Secreto, datos.Codigo, ahora)
+	if ok {
+		// El código de la confirmación tampoco sirve después para iniciar sesión
+		ok, err = m.repo.RegistrarPasoTOTP(factor.UsuarioID, paso)
+		if err != nil {
+			log.Println("Error al registrar el código TOTP:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo confirmar el segundo factor"})
+			return
+		}
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Código incorrecto"})
+		return
+	}
+
+	codigos, err := m.reemplazarCodigosRecuperacion(factor.UsuarioID)
+	if err != nil {
+		log.Println("Error al generar los códigos de recuperación:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo confirmar el segundo factor"})
+		return
+	}
+	if err := m.repo.ConfirmarSegundoFactor(factor.UsuarioID, ahora); err != nil {
+		log.Println("Error al confirmar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo confirmar el segundo factor"})
+		return
+	}
+
+	log.Printf("Usuario ID %d activó el segundo factor", factor.UsuarioID)
+	c.JSON(http.StatusOK, gin.H{"codigos_recuperacion": codigos})
+}
+
+// RegenerarCodigosRecuperacion reemplaza los códigos de recuperación por otros nuevos
+// (POST /me/2fa/codigos-recuperacion). Pide un código para que un token robado no alcance.
+func (m *ManejadorUsuarios) RegenerarCodigosRecuperacion(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	if !m.exigirSegundoFactor(c, reclamos.Id) {
+		return
+	}
+
+	codigos, err := m.reemplazarCodigosRecuperacion(reclamos.Id)
+	if err != nil {
+		log.Println("Error al generar los códigos de recuperación:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron generar los códigos de recuperación"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"codigos_recuperacion": codigos})
+}
+
+// DesactivarSegundoFactor borra el segundo factor propio (DELETE /me/2fa), con un código
+// de la aplicación o de recuperación
+func (m *ManejadorUsuarios) DesactivarSegundoFactor(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	if !m.exigirSegundoFactor(c, reclamos.Id) {
+		return
+	}
+
+	if err := m.repo.EliminarSegundoFactor(reclamos.Id); err != nil {
+		log.Println("Error al eliminar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo desactivar el segundo factor"})
+		return
+	}
+	log.Printf("Usuario ID %d desactivó el segundo factor", reclamos.Id)
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Segundo factor desactivado"})
+}
+
+// EliminarSegundoFactorDeUsuario borra el segundo factor de otro usuario, para quien
+// perdió el teléfono y los códigos de recuperación. Solo por /usuarios/:id/2fa.
+func (m *ManejadorUsuarios) EliminarSegundoFactorDeUsuario(c *gin.Context) {
+	idInt, ok := idObjetivo(c)
+	if !ok {
+		return
+	}
+	id := uint(idInt)
+
+	if _, err := m.repo.BuscarPorID(id); err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		log.Println("Error al consultar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		return
+	}
+	if err := m.repo.EliminarSegundoFactor(id); err != nil {
+		log.Println("Error al eliminar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo desactivar el segundo factor"})
+		return
+	}
+
+	log.Printf("Usuario ID %s desactivó el segundo factor del usuario ID %d", c.GetString("id_usuario"), id)
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Segundo factor desactivado"})
+}
+
+// LoginSegundoFactor cambia el token_mfa que devolvió Login y un código válido por los
+// tokens de acceso y de refresco (POST /login/2fa)
+func (m *ManejadorUsuarios) LoginSegundoFactor(c *gin.Context) {
+	var datos struct {
+		TokenMFA string `json:"token_mfa" binding:"required"`
+		Codigo   string `json:"codigo" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	usuarioID, err := m.completarSegundoFactor(datos.TokenMFA, datos.Codigo)
+	if err != nil {
+		var bloqueo *errorBloqueo
+		switch {
+		case errors.As(err, &bloqueo):
+			responderBloqueo(c, bloqueo) //429
+		case errors.Is(err, errTokenMFAInvalido):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o vencido, vuelva a iniciar sesión"})
+		case errors.Is(err, errCodigoIncorrecto):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Código incorrecto"})
+		default:
+			log.Println("Error al verificar el segundo factor:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+		}
+		return
+	}
+
+	tokens, err := m.emitirTokens(usuarioID, "")
+	if err != nil {
+		log.Println("Error al generar los tokens:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el token"})
+		return
+	}
+	c.JSON(http.StatusCreated, tokens)
+}
+
+// respuestaMFA es lo que devuelve Login, en lugar de los tokens, a quien tiene el
+// segundo factor activo
+type respuestaMFA struct {
+	MFARequerido bool      `json:"mfa_requerido"`
+	TokenMFA     string    `json:"token_mfa"`
+	ExpiraEn     time.Time `json:"expira_en"`
+}
+
+// segundoFactorActivo indica si el login del usuario tiene que pedir el código
+func (m *ManejadorUsuarios) segundoFactorActivo(usuarioID uint) (bool, error) {
+	factor, err := m.repo.BuscarSegundoFactor(usuarioID)
+	if errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return factor.Activo(), nil
+}
+
+// completarSegundoFactor valida el token_mfa y el código. Si el código es correcto
+// revoca el token_mfa (sirve una sola vez); después de maxIntentosSegundoFactor
+// códigos incorrectos también. Los códigos incorrectos cuentan además para el bloqueo de
+// la cuenta, que puede devolver un *errorBloqueo. La usan LoginSegundoFactor y el
+// formulario de OpenID Connect.
+func (m *ManejadorUsuarios) completarSegundoFactor(tokenMFA, codigo string) (uint, error) {
+	reclamos, err := auth.ValidarTokenMFA(tokenMFA)
+	if err != nil {
+		return 0, errTokenMFAInvalido
+	}
+
+	ok, err := m.verificarSegundoFactorContando(reclamos.Id, codigo)
+	if err != nil {
+		return 0, err
+	}
+	if !ok && !m.agotoIntentosMFA(reclamos) {
+		return 0, errCodigoIncorrecto
+	}
+
+	if err := m.repo.RevocarTokenAcceso(reclamos.ID, reclamos.Id, reclamos.ExpiresAt.Time); err != nil {
+		return 0, err
+	}
+	auth.NotificarTokenRevocado(reclamos.ID, reclamos.ExpiresAt.Time)
+	if !ok {
+		log.Printf("Se revocó el token_mfa del usuario ID %d por demasiados códigos incorrectos", reclamos.Id)
+		return 0, errCodigoIncorrecto
+	}
+	return reclamos.Id, nil
+}
+
+// agotoIntentosMFA anota un código incorrecto para el token_mfa y devuelve true al
+// llegar al máximo. De paso olvida los contadores de tokens que ya expiraron.
+func (m *ManejadorUsuarios) agotoIntentosMFA(reclamos *auth.Reclamos) bool {
+	m.muMFA.Lock()
+	defer m.muMFA.Unlock()
+
+	ahora := time.Now()
+	for jti, intento := range m.intentosMFA {
+		if ahora.After(intento.expira) {
+			delete(m.intentosMFA, jti)
+		}
+	}
+	intento := m.intentosMFA[reclamos.ID]
+	intento.fallos++
+	intento.expira = reclamos.ExpiresAt.Time
+	if intento.fallos >= maxIntentosSegundoFactor {
+		delete(m.intentosMFA, reclamos.ID)
+		return true
+	}
+	m.intentosMFA[reclamos.ID] = intento
+	return false
+}
+
+// exigirSegundoFactor pide un código válido en el cuerpo ({"codigo": ...}) antes de
+// cambiar el segundo factor. Si devuelve false ya respondió.
+func (m *ManejadorUsuarios) exigirSegundoFactor(c *gin.Context, usuarioID uint) bool {
+	var datos struct {
+		Codigo string `json:"codigo" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return false
+	}
+
+	activo, err := m.segundoFactorActivo(usuarioID)
+	if err != nil {
+		log.Println("Error al buscar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el segundo factor"})
+		return false
+	}
+	if !activo {
+		c.JSON(http.StatusNotFound, gin.H{"error": "El segundo factor no está activado"})
+		return false
+	}
+
+	ok, err := m.verificarSegundoFactorContando(usuarioID, datos.Codigo)
+	if err != nil {
+		var bloqueo *errorBloqueo
+		if errors.As(err, &bloqueo) {
+			responderBloqueo(c, bloqueo)
+			return false
+		}
+		log.Println("Error al verificar el segundo factor:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el segundo factor"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Código incorrecto"})
+		return false
+	}
+	return true
+}
+
+// claveSegundoFactor es la clave con la que se cuentan los códigos incorrectos del
+// usuario: la misma de la cuenta en Login, así adivinar códigos bloquea la cuenta igual
+// que adivinar contraseñas. Si Login no controla las cuentas, el máximo es
+// maxIntentosSegundoFactor.
+func (m *ManejadorUsuarios) claveSegundoFactor(nombreUsuario string) *claveIntentos {
+	maximo := m.opciones.MaxIntentosLogin
+	if maximo <= 0 {
+		maximo = maxIntentosSegundoFactor
+	}
+	return &claveIntentos{clave: claveCuenta(nombreUsuario), maximo: maximo}
+}
+
+// verificarSegundoFactorContando es verificarSegundoFactor con el control de intentos
+// de verificarCredenciales: si la cuenta tiene que esperar devuelve un *errorBloqueo sin
+// mirar el código, y si no anota el fallo antes de comparar y lo borra si el código es
+// correcto.
+func (m *ManejadorUsuarios) verificarSegundoFactorContando(usuarioID uint, codigo string) (bool, error) {
+	usuario, err := m.repo.BuscarPorID(usuarioID)
+	if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ahora := time.Now()
+	clave := m.claveSegundoFactor(usuario.NombreUsuario)
+	if err := m.controlarIntentos(ahora, clave); err != nil {
+		return false, err
+	}
+	intentos, err := m.registrarFallo(clave, ahora)
+	if err != nil {
+		return false, err
+	}
+	if intentos.Fallos > clave.maximo {
+		return false, &errorBloqueo{hasta: m.esperaHasta(intentos, clave.maximo)}
+	}
+
+	ok, err := m.verificarSegundoFactor(usuarioID, codigo)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := m.repo.ReiniciarIntentosLogin(clave.clave); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verificarSegundoFactor acepta un código de la aplicación que no se haya usado antes
+// o un código de recuperación sin usar, que queda consumido
+func (m *ManejadorUsuarios) verificarSegundoFactor(usuarioID uint, codigo string) (bool, error) {
+	factor, err := m.repo.BuscarSegundoFactor(usuarioID)
+	if errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !factor.Activo() {
+		return false, nil
+	}
+
+	ahora := time.Now()
+	if paso, ok := auth.VerificarTOTP(factor.Secreto, codigo, ahora); ok {
+		return m.repo.RegistrarPasoTOTP(usuarioID, paso)
+	}
+	return m.repo.UsarCodigoRecuperacion(usuarioID, auth.HashCodigoRecuperacion(codigo), ahora)
+}
+
+// reemplazarCodigosRecuperacion genera códigos nuevos, guarda sus hashes (los anteriores
+// dejan de valer) y devuelve los códigos en claro para mostrarlos una vez
+func (m *ManejadorUsuarios) reemplazarCodigosRecuperacion(usuarioID uint) ([]string, error) {
+	codigos, hashes, err := auth.GenerarCodigosRecuperacion()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.repo.ReemplazarCodigosRecuperacion(usuarioID, hashes); err != nil {
+		return nil, err
+	}
+	return codigos, nil
+}
@@ -0,0 +1,234 @@
+package manejadores
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller6/auth"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// codigoTOTPPrueba calcula el código de la aplicación autenticadora en el momento indicado
+// (RFC 6238 con SHA-1, 6 dígitos y pasos de 30 segundos)
+func codigoTOTPPrueba(t *testing.T, secreto string, momento time.Time) string {
+	t.Helper()
+	clave, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secreto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha1.New, clave)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(momento.Unix()/30)))
+	suma := mac.Sum(nil)
+	desplazamiento := suma[len(suma)-1] & 0x0f
+	valor := binary.BigEndian.Uint32(suma[desplazamiento:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", valor%1_000_000)
+}
+
+// decodificar lee la respuesta JSON y falla si el estado no es el esperado
+func decodificar(t *testing.T, respuesta *httptest.ResponseRecorder, estado int, destino interface{}) {
+	t.Helper()
+	if respuesta.Code != estado {
+		t.Fatalf("se obtuvo %d, se esperaba %d: %s", respuesta.Code, estado, respuesta.Body)
+	}
+	if err := json.Unmarshal(respuesta.Body.Bytes(), destino); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// activarSegundoFactor inscribe y confirma el TOTP del usuario; devuelve el secreto y
+// los códigos de recuperación. El código de la confirmación es el del momento actual.
+func (p *pruebaLogin) activarSegundoFactor(t *testing.T, token string) (string, []string) {
+	t.Helper()
+	var inscripcion struct {
+		Secreto string `json:"secreto"`
+		URI     string `json:"uri"`
+	}
+	respuesta := p.pedir(t, http.MethodPost, "/me/2fa", "192.0.2.1", token, gin.H{})
+	decodificar(t, respuesta, http.StatusCreated, &inscripcion)
+	if !strings.Contains(inscripcion.URI, "secret="+inscripcion.Secreto) {
+		t.Errorf("la URI %q no lleva el secreto", inscripcion.URI)
+	}
+
+	// Hasta confirmarlo no está activo
+	if respuesta := p.pedir(t, http.MethodPost, "/me/2fa/confirmar", "192.0.2.1", token, gin.H{"codigo": "000000"}); respuesta.Code != http.StatusUnauthorized {
+		t.Fatalf("confirmar con un código incorrecto devolvió %d", respuesta.Code)
+	}
+	var confirmacion struct {
+		Codigos []string `json:"codigos_recuperacion"`
+	}
+	codigo := codigoTOTPPrueba(t, inscripcion.Secreto, time.Now())
+	respuesta = p.pedir(t, http.MethodPost, "/me/2fa/confirmar", "192.0.2.1", token, gin.H{"codigo": codigo})
+	decodificar(t, respuesta, http.StatusOK, &confirmacion)
+	if len(confirmacion.Codigos) != auth.CantidadCodigosRecuperacion {
+		t.Fatalf("se recibieron %d códigos de recuperación", len(confirmacion.Codigos))
+	}
+	return inscripcion.Secreto, confirmacion.Codigos
+}
+
+// tokenMFA hace el login con la contraseña y devuelve el token_mfa
+func (p *pruebaLogin) tokenMFA(t *testing.T, nombre string) string {
+	t.Helper()
+	var mfa respuestaMFA
+	respuesta := p.loguear(t, "192.0.2.1", nombre, contrasenaPrueba)
+	decodificar(t, respuesta, http.StatusAccepted, &mfa)
+	if !mfa.MFARequerido || mfa.TokenMFA == "" {
+		t.Fatalf("el login no pidió el segundo factor: %+v", mfa)
+	}
+	return mfa.TokenMFA
+}
+
+// completar hace POST /login/2fa
+func (p *pruebaLogin) completar(t *testing.T, tokenMFA, codigo string) int {
+	t.Helper()
+	return p.pedir(t, http.MethodPost, "/login/2fa", "192.0.2.1", "", gin.H{"token_mfa": tokenMFA, "codigo": codigo}).Code
+}
+
+func TestSegundoFactorInscripcionYLogin(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{EmisorTOTP: "Taller 6", MaxIntentosLogin: 5, DuracionBloqueoLogin: time.Minute})
+	usuario := p.crearUsuario(t, "ana")
+	token, err := auth.GenerarToken(usuario.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secreto, codigos := p.activarSegundoFactor(t, token)
+
+	var estado struct {
+		Activado  bool `json:"activado"`
+		Restantes int  `json:"codigos_recuperacion_restantes"`
+	}
+	respuesta := p.pedir(t, http.MethodGet, "/me/2fa", "192.0.2.1", token, nil)
+	decodificar(t, respuesta, http.StatusOK, &estado)
+	if !estado.Activado || estado.Restantes != auth.CantidadCodigosRecuperacion {
+		t.Errorf("GET /me/2fa devolvió %+v", estado)
+	}
+
+	// El token_mfa no sirve como token de acceso
+	tokenMFA := p.tokenMFA(t, "ana")
+	if respuesta := p.pedir(t, http.MethodGet, "/me/2fa", "192.0.2.1", tokenMFA, nil); respuesta.Code != http.StatusUnauthorized {
+		t.Errorf("el token_mfa se aceptó como token de acceso: %d", respuesta.Code)
+	}
+
+	// El código que confirmó la inscripción ya se usó; el del paso siguiente entra por la tolerancia
+	ahora := time.Now()
+	if codigo := p.completar(t, tokenMFA, codigoTOTPPrueba(t, secreto, ahora)); codigo != http.StatusUnauthorized {
+		t.Errorf("repetir el código de la confirmación devolvió %d, se esperaba %d", codigo, http.StatusUnauthorized)
+	}
+	siguiente := codigoTOTPPrueba(t, secreto, ahora.Add(30*time.Second))
+	if codigo := p.completar(t, tokenMFA, siguiente); codigo != http.StatusCreated {
+		t.Fatalf("con un código válido se obtuvo %d", codigo)
+	}
+	// El token_mfa sirve una sola vez
+	if codigo := p.completar(t, tokenMFA, codigoTOTPPrueba(t, secreto, ahora.Add(-30*time.Second))); codigo != http.StatusUnauthorized {
+		t.Errorf("reusar el token_mfa devolvió %d, se esperaba %d", codigo, http.StatusUnauthorized)
+	}
+
+	// Un código de recuperación entra una vez, escrito de cualquier forma
+	tokenMFA = p.tokenMFA(t, "ana")
+	escrito := strings.ToUpper(strings.ReplaceAll(codigos[0], "-", ""))
+	if codigo := p.completar(t, tokenMFA, escrito); codigo != http.StatusCreated {
+		t.Fatalf("con un código de recuperación se obtuvo %d", codigo)
+	}
+	tokenMFA = p.tokenMFA(t, "ana")
+	if codigo := p.completar(t, tokenMFA, codigos[0]); codigo != http.StatusUnauthorized {
+		t.Errorf("reusar el código de recuperación devolvió %d, se esperaba %d", codigo, http.StatusUnauthorized)
+	}
+
+	// Desactivarlo pide un código; después el login entrega los tokens directamente
+	if respuesta := p.pedir(t, http.MethodDelete, "/me/2fa", "192.0.2.1", token, gin.H{"codigo": codigos[1]}); respuesta.Code != http.StatusOK {
+		t.Fatalf("DELETE /me/2fa devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusCreated {
+		t.Errorf("sin segundo factor el login devolvió %d", respuesta.Code)
+	}
+}
+
+func TestSegundoFactorCodigosIncorrectosBloqueanLaCuenta(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		adivinar func(p *pruebaLogin, t *testing.T, token string) int
+	}{
+		{"desactivar", func(p *pruebaLogin, t *testing.T, token string) int {
+			return p.pedir(t, http.MethodDelete, "/me/2fa", "192.0.2.1", token, gin.H{"codigo": "000000"}).Code
+		}},
+		{"regenerar los códigos de recuperación", func(p *pruebaLogin, t *testing.T, token string) int {
+			return p.pedir(t, http.MethodPost, "/me/2fa/codigos-recuperacion", "192.0.2.1", token, gin.H{"codigo": "000000"}).Code
+		}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			p := nuevaPruebaLogin(t, Opciones{MaxIntentosLogin: 4, DuracionBloqueoLogin: time.Minute})
+			usuario := p.crearUsuario(t, "ana")
+			token, err := auth.GenerarToken(usuario.ID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secreto, _ := p.activarSegundoFactor(t, token)
+
+			// Con un máximo de 4, hasta el segundo fallo no hay espera y el cuarto intento ya espera
+			for i := 1; i <= 3; i++ {
+				if codigo := caso.adivinar(p, t, token); codigo != http.StatusUnauthorized {
+					t.Fatalf("el fallo %d devolvió %d, se esperaba %d", i, codigo, http.StatusUnauthorized)
+				}
+			}
+			if codigo := caso.adivinar(p, t, token); codigo != http.StatusTooManyRequests {
+				t.Fatalf("el cuarto intento devolvió %d, se esperaba %d", codigo, http.StatusTooManyRequests)
+			}
+			// Mientras espera, ni un código correcto ni la contraseña entran
+			correcto := codigoTOTPPrueba(t, secreto, time.Now().Add(30*time.Second))
+			if respuesta := p.pedir(t, http.MethodDelete, "/me/2fa", "192.0.2.1", token, gin.H{"codigo": correcto}); respuesta.Code != http.StatusTooManyRequests {
+				t.Errorf("con un código correcto se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+			}
+			if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusTooManyRequests {
+				t.Errorf("el login devolvió %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+			}
+		})
+	}
+}
+
+func TestLoginSegundoFactorCuentaLosFallosDeLaCuenta(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{MaxIntentosLogin: 4, DuracionBloqueoLogin: time.Minute})
+	usuario := p.crearUsuario(t, "ana")
+	token, err := auth.GenerarToken(usuario.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secreto, _ := p.activarSegundoFactor(t, token)
+
+	// Un código correcto borra los fallos anteriores
+	tokenMFA := p.tokenMFA(t, "ana")
+	if codigo := p.completar(t, tokenMFA, "000000"); codigo != http.StatusUnauthorized {
+		t.Fatalf("con un código incorrecto se obtuvo %d", codigo)
+	}
+	if codigo := p.completar(t, tokenMFA, codigoTOTPPrueba(t, secreto, time.Now().Add(30*time.Second))); codigo != http.StatusCreated {
+		t.Fatalf("con un código correcto se obtuvo %d", codigo)
+	}
+	if intentos, err := p.repo.BuscarIntentosLogin(claveCuenta("ana")); err != nil || intentos.Fallos != 0 {
+		t.Errorf("después del código correcto quedaron los fallos %+v (%v)", intentos, err)
+	}
+
+	// Quien sabe la contraseña no consigue más intentos pidiendo otro token_mfa
+	tokenMFA = p.tokenMFA(t, "ana")
+	for i := 1; i <= 3; i++ {
+		if codigo := p.completar(t, tokenMFA, "000000"); codigo != http.StatusUnauthorized {
+			t.Fatalf("el fallo %d devolvió %d, se esperaba %d", i, codigo, http.StatusUnauthorized)
+		}
+	}
+	if codigo := p.completar(t, tokenMFA, "000000"); codigo != http.StatusTooManyRequests {
+		t.Errorf("el cuarto código devolvió %d, se esperaba %d", codigo, http.StatusTooManyRequests)
+	}
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusTooManyRequests {
+		t.Errorf("un login nuevo devolvió %d, se esperaba %d", respuesta.Code, http.StatusTooManyRequests)
+	}
+	if intentos, err := p.repo.BuscarIntentosLogin(claveCuenta("ana")); err != nil || intentos.Fallos != 3 {
+		t.Errorf("la cuenta tiene %+v (%v), se esperaban 3 fallos", intentos, err)
+	}
+}
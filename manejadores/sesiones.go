@@ -0,0 +1,99 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"taller6/auth"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logout revoca el token de acceso con el que se hizo el pedido y, si se envía,
+// la familia del token de refresco de la misma sesión
+func (m *ManejadorUsuarios) Logout(c *gin.Context) {
+	var datos struct {
+		TokenRefresco string `json:"token_refresco"`
+	}
+	// El cuerpo es opcional: sin token de refresco solo se revoca el de acceso
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&datos); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+			return
+		}
+	}
+
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	ahora := time.Now()
+
+	if err := m.repo.RevocarTokenAcceso(reclamos.ID, reclamos.Id, reclamos.ExpiresAt.Time); err != nil {
+		log.Println("Error al revocar el token de acceso:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cerrar la sesión"})
+		return
+	}
+	auth.NotificarTokenRevocado(reclamos.ID, reclamos.ExpiresAt.Time)
+
+	if datos.TokenRefresco != "" {
+		// Solo se revoca la familia si el token de refresco es del mismo usuario
+		token, err := m.repo.BuscarTokenRefresco(auth.HashTokenRefresco(datos.TokenRefresco))
+		switch {
+		case err == nil && token.UsuarioID == reclamos.Id:
+			if err := m.repo.RevocarFamilia(token.Familia, ahora); err != nil {
+				log.Println("Error al revocar la familia de tokens:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cerrar la sesión"})
+				return
+			}
+		case err != nil && !errors.Is(err, repositorio.ErrTokenNoEncontrado):
+			log.Println("Error al buscar el token de refresco:", err)
+		}
+	}
+
+	// Aprovechamos para olvidar los tokens revocados que ya expiraron
+	if err := m.repo.PurgarRevocacionesVencidas(ahora); err != nil {
+		log.Println("Error al purgar los tokens revocados:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Sesión cerrada"})
+}
+
+// RevocarTokensDeUsuario invalida todas las sesiones de un usuario: los tokens de acceso
+// emitidos hasta ahora y todos sus tokens de refresco. Solo por /usuarios/:id/revocar-tokens.
+func (m *ManejadorUsuarios) RevocarTokensDeUsuario(c *gin.Context) {
+	idInt, ok := idObjetivo(c)
+	if !ok {
+		return
+	}
+	id := uint(idInt)
+	ahora := time.Now()
+
+	if _, err := m.repo.BuscarPorID(id); err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		log.Println("Error al consultar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		return
+	}
+
+	if err := m.revocarSesiones(id, ahora); err != nil {
+		log.Println("Error al revocar los tokens:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron revocar los tokens"})
+		return
+	}
+
+	log.Printf("Usuario ID %s revocó todos los tokens del usuario ID %d", c.GetString("id_usuario"), id)
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Tokens revocados"})
+}
+
+// revocarSesiones invalida los tokens de acceso emitidos hasta ahora y todos los
+// tokens de refresco del usuario
+func (m *ManejadorUsuarios) revocarSesiones(usuarioID uint, momento time.Time) error {
+	if err := m.repo.RevocarTokensAccesoDeUsuario(usuarioID, momento); err != nil {
+		return err
+	}
+	auth.NotificarUsuarioRevocado(usuarioID, momento)
+	return m.repo.RevocarTokensRefrescoDeUsuario(usuarioID, momento)
+}
@@ -0,0 +1,109 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"taller6/auth"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respuestaTokens es lo que devuelven Login y RefrescarToken
+type respuestaTokens struct {
+	Token         string `json:"token"`
+	TokenRefresco string `json:"token_refresco"`
+}
+
+// emitirTokens genera un token de acceso con los roles actuales del usuario y un
+// token de refresco nuevo. Con familia vacía empieza una familia nueva (login).
+func (m *ManejadorUsuarios) emitirTokens(usuarioID uint, familia string) (*respuestaTokens, error) {
+	roles, err := m.repo.RolesDeUsuario(usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := auth.GenerarToken(usuarioID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	if familia == "" {
+		if familia, err = auth.GenerarFamilia(); err != nil {
+			return nil, err
+		}
+	}
+	tokenRefresco, hash, err := auth.GenerarTokenRefresco()
+	if err != nil {
+		return nil, err
+	}
+	ahora := time.Now()
+	err = m.repo.GuardarTokenRefresco(&modelos.TokenRefresco{
+		UsuarioID: usuarioID,
+		Familia:   familia,
+		Hash:      hash,
+		CreadoEn:  ahora,
+		ExpiraEn:  ahora.Add(m.opciones.DuracionRefresco),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &respuestaTokens{Token: token, TokenRefresco: tokenRefresco}, nil
+}
+
+// RefrescarToken rota un token de refresco: lo marca como usado y entrega un token de
+// acceso y uno de refresco nuevos de la misma familia. Si llega un token ya usado
+// (alguien lo está reutilizando) se revoca toda la familia.
+func (m *ManejadorUsuarios) RefrescarToken(c *gin.Context) {
+	var datos struct {
+		TokenRefresco string `json:"token_refresco"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil || datos.TokenRefresco == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	token, err := m.repo.BuscarTokenRefresco(auth.HashTokenRefresco(datos.TokenRefresco))
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrTokenNoEncontrado) {
+			log.Println("Error al buscar el token de refresco:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de refresco inválido"})
+		return
+	}
+
+	ahora := time.Now()
+	if token.RevocadoEn != nil || ahora.After(token.ExpiraEn) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de refresco inválido"})
+		return
+	}
+
+	// El UPDATE condicional garantiza que solo un pedido pueda rotar el token
+	rotado, err := m.repo.MarcarTokenRefrescoUsado(token.ID, ahora)
+	if err != nil {
+		log.Println("Error al marcar el token de refresco:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo refrescar el token"})
+		return
+	}
+	if !rotado {
+		// Reutilización: el token ya se había cambiado por otro. Revocamos toda la familia
+		// para que ni el atacante ni el usuario legítimo puedan seguir usándola.
+		log.Printf("Reutilización del token de refresco de la familia %s (usuario ID %d), se revoca la familia", token.Familia, token.UsuarioID)
+		if err := m.repo.RevocarFamilia(token.Familia, ahora); err != nil {
+			log.Println("Error al revocar la familia de tokens:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de refresco inválido"})
+		return
+	}
+
+	tokens, err := m.emitirTokens(token.UsuarioID, token.Familia)
+	if err != nil {
+		log.Println("Error al generar los tokens:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo refrescar el token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
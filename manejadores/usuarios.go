@@ -1,22 +1,64 @@
 package manejadores
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
 	"taller6/auth"
-	"taller6/base_datos"
+	"taller6/contrasenas"
+	"taller6/correo"
 	"taller6/modelos"
+	"taller6/repositorio"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt" // Para encriptar contraseñas
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
+// ManejadorUsuarios agrupa los manejadores del CRUD de usuarios.
+// El repositorio se inyecta al arrancar el servidor en main.go.
+type ManejadorUsuarios struct {
+	repo     repositorio.Repositorio
+	opciones Opciones
+
+	muMFA       sync.Mutex
+	intentosMFA map[string]intentosMFA // Códigos incorrectos por jti del token_mfa
+
+	unaVezHashFicticio sync.Once
+	hashFicticio       string // Para comparar cuando el usuario no existe
+}
+
+// Opciones son los valores de la configuración que usan los manejadores
+type Opciones struct {
+	Hasheador        contrasenas.Hasheador // Cómo se encriptan las contraseñas nuevas
+	DuracionRefresco time.Duration         // Tiempo de vida de los tokens de refresco
+	EmisorOIDC       string                // URL pública del servicio (iss); vacío desactiva OpenID Connect
+
+	DuracionRestablecimiento time.Duration      // Tiempo de vida de los tokens para restablecer la contraseña
+	URLRestablecimiento      string             // Página del frontend que recibe el token; vacío envía el token solo
+	DuracionVerificacion     time.Duration      // Tiempo de vida de los enlaces para verificar el correo
+	URLVerificacion          string             // Página del frontend que recibe el token de verificación
+	RequiereCorreoVerificado bool               // Login rechaza las cuentas que no verificaron el correo
+	Enviador                 correo.Enviador    // Cómo se envían los correos
+	EmisorTOTP               string             // Nombre del servicio que muestran las aplicaciones autenticadoras
+	WebAuthn                 *webauthn.WebAuthn // Relying party de las llaves de acceso; nil las desactiva
+	MaxIntentosLogin         int                // Fallos seguidos que bloquean una cuenta; 0 no la controla
+	MaxIntentosLoginIP       int                // Fallos que bloquean una IP, en cualquier cuenta; 0 no la controla
+	DuracionBloqueoLogin     time.Duration      // Cuánto dura el bloqueo y cuánto se recuerda cada fallo
+	PoliticaContrasena       contrasenas.Politica
+	HistorialContrasenas     int           // Contraseñas (contando la actual) que no se pueden repetir; 0 no lo controla
+	RetencionEliminados      time.Duration // Cuánto se puede restaurar un usuario eliminado antes de purgarlo; 0 no purga
+}
+
+// NuevoManejadorUsuarios crea los manejadores a partir del repositorio y las opciones
+func NuevoManejadorUsuarios(repo repositorio.Repositorio, opciones Opciones) *ManejadorUsuarios {
+	return &ManejadorUsuarios{repo: repo, opciones: opciones, intentosMFA: map[string]intentosMFA{}}
+}
+
 // CrearUsuario maneja la creación de un nuevo usuario
-func CrearUsuario(c *gin.Context) {
+func (m *ManejadorUsuarios) CrearUsuario(c *gin.Context) {
 	var usuario modelos.UsuarioConToken
 
 	// Validamos la entrada
@@ -24,61 +66,62 @@ func CrearUsuario(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
 		return
 	}
-
-	// Verificar si el usuario o correo ya existen
-	var existeUsuario int
-	consultaVerificacion := `SELECT COUNT(*) FROM usuarios WHERE nombre_usuario = ? OR correo = ?`
-	err := base_datos.BD.QueryRow(consultaVerificacion, usuario.NombreUsuario, usuario.Correo).Scan(&existeUsuario)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar la existencia del usuario"})
+	// Si hay que verificar el correo para entrar, sin correo no podría entrar nunca
+	if m.opciones.RequiereCorreoVerificado && usuario.Correo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Debe indicar un correo"})
 		return
 	}
-	if existeUsuario > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "El usuario ya existe."})
+	datos := contrasenas.Datos{NombreUsuario: usuario.NombreUsuario, Correo: usuario.Correo}
+	if !m.validarContrasenaNueva(c, usuario.Contrasena, datos, nil) {
 		return
 	}
 
 	// Encriptamos la contraseña antes de guardarla
-	contrasenaEncriptada, err := bcrypt.GenerateFromPassword([]byte(usuario.Contrasena), bcrypt.DefaultCost)
+	contrasenaEncriptada, err := m.opciones.Hasheador.Hashear(usuario.Contrasena)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al encriptar la contraseña"})
 		return
 	}
-	usuario.Contrasena = string(contrasenaEncriptada)
-	usuario.CreadoEn = time.Now()
 
-	// Insertamos el usuario en la base de datos
-	consulta := `INSERT INTO usuarios (nombre_usuario, correo, contrasena, creado_en) VALUES (?, ?, ?, ?)`
-	resultado, err := base_datos.BD.Exec(consulta, usuario.NombreUsuario, usuario.Correo, usuario.Contrasena, usuario.CreadoEn)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el usuario, no logra guardarse en la base."})
-		return
+	// Guardamos el usuario en el repositorio
+	nuevo := modelos.Usuario{
+		NombreUsuario: usuario.NombreUsuario,
+		Correo:        usuario.Correo,
+		Contrasena:    contrasenaEncriptada,
+		CreadoEn:      time.Now(),
 	}
-
-	// Obtener el ID del usuario recién insertado
-	usuarioID, err := resultado.LastInsertId()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo obtener el ID del usuario"})
+	if err := m.repo.Crear(&nuevo); err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioDuplicado) {
+			c.JSON(http.StatusConflict, gin.H{"error": "El usuario ya existe."})
+			return
+		}
+		log.Println("Error al crear el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el usuario, no logra guardarse en la base."})
 		return
 	}
-	usuario.ID = uint(usuarioID)
 
-	// Generar el token para el usuario
-	token, err := auth.GenerarToken(usuario.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el token"})
-		return
+	if nuevo.Correo != "" {
+		go m.enviarVerificacion(nuevo, idiomaCorreo(c))
 	}
 
-	usuario.Token = token
-
 	// Crear la respuesta sin la contraseña
 	usuarioSinContrasena := modelos.UsuarioConToken{
-		ID:            usuario.ID,
-		NombreUsuario: usuario.NombreUsuario,
-		Correo:        usuario.Correo,
-		CreadoEn:      usuario.CreadoEn,
-		Token:         token,
+		ID:            nuevo.ID,
+		NombreUsuario: nuevo.NombreUsuario,
+		Correo:        nuevo.Correo,
+		CreadoEn:      nuevo.CreadoEn,
+	}
+
+	// Generar los tokens para el usuario, salvo que tenga que verificar el correo primero
+	if !m.opciones.RequiereCorreoVerificado {
+		tokens, err := m.emitirTokens(nuevo.ID, "")
+		if err != nil {
+			log.Println("Error al generar los tokens:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el token"})
+			return
+		}
+		usuarioSinContrasena.Token = tokens.Token
+		usuarioSinContrasena.TokenRefresco = tokens.TokenRefresco
 	}
 
 	// Devolvemos el usuario creado (sin la contraseña)
@@ -86,7 +129,7 @@ func CrearUsuario(c *gin.Context) {
 }
 
 // Login maneja la autenticación de un usuario
-func Login(c *gin.Context) {
+func (m *ManejadorUsuarios) Login(c *gin.Context) {
 	var datosLogin struct {
 		NombreUsuario string `json:"nombre_usuario"`
 		Contrasena    string `json:"contrasena"`
@@ -98,206 +141,217 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	//Le digo traeme id, nombre, contraseña de la tabla usuario donde el nombre de usuario sea el nombre_usuario que me envia el usuario
-	var usuario modelos.Usuario
-	consulta := `SELECT id, nombre_usuario, contrasena FROM usuarios WHERE nombre_usuario = ?`
-	//queryRow ejecuta la consulta y devuelve una fila \ Scan asigna los valores a la fila que devolvio queryRow (en este caso, id, nombre, contraseña del usuario)
-	err := base_datos.BD.QueryRow(consulta, datosLogin.NombreUsuario).Scan(&usuario.ID, &usuario.NombreUsuario, &usuario.Contrasena)
-	//sino encuentra ese nombre de usuario en la base, ROMPE (401 Unauthorized)
+	// Verificamos el usuario y la contraseña; si no coinciden, ROMPE (401 Unauthorized).
+	// No se dice cuál de los dos estaba mal, así no se puede averiguar qué usuarios existen.
+	usuario, err := m.verificarCredenciales(datosLogin.NombreUsuario, datosLogin.Contrasena, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario ingresado no existe."})
+		var bloqueo *errorBloqueo
+		switch {
+		case errors.As(err, &bloqueo):
+			responderBloqueo(c, bloqueo) //429
+		case errors.Is(err, errCredencialesIncorrectas):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario o contraseña incorrectos"})
+		case errors.Is(err, errCorreoSinVerificar):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Debe verificar su correo antes de iniciar sesión"})
+		default:
+			log.Println("Error al buscar el usuario:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+		}
 		return
 	}
 
-	// Log propio para ver si va todo OK hasta aca
-	log.Printf("(Login) Contraseña proporcionada en Login es: %s", datosLogin.Contrasena)
-	log.Printf("(Login) Contraseña encriptada en DB: %s", usuario.Contrasena)
+	m.responderLogin(c, usuario.ID, true)
+}
 
-	// **************Comparamos la contraseña encriptada*****************************
-	// usuario.Contrasena es la contraseña encriptada en la base
-	// datosLogin.Contrasena es la contraseña en texto plano que envia el usuario
-	//CompareHashAndPassword realiza la comparacion entre la contraseña hasheada guardada en la base con la contraseña que envia el usuario luego de hashearla
-	//Si estas dos no coinciden, ROMPE (401 Unauthorized)
-	if err := bcrypt.CompareHashAndPassword([]byte(usuario.Contrasena), []byte(datosLogin.Contrasena)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "La contraseña hasheada guardada en la base no coincide con la contraseña que envió el usuario, hasheada en el momento."})
-		return
+// responderLogin entrega los tokens al usuario ya autenticado o, si tiene el segundo
+// factor activo y hay que pedirlo, un token que solo sirve para POST /login/2fa.
+// La usan Login y CompletarLoginWebAuthn.
+func (m *ManejadorUsuarios) responderLogin(c *gin.Context, usuarioID uint, pedirSegundoFactor bool) {
+	if pedirSegundoFactor {
+		activo, err := m.segundoFactorActivo(usuarioID)
+		if err != nil {
+			log.Println("Error al buscar el segundo factor:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+			return
+		}
+		if activo {
+			tokenMFA, expira, err := auth.GenerarTokenMFA(usuarioID)
+			if err != nil {
+				log.Println("Error al generar el token_mfa:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el token"})
+				return
+			}
+			c.JSON(http.StatusAccepted, respuestaMFA{MFARequerido: true, TokenMFA: tokenMFA, ExpiraEn: expira}) //202
+			return
+		}
 	}
 
-	// Generamos el token JWT
-	//x := strconv.FormatUint(uint64(usuario.ID), 10)
-	token, err := auth.GenerarToken(usuario.ID)
+	// Generamos el token JWT de acceso y el token de refresco (empieza una familia nueva)
+	tokens, err := m.emitirTokens(usuarioID, "")
 	if err != nil {
+		log.Println("Error al generar los tokens:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el token"})
 		return
 	}
 
-	// Enviamos el token al cliente
-	c.JSON(http.StatusCreated, gin.H{"token": token}) //201
-
+	// Enviamos los tokens al cliente
+	c.JSON(http.StatusCreated, tokens) //201
 }
 
-// ObtenerUsuario permite obtener el perfil del usuario o de otro si es administrador
-func ObtenerUsuario(c *gin.Context) {
-	esAdmin, existe := c.Get("es_admin")
-	if !existe {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo verificar el tipo de usuario"})
-		return
-	}
+// Errores de verificarCredenciales; además puede devolver un *errorBloqueo
+var (
+	errCredencialesIncorrectas = errors.New("el usuario no existe o la contraseña no coincide")
+	errCorreoSinVerificar      = errors.New("el correo no está verificado")
+)
 
-	if esAdmin.(bool) {
-		id := c.Param("id")
-		idInt, err := strconv.Atoi(id)
+// verificarCredenciales busca el usuario por nombre y compara la contraseña. La usan
+// Login y el formulario de inicio de sesión de OpenID Connect. Antes controla los fallos
+// recientes de la cuenta y de la IP; cuando son demasiados ni siquiera mira la contraseña.
+func (m *ManejadorUsuarios) verificarCredenciales(nombreUsuario, contrasena, ip string) (*modelos.Usuario, error) {
+	ahora := time.Now()
+	cuenta, porIP := m.clavesIntentos(nombreUsuario, ip)
+	if err := m.controlarIntentos(ahora, cuenta, porIP); err != nil {
+		return nil, err
+	}
+	// El fallo de la cuenta se anota antes de comparar y se borra si la contraseña es
+	// correcta: así muchos pedidos simultáneos no pasan todos el control de arriba
+	if cuenta != nil {
+		intentos, err := m.registrarFallo(cuenta, ahora)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido (es admin)"})
-			return
+			return nil, err
 		}
-		ObtenerUsuarioPorID(c, idInt)
-	} else {
-		id := c.GetString("id_usuario")
-		idInt, err := strconv.Atoi(id)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido (no es admin)"})
-			return
+		if intentos.Fallos > cuenta.maximo {
+			return nil, &errorBloqueo{hasta: m.esperaHasta(intentos, cuenta.maximo)}
 		}
-		ObtenerUsuarioPorID(c, idInt)
 	}
-}
-
-// obtener me
-func ObtenerUsuarioPorID(c *gin.Context, id int) {
-	var usuario modelos.Usuario
-	var creadoEn string // Usamos string para capturar el valor de la fecha
 
-	consulta := `SELECT id, nombre_usuario, correo, creado_en FROM usuarios WHERE id = ?`
-	err := base_datos.BD.QueryRow(consulta, id).Scan(&usuario.ID, &usuario.NombreUsuario, &usuario.Correo, &creadoEn)
+	usuario, err := m.verificarContrasena(nombreUsuario, contrasena)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Println("ObtenerUsuario: No se encontraron filas para el ID:", id)
-			c.JSON(http.StatusNotFound, gin.H{"error": "ObtenerUsuario: Usuario no encontrado"})
-		} else {
-			log.Println("Error al ejecutar la consulta:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		if errors.Is(err, errCredencialesIncorrectas) && porIP != nil {
+			if _, err := m.registrarFallo(porIP, ahora); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if cuenta != nil {
+		if err := m.repo.ReiniciarIntentosLogin(cuenta.clave); err != nil {
+			return nil, err
+		}
+		// Aprovechamos para olvidar los fallos que ya no bloquean a nadie
+		if err := m.repo.PurgarIntentosLogin(ahora.Add(-m.opciones.DuracionBloqueoLogin)); err != nil {
+			log.Println("Error al purgar los intentos de inicio de sesión:", err)
 		}
-		return
 	}
+	// Se controla después de la contraseña para no revelar el estado de la cuenta a cualquiera
+	if m.opciones.RequiereCorreoVerificado && !usuario.CorreoVerificado {
+		return nil, errCorreoSinVerificar
+	}
+	return usuario, nil
+}
 
-	// Convertimos la cadena a time.Time porque en uint rompia
-	usuario.CreadoEn, err = time.Parse("2006-01-02 15:04:05", creadoEn)
+// verificarContrasena busca el usuario y compara la contraseña. Si el usuario no existe
+// compara igual contra un hash ficticio, para que las dos respuestas tarden lo mismo.
+func (m *ManejadorUsuarios) verificarContrasena(nombreUsuario, contrasena string) (*modelos.Usuario, error) {
+	// Buscamos el usuario por el nombre de usuario que nos envían
+	usuario, err := m.repo.BuscarPorNombre(nombreUsuario)
 	if err != nil {
-		log.Println("Error al convertir la fecha:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar la fecha de creación"})
-		return
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			m.compararContrasenaFicticia(contrasena)
+			return nil, errCredencialesIncorrectas
+		}
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, usuario)
+	// **************Comparamos la contraseña encriptada*****************************
+	// usuario.Contrasena es la contraseña encriptada en la base
+	// contrasena es la contraseña en texto plano que envia el usuario
+	// Verificar reconoce el algoritmo por el formato del hash guardado (bcrypt o Argon2id)
+	coincide, rehashear, err := m.opciones.Hasheador.Verificar(usuario.Contrasena, contrasena)
+	if err != nil {
+		return nil, err
+	}
+	if !coincide {
+		return nil, errCredencialesIncorrectas
+	}
+	if rehashear {
+		m.rehashearContrasena(usuario, contrasena)
+	}
+	return usuario, nil
 }
 
-// ActualizarUsuario maneja la actualización de un usuario
-func ActualizarUsuario(c *gin.Context) {
-	esAdmin, existe := c.Get("es_admin")
-	if !existe {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo verificar el tipo de usuario"})
+// rehashearContrasena reemplaza un hash de otro algoritmo o con otros costos (por ejemplo,
+// bcrypt de antes de pasar a Argon2id) por uno actual, aprovechando que se tiene la
+// contraseña. Si el usuario la cambió mientras tanto no se pisa. Un error solo se registra:
+// el inicio de sesión ya fue válido y se vuelve a intentar en el próximo.
+func (m *ManejadorUsuarios) rehashearContrasena(usuario *modelos.Usuario, contrasena string) {
+	nuevo, err := m.opciones.Hasheador.Hashear(contrasena)
+	if err != nil {
+		log.Printf("Error al volver a encriptar la contraseña del usuario ID %d: %v", usuario.ID, err)
 		return
 	}
+	reemplazada, err := m.repo.ReemplazarContrasena(usuario.ID, usuario.Contrasena, nuevo)
+	if err != nil {
+		log.Printf("Error al guardar la contraseña reencriptada del usuario ID %d: %v", usuario.ID, err)
+		return
+	}
+	if reemplazada {
+		usuario.Contrasena = nuevo
+		log.Printf("Contraseña del usuario ID %d reencriptada con %s", usuario.ID, m.opciones.Hasheador.Algoritmo)
+	}
+}
 
-	// Obtener datos enviados por el cliente
-	var datosUsuario modelos.Usuario
-	if err := c.ShouldBindJSON(&datosUsuario); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+// ObtenerUsuario devuelve el propio perfil (/me) o el del usuario indicado (/usuarios/:id).
+// El permiso para ver a otros usuarios lo controla auth.RequierePermiso en la ruta.
+func (m *ManejadorUsuarios) ObtenerUsuario(c *gin.Context) {
+	idInt, ok := idObjetivo(c)
+	if !ok {
 		return
 	}
+	m.ObtenerUsuarioPorID(c, idInt)
+}
 
-	// Determinar ID según si es admin o no
-	var id string
-	if esAdmin.(bool) {
-		id = c.Param("id")
-	} else {
+// idObjetivo devuelve el ID de la ruta (/usuarios/:id) o, si no hay, el del usuario autenticado (/me).
+// Para actuar sobre otro usuario la ruta tiene que haber pasado por auth.RequierePermiso; si no,
+// responde 403 en lugar de caer silenciosamente en el propio usuario. Si devuelve false ya respondió.
+func idObjetivo(c *gin.Context) (int, bool) {
+	id := c.Param("id")
+	if id == "" {
 		id = c.GetString("id_usuario")
+	} else if !c.GetBool("permiso_verificado") {
+		log.Printf("Ruta %s %s sin verificación de permisos", c.Request.Method, c.FullPath())
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tiene permiso para realizar esta acción"})
+		return 0, false
 	}
 
 	idInt, err := strconv.Atoi(id)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
-		return
-	}
-
-	// Construir consulta de actualización solo con campos presentes
-	consulta := "UPDATE usuarios SET "
-	args := []interface{}{}
-
-	if datosUsuario.NombreUsuario != "" {
-		consulta += "nombre_usuario = ?, "
-		args = append(args, datosUsuario.NombreUsuario)
-	}
-	if datosUsuario.Correo != "" {
-		consulta += "correo = ?, "
-		args = append(args, datosUsuario.Correo)
-	}
-	if datosUsuario.Contrasena != "" {
-		contrasenaEncriptada, err := bcrypt.GenerateFromPassword([]byte(datosUsuario.Contrasena), bcrypt.DefaultCost)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al encriptar la contraseña"})
-			return
-		}
-		consulta += "contrasena = ?, "
-		args = append(args, string(contrasenaEncriptada))
+		return 0, false
 	}
+	return idInt, true
+}
 
-	// Eliminar la última coma y espacio
-	consulta = consulta[:len(consulta)-2]
-	consulta += " WHERE id = ?"
-	args = append(args, idInt)
-
-	// Log para verificar la consulta antes de ejecutarla
-	log.Printf("Consulta de actualización: %s, con parámetros: %v", consulta, args)
-
-	// Ejecutar la consulta
-	_, err = base_datos.BD.Exec(consulta, args...)
+// obtener me
+func (m *ManejadorUsuarios) ObtenerUsuarioPorID(c *gin.Context, id int) {
+	usuario, err := m.repo.BuscarPorID(uint(id))
 	if err != nil {
-		// Log del error para mayor detalle
-		log.Printf("Error al ejecutar la consulta de actualización: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar el usuario"})
-		return
-	}
-
-	// Recuperar los datos actualizados del usuario, excluyendo la contraseña
-	var usuarioActualizado modelos.UsuarioSinContrasena
-	consulta = "SELECT id, nombre_usuario, correo, creado_en FROM usuarios WHERE id = ?"
-	row := base_datos.BD.QueryRow(consulta, idInt)
-
-	// Utilizar sql.NullString para manejar la fecha como string
-	var creadoEn []byte
-	if err := row.Scan(&usuarioActualizado.ID, &usuarioActualizado.NombreUsuario, &usuarioActualizado.Correo, &creadoEn); err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			log.Println("ObtenerUsuario: No se encontraron filas para el ID:", id)
+			c.JSON(http.StatusNotFound, gin.H{"error": "ObtenerUsuario: Usuario no encontrado"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al recuperar los datos del usuario: %v", err)})
+			log.Println("Error al ejecutar la consulta:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
 		}
 		return
 	}
 
-	// Convertir la fecha de []byte a time.Time
-	parsedDate, err := time.Parse("2006-01-02 15:04:05", string(creadoEn))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al parsear la fecha de creación"})
-		return
-	}
-
-	usuarioActualizado.CreadoEn = parsedDate
-
-	// Devolver el usuario actualizado sin la contraseña
-	c.JSON(http.StatusOK, usuarioActualizado)
+	c.JSON(http.StatusOK, usuario.SinContrasena())
 }
 
-/* ******************************************************
 // ActualizarUsuario maneja la actualización de un usuario
-func ActualizarUsuario(c *gin.Context) {
-	esAdmin, existe := c.Get("es_admin")
-	if !existe {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo verificar el tipo de usuario"})
-		return
-	}
-
+func (m *ManejadorUsuarios) ActualizarUsuario(c *gin.Context) {
 	// Obtener datos enviados por el cliente
 	var datosUsuario modelos.Usuario
 	if err := c.ShouldBindJSON(&datosUsuario); err != nil {
@@ -305,107 +359,124 @@ func ActualizarUsuario(c *gin.Context) {
 		return
 	}
 
-	// Determinar ID según si es admin o no
-	var id string
-	if esAdmin.(bool) {
-		id = c.Param("id")
-	} else {
-		id = c.GetString("id_usuario")
-	}
-
-	idInt, err := strconv.Atoi(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+	// Determinar ID según la ruta (/me o /usuarios/:id)
+	idInt, ok := idObjetivo(c)
+	if !ok {
 		return
 	}
 
-	// Construir consulta de actualización solo con campos presentes
-	consulta := "UPDATE usuarios SET "
-	args := []interface{}{}
-
-	if datosUsuario.NombreUsuario != "" {
-		consulta += "nombre_usuario = ?, "
-		args = append(args, datosUsuario.NombreUsuario)
-	}
-	if datosUsuario.Correo != "" {
-		consulta += "correo = ?, "
-		args = append(args, datosUsuario.Correo)
+	// Solo se actualizan los campos presentes
+	cambios := modelos.Usuario{
+		NombreUsuario: datosUsuario.NombreUsuario,
+		Correo:        datosUsuario.Correo,
 	}
+	var anterior *modelos.Usuario
 	if datosUsuario.Contrasena != "" {
-		contrasenaEncriptada, err := bcrypt.GenerateFromPassword([]byte(datosUsuario.Contrasena), bcrypt.DefaultCost)
+		// Hace falta el usuario actual para el historial y para comparar con el nombre y
+		// el correo que va a tener después del cambio
+		var err error
+		anterior, err = m.repo.BuscarPorID(uint(idInt))
+		if err != nil {
+			if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al recuperar los datos del usuario"})
+			}
+			return
+		}
+		datos := contrasenas.Datos{NombreUsuario: anterior.NombreUsuario, Correo: anterior.Correo}
+		if cambios.NombreUsuario != "" {
+			datos.NombreUsuario = cambios.NombreUsuario
+		}
+		if cambios.Correo != "" {
+			datos.Correo = cambios.Correo
+		}
+		if !m.validarContrasenaNueva(c, datosUsuario.Contrasena, datos, anterior) {
+			return
+		}
+
+		contrasenaEncriptada, err := m.opciones.Hasheador.Hashear(datosUsuario.Contrasena)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al encriptar la contraseña"})
 			return
 		}
-		consulta += "contrasena = ?, "
-		args = append(args, string(contrasenaEncriptada))
+		cambios.Contrasena = contrasenaEncriptada
 	}
 
-	// Eliminar la última coma y espacio
-	consulta = consulta[:len(consulta)-2]
-	consulta += " WHERE id = ?"
-	args = append(args, idInt)
-
-	_, err = base_datos.BD.Exec(consulta, args...)
-	if err != nil {
-		log.Println("Error al actualizar el usuario:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar el usuario"})
+	if err := m.repo.Actualizar(uint(idInt), cambios); err != nil {
+		switch {
+		case errors.Is(err, repositorio.ErrUsuarioNoEncontrado):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		case errors.Is(err, repositorio.ErrUsuarioDuplicado):
+			c.JSON(http.StatusConflict, gin.H{"error": "El usuario ya existe."})
+		default:
+			// Log del error para mayor detalle
+			log.Printf("Error al ejecutar la actualización: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar el usuario"})
+		}
 		return
 	}
+	if anterior != nil {
+		m.guardarContrasenaAnterior(anterior, time.Now())
+	}
 
-	c.JSON(http.StatusNoContent, gin.H{"mensaje": "Usuario actualizado correctamente"})
-}
-*/
-
-// EliminarUsuario borra un usuario por su ID
-func EliminarUsuario(c *gin.Context) {
-	id := c.Param("id")
-
-	// Convertimos el ID de string a entero
-	idInt, err := strconv.Atoi(id)
+	// Recuperar los datos actualizados del usuario, excluyendo la contraseña
+	usuarioActualizado, err := m.repo.BuscarPorID(uint(idInt))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al recuperar los datos del usuario"})
+		}
 		return
 	}
 
-	consulta := `DELETE FROM usuarios WHERE id = ?`
-	_, err = base_datos.BD.Exec(consulta, idInt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo eliminar el usuario"})
-		return
+	// Un correo nuevo hay que verificarlo
+	if cambios.Correo != "" && !usuarioActualizado.CorreoVerificado {
+		go m.enviarVerificacion(*usuarioActualizado, idiomaCorreo(c))
 	}
 
-	c.JSON(http.StatusNoContent, gin.H{"mensaje": "Usuario eliminado correctamente"})
+	// Devolver el usuario actualizado sin la contraseña
+	c.JSON(http.StatusOK, usuarioActualizado.SinContrasena())
 }
 
-// ObtenerUsuarios trae todos los usuarios de la base de datos sin validación de token
-func ObtenerUsuarios(c *gin.Context) {
-	var usuarios []modelos.Usuario
-	rows, err := base_datos.BD.Query("SELECT id, nombre_usuario, correo, creado_en FROM usuarios")
-	if err != nil {
-		log.Println("Error al ejecutar la consulta:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar los usuarios"})
+// EliminarUsuario da de baja a un usuario por su ID. Se puede restaurar con POST
+// /usuarios/:id/restaurar hasta que la purga lo borra; mientras tanto su nombre de
+// usuario y su correo siguen ocupados.
+func (m *ManejadorUsuarios) EliminarUsuario(c *gin.Context) {
+	// Solo se elimina por /usuarios/:id, con el permiso ya verificado
+	idInt, ok := idObjetivo(c)
+	if !ok {
 		return
 	}
-	defer rows.Close()
+	id := uint(idInt)
 
-	for rows.Next() {
-		var usuario modelos.Usuario
-		var creadoEn string
-		err := rows.Scan(&usuario.ID, &usuario.NombreUsuario, &usuario.Correo, &creadoEn)
-		if err != nil {
-			log.Println("Error al escanear fila:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar los datos de usuarios"})
+	if _, err := m.repo.BuscarPorID(id); err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
 			return
 		}
-		usuario.CreadoEn, err = time.Parse("2006-01-02 15:04:05", creadoEn)
-		if err != nil {
-			log.Println("Error al convertir la fecha:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar la fecha de creación"})
+		log.Println("Error al consultar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo eliminar el usuario"})
+		return
+	}
+	// La baja es lógica y los tokens no se borran con el usuario, así que se revocan
+	// antes: si esto falla, el usuario queda como estaba
+	if err := m.revocarSesiones(id, time.Now()); err != nil {
+		log.Println("Error al revocar los tokens:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo eliminar el usuario"})
+		return
+	}
+
+	if err := m.repo.Eliminar(id); err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
 			return
 		}
-		usuarios = append(usuarios, usuario)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo eliminar el usuario"})
+		return
 	}
 
-	c.JSON(http.StatusOK, usuarios)
+	log.Printf("Usuario ID %s eliminó al usuario ID %d", c.GetString("id_usuario"), id)
+	c.JSON(http.StatusNoContent, gin.H{"mensaje": "Usuario eliminado correctamente"})
 }
@@ -0,0 +1,52 @@
+package manejadores
+
+import (
+	"net/http"
+	"strings"
+	"taller6/contrasenas"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginReencriptaContrasena(t *testing.T) {
+	p := nuevaPruebaLogin(t, Opciones{})
+	// El usuario tiene un hash de bcrypt de antes de pasar a Argon2id
+	usuario := p.crearUsuario(t, "ana")
+	anterior := usuario.Contrasena
+	p.usuarios.opciones.Hasheador = contrasenas.Hasheador{
+		Algoritmo:   contrasenas.AlgoritmoArgon2id,
+		Argon2:      contrasenas.ParametrosArgon2{Memoria: 64, Iteraciones: 1, Paralelismo: 1},
+		CostoBcrypt: bcrypt.MinCost,
+	}
+
+	// Una contraseña incorrecta no toca el hash
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", "incorrecta"); respuesta.Code != http.StatusUnauthorized {
+		t.Fatalf("con la contraseña incorrecta se obtuvo %d", respuesta.Code)
+	}
+	if guardado, _ := p.repo.BuscarPorID(usuario.ID); guardado.Contrasena != anterior {
+		t.Fatal("una contraseña incorrecta reemplazó el hash")
+	}
+
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el login devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+	guardado, err := p.repo.BuscarPorID(usuario.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(guardado.Contrasena, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("el hash guardado es %q, se esperaba uno de Argon2id", guardado.Contrasena)
+	}
+	if coincide, rehashear, err := p.usuarios.opciones.Hasheador.Verificar(guardado.Contrasena, contrasenaPrueba); err != nil || !coincide || rehashear {
+		t.Errorf("el hash nuevo dio coincide=%v rehashear=%v (%v)", coincide, rehashear, err)
+	}
+
+	// Con el hash nuevo se sigue entrando y ya no se reemplaza
+	if respuesta := p.loguear(t, "192.0.2.1", "ana", contrasenaPrueba); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el segundo login devolvió %d", respuesta.Code)
+	}
+	if otra, _ := p.repo.BuscarPorID(usuario.ID); otra.Contrasena != guardado.Contrasena {
+		t.Error("el segundo login volvió a reemplazar un hash actual")
+	}
+}
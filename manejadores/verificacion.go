@@ -0,0 +1,137 @@
+package manejadores
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"taller6/auth"
+	"taller6/correo"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerificarCorreo consume el token del enlace (GET /verificar?token=...) y marca el
+// correo como verificado. El token guarda el correo al que se envió, así un enlace
+// viejo no verifica un correo que el usuario cambió después.
+func (m *ManejadorUsuarios) VerificarCorreo(c *gin.Context) {
+	valor := c.Query("token")
+	if valor == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Falta el token"})
+		return
+	}
+
+	token, err := m.repo.BuscarTokenUnUso(modelos.PropositoVerificar, auth.HashSecreto(valor))
+	if err != nil {
+		if !errors.Is(err, repositorio.ErrTokenNoEncontrado) {
+			log.Println("Error al buscar el token de verificación:", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+	ahora := time.Now()
+	if token.UsadoEn != nil || ahora.After(token.ExpiraEn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+
+	usado, err := m.repo.MarcarTokenUnUsoUsado(token.ID, ahora)
+	if err != nil {
+		log.Println("Error al marcar el token de verificación:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo verificar el correo"})
+		return
+	}
+	if !usado {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+
+	verificado, err := m.repo.VerificarCorreo(token.UsuarioID, token.Dato)
+	if err != nil {
+		log.Println("Error al verificar el correo:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo verificar el correo"})
+		return
+	}
+	if !verificado {
+		// El usuario cambió el correo después de pedir este enlace
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido o vencido"})
+		return
+	}
+
+	log.Printf("Usuario ID %d verificó su correo", token.UsuarioID)
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Correo verificado"})
+}
+
+// ReenviarVerificacion vuelve a enviar el enlace de verificación. Es pública porque
+// quien no verificó el correo puede no tener forma de iniciar sesión, y por eso responde
+// siempre lo mismo, igual que OlvidoContrasena.
+func (m *ManejadorUsuarios) ReenviarVerificacion(c *gin.Context) {
+	var datos struct {
+		Correo string `json:"correo" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	idioma := idiomaCorreo(c)
+	go func() {
+		usuario, err := m.repo.BuscarPorCorreo(datos.Correo)
+		if err != nil {
+			if !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+				log.Println("Error al buscar el usuario por correo:", err)
+			}
+			return
+		}
+		if !usuario.CorreoVerificado {
+			m.enviarVerificacion(*usuario, idioma)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"mensaje": "Si el correo está registrado y sin verificar, recibirá un enlace nuevo"})
+}
+
+// enviarVerificacion genera el token para el correo actual del usuario, invalida los
+// anteriores y envía el enlace. Se llama en segundo plano al registrarse y al cambiar el correo.
+func (m *ManejadorUsuarios) enviarVerificacion(usuario modelos.Usuario, idioma string) {
+	ahora := time.Now()
+	// Solo vale el último enlace enviado
+	if err := m.repo.InvalidarTokensUnUso(usuario.ID, modelos.PropositoVerificar, ahora); err != nil {
+		log.Println("Error al invalidar los tokens de verificación:", err)
+		return
+	}
+	token, hash, err := auth.GenerarSecreto()
+	if err != nil {
+		log.Println("Error al generar el token de verificación:", err)
+		return
+	}
+	err = m.repo.GuardarTokenUnUso(&modelos.TokenUnUso{
+		UsuarioID: usuario.ID,
+		Proposito: modelos.PropositoVerificar,
+		Hash:      hash,
+		CreadoEn:  ahora,
+		ExpiraEn:  ahora.Add(m.opciones.DuracionVerificacion),
+		Dato:      usuario.Correo,
+	})
+	if err != nil {
+		log.Println("Error al guardar el token de verificación:", err)
+		return
+	}
+
+	m.enviarCorreo(correo.PlantillaVerificar, idioma, usuario, correo.Datos{
+		Enlace:  m.enlaceVerificacion(token),
+		Validez: m.opciones.DuracionVerificacion,
+	})
+}
+
+// enlaceVerificacion usa la página configurada o, si no hay, GET /verificar del propio
+// servicio cuando se conoce su URL pública (emisor_oidc)
+func (m *ManejadorUsuarios) enlaceVerificacion(token string) string {
+	pagina := m.opciones.URLVerificacion
+	if pagina == "" && m.opciones.EmisorOIDC != "" {
+		pagina = m.opciones.EmisorOIDC + "/verificar"
+	}
+	return enlaceConToken(pagina, token)
+}
@@ -0,0 +1,442 @@
+package manejadores
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"taller6/auth"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Tiempo que tiene el usuario para tocar la llave o confirmar en el teléfono
+const DuracionCeremoniaWebAuthn = 5 * time.Minute
+
+// Se pide verificar al usuario (PIN, huella) si el autenticador puede; si no lo hizo,
+// la llave cuenta como un solo factor y Login pide además el TOTP si está activo
+const verificacionWebAuthn = protocol.VerificationPreferred
+
+// Los autenticadores pueden usar hasta 1023 bytes, pero la columna guarda 512 caracteres en base64url
+const maxLargoCredencialID = 384
+
+// errSesionWebAuthnInvalida es la sesión de una ceremonia que no existe, venció o ya se usó
+var errSesionWebAuthnInvalida = errors.New("sesión WebAuthn inválida o vencida")
+
+// usuarioWebAuthn adapta el usuario y sus credenciales a lo que pide la biblioteca
+type usuarioWebAuthn struct {
+	usuario      *modelos.Usuario
+	credenciales []modelos.CredencialWebAuthn
+}
+
+// WebAuthnID es el user handle: el ID del usuario en 8 bytes, que no revela el nombre ni el correo
+func (u usuarioWebAuthn) WebAuthnID() []byte {
+	return idWebAuthn(u.usuario.ID)
+}
+
+func (u usuarioWebAuthn) WebAuthnName() string        { return u.usuario.NombreUsuario }
+func (u usuarioWebAuthn) WebAuthnDisplayName() string { return u.usuario.NombreUsuario }
+func (u usuarioWebAuthn) WebAuthnIcon() string        { return "" }
+
+// WebAuthnCredentials convierte las credenciales guardadas al tipo de la biblioteca
+func (u usuarioWebAuthn) WebAuthnCredentials() []webauthn.Credential {
+	credenciales := make([]webauthn.Credential, len(u.credenciales))
+	for i, credencial := range u.credenciales {
+		transportes := make([]protocol.AuthenticatorTransport, len(credencial.Transportes))
+		for j, transporte := range credencial.Transportes {
+			transportes[j] = protocol.AuthenticatorTransport(transporte)
+		}
+		credenciales[i] = webauthn.Credential{
+			ID:              credencial.CredencialID,
+			PublicKey:       credencial.ClavePublica,
+			AttestationType: credencial.TipoAtestacion,
+			Transport:       transportes,
+			Authenticator:   webauthn.Authenticator{SignCount: credencial.Contador},
+		}
+	}
+	return credenciales
+}
+
+// idWebAuthn arma el user handle a partir del ID del usuario
+func idWebAuthn(id uint) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// ListarCredencialesWebAuthn devuelve las llaves de acceso propias (GET /me/webauthn)
+func (m *ManejadorUsuarios) ListarCredencialesWebAuthn(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+
+	credenciales, err := m.repo.CredencialesWebAuthnDeUsuario(reclamos.Id)
+	if err != nil {
+		log.Println("Error al listar las credenciales WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar las llaves de acceso"})
+		return
+	}
+	if credenciales == nil {
+		credenciales = []modelos.CredencialWebAuthn{}
+	}
+	c.JSON(http.StatusOK, credenciales)
+}
+
+// EliminarCredencialWebAuthn borra una llave de acceso propia (DELETE /me/webauthn/:id)
+func (m *ManejadorUsuarios) EliminarCredencialWebAuthn(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := m.repo.EliminarCredencialWebAuthn(reclamos.Id, uint(id)); err != nil {
+		if errors.Is(err, repositorio.ErrCredencialNoEncontrada) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Llave de acceso no encontrada"})
+			return
+		}
+		log.Println("Error al eliminar la credencial WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo eliminar la llave de acceso"})
+		return
+	}
+	log.Printf("Usuario ID %d eliminó la llave de acceso ID %d", reclamos.Id, id)
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Llave de acceso eliminada"})
+}
+
+// IniciarRegistroWebAuthn empieza el registro de una llave de acceso (POST /me/webauthn/registro).
+// Devuelve las opciones para navigator.credentials.create() y la sesión que hay que
+// mandar de vuelta con la respuesta del autenticador.
+func (m *ManejadorUsuarios) IniciarRegistroWebAuthn(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+
+	usuario, ok := m.usuarioWebAuthn(c, reclamos.Id)
+	if !ok {
+		return
+	}
+	// Las que ya tiene van excluidas, así el navegador avisa si la llave ya estaba registrada
+	excluidas := make([]protocol.CredentialDescriptor, 0, len(usuario.credenciales))
+	for _, credencial := range usuario.WebAuthnCredentials() {
+		excluidas = append(excluidas, credencial.Descriptor())
+	}
+	opciones, sesion, err := m.opciones.WebAuthn.BeginRegistration(usuario,
+		webauthn.WithExclusions(excluidas),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: verificacionWebAuthn,
+		}),
+	)
+	if err != nil {
+		log.Println("Error al iniciar el registro WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar el registro"})
+		return
+	}
+
+	token, err := m.guardarSesionWebAuthn(reclamos.Id, modelos.PropositoRegistroWebAuthn, sesion.Challenge)
+	if err != nil {
+		log.Println("Error al guardar la sesión WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar el registro"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sesion": token, "publicKey": opciones.Response})
+}
+
+// CompletarRegistroWebAuthn verifica la respuesta de navigator.credentials.create() y
+// guarda la llave (POST /me/webauthn/registro/completar)
+func (m *ManejadorUsuarios) CompletarRegistroWebAuthn(c *gin.Context) {
+	reclamos := c.MustGet("reclamos").(*auth.Reclamos)
+	var datos struct {
+		Sesion     string          `json:"sesion" binding:"required"`
+		Nombre     string          `json:"nombre" binding:"max=100"`
+		Credencial json.RawMessage `json:"credencial" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	sesion, err := m.consumirSesionWebAuthn(modelos.PropositoRegistroWebAuthn, datos.Sesion)
+	if err != nil {
+		if errors.Is(err, errSesionWebAuthnInvalida) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sesión inválida o vencida"})
+			return
+		}
+		log.Println("Error al consumir la sesión WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo completar la operación"})
+		return
+	}
+	// La sesión es del usuario que la pidió; con otro token no sirve
+	if !bytes.Equal(sesion.UserID, idWebAuthn(reclamos.Id)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sesión inválida o vencida"})
+		return
+	}
+	usuario, ok := m.usuarioWebAuthn(c, reclamos.Id)
+	if !ok {
+		return
+	}
+
+	respuesta, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(datos.Credencial))
+	if err != nil {
+		log.Println("Respuesta de registro WebAuthn inválida:", detalleWebAuthn(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo verificar la llave de acceso"})
+		return
+	}
+	nueva, err := m.opciones.WebAuthn.CreateCredential(usuario, *sesion, respuesta)
+	if err != nil {
+		log.Println("Registro WebAuthn rechazado:", detalleWebAuthn(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo verificar la llave de acceso"})
+		return
+	}
+	if len(nueva.ID) > maxLargoCredencialID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La llave de acceso no es compatible"})
+		return
+	}
+
+	transportes := make([]string, len(nueva.Transport))
+	for i, transporte := range nueva.Transport {
+		transportes[i] = string(transporte)
+	}
+	credencial := modelos.CredencialWebAuthn{
+		UsuarioID:      reclamos.Id,
+		CredencialID:   nueva.ID,
+		ClavePublica:   nueva.PublicKey,
+		TipoAtestacion: nueva.AttestationType,
+		Transportes:    transportes,
+		Contador:       nueva.Authenticator.SignCount,
+		Nombre:         datos.Nombre,
+		CreadoEn:       time.Now(),
+	}
+	if err := m.repo.GuardarCredencialWebAuthn(&credencial); err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioDuplicado) {
+			c.JSON(http.StatusConflict, gin.H{"error": "La llave de acceso ya está registrada"})
+			return
+		}
+		log.Println("Error al guardar la credencial WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar la llave de acceso"})
+		return
+	}
+
+	log.Printf("Usuario ID %d registró la llave de acceso ID %d", reclamos.Id, credencial.ID)
+	c.JSON(http.StatusCreated, credencial)
+}
+
+// IniciarLoginWebAuthn empieza el inicio de sesión con llave de acceso (POST /login/webauthn).
+// Devuelve las opciones para navigator.credentials.get() y la sesión.
+func (m *ManejadorUsuarios) IniciarLoginWebAuthn(c *gin.Context) {
+	var datos struct {
+		NombreUsuario string `json:"nombre_usuario" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	// Si el usuario no existe o no tiene llaves se responde igual, con una llave señuelo:
+	// la respuesta no revela qué nombres están registrados
+	var usuario usuarioWebAuthn
+	encontrado, err := m.repo.BuscarPorNombre(datos.NombreUsuario)
+	switch {
+	case err == nil:
+		credenciales, err := m.repo.CredencialesWebAuthnDeUsuario(encontrado.ID)
+		if err != nil {
+			log.Println("Error al listar las credenciales WebAuthn:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+			return
+		}
+		usuario = usuarioWebAuthn{usuario: encontrado, credenciales: credenciales}
+	case !errors.Is(err, repositorio.ErrUsuarioNoEncontrado):
+		log.Println("Error al buscar el usuario:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+		return
+	}
+	if len(usuario.credenciales) == 0 {
+		usuario = usuarioSenueloWebAuthn(datos.NombreUsuario)
+	}
+
+	opciones, sesion, err := m.opciones.WebAuthn.BeginLogin(usuario, webauthn.WithUserVerification(verificacionWebAuthn))
+	if err != nil {
+		log.Println("Error al iniciar el login WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+		return
+	}
+	// La sesión del señuelo no se guarda: al completarla se rechaza como una llave inválida
+	var token string
+	if usuario.usuario.ID == 0 {
+		token, _, err = auth.GenerarSecreto()
+	} else {
+		token, err = m.guardarSesionWebAuthn(usuario.usuario.ID, modelos.PropositoLoginWebAuthn, sesion.Challenge)
+	}
+	if err != nil {
+		log.Println("Error al guardar la sesión WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sesion": token, "publicKey": opciones.Response})
+}
+
+// usuarioSenueloWebAuthn es el usuario que IniciarLoginWebAuthn usa para un nombre sin
+// llaves: tiene una llave cuyo ID sale del nombre, así repetir el pedido da la misma
+func usuarioSenueloWebAuthn(nombreUsuario string) usuarioWebAuthn {
+	return usuarioWebAuthn{
+		usuario:      &modelos.Usuario{NombreUsuario: nombreUsuario},
+		credenciales: []modelos.CredencialWebAuthn{{CredencialID: auth.Senuelo("webauthn:" + nombreUsuario)}},
+	}
+}
+
+// CompletarLoginWebAuthn verifica la respuesta de navigator.credentials.get() y entrega
+// los mismos tokens que Login (POST /login/webauthn/completar)
+func (m *ManejadorUsuarios) CompletarLoginWebAuthn(c *gin.Context) {
+	var datos struct {
+		Sesion     string          `json:"sesion" binding:"required"`
+		Credencial json.RawMessage `json:"credencial" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&datos); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos incorrectos"})
+		return
+	}
+
+	// Una sesión inválida se rechaza como una llave inválida: las del señuelo de
+	// IniciarLoginWebAuthn no se guardan y no se tienen que distinguir
+	sesion, err := m.consumirSesionWebAuthn(modelos.PropositoLoginWebAuthn, datos.Sesion)
+	if err != nil {
+		if errors.Is(err, errSesionWebAuthnInvalida) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No se pudo verificar la llave de acceso"})
+			return
+		}
+		log.Println("Error al consumir la sesión WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo completar la operación"})
+		return
+	}
+	usuarioID := uint(binary.BigEndian.Uint64(sesion.UserID))
+	usuario, ok := m.usuarioWebAuthn(c, usuarioID)
+	if !ok {
+		return
+	}
+
+	respuesta, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(datos.Credencial))
+	if err != nil {
+		log.Println("Respuesta de login WebAuthn inválida:", detalleWebAuthn(err))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No se pudo verificar la llave de acceso"})
+		return
+	}
+	credencial, err := m.opciones.WebAuthn.ValidateLogin(usuario, *sesion, respuesta)
+	if err != nil {
+		log.Println("Login WebAuthn rechazado:", detalleWebAuthn(err))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No se pudo verificar la llave de acceso"})
+		return
+	}
+
+	// El contador tiene que avanzar: si no, la llave puede estar clonada
+	var guardada *modelos.CredencialWebAuthn
+	for i := range usuario.credenciales {
+		if bytes.Equal(usuario.credenciales[i].CredencialID, credencial.ID) {
+			guardada = &usuario.credenciales[i]
+		}
+	}
+	avanzo := false
+	if guardada != nil && !credencial.Authenticator.CloneWarning {
+		avanzo, err = m.repo.RegistrarUsoCredencialWebAuthn(guardada.ID, credencial.Authenticator.SignCount, time.Now())
+		if err != nil {
+			log.Println("Error al registrar el uso de la credencial WebAuthn:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+			return
+		}
+	}
+	if !avanzo {
+		log.Printf("Login WebAuthn rechazado para el usuario ID %d: el contador de firmas no avanzó (¿llave clonada?)", usuarioID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No se pudo verificar la llave de acceso"})
+		return
+	}
+
+	if m.opciones.RequiereCorreoVerificado && !usuario.usuario.CorreoVerificado {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Debe verificar su correo antes de iniciar sesión"})
+		return
+	}
+	// Con verificación del usuario (PIN, huella) la llave ya son dos factores
+	m.responderLogin(c, usuarioID, !credencial.Flags.UserVerified)
+}
+
+// usuarioWebAuthn busca el usuario con sus credenciales. Si devuelve false ya respondió.
+func (m *ManejadorUsuarios) usuarioWebAuthn(c *gin.Context, id uint) (usuarioWebAuthn, bool) {
+	usuario, err := m.repo.BuscarPorID(id)
+	if err != nil {
+		if errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		} else {
+			log.Println("Error al consultar el usuario:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar el usuario"})
+		}
+		return usuarioWebAuthn{}, false
+	}
+	credenciales, err := m.repo.CredencialesWebAuthnDeUsuario(id)
+	if err != nil {
+		log.Println("Error al listar las credenciales WebAuthn:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar las llaves de acceso"})
+		return usuarioWebAuthn{}, false
+	}
+	return usuarioWebAuthn{usuario: usuario, credenciales: credenciales}, true
+}
+
+// guardarSesionWebAuthn guarda el desafío como token de un solo uso y devuelve el
+// token que identifica la ceremonia. Así cualquier instancia puede completarla.
+func (m *ManejadorUsuarios) guardarSesionWebAuthn(usuarioID uint, proposito, desafio string) (string, error) {
+	token, hash, err := auth.GenerarSecreto()
+	if err != nil {
+		return "", err
+	}
+	ahora := time.Now()
+	err = m.repo.GuardarTokenUnUso(&modelos.TokenUnUso{
+		UsuarioID: usuarioID,
+		Proposito: proposito,
+		Hash:      hash,
+		CreadoEn:  ahora,
+		ExpiraEn:  ahora.Add(DuracionCeremoniaWebAuthn),
+		Dato:      desafio,
+	})
+	return token, err
+}
+
+// consumirSesionWebAuthn marca la sesión como usada y arma la SessionData que espera la
+// biblioteca; un desafío no sirve dos veces aunque la respuesta falle. Devuelve
+// errSesionWebAuthnInvalida si la sesión no existe, venció o ya se usó.
+func (m *ManejadorUsuarios) consumirSesionWebAuthn(proposito, valor string) (*webauthn.SessionData, error) {
+	token, err := m.repo.BuscarTokenUnUso(proposito, auth.HashSecreto(valor))
+	if err != nil {
+		if errors.Is(err, repositorio.ErrTokenNoEncontrado) {
+			return nil, errSesionWebAuthnInvalida
+		}
+		return nil, err
+	}
+	ahora := time.Now()
+	if token.UsadoEn != nil || ahora.After(token.ExpiraEn) {
+		return nil, errSesionWebAuthnInvalida
+	}
+	usado, err := m.repo.MarcarTokenUnUsoUsado(token.ID, ahora)
+	if err != nil {
+		return nil, err
+	}
+	if !usado {
+		return nil, errSesionWebAuthnInvalida
+	}
+
+	return &webauthn.SessionData{
+		Challenge:        token.Dato,
+		UserID:           idWebAuthn(token.UsuarioID),
+		Expires:          token.ExpiraEn,
+		UserVerification: verificacionWebAuthn,
+	}, nil
+}
+
+// detalleWebAuthn agrega al log la información para desarrolladores de los errores de la biblioteca
+func detalleWebAuthn(err error) string {
+	var errProtocolo *protocol.Error
+	if errors.As(err, &errProtocolo) && errProtocolo.DevInfo != "" {
+		return errProtocolo.Details + " (" + errProtocolo.DevInfo + ")"
+	}
+	return err.Error()
+}
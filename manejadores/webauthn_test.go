@@ -0,0 +1,280 @@
+package manejadores
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taller6/auth"
+	"taller6/auth/webauthntest"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Sitio de las pruebas de llaves de acceso
+const (
+	dominioPrueba = "ejemplo.com"
+	origenPrueba  = "https://ejemplo.com"
+)
+
+// pruebaWebAuthn es un servidor con las rutas de llaves de acceso, un usuario y su token
+type pruebaWebAuthn struct {
+	servidor *gin.Engine
+	usuario  *modelos.Usuario
+	token    string
+}
+
+// nuevaPruebaWebAuthn arma el servidor sobre un repositorio en memoria, con las rutas
+// como en main.go
+func nuevaPruebaWebAuthn(t *testing.T) *pruebaWebAuthn {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	auth.Configurar("clave-de-prueba", time.Minute)
+
+	ceremonia := webauthn.TimeoutConfig{Enforce: true, Timeout: DuracionCeremoniaWebAuthn, TimeoutUVD: DuracionCeremoniaWebAuthn}
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:                  dominioPrueba,
+		RPDisplayName:         "Taller 6",
+		RPOrigins:             []string{origenPrueba},
+		AttestationPreference: protocol.PreferNoAttestation,
+		Timeouts:              webauthn.TimeoutsConfig{Login: ceremonia, Registration: ceremonia},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repositorio.NuevoUsuarioRepositorioMemoria()
+	usuario := &modelos.Usuario{NombreUsuario: "ana", Correo: "ana@ejemplo.com", Contrasena: "hash"}
+	if err := repo.Crear(usuario); err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.GenerarToken(usuario.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usuarios := NuevoManejadorUsuarios(repo, Opciones{WebAuthn: relyingParty, DuracionRefresco: time.Hour})
+	servidor := gin.New()
+	servidor.POST("/login/webauthn", usuarios.IniciarLoginWebAuthn)
+	servidor.POST("/login/webauthn/completar", usuarios.CompletarLoginWebAuthn)
+	rutasProtegidas := servidor.Group("/", auth.RequiereAutenticacion())
+	rutasProtegidas.GET("/me/webauthn", usuarios.ListarCredencialesWebAuthn)
+	rutasProtegidas.POST("/me/webauthn/registro", usuarios.IniciarRegistroWebAuthn)
+	rutasProtegidas.POST("/me/webauthn/registro/completar", usuarios.CompletarRegistroWebAuthn)
+
+	return &pruebaWebAuthn{servidor: servidor, usuario: usuario, token: token}
+}
+
+// pedir hace un POST con el cuerpo en JSON y, si se indica, el token
+func (p *pruebaWebAuthn) pedir(t *testing.T, ruta, token string, cuerpo interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	contenido, err := json.Marshal(cuerpo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pedido := httptest.NewRequest(http.MethodPost, ruta, bytes.NewReader(contenido))
+	pedido.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		pedido.Header.Set("Authorization", "Bearer "+token)
+	}
+	respuesta := httptest.NewRecorder()
+	p.servidor.ServeHTTP(respuesta, pedido)
+	return respuesta
+}
+
+// inicioCeremonia es la respuesta de IniciarRegistroWebAuthn e IniciarLoginWebAuthn
+type inicioCeremonia struct {
+	Sesion    string          `json:"sesion"`
+	PublicKey json.RawMessage `json:"publicKey"`
+}
+
+// iniciar pide las opciones de una ceremonia y falla si no se obtienen
+func (p *pruebaWebAuthn) iniciar(t *testing.T, ruta, token string, cuerpo interface{}) inicioCeremonia {
+	t.Helper()
+	respuesta := p.pedir(t, ruta, token, cuerpo)
+	if respuesta.Code != http.StatusOK {
+		t.Fatalf("%s devolvió %d: %s", ruta, respuesta.Code, respuesta.Body)
+	}
+	var inicio inicioCeremonia
+	if err := json.Unmarshal(respuesta.Body.Bytes(), &inicio); err != nil {
+		t.Fatal(err)
+	}
+	return inicio
+}
+
+// registrar hace la ceremonia de registro completa con el autenticador y devuelve la
+// respuesta de CompletarRegistroWebAuthn
+func (p *pruebaWebAuthn) registrar(t *testing.T, autenticador *webauthntest.Autenticador) *httptest.ResponseRecorder {
+	t.Helper()
+	inicio := p.iniciar(t, "/me/webauthn/registro", p.token, gin.H{})
+	credencial, err := autenticador.Registrar(inicio.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.pedir(t, "/me/webauthn/registro/completar", p.token, gin.H{"sesion": inicio.Sesion, "nombre": "llave", "credencial": credencial})
+}
+
+// loguear hace la ceremonia de inicio de sesión completa con el autenticador y devuelve
+// la respuesta de CompletarLoginWebAuthn
+func (p *pruebaWebAuthn) loguear(t *testing.T, autenticador *webauthntest.Autenticador) *httptest.ResponseRecorder {
+	t.Helper()
+	inicio := p.iniciar(t, "/login/webauthn", "", gin.H{"nombre_usuario": p.usuario.NombreUsuario})
+	credencial, err := autenticador.Firmar(inicio.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.pedir(t, "/login/webauthn/completar", "", gin.H{"sesion": inicio.Sesion, "credencial": credencial})
+}
+
+func TestRegistroYLoginWebAuthn(t *testing.T) {
+	p := nuevaPruebaWebAuthn(t)
+	autenticador := webauthntest.Nuevo(origenPrueba)
+
+	if respuesta := p.registrar(t, autenticador); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el registro devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+
+	// La misma llave va excluida en el próximo registro
+	inicio := p.iniciar(t, "/me/webauthn/registro", p.token, gin.H{})
+	if _, err := autenticador.Registrar(inicio.PublicKey); err == nil {
+		t.Error("el autenticador volvió a registrar una llave excluida")
+	}
+
+	// Dos inicios de sesión seguidos: el contador avanza en cada uno
+	for i := 0; i < 2; i++ {
+		respuesta := p.loguear(t, autenticador)
+		if respuesta.Code != http.StatusCreated {
+			t.Fatalf("el inicio de sesión %d devolvió %d: %s", i+1, respuesta.Code, respuesta.Body)
+		}
+		var tokens struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(respuesta.Body.Bytes(), &tokens); err != nil {
+			t.Fatal(err)
+		}
+		reclamos, err := auth.ValidarToken(tokens.Token)
+		if err != nil {
+			t.Fatal("el token del inicio de sesión no es válido:", err)
+		}
+		if reclamos.Id != p.usuario.ID {
+			t.Fatalf("el token es del usuario %d, se esperaba %d", reclamos.Id, p.usuario.ID)
+		}
+	}
+}
+
+func TestLoginWebAuthnSesionDeUnSoloUso(t *testing.T) {
+	p := nuevaPruebaWebAuthn(t)
+	autenticador := webauthntest.Nuevo(origenPrueba)
+	if respuesta := p.registrar(t, autenticador); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el registro devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+
+	inicio := p.iniciar(t, "/login/webauthn", "", gin.H{"nombre_usuario": p.usuario.NombreUsuario})
+	credencial, err := autenticador.Firmar(inicio.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cuerpo := gin.H{"sesion": inicio.Sesion, "credencial": credencial}
+	if respuesta := p.pedir(t, "/login/webauthn/completar", "", cuerpo); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el inicio de sesión devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+	if respuesta := p.pedir(t, "/login/webauthn/completar", "", cuerpo); respuesta.Code != http.StatusUnauthorized {
+		t.Fatalf("repetir la misma aserción devolvió %d, se esperaba %d", respuesta.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginWebAuthnContadorRetrocedido(t *testing.T) {
+	p := nuevaPruebaWebAuthn(t)
+	autenticador := webauthntest.Nuevo(origenPrueba)
+	if respuesta := p.registrar(t, autenticador); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el registro devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+	if respuesta := p.loguear(t, autenticador); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el inicio de sesión devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+
+	// Una llave clonada firma con un contador que ya se vio
+	autenticador.RetrocederContador()
+	if respuesta := p.loguear(t, autenticador); respuesta.Code != http.StatusUnauthorized {
+		t.Fatalf("con el contador retrocedido se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginWebAuthnOtroOrigen(t *testing.T) {
+	p := nuevaPruebaWebAuthn(t)
+	autenticador := webauthntest.Nuevo(origenPrueba)
+	if respuesta := p.registrar(t, autenticador); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el registro devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+
+	// Un sitio de phishing recibe la aserción con su propio origen
+	autenticador.Origen = "https://ejemplo.com.phishing.net"
+	if respuesta := p.loguear(t, autenticador); respuesta.Code != http.StatusUnauthorized {
+		t.Fatalf("con otro origen se obtuvo %d, se esperaba %d", respuesta.Code, http.StatusUnauthorized)
+	}
+}
+
+// opcionesLogin son los campos de las opciones de navigator.credentials.get() que
+// podrían distinguir a un usuario de otro
+type opcionesLogin struct {
+	AllowCredentials []struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"allowCredentials"`
+	UserVerification string `json:"userVerification"`
+}
+
+func TestIniciarLoginWebAuthnNoRevelaUsuarios(t *testing.T) {
+	p := nuevaPruebaWebAuthn(t)
+	opciones := func(nombre string) (inicioCeremonia, opcionesLogin) {
+		t.Helper()
+		inicio := p.iniciar(t, "/login/webauthn", "", gin.H{"nombre_usuario": nombre})
+		var opciones opcionesLogin
+		if err := json.Unmarshal(inicio.PublicKey, &opciones); err != nil {
+			t.Fatal(err)
+		}
+		if len(opciones.AllowCredentials) != 1 || opciones.AllowCredentials[0].Type != "public-key" || opciones.AllowCredentials[0].ID == "" {
+			t.Fatalf("las opciones para %q no tienen una llave: %s", nombre, inicio.PublicKey)
+		}
+		return inicio, opciones
+	}
+
+	// Un usuario sin llaves y uno que no existe reciben una llave señuelo, siempre la misma
+	_, sinLlaves := opciones(p.usuario.NombreUsuario)
+	inicio, inexistente := opciones("nadie")
+	_, repetido := opciones("nadie")
+	if inexistente.AllowCredentials[0].ID != repetido.AllowCredentials[0].ID {
+		t.Error("el señuelo cambió entre dos pedidos con el mismo nombre")
+	}
+	if inexistente.AllowCredentials[0].ID == sinLlaves.AllowCredentials[0].ID {
+		t.Error("dos nombres distintos recibieron el mismo señuelo")
+	}
+
+	// Con una llave registrada la respuesta tiene la misma forma
+	autenticador := webauthntest.Nuevo(origenPrueba)
+	if respuesta := p.registrar(t, autenticador); respuesta.Code != http.StatusCreated {
+		t.Fatalf("el registro devolvió %d: %s", respuesta.Code, respuesta.Body)
+	}
+	_, conLlave := opciones(p.usuario.NombreUsuario)
+	if conLlave.UserVerification != inexistente.UserVerification {
+		t.Errorf("userVerification es %q con llave y %q sin usuario", conLlave.UserVerification, inexistente.UserVerification)
+	}
+
+	// Completar la sesión del señuelo, aun con una aserción válida de otra sesión, se
+	// rechaza como una llave inválida
+	real := p.iniciar(t, "/login/webauthn", "", gin.H{"nombre_usuario": p.usuario.NombreUsuario})
+	credencial, err := autenticador.Firmar(real.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respuesta := p.pedir(t, "/login/webauthn/completar", "", gin.H{"sesion": inicio.Sesion, "credencial": credencial})
+	if respuesta.Code != http.StatusUnauthorized {
+		t.Fatalf("completar el señuelo devolvió %d, se esperaba %d", respuesta.Code, http.StatusUnauthorized)
+	}
+}
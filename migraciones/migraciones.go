@@ -0,0 +1,306 @@
+package migraciones
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Archivos de migración de todos los dialectos: sql/<dialecto>/<version>_<nombre>.<up|down>.sql
+//
+//go:embed sql
+var archivos embed.FS
+
+// Formato del nombre de cada archivo de migración
+var patronArchivo = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Dialecto agrupa lo que cambia entre motores de base de datos
+type Dialecto interface {
+	// Nombre es la carpeta dentro de sql/ con las migraciones del motor
+	Nombre() string
+	// Marcador devuelve el placeholder del parámetro n (empezando en 1)
+	Marcador(n int) string
+	// TablaMigraciones devuelve el CREATE TABLE IF NOT EXISTS de schema_migrations
+	TablaMigraciones() string
+	// Bloquear impide que otra instancia migre al mismo tiempo
+	Bloquear(ctx context.Context, conn *sql.Conn) error
+	// Desbloquear libera el lock tomado por Bloquear
+	Desbloquear(ctx context.Context, conn *sql.Conn) error
+}
+
+// Migracion es un cambio de esquema con su versión y su reversa
+type Migracion struct {
+	Version  int64
+	Nombre   string
+	Subir    string
+	Bajar    string
+	Checksum string // sha256 del SQL de subida
+}
+
+// Estado indica si una migración ya se aplicó y cuándo
+type Estado struct {
+	Migracion
+	Aplicada   bool
+	AplicadaEn time.Time
+}
+
+// Migrador aplica y revierte las migraciones de un dialecto sobre una base
+type Migrador struct {
+	bd          *sql.DB
+	dialecto    Dialecto
+	migraciones []Migracion
+}
+
+// Nuevo carga las migraciones del dialecto y valida que estén completas
+func Nuevo(bd *sql.DB, dialecto Dialecto) (*Migrador, error) {
+	migraciones, err := cargar(dialecto.Nombre())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrador{bd: bd, dialecto: dialecto, migraciones: migraciones}, nil
+}
+
+// Subir aplica, en orden, todas las migraciones pendientes y devuelve cuántas aplicó
+func (m *Migrador) Subir() (int, error) {
+	aplicadas := 0
+	err := m.conLock(func(ctx context.Context, conn *sql.Conn) error {
+		registradas, err := m.registradas(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migracion := range m.migraciones {
+			if _, existe := registradas[migracion.Version]; existe {
+				continue
+			}
+			if err := m.aplicar(ctx, conn, migracion, true); err != nil {
+				return err
+			}
+			aplicadas++
+		}
+		return nil
+	})
+	return aplicadas, err
+}
+
+// Bajar revierte las últimas "pasos" migraciones aplicadas y devuelve cuántas revirtió
+func (m *Migrador) Bajar(pasos int) (int, error) {
+	revertidas := 0
+	err := m.conLock(func(ctx context.Context, conn *sql.Conn) error {
+		registradas, err := m.registradas(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migraciones) - 1; i >= 0 && revertidas < pasos; i-- {
+			migracion := m.migraciones[i]
+			if _, existe := registradas[migracion.Version]; !existe {
+				continue
+			}
+			if err := m.aplicar(ctx, conn, migracion, false); err != nil {
+				return err
+			}
+			revertidas++
+		}
+		return nil
+	})
+	return revertidas, err
+}
+
+// Estado devuelve todas las migraciones conocidas indicando cuáles están aplicadas
+func (m *Migrador) Estado() ([]Estado, error) {
+	var estados []Estado
+	err := m.conLock(func(ctx context.Context, conn *sql.Conn) error {
+		registradas, err := m.registradas(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migracion := range m.migraciones {
+			aplicadaEn, aplicada := registradas[migracion.Version]
+			estados = append(estados, Estado{Migracion: migracion, Aplicada: aplicada, AplicadaEn: aplicadaEn})
+		}
+		return nil
+	})
+	return estados, err
+}
+
+// conLock reserva una conexión, toma el lock del dialecto y se asegura de que
+// exista schema_migrations antes de ejecutar la función
+func (m *Migrador) conLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.bd.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.dialecto.Bloquear(ctx, conn); err != nil {
+		return fmt.Errorf("migraciones: %w", err)
+	}
+	defer m.dialecto.Desbloquear(ctx, conn)
+
+	if _, err := conn.ExecContext(ctx, m.dialecto.TablaMigraciones()); err != nil {
+		return fmt.Errorf("migraciones: no se pudo crear schema_migrations: %w", err)
+	}
+	return fn(ctx, conn)
+}
+
+// registradas lee schema_migrations y verifica que los checksums coincidan con los archivos
+func (m *Migrador) registradas(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, aplicada_en FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	porVersion := make(map[int64]Migracion, len(m.migraciones))
+	for _, migracion := range m.migraciones {
+		porVersion[migracion.Version] = migracion
+	}
+
+	registradas := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var checksum string
+		var aplicadaEn time.Time
+		if err := rows.Scan(&version, &checksum, (*marcaDeTiempo)(&aplicadaEn)); err != nil {
+			return nil, err
+		}
+		migracion, conocida := porVersion[version]
+		if !conocida {
+			return nil, fmt.Errorf("migraciones: la base tiene aplicada la versión %d, que no existe en este binario", version)
+		}
+		if migracion.Checksum != checksum {
+			return nil, fmt.Errorf("migraciones: el checksum de %d_%s no coincide con el aplicado; las migraciones aplicadas no se deben modificar", version, migracion.Nombre)
+		}
+		registradas[version] = aplicadaEn
+	}
+	return registradas, rows.Err()
+}
+
+// aplicar ejecuta la subida o la bajada de una migración y actualiza schema_migrations
+func (m *Migrador) aplicar(ctx context.Context, conn *sql.Conn, migracion Migracion, subir bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	contenido, registro, args := migracion.Bajar, "DELETE FROM schema_migrations WHERE version = "+m.dialecto.Marcador(1), []interface{}{migracion.Version}
+	if subir {
+		contenido = migracion.Subir
+		registro = fmt.Sprintf("INSERT INTO schema_migrations (version, nombre, checksum, aplicada_en) VALUES (%s, %s, %s, %s)",
+			m.dialecto.Marcador(1), m.dialecto.Marcador(2), m.dialecto.Marcador(3), m.dialecto.Marcador(4))
+		args = []interface{}{migracion.Version, migracion.Nombre, migracion.Checksum, time.Now().UTC()}
+	}
+
+	for _, sentencia := range separarSentencias(contenido) {
+		if _, err := tx.ExecContext(ctx, sentencia); err != nil {
+			return fmt.Errorf("migraciones: %d_%s: %w", migracion.Version, migracion.Nombre, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, registro, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// cargar lee las migraciones embebidas del dialecto, ordenadas por versión
+func cargar(dialecto string) ([]Migracion, error) {
+	carpeta := "sql/" + dialecto
+	entradas, err := fs.ReadDir(archivos, carpeta)
+	if err != nil {
+		return nil, fmt.Errorf("migraciones: no hay migraciones para %q: %w", dialecto, err)
+	}
+
+	porVersion := make(map[int64]*Migracion)
+	for _, entrada := range entradas {
+		partes := patronArchivo.FindStringSubmatch(entrada.Name())
+		if partes == nil {
+			return nil, fmt.Errorf("migraciones: nombre de archivo inválido: %s/%s", carpeta, entrada.Name())
+		}
+		version, _ := strconv.ParseInt(partes[1], 10, 64)
+		contenido, err := archivos.ReadFile(carpeta + "/" + entrada.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migracion, existe := porVersion[version]
+		if !existe {
+			migracion = &Migracion{Version: version, Nombre: partes[2]}
+			porVersion[version] = migracion
+		} else if migracion.Nombre != partes[2] {
+			return nil, fmt.Errorf("migraciones: la versión %d tiene dos nombres: %s y %s", version, migracion.Nombre, partes[2])
+		}
+		if partes[3] == "up" {
+			migracion.Subir = string(contenido)
+			suma := sha256.Sum256(contenido)
+			migracion.Checksum = hex.EncodeToString(suma[:])
+		} else {
+			migracion.Bajar = string(contenido)
+		}
+	}
+
+	migraciones := make([]Migracion, 0, len(porVersion))
+	for _, migracion := range porVersion {
+		if migracion.Subir == "" || migracion.Bajar == "" {
+			return nil, fmt.Errorf("migraciones: %d_%s necesita los archivos .up.sql y .down.sql", migracion.Version, migracion.Nombre)
+		}
+		migraciones = append(migraciones, *migracion)
+	}
+	sort.Slice(migraciones, func(i, j int) bool { return migraciones[i].Version < migraciones[j].Version })
+	return migraciones, nil
+}
+
+// separarSentencias divide un archivo en sentencias terminadas en ";" y descarta
+// los comentarios de línea. Las migraciones no deben tener ";" dentro de textos.
+func separarSentencias(contenido string) []string {
+	var limpio strings.Builder
+	for _, linea := range strings.Split(contenido, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(linea), "--") {
+			continue
+		}
+		limpio.WriteString(linea)
+		limpio.WriteString("\n")
+	}
+
+	var sentencias []string
+	for _, sentencia := range strings.Split(limpio.String(), ";") {
+		if sentencia = strings.TrimSpace(sentencia); sentencia != "" {
+			sentencias = append(sentencias, sentencia)
+		}
+	}
+	return sentencias
+}
+
+// marcaDeTiempo escanea fechas que algunos drivers devuelven como texto
+// (MySQL sin parseTime) y otros como time.Time
+type marcaDeTiempo time.Time
+
+// Scan implementa sql.Scanner
+func (t *marcaDeTiempo) Scan(valor interface{}) error {
+	switch v := valor.(type) {
+	case time.Time:
+		*t = marcaDeTiempo(v)
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		fecha, err := time.Parse("2006-01-02 15:04:05", v)
+		if err != nil {
+			return err
+		}
+		*t = marcaDeTiempo(fecha)
+	case nil:
+		*t = marcaDeTiempo(time.Time{})
+	default:
+		return fmt.Errorf("tipo de fecha no soportado: %T", valor)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package migraciones
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestCargar(t *testing.T) {
+	for _, dialecto := range []Dialecto{MySQL{}, Postgres{}, SQLite{}} {
+		migraciones, err := cargar(dialecto.Nombre())
+		if err != nil {
+			t.Fatalf("%s: %v", dialecto.Nombre(), err)
+		}
+		if len(migraciones) == 0 {
+			t.Fatalf("%s: no se cargó ninguna migración", dialecto.Nombre())
+		}
+		for i, migracion := range migraciones {
+			if i > 0 && migracion.Version <= migraciones[i-1].Version {
+				t.Errorf("%s: la versión %d viene después de la %d", dialecto.Nombre(), migracion.Version, migraciones[i-1].Version)
+			}
+			if migracion.Nombre == "" || strings.TrimSpace(migracion.Subir) == "" || strings.TrimSpace(migracion.Bajar) == "" {
+				t.Errorf("%s: la migración %d está incompleta: %+v", dialecto.Nombre(), migracion.Version, migracion)
+			}
+			suma := sha256.Sum256([]byte(migracion.Subir))
+			if migracion.Checksum != hex.EncodeToString(suma[:]) {
+				t.Errorf("%s: el checksum de %d_%s no es el sha256 de la subida", dialecto.Nombre(), migracion.Version, migracion.Nombre)
+			}
+		}
+	}
+
+	if _, err := cargar("oracle"); err == nil {
+		t.Error("se cargaron migraciones de un dialecto que no existe")
+	}
+}
+
+func TestSepararSentencias(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		contenido string
+		esperadas []string
+	}{
+		{"vacío", "", nil},
+		{"solo comentarios", "-- nada\n  -- tampoco\n", nil},
+		{"una sentencia", "CREATE TABLE a (id INTEGER);\n", []string{"CREATE TABLE a (id INTEGER)"}},
+		{
+			"varias con comentarios",
+			"-- Tabla a\nCREATE TABLE a (\n    id INTEGER -- no se corta acá\n);\n\n    -- Índice\nCREATE INDEX a_id ON a (id);",
+			[]string{"CREATE TABLE a (\n    id INTEGER -- no se corta acá\n)", "CREATE INDEX a_id ON a (id)"},
+		},
+		{"la última sin punto y coma", "DROP TABLE a;\nDROP TABLE b", []string{"DROP TABLE a", "DROP TABLE b"}},
+		{"punto y coma sobrantes", ";;DROP TABLE a;;\n;", []string{"DROP TABLE a"}},
+	}
+	for _, caso := range casos {
+		if sentencias := separarSentencias(caso.contenido); !reflect.DeepEqual(sentencias, caso.esperadas) {
+			t.Errorf("%s: se obtuvo %q, se esperaba %q", caso.nombre, sentencias, caso.esperadas)
+		}
+	}
+}
+
+func TestMarcaDeTiempo(t *testing.T) {
+	fecha := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	casos := []struct {
+		nombre   string
+		valor    interface{}
+		esperada time.Time
+		valida   bool
+	}{
+		{"time.Time", fecha, fecha, true},
+		{"texto", "2024-03-01 12:30:00", fecha, true},
+		{"bytes", []byte("2024-03-01 12:30:00"), fecha, true},
+		{"nulo", nil, time.Time{}, true},
+		{"texto inválido", "ayer", time.Time{}, false},
+		{"entero", int64(1709296200), time.Time{}, false},
+	}
+	for _, caso := range casos {
+		var marca marcaDeTiempo
+		err := marca.Scan(caso.valor)
+		if (err == nil) != caso.valida {
+			t.Errorf("%s: error %v, se esperaba válida=%v", caso.nombre, err, caso.valida)
+			continue
+		}
+		if caso.valida && !time.Time(marca).Equal(caso.esperada) {
+			t.Errorf("%s: se obtuvo %v, se esperaba %v", caso.nombre, time.Time(marca), caso.esperada)
+		}
+	}
+}
+
+// baseEnMemoria abre una base SQLite en memoria. Con una sola conexión todas las
+// operaciones ven la misma base.
+func baseEnMemoria(t *testing.T) *sql.DB {
+	t.Helper()
+	bd, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd.SetMaxOpenConns(1)
+	t.Cleanup(func() { bd.Close() })
+	return bd
+}
+
+// aplicadas devuelve las versiones aplicadas según Estado
+func aplicadas(t *testing.T, migrador *Migrador) []int64 {
+	t.Helper()
+	estados, err := migrador.Estado()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estados) != len(migrador.migraciones) {
+		t.Fatalf("Estado devolvió %d migraciones, se esperaban %d", len(estados), len(migrador.migraciones))
+	}
+	var versiones []int64
+	for _, estado := range estados {
+		if estado.Aplicada {
+			if estado.AplicadaEn.IsZero() {
+				t.Errorf("la versión %d está aplicada sin fecha", estado.Version)
+			}
+			versiones = append(versiones, estado.Version)
+		}
+	}
+	return versiones
+}
+
+// versiones devuelve las versiones de las migraciones hasta la posición indicada
+func versiones(migraciones []Migracion, hasta int) []int64 {
+	var lista []int64
+	for _, migracion := range migraciones[:hasta] {
+		lista = append(lista, migracion.Version)
+	}
+	return lista
+}
+
+// tablas devuelve las tablas creadas por las migraciones
+func tablas(t *testing.T, bd *sql.DB) []string {
+	t.Helper()
+	rows, err := bd.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT LIKE 'schema_migrations%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var lista []string
+	for rows.Next() {
+		var nombre string
+		if err := rows.Scan(&nombre); err != nil {
+			t.Fatal(err)
+		}
+		lista = append(lista, nombre)
+	}
+	return lista
+}
+
+func TestMigradorSQLite(t *testing.T) {
+	bd := baseEnMemoria(t)
+	migrador, err := Nuevo(bd, SQLite{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(migrador.migraciones)
+	if total < 2 {
+		t.Fatalf("la prueba necesita al menos 2 migraciones, hay %d", total)
+	}
+
+	if versionesAplicadas := aplicadas(t, migrador); len(versionesAplicadas) != 0 {
+		t.Fatalf("una base nueva tiene aplicadas %v", versionesAplicadas)
+	}
+
+	if n, err := migrador.Subir(); err != nil || n != total {
+		t.Fatalf("Subir aplicó %d (%v), se esperaban %d", n, err, total)
+	}
+	if versionesAplicadas := aplicadas(t, migrador); !reflect.DeepEqual(versionesAplicadas, versiones(migrador.migraciones, total)) {
+		t.Fatalf("después de Subir están aplicadas %v", versionesAplicadas)
+	}
+	creadas := tablas(t, bd)
+	if len(creadas) == 0 {
+		t.Fatal("Subir no creó ninguna tabla")
+	}
+	if n, err := migrador.Subir(); err != nil || n != 0 {
+		t.Errorf("el segundo Subir aplicó %d (%v), se esperaba 0", n, err)
+	}
+
+	// Bajar revierte desde la más nueva
+	if n, err := migrador.Bajar(2); err != nil || n != 2 {
+		t.Fatalf("Bajar(2) revirtió %d (%v)", n, err)
+	}
+	if versionesAplicadas := aplicadas(t, migrador); !reflect.DeepEqual(versionesAplicadas, versiones(migrador.migraciones, total-2)) {
+		t.Fatalf("después de Bajar(2) están aplicadas %v", versionesAplicadas)
+	}
+	if n, err := migrador.Subir(); err != nil || n != 2 {
+		t.Fatalf("Subir volvió a aplicar %d (%v), se esperaban 2", n, err)
+	}
+
+	// Todas las bajadas dejan la base vacía y se puede volver a subir
+	if n, err := migrador.Bajar(total + 1); err != nil || n != total {
+		t.Fatalf("Bajar revirtió %d (%v), se esperaban %d", n, err, total)
+	}
+	if sobrantes := tablas(t, bd); len(sobrantes) != 0 {
+		t.Errorf("después de revertir todo quedan las tablas %v", sobrantes)
+	}
+	if n, err := migrador.Bajar(1); err != nil || n != 0 {
+		t.Errorf("Bajar sin migraciones aplicadas revirtió %d (%v)", n, err)
+	}
+	if n, err := migrador.Subir(); err != nil || n != total {
+		t.Fatalf("Subir después de revertir todo aplicó %d (%v)", n, err)
+	}
+	if otra := tablas(t, bd); !reflect.DeepEqual(otra, creadas) {
+		t.Errorf("al volver a subir quedaron %v, se esperaba %v", otra, creadas)
+	}
+}
+
+func TestMigradorMigracionModificada(t *testing.T) {
+	bd := baseEnMemoria(t)
+	migrador, err := Nuevo(bd, SQLite{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrador.Subir(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Un binario cuya primera migración se editó después de aplicarla
+	modificadas := append([]Migracion(nil), migrador.migraciones...)
+	modificadas[0].Subir += "\nCREATE INDEX agregado ON usuarios (id);"
+	suma := sha256.Sum256([]byte(modificadas[0].Subir))
+	modificadas[0].Checksum = hex.EncodeToString(suma[:])
+	modificado := &Migrador{bd: bd, dialecto: SQLite{}, migraciones: modificadas}
+
+	if _, err := modificado.Subir(); err == nil || !strings.Contains(err.Error(), "no coincide") {
+		t.Errorf("Subir con una migración modificada devolvió %v", err)
+	}
+	if _, err := modificado.Bajar(1); err == nil || !strings.Contains(err.Error(), "no coincide") {
+		t.Errorf("Bajar con una migración modificada devolvió %v", err)
+	}
+	if _, err := modificado.Estado(); err == nil || !strings.Contains(err.Error(), "no coincide") {
+		t.Errorf("Estado con una migración modificada devolvió %v", err)
+	}
+
+	// Un binario viejo que no conoce la última migración aplicada
+	viejo := &Migrador{bd: bd, dialecto: SQLite{}, migraciones: migrador.migraciones[:len(migrador.migraciones)-1]}
+	if _, err := viejo.Subir(); err == nil || !strings.Contains(err.Error(), "no existe en este binario") {
+		t.Errorf("Subir con una versión desconocida devolvió %v", err)
+	}
+
+	// El fallo no dejó el lock tomado
+	if _, err := migrador.Estado(); err != nil {
+		t.Errorf("el migrador original falló después de los errores: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package migraciones
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Nombre del lock de MySQL que toma el migrador
+const nombreLockMySQL = "taller6_migraciones"
+
+// Segundos que se espera el lock antes de rendirse
+const esperaLockMySQL = 60
+
+// MySQL es el dialecto de migraciones para MySQL/MariaDB
+type MySQL struct{}
+
+// Nombre devuelve la carpeta de sql/ con las migraciones de MySQL
+func (MySQL) Nombre() string { return "mysql" }
+
+// Marcador devuelve el placeholder de MySQL, que siempre es "?"
+func (MySQL) Marcador(int) string { return "?" }
+
+// TablaMigraciones devuelve el CREATE de schema_migrations
+func (MySQL) TablaMigraciones() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL PRIMARY KEY,
+    nombre VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    aplicada_en TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+}
+
+// Bloquear toma un lock con nombre (GET_LOCK) atado a la conexión
+func (MySQL) Bloquear(ctx context.Context, conn *sql.Conn) error {
+	var obtenido sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", nombreLockMySQL, esperaLockMySQL).Scan(&obtenido)
+	if err != nil {
+		return err
+	}
+	if !obtenido.Valid || obtenido.Int64 != 1 {
+		return fmt.Errorf("no se obtuvo el lock %q en %d segundos", nombreLockMySQL, esperaLockMySQL)
+	}
+	return nil
+}
+
+// Desbloquear libera el lock tomado por Bloquear
+func (MySQL) Desbloquear(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", nombreLockMySQL)
+	return err
+}
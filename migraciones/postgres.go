@@ -0,0 +1,58 @@
+package migraciones
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Clave del advisory lock de PostgreSQL que toma el migrador
+const claveLockPostgres = 727306
+
+// Cuánto se espera el advisory lock antes de rendirse
+const esperaLockPostgres = 60 * time.Second
+
+// Postgres es el dialecto de migraciones para PostgreSQL
+type Postgres struct{}
+
+// Nombre devuelve la carpeta de sql/ con las migraciones de PostgreSQL
+func (Postgres) Nombre() string { return "postgres" }
+
+// Marcador devuelve el placeholder numerado de PostgreSQL ($1, $2, ...)
+func (Postgres) Marcador(n int) string { return "$" + strconv.Itoa(n) }
+
+// TablaMigraciones devuelve el CREATE de schema_migrations
+func (Postgres) TablaMigraciones() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL PRIMARY KEY,
+    nombre VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    aplicada_en TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+)`
+}
+
+// Bloquear toma un advisory lock de sesión, reintentando hasta esperaLockPostgres
+func (Postgres) Bloquear(ctx context.Context, conn *sql.Conn) error {
+	limite := time.Now().Add(esperaLockPostgres)
+	for {
+		var obtenido bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", claveLockPostgres).Scan(&obtenido); err != nil {
+			return err
+		}
+		if obtenido {
+			return nil
+		}
+		if time.Now().After(limite) {
+			return fmt.Errorf("no se obtuvo el advisory lock %d en %s", claveLockPostgres, esperaLockPostgres)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Desbloquear libera el advisory lock tomado por Bloquear
+func (Postgres) Desbloquear(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", claveLockPostgres)
+	return err
+}
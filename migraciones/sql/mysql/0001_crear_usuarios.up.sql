@@ -0,0 +1,9 @@
+-- Tabla inicial de usuarios. Usa IF NOT EXISTS para adoptar las bases que
+-- ya tenían la tabla creada por el antiguo base_datos.CrearTabla.
+CREATE TABLE IF NOT EXISTS usuarios (
+    id SERIAL PRIMARY KEY,
+    nombre_usuario VARCHAR(50) UNIQUE NOT NULL,
+    correo VARCHAR(100) UNIQUE NOT NULL,
+    contrasena TEXT NOT NULL,
+    creado_en TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
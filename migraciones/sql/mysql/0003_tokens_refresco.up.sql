@@ -0,0 +1,13 @@
+-- Tokens de refresco: solo se guarda el sha256 del token opaco
+CREATE TABLE tokens_refresco (
+    id SERIAL PRIMARY KEY,
+    usuario_id BIGINT UNSIGNED NOT NULL,
+    familia CHAR(32) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL,
+    creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expira_en DATETIME NOT NULL,
+    usado_en DATETIME NULL,
+    revocado_en DATETIME NULL,
+    INDEX idx_tokens_refresco_familia (familia),
+    FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+);
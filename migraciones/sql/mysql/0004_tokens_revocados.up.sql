@@ -0,0 +1,18 @@
+-- Tokens de acceso revocados antes de expirar (logout), identificados por su jti
+CREATE TABLE tokens_revocados (
+    jti CHAR(32) PRIMARY KEY,
+    usuario_id BIGINT UNSIGNED NOT NULL,
+    expira_en DATETIME NOT NULL,
+    INDEX idx_tokens_revocados_expira_en (expira_en),
+    FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+);
+
+-- Revocación de todos los tokens de un usuario: vale todo token emitido hasta revocado_en
+CREATE TABLE revocaciones_usuario (
+    usuario_id BIGINT UNSIGNED PRIMARY KEY,
+    revocado_en DATETIME NOT NULL,
+    FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+);
+
+-- El rol admin puede revocar las sesiones de cualquier usuario
+UPDATE roles SET permisos = CONCAT(permisos, ' sesiones:revocar') WHERE nombre = 'admin';
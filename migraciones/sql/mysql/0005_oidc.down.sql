@@ -0,0 +1,3 @@
+UPDATE roles SET permisos = REPLACE(permisos, ' clientes:administrar', '') WHERE nombre = 'admin';
+DROP TABLE IF EXISTS codigos_autorizacion;
+DROP TABLE IF EXISTS clientes_oidc;
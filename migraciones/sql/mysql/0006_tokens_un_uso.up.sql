@@ -0,0 +1,13 @@
+-- Tokens de un solo uso que se envían por correo (por ejemplo, restablecer la contraseña).
+-- Solo se guarda el sha256 del token.
+CREATE TABLE tokens_un_uso (
+    id SERIAL PRIMARY KEY,
+    usuario_id BIGINT UNSIGNED NOT NULL,
+    proposito VARCHAR(32) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL,
+    creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expira_en DATETIME NOT NULL,
+    usado_en DATETIME NULL,
+    INDEX idx_tokens_un_uso_usuario (usuario_id, proposito),
+    FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS codigos_recuperacion;
+
+DROP TABLE IF EXISTS segundo_factor;
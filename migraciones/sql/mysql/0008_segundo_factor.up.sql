@@ -0,0 +1,21 @@
+-- Segundo factor TOTP (RFC 6238) de cada usuario. El secreto tiene que poder leerse
+-- para calcular los códigos, así que se guarda tal cual (en base32).
+-- ultimo_paso es el último paso de 30 segundos aceptado, para que un código no sirva dos veces.
+CREATE TABLE segundo_factor (
+    usuario_id BIGINT UNSIGNED PRIMARY KEY,
+    secreto VARCHAR(64) NOT NULL,
+    creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    confirmado_en DATETIME NULL,
+    ultimo_paso BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+);
+
+-- Códigos de recuperación de un solo uso; solo se guarda el sha256
+CREATE TABLE codigos_recuperacion (
+    id SERIAL PRIMARY KEY,
+    usuario_id BIGINT UNSIGNED NOT NULL,
+    hash CHAR(64) NOT NULL,
+    usado_en DATETIME NULL,
+    INDEX idx_codigos_recuperacion_usuario (usuario_id),
+    FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+);
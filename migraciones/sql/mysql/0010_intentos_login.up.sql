@@ -0,0 +1,8 @@
+-- Inicios de sesión fallidos por cuenta y por IP, para frenar los ataques de fuerza bruta.
+-- La clave es "cuenta:<sha256 del nombre>" o "ip:<dirección>".
+CREATE TABLE intentos_login (
+    clave VARCHAR(128) PRIMARY KEY,
+    fallos INT NOT NULL DEFAULT 0,
+    ultimo_fallo DATETIME NOT NULL,
+    INDEX idx_intentos_login_ultimo_fallo (ultimo_fallo)
+);
@@ -0,0 +1,9 @@
+-- Hashes de las contraseñas anteriores de cada usuario, para no dejar que vuelva a usarlas
+CREATE TABLE historial_contrasenas (
+    id SERIAL PRIMARY KEY,
+    usuario_id BIGINT UNSIGNED NOT NULL,
+    hash VARCHAR(255) NOT NULL,
+    creado_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_historial_contrasenas_usuario (usuario_id),
+    FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP INDEX idx_usuarios_creado_en ON usuarios;
@@ -0,0 +1 @@
+DROP INDEX idx_usuarios_busqueda ON usuarios;
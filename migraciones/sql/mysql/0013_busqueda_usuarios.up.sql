@@ -0,0 +1,4 @@
+-- Índice FULLTEXT para GET /usuarios/buscar. El parser ngram parte el texto en pedazos
+-- de dos caracteres (ngram_token_size), así se encuentran partes de palabras y correos.
+-- Solo MySQL: SQLite y PostgreSQL usan el índice de trigramas del proceso.
+ALTER TABLE usuarios ADD FULLTEXT INDEX idx_usuarios_busqueda (nombre_usuario, correo) WITH PARSER ngram;
@@ -0,0 +1,3 @@
+DROP INDEX idx_usuarios_eliminado_en ON usuarios;
+
+ALTER TABLE usuarios DROP COLUMN eliminado_en;
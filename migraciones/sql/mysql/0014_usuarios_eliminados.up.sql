@@ -0,0 +1,6 @@
+-- Baja lógica: DELETE /usuarios/:id marca la fecha y el usuario se puede restaurar
+-- hasta que la purga lo borra de verdad. NULL es un usuario activo.
+ALTER TABLE usuarios ADD COLUMN eliminado_en DATETIME NULL DEFAULT NULL;
+
+-- Para la purga, que busca los eliminados hace más tiempo que la retención
+CREATE INDEX idx_usuarios_eliminado_en ON usuarios (eliminado_en);
@@ -0,0 +1,3 @@
+UPDATE roles SET permisos = REPLACE(permisos, ' sesiones:revocar', '') WHERE nombre = 'admin';
+DROP TABLE IF EXISTS revocaciones_usuario;
+DROP TABLE IF EXISTS tokens_revocados;
@@ -0,0 +1,27 @@
+-- Clientes del proveedor OpenID Connect; las redirect URI van separadas por espacios
+CREATE TABLE clientes_oidc (
+    id SERIAL PRIMARY KEY,
+    cliente_id VARCHAR(64) UNIQUE NOT NULL,
+    nombre VARCHAR(100) NOT NULL,
+    secreto_hash VARCHAR(64) NOT NULL DEFAULT '',
+    redirect_uris TEXT NOT NULL,
+    creado_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Códigos de autorización: solo se guarda el sha256 del código
+CREATE TABLE codigos_autorizacion (
+    id SERIAL PRIMARY KEY,
+    hash CHAR(64) UNIQUE NOT NULL,
+    cliente_id VARCHAR(64) NOT NULL REFERENCES clientes_oidc (cliente_id) ON DELETE CASCADE,
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope VARCHAR(255) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    desafio_pkce VARCHAR(128) NOT NULL,
+    autenticado_en TIMESTAMP NOT NULL,
+    expira_en TIMESTAMP NOT NULL,
+    usado_en TIMESTAMP NULL
+);
+
+-- El rol admin puede registrar clientes
+UPDATE roles SET permisos = permisos || ' clientes:administrar' WHERE nombre = 'admin';
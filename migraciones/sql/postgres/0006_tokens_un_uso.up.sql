@@ -0,0 +1,13 @@
+-- Tokens de un solo uso que se envían por correo (por ejemplo, restablecer la contraseña).
+-- Solo se guarda el sha256 del token.
+CREATE TABLE tokens_un_uso (
+    id SERIAL PRIMARY KEY,
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    proposito VARCHAR(32) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL,
+    creado_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expira_en TIMESTAMP NOT NULL,
+    usado_en TIMESTAMP NULL
+);
+
+CREATE INDEX idx_tokens_un_uso_usuario ON tokens_un_uso (usuario_id, proposito);
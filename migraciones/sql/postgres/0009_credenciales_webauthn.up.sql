@@ -0,0 +1,17 @@
+-- Credenciales WebAuthn (llaves de acceso y llaves de seguridad). El ID de la credencial
+-- y la clave pública (COSE) se guardan en base64url; los transportes van separados por espacios.
+-- contador es el último contador de firmas visto, para detectar autenticadores clonados.
+CREATE TABLE credenciales_webauthn (
+    id SERIAL PRIMARY KEY,
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    credencial_id VARCHAR(512) UNIQUE NOT NULL,
+    clave_publica TEXT NOT NULL,
+    tipo_atestacion VARCHAR(32) NOT NULL DEFAULT '',
+    transportes VARCHAR(255) NOT NULL DEFAULT '',
+    contador BIGINT NOT NULL DEFAULT 0,
+    nombre VARCHAR(100) NOT NULL DEFAULT '',
+    creado_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    usado_en TIMESTAMP NULL
+);
+
+CREATE INDEX idx_credenciales_webauthn_usuario ON credenciales_webauthn (usuario_id);
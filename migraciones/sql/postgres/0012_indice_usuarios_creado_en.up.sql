@@ -0,0 +1,2 @@
+-- Índice para ordenar y filtrar el listado de usuarios por fecha de creación
+CREATE INDEX idx_usuarios_creado_en ON usuarios (creado_en);
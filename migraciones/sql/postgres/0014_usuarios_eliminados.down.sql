@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_usuarios_eliminado_en;
+
+ALTER TABLE usuarios DROP COLUMN eliminado_en;
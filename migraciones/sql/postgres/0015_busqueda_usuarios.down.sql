@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_usuarios_busqueda;
+
+DROP FUNCTION IF EXISTS normalizar_busqueda(TEXT);
+
+-- Las extensiones quedan: otras tablas o bases pueden estar usándolas
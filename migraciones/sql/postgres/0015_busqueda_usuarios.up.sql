@@ -0,0 +1,14 @@
+-- Búsqueda de GET /usuarios/buscar en la base, así todas las instancias ven los mismos
+-- datos. pg_trgm compara por trigramas (encuentra partes de palabras y errores de tipeo)
+-- y unaccent saca las tildes. Las dos son extensiones "trusted" desde PostgreSQL 13: las
+-- puede crear el dueño de la base sin ser superusuario.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- Minúsculas y sin tildes, como busqueda.Normalizar. Se marca IMMUTABLE para poder
+-- indexarla; el search_path fijo es el de la migración, donde quedó unaccent.
+CREATE FUNCTION normalizar_busqueda(texto TEXT) RETURNS TEXT AS $$
+	SELECT lower(unaccent('unaccent', texto))
+$$ LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE SET search_path FROM CURRENT;
+
+CREATE INDEX idx_usuarios_busqueda ON usuarios USING GIN (normalizar_busqueda(nombre_usuario || ' ' || correo) gin_trgm_ops);
@@ -0,0 +1,46 @@
+-- Vuelven a TIMESTAMP con la hora en la zona de la sesión
+ALTER TABLE usuarios
+    ALTER COLUMN creado_en TYPE TIMESTAMP,
+    ALTER COLUMN eliminado_en TYPE TIMESTAMP;
+
+ALTER TABLE tokens_refresco
+    ALTER COLUMN creado_en TYPE TIMESTAMP,
+    ALTER COLUMN expira_en TYPE TIMESTAMP,
+    ALTER COLUMN usado_en TYPE TIMESTAMP,
+    ALTER COLUMN revocado_en TYPE TIMESTAMP;
+
+ALTER TABLE tokens_revocados
+    ALTER COLUMN expira_en TYPE TIMESTAMP;
+
+ALTER TABLE revocaciones_usuario
+    ALTER COLUMN revocado_en TYPE TIMESTAMP;
+
+ALTER TABLE clientes_oidc
+    ALTER COLUMN creado_en TYPE TIMESTAMP;
+
+ALTER TABLE codigos_autorizacion
+    ALTER COLUMN autenticado_en TYPE TIMESTAMP,
+    ALTER COLUMN expira_en TYPE TIMESTAMP,
+    ALTER COLUMN usado_en TYPE TIMESTAMP;
+
+ALTER TABLE tokens_un_uso
+    ALTER COLUMN creado_en TYPE TIMESTAMP,
+    ALTER COLUMN expira_en TYPE TIMESTAMP,
+    ALTER COLUMN usado_en TYPE TIMESTAMP;
+
+ALTER TABLE segundo_factor
+    ALTER COLUMN creado_en TYPE TIMESTAMP,
+    ALTER COLUMN confirmado_en TYPE TIMESTAMP;
+
+ALTER TABLE codigos_recuperacion
+    ALTER COLUMN usado_en TYPE TIMESTAMP;
+
+ALTER TABLE credenciales_webauthn
+    ALTER COLUMN creado_en TYPE TIMESTAMP,
+    ALTER COLUMN usado_en TYPE TIMESTAMP;
+
+ALTER TABLE intentos_login
+    ALTER COLUMN ultimo_fallo TYPE TIMESTAMP;
+
+ALTER TABLE historial_contrasenas
+    ALTER COLUMN creado_en TYPE TIMESTAMP;
@@ -0,0 +1,50 @@
+-- Las fechas pasan a TIMESTAMPTZ. Con TIMESTAMP pgx descarta la zona horaria del
+-- time.Time y guarda la hora como la marca el reloj de esa zona, así que un proceso con
+-- otra zona que la de UTC comparaba vencimientos corridos por esas horas. Las filas que ya
+-- están se interpretan en la zona de la sesión, que es la del servidor, como lo hacía
+-- CURRENT_TIMESTAMP al guardarlas.
+ALTER TABLE usuarios
+    ALTER COLUMN creado_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN eliminado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE tokens_refresco
+    ALTER COLUMN creado_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN expira_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN usado_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN revocado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE tokens_revocados
+    ALTER COLUMN expira_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE revocaciones_usuario
+    ALTER COLUMN revocado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE clientes_oidc
+    ALTER COLUMN creado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE codigos_autorizacion
+    ALTER COLUMN autenticado_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN expira_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN usado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE tokens_un_uso
+    ALTER COLUMN creado_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN expira_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN usado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE segundo_factor
+    ALTER COLUMN creado_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN confirmado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE codigos_recuperacion
+    ALTER COLUMN usado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE credenciales_webauthn
+    ALTER COLUMN creado_en TYPE TIMESTAMPTZ,
+    ALTER COLUMN usado_en TYPE TIMESTAMPTZ;
+
+ALTER TABLE intentos_login
+    ALTER COLUMN ultimo_fallo TYPE TIMESTAMPTZ;
+
+ALTER TABLE historial_contrasenas
+    ALTER COLUMN creado_en TYPE TIMESTAMPTZ;
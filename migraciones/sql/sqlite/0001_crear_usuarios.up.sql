@@ -0,0 +1,9 @@
+-- Tabla inicial de usuarios, traducida de la versión de MySQL:
+-- SERIAL pasa a INTEGER PRIMARY KEY AUTOINCREMENT.
+CREATE TABLE IF NOT EXISTS usuarios (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    nombre_usuario VARCHAR(50) UNIQUE NOT NULL,
+    correo VARCHAR(100) UNIQUE NOT NULL,
+    contrasena TEXT NOT NULL,
+    creado_en TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,21 @@
+-- Roles con sus permisos (separados por espacios) y su asignación a usuarios
+CREATE TABLE roles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    nombre VARCHAR(50) UNIQUE NOT NULL,
+    permisos TEXT NOT NULL
+);
+
+CREATE TABLE usuario_roles (
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    rol_id INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (usuario_id, rol_id)
+);
+
+INSERT INTO roles (nombre, permisos) VALUES
+    ('admin', 'usuarios:leer usuarios:escribir usuarios:eliminar roles:administrar'),
+    ('soporte', 'usuarios:leer');
+
+-- El usuario "admin" que ya existía (antes identificado por tener el ID 1) recibe el rol admin
+INSERT INTO usuario_roles (usuario_id, rol_id)
+    SELECT u.id, r.id FROM usuarios u, roles r
+    WHERE u.nombre_usuario = 'admin' AND r.nombre = 'admin';
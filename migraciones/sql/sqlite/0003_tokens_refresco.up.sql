@@ -0,0 +1,13 @@
+-- Tokens de refresco: solo se guarda el sha256 del token opaco
+CREATE TABLE tokens_refresco (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    familia CHAR(32) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL,
+    creado_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expira_en TIMESTAMP NOT NULL,
+    usado_en TIMESTAMP NULL,
+    revocado_en TIMESTAMP NULL
+);
+
+CREATE INDEX idx_tokens_refresco_familia ON tokens_refresco (familia);
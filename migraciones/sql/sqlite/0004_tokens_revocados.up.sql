@@ -0,0 +1,17 @@
+-- Tokens de acceso revocados antes de expirar (logout), identificados por su jti
+CREATE TABLE tokens_revocados (
+    jti CHAR(32) PRIMARY KEY,
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    expira_en TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_tokens_revocados_expira_en ON tokens_revocados (expira_en);
+
+-- Revocación de todos los tokens de un usuario: vale todo token emitido hasta revocado_en
+CREATE TABLE revocaciones_usuario (
+    usuario_id INTEGER PRIMARY KEY REFERENCES usuarios (id) ON DELETE CASCADE,
+    revocado_en TIMESTAMP NOT NULL
+);
+
+-- El rol admin puede revocar las sesiones de cualquier usuario
+UPDATE roles SET permisos = permisos || ' sesiones:revocar' WHERE nombre = 'admin';
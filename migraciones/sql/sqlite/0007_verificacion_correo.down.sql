@@ -0,0 +1,3 @@
+ALTER TABLE tokens_un_uso DROP COLUMN dato;
+
+ALTER TABLE usuarios DROP COLUMN correo_verificado;
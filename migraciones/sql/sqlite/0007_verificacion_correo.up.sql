@@ -0,0 +1,8 @@
+-- Marca si el usuario confirmó su correo con el enlace que se le envió.
+-- Los usuarios que ya existían se dan por verificados para no bloquearlos.
+ALTER TABLE usuarios ADD COLUMN correo_verificado BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE usuarios SET correo_verificado = TRUE;
+
+-- Dato asociado al token; en los de verificación, el correo que se está verificando
+ALTER TABLE tokens_un_uso ADD COLUMN dato VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+-- Segundo factor TOTP (RFC 6238) de cada usuario. El secreto tiene que poder leerse
+-- para calcular los códigos, así que se guarda tal cual (en base32).
+-- ultimo_paso es el último paso de 30 segundos aceptado, para que un código no sirva dos veces.
+CREATE TABLE segundo_factor (
+    usuario_id INTEGER PRIMARY KEY REFERENCES usuarios (id) ON DELETE CASCADE,
+    secreto VARCHAR(64) NOT NULL,
+    creado_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    confirmado_en TIMESTAMP NULL,
+    ultimo_paso BIGINT NOT NULL DEFAULT 0
+);
+
+-- Códigos de recuperación de un solo uso; solo se guarda el sha256
+CREATE TABLE codigos_recuperacion (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    hash CHAR(64) NOT NULL,
+    usado_en TIMESTAMP NULL
+);
+
+CREATE INDEX idx_codigos_recuperacion_usuario ON codigos_recuperacion (usuario_id);
@@ -0,0 +1,9 @@
+-- Hashes de las contraseñas anteriores de cada usuario, para no dejar que vuelva a usarlas
+CREATE TABLE historial_contrasenas (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    usuario_id INTEGER NOT NULL REFERENCES usuarios (id) ON DELETE CASCADE,
+    hash VARCHAR(255) NOT NULL,
+    creado_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_historial_contrasenas_usuario ON historial_contrasenas (usuario_id);
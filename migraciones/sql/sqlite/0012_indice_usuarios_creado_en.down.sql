@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_usuarios_creado_en;
@@ -0,0 +1,3 @@
+-- Índice para ordenar y filtrar el listado de usuarios por fecha de creación. Es sobre
+-- julianday() porque así compara las fechas el repositorio (se guardan como texto)
+CREATE INDEX idx_usuarios_creado_en ON usuarios (julianday(creado_en));
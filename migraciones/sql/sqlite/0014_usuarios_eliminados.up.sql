@@ -0,0 +1,7 @@
+-- Baja lógica: DELETE /usuarios/:id marca la fecha y el usuario se puede restaurar
+-- hasta que la purga lo borra de verdad. NULL es un usuario activo.
+ALTER TABLE usuarios ADD COLUMN eliminado_en TIMESTAMP NULL;
+
+-- Para la purga, que busca los eliminados hace más tiempo que la retención. Es sobre
+-- julianday() porque así compara las fechas el repositorio (se guardan como texto)
+CREATE INDEX idx_usuarios_eliminado_en ON usuarios (julianday(eliminado_en));
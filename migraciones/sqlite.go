@@ -0,0 +1,73 @@
+package migraciones
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Cuánto se espera el lock de SQLite y cuándo se considera abandonado
+const (
+	esperaLockSQLite     = 60 * time.Second
+	lockSQLiteAbandonado = 10 * time.Minute
+)
+
+// SQLite es el dialecto de migraciones para SQLite. Como SQLite no tiene locks
+// con nombre, el lock es una fila en la tabla schema_migrations_lock.
+type SQLite struct{}
+
+// Nombre devuelve la carpeta de sql/ con las migraciones de SQLite
+func (SQLite) Nombre() string { return "sqlite" }
+
+// Marcador devuelve el placeholder de SQLite, que siempre es "?"
+func (SQLite) Marcador(int) string { return "?" }
+
+// TablaMigraciones devuelve el CREATE de schema_migrations
+func (SQLite) TablaMigraciones() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER NOT NULL PRIMARY KEY,
+    nombre TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    aplicada_en TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+}
+
+// Bloquear inserta la única fila de schema_migrations_lock; si ya existe, otra
+// instancia está migrando y se reintenta hasta esperaLockSQLite. Un lock más viejo
+// que lockSQLiteAbandonado se asume de un proceso que murió y se descarta.
+func (SQLite) Bloquear(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+    id INTEGER NOT NULL PRIMARY KEY CHECK (id = 1),
+    tomado_en INTEGER NOT NULL
+)`)
+	if err != nil {
+		return err
+	}
+
+	limite := time.Now().Add(esperaLockSQLite)
+	for {
+		ahora := time.Now()
+		_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE tomado_en < ?", ahora.Add(-lockSQLiteAbandonado).Unix())
+		if err != nil {
+			return err
+		}
+		resultado, err := conn.ExecContext(ctx, "INSERT OR IGNORE INTO schema_migrations_lock (id, tomado_en) VALUES (1, ?)", ahora.Unix())
+		if err != nil {
+			return err
+		}
+		if filas, _ := resultado.RowsAffected(); filas == 1 {
+			return nil
+		}
+		if ahora.After(limite) {
+			return fmt.Errorf("no se obtuvo el lock de migraciones en %s", esperaLockSQLite)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Desbloquear borra la fila de lock
+func (SQLite) Desbloquear(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1")
+	return err
+}
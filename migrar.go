@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"taller6/base_datos"
+	"taller6/migraciones"
+)
+
+// dialectoMigraciones devuelve el dialecto de migraciones del motor conectado
+func dialectoMigraciones() migraciones.Dialecto {
+	switch base_datos.Motor {
+	case base_datos.MotorSQLite:
+		return migraciones.SQLite{}
+	case base_datos.MotorPostgres:
+		return migraciones.Postgres{}
+	default:
+		return migraciones.MySQL{}
+	}
+}
+
+// ejecutarComandoMigrar atiende "taller6 migrar <subir|bajar [n]|estado>"
+func ejecutarComandoMigrar(bd *sql.DB, args []string) {
+	migrador, err := migraciones.Nuevo(bd, dialectoMigraciones())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	accion := "subir"
+	if len(args) > 0 {
+		accion = args[0]
+	}
+
+	switch accion {
+	case "subir":
+		aplicadas, err := migrador.Subir()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Migraciones aplicadas: %d\n", aplicadas)
+	case "bajar":
+		pasos := 1
+		if len(args) > 1 {
+			pasos, err = strconv.Atoi(args[1])
+			if err != nil || pasos < 1 {
+				log.Fatalf("Cantidad de pasos inválida: %q", args[1])
+			}
+		}
+		revertidas, err := migrador.Bajar(pasos)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Migraciones revertidas: %d\n", revertidas)
+	case "estado":
+		estados, err := migrador.Estado()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, estado := range estados {
+			if estado.Aplicada {
+				fmt.Printf("%04d_%s\taplicada %s\n", estado.Version, estado.Nombre, estado.AplicadaEn.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%s\tpendiente\n", estado.Version, estado.Nombre)
+			}
+		}
+	default:
+		log.Fatalf("Uso: taller6 migrar <subir|bajar [n]|estado>")
+	}
+}
+
+// migrarAlIniciar aplica las migraciones pendientes antes de levantar el servidor
+func migrarAlIniciar(bd *sql.DB) {
+	migrador, err := migraciones.Nuevo(bd, dialectoMigraciones())
+	if err != nil {
+		log.Fatal(err)
+	}
+	aplicadas, err := migrador.Subir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if aplicadas > 0 {
+		log.Printf("Migraciones aplicadas: %d", aplicadas)
+	}
+}
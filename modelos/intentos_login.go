@@ -0,0 +1,11 @@
+package modelos
+
+import "time"
+
+// IntentosLogin cuenta los inicios de sesión fallidos de una clave (una cuenta o una IP).
+// Los fallos se olvidan cuando pasa el tiempo de bloqueo sin que haya otro.
+type IntentosLogin struct {
+	Clave       string
+	Fallos      int
+	UltimoFallo time.Time
+}
@@ -0,0 +1,45 @@
+package modelos
+
+import "time"
+
+// ClienteOIDC es una aplicación registrada que puede usar el inicio de sesión único
+type ClienteOIDC struct {
+	ID           uint      `json:"id"`
+	ClienteID    string    `json:"client_id"`
+	Nombre       string    `json:"nombre"`
+	SecretoHash  string    `json:"-"` // sha256 del secreto; vacío para clientes públicos (SPA, móviles)
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreadoEn     time.Time `json:"creado_en"`
+}
+
+// Confidencial indica si el cliente tiene secreto y debe presentarlo en /token
+func (c ClienteOIDC) Confidencial() bool {
+	return c.SecretoHash != ""
+}
+
+// AceptaRedireccion indica si la URI está registrada. La comparación es exacta,
+// como pide OAuth 2.0 para no redirigir códigos a destinos parecidos.
+func (c ClienteOIDC) AceptaRedireccion(uri string) bool {
+	for _, registrada := range c.RedirectURIs {
+		if registrada == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// CodigoAutorizacion es el código de un solo uso que /authorize entrega al cliente
+// y que este cambia por tokens en /token. Igual que los tokens de refresco, solo se guarda su hash.
+type CodigoAutorizacion struct {
+	ID            uint
+	Hash          string
+	ClienteID     string
+	UsuarioID     uint
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	DesafioPKCE   string // code_challenge con método S256
+	AutenticadoEn time.Time
+	ExpiraEn      time.Time
+	UsadoEn       *time.Time
+}
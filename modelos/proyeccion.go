@@ -0,0 +1,56 @@
+package modelos
+
+import (
+	"reflect"
+	"strings"
+)
+
+// CamposJSON devuelve los nombres JSON de los campos de un struct (o de un puntero a
+// struct), en el orden en que están declarados. Son los campos que se pueden pedir con
+// fields= en las respuestas que usan ese struct.
+func CamposJSON(valor interface{}) []string {
+	tipo := reflect.TypeOf(valor)
+	if tipo.Kind() == reflect.Pointer {
+		tipo = tipo.Elem()
+	}
+	var campos []string
+	for i := 0; i < tipo.NumField(); i++ {
+		if nombre, ok := nombreJSON(tipo.Field(i)); ok {
+			campos = append(campos, nombre)
+		}
+	}
+	return campos
+}
+
+// Proyectar devuelve solo los campos pedidos del struct, con sus nombres JSON. Los
+// nombres que el struct no tiene se ignoran; hay que validarlos antes con CamposJSON.
+func Proyectar(valor interface{}, campos []string) map[string]interface{} {
+	pedidos := make(map[string]bool, len(campos))
+	for _, campo := range campos {
+		pedidos[campo] = true
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(valor))
+	resultado := make(map[string]interface{}, len(campos))
+	for i := 0; i < v.NumField(); i++ {
+		if nombre, ok := nombreJSON(v.Type().Field(i)); ok && pedidos[nombre] {
+			resultado[nombre] = v.Field(i).Interface()
+		}
+	}
+	return resultado
+}
+
+// nombreJSON lee el nombre del campo en el tag json; false si no se serializa
+func nombreJSON(campo reflect.StructField) (string, bool) {
+	if !campo.IsExported() {
+		return "", false
+	}
+	nombre, _, _ := strings.Cut(campo.Tag.Get("json"), ",")
+	switch nombre {
+	case "-":
+		return "", false
+	case "":
+		return campo.Name, true
+	}
+	return nombre, true
+}
@@ -0,0 +1,52 @@
+package modelos
+
+import "sort"
+
+// Permisos que se pueden asignar a un rol
+const (
+	PermisoUsuariosLeer        = "usuarios:leer"
+	PermisoUsuariosEscribir    = "usuarios:escribir"
+	PermisoUsuariosEliminar    = "usuarios:eliminar"
+	PermisoRolesAdministrar    = "roles:administrar"
+	PermisoSesionesRevocar     = "sesiones:revocar"
+	PermisoClientesAdministrar = "clientes:administrar"
+)
+
+// Roles que crea la migración de roles
+const (
+	RolAdmin   = "admin"   // Todos los permisos
+	RolSoporte = "soporte" // Solo lectura de usuarios
+)
+
+// Rol agrupa un conjunto de permisos con un nombre
+type Rol struct {
+	ID       uint     `json:"id"`
+	Nombre   string   `json:"nombre"`
+	Permisos []string `json:"permisos"`
+}
+
+// RolesPorDefecto son los roles iniciales, los mismos que dejan las migraciones
+// 0002_roles, 0004_tokens_revocados y 0005_oidc
+func RolesPorDefecto() []Rol {
+	return []Rol{
+		{ID: 1, Nombre: RolAdmin, Permisos: []string{PermisoUsuariosLeer, PermisoUsuariosEscribir, PermisoUsuariosEliminar, PermisoRolesAdministrar, PermisoSesionesRevocar, PermisoClientesAdministrar}},
+		{ID: 2, Nombre: RolSoporte, Permisos: []string{PermisoUsuariosLeer}},
+	}
+}
+
+// NombresYPermisos devuelve los nombres de los roles y la unión de sus permisos, ordenados
+func NombresYPermisos(roles []Rol) (nombres []string, permisos []string) {
+	vistos := make(map[string]bool)
+	for _, rol := range roles {
+		nombres = append(nombres, rol.Nombre)
+		for _, permiso := range rol.Permisos {
+			if !vistos[permiso] {
+				vistos[permiso] = true
+				permisos = append(permisos, permiso)
+			}
+		}
+	}
+	sort.Strings(nombres)
+	sort.Strings(permisos)
+	return nombres, permisos
+}
@@ -0,0 +1,18 @@
+package modelos
+
+import "time"
+
+// SegundoFactor es el TOTP (RFC 6238) de un usuario. Mientras ConfirmadoEn sea nil
+// la inscripción está pendiente y el login no lo pide.
+type SegundoFactor struct {
+	UsuarioID    uint
+	Secreto      string // En base32, como se muestra en la URI otpauth://
+	CreadoEn     time.Time
+	ConfirmadoEn *time.Time
+	UltimoPaso   int64 // Último paso de 30 segundos aceptado; un código no se acepta dos veces
+}
+
+// Activo indica si el usuario confirmó el segundo factor y el login debe pedirlo
+func (f SegundoFactor) Activo() bool {
+	return f.ConfirmadoEn != nil
+}
@@ -0,0 +1,40 @@
+package modelos
+
+import "time"
+
+// TokenRefresco es un token opaco que permite pedir nuevos tokens de acceso.
+// En la base solo se guarda el hash; los tokens de una misma "familia" salen
+// de rotar sucesivamente el token entregado en el login.
+type TokenRefresco struct {
+	ID         uint
+	UsuarioID  uint
+	Familia    string
+	Hash       string
+	CreadoEn   time.Time
+	ExpiraEn   time.Time
+	UsadoEn    *time.Time // Momento en que se rotó; un segundo uso indica robo
+	RevocadoEn *time.Time
+}
+
+// Propósitos de los tokens de un solo uso
+const (
+	PropositoRestablecer = "restablecer" // Restablecer la contraseña olvidada
+	PropositoVerificar   = "verificar"   // Confirmar el correo (Dato es el correo a confirmar)
+	// Sesiones de las ceremonias de WebAuthn, que no se envían por correo sino en la
+	// respuesta (Dato es el desafío)
+	PropositoRegistroWebAuthn = "webauthn_registro"
+	PropositoLoginWebAuthn    = "webauthn_login"
+)
+
+// TokenUnUso es un token que se envía por correo (o en la respuesta) y se consume una sola vez.
+// Igual que los de refresco, en la base solo se guarda el hash.
+type TokenUnUso struct {
+	ID        uint
+	UsuarioID uint
+	Proposito string
+	Hash      string
+	CreadoEn  time.Time
+	ExpiraEn  time.Time
+	UsadoEn   *time.Time
+	Dato      string // Depende del propósito; vacío si no hace falta
+}
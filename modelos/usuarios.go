@@ -9,6 +9,11 @@ type Usuario struct {
 	Correo        string    `json:"correo"`
 	Contrasena    string    `json:"contrasena"` // No mostramos la contraseña
 	CreadoEn      time.Time `json:"creado_en"`
+	// CorreoVerificado se pone en true cuando el usuario abre el enlace de verificación
+	// y vuelve a false cada vez que cambia el correo
+	CorreoVerificado bool `json:"correo_verificado"`
+	// EliminadoEn es la fecha de la baja; nil en los usuarios activos
+	EliminadoEn *time.Time `json:"eliminado_en,omitempty"`
 }
 
 type UsuarioConToken struct {
@@ -17,7 +22,10 @@ type UsuarioConToken struct {
 	Correo        string    `json:"correo"`
 	Contrasena    string    `json:"contrasena"` // No mostramos la contraseña
 	CreadoEn      time.Time `json:"creado_en"`
-	Token         string    `json:"token"`
+	Token         string    `json:"token,omitempty"` // Vacío si hay que verificar el correo antes de iniciar sesión
+	TokenRefresco string    `json:"token_refresco,omitempty"`
+
+	CorreoVerificado bool `json:"correo_verificado"`
 }
 
 type UsuarioSinContrasena struct {
@@ -25,13 +33,35 @@ type UsuarioSinContrasena struct {
 	NombreUsuario string    `json:"nombre_usuario"`
 	Correo        string    `json:"correo"`
 	CreadoEn      time.Time `json:"creado_en"`
+
+	CorreoVerificado bool       `json:"correo_verificado"`
+	EliminadoEn      *time.Time `json:"eliminado_en,omitempty"` // Solo en el listado de eliminados
+}
+
+// SinContrasena devuelve una copia del usuario apta para enviar al cliente
+func (u Usuario) SinContrasena() UsuarioSinContrasena {
+	return UsuarioSinContrasena{
+		ID:            u.ID,
+		NombreUsuario: u.NombreUsuario,
+		Correo:        u.Correo,
+		CreadoEn:      u.CreadoEn,
+
+		CorreoVerificado: u.CorreoVerificado,
+		EliminadoEn:      u.EliminadoEn,
+	}
+}
+
+// UsuarioPublico es lo que ve de otro usuario quien no tiene permiso de leer usuarios:
+// sin el correo ni su estado, que sirven para contactarlo o para saber si la cuenta se usa
+type UsuarioPublico struct {
+	ID            uint      `json:"id"`
+	NombreUsuario string    `json:"nombre_usuario"`
+	CreadoEn      time.Time `json:"creado_en"`
+}
+
+// Publico devuelve la proyección pública del usuario
+func (u Usuario) Publico() UsuarioPublico {
+	return UsuarioPublico{ID: u.ID, NombreUsuario: u.NombreUsuario, CreadoEn: u.CreadoEn}
 }
 
-// Esquema para crear la base de datos usuarios si es que no existe ya
-const UsuariosSchema string = `CREATE TABLE usuarios (
-    id SERIAL PRIMARY KEY,
-    nombre_usuario VARCHAR(50) UNIQUE NOT NULL,
-    correo VARCHAR(100) UNIQUE NOT NULL,
-    contrasena TEXT NOT NULL,
-    creado_en TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-)`
+// El esquema de la tabla usuarios vive en las migraciones (carpeta migraciones/sql)
@@ -0,0 +1,18 @@
+package modelos
+
+import "time"
+
+// CredencialWebAuthn es una llave de acceso (passkey) o llave de seguridad que el
+// usuario registró para iniciar sesión sin contraseña
+type CredencialWebAuthn struct {
+	ID             uint       `json:"id"`
+	UsuarioID      uint       `json:"-"`
+	CredencialID   []byte     `json:"-"` // ID que eligió el autenticador
+	ClavePublica   []byte     `json:"-"` // En formato COSE, como la envía el autenticador
+	TipoAtestacion string     `json:"-"`
+	Transportes    []string   `json:"transportes"` // usb, nfc, ble, internal, hybrid
+	Contador       uint32     `json:"-"`           // Último contador de firmas visto
+	Nombre         string     `json:"nombre"`      // Para que el usuario las distinga, por ejemplo "Teléfono"
+	CreadoEn       time.Time  `json:"creado_en"`
+	UsadoEn        *time.Time `json:"usado_en"`
+}
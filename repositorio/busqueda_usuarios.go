@@ -0,0 +1,10 @@
+package repositorio
+
+import "taller6/modelos"
+
+// UsuarioEncontrado es un resultado de BuscarTexto. El puntaje sirve para ordenar los
+// resultados de una misma búsqueda; su escala depende del motor.
+type UsuarioEncontrado struct {
+	Usuario modelos.Usuario
+	Puntaje float64
+}
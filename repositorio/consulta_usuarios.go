@@ -0,0 +1,104 @@
+package repositorio
+
+import (
+	"strings"
+	"taller6/modelos"
+	"time"
+)
+
+// Campos por los que se puede ordenar el listado de usuarios; son también los
+// nombres de las columnas, así que no puede entrar ningún otro valor en la consulta
+const (
+	OrdenID            = "id"
+	OrdenNombreUsuario = "nombre_usuario"
+	OrdenCorreo        = "correo"
+	OrdenCreadoEn      = "creado_en"
+)
+
+// OrdenValido indica si el campo es uno de los que se pueden usar para ordenar
+func OrdenValido(campo string) bool {
+	switch campo {
+	case OrdenID, OrdenNombreUsuario, OrdenCorreo, OrdenCreadoEn:
+		return true
+	}
+	return false
+}
+
+// FiltroUsuarios son las condiciones de un listado; los campos vacíos no filtran
+type FiltroUsuarios struct {
+	PrefijoNombre string    // Sin importar mayúsculas
+	PrefijoCorreo string    // Sin importar mayúsculas
+	CreadoDesde   time.Time // Inclusive
+	CreadoHasta   time.Time // Exclusive
+	Eliminados    bool      // true lista solo los eliminados (para restaurarlos); false, solo los activos
+}
+
+// ConsultaUsuarios pide una página del listado. La paginación es por cursor (keyset):
+// Despues es el último usuario de la página anterior y la página sigue a partir de su
+// valor en el campo de orden y, para desempatar, de su ID. A diferencia de un OFFSET,
+// no se saltea ni se repite nadie si se crean o borran usuarios entre una página y otra.
+type ConsultaUsuarios struct {
+	Filtro      FiltroUsuarios
+	Orden       string // Uno de los Orden*; vacío es OrdenID
+	Descendente bool
+	Limite      int
+	Despues     *modelos.Usuario // nil para la primera página
+}
+
+// campoOrden devuelve el campo de orden, con OrdenID si no se indicó
+func (c ConsultaUsuarios) campoOrden() string {
+	if c.Orden == "" {
+		return OrdenID
+	}
+	return c.Orden
+}
+
+// cumpleFiltro indica si el usuario entra en el filtro; es lo mismo que arma el WHERE de SQL
+func (f FiltroUsuarios) cumpleFiltro(usuario modelos.Usuario) bool {
+	if (usuario.EliminadoEn != nil) != f.Eliminados {
+		return false
+	}
+	if f.PrefijoNombre != "" && !strings.HasPrefix(strings.ToLower(usuario.NombreUsuario), strings.ToLower(f.PrefijoNombre)) {
+		return false
+	}
+	if f.PrefijoCorreo != "" && !strings.HasPrefix(strings.ToLower(usuario.Correo), strings.ToLower(f.PrefijoCorreo)) {
+		return false
+	}
+	if !f.CreadoDesde.IsZero() && usuario.CreadoEn.Before(f.CreadoDesde) {
+		return false
+	}
+	if !f.CreadoHasta.IsZero() && !usuario.CreadoEn.Before(f.CreadoHasta) {
+		return false
+	}
+	return true
+}
+
+// compararPorCampo compara dos usuarios por el campo de orden y después por ID
+// (negativo si a va antes, en orden ascendente)
+func compararPorCampo(campo string, a, b modelos.Usuario) int {
+	var resultado int
+	switch campo {
+	case OrdenNombreUsuario:
+		resultado = strings.Compare(a.NombreUsuario, b.NombreUsuario)
+	case OrdenCorreo:
+		resultado = strings.Compare(a.Correo, b.Correo)
+	case OrdenCreadoEn:
+		resultado = a.CreadoEn.Compare(b.CreadoEn)
+	}
+	if resultado != 0 {
+		return resultado
+	}
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
+
+// escaparLike escapa los comodines de LIKE con "!", que no tiene significado especial
+// en los literales de ningún motor (la barra invertida sí, en MySQL)
+func escaparLike(texto string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(texto)
+}
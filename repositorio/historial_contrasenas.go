@@ -0,0 +1,13 @@
+package repositorio
+
+import "time"
+
+// HistorialContrasenaRepositorio guarda los hashes de las contraseñas que tuvo cada
+// usuario, para no dejarlo volver a una de las últimas al cambiarla
+type HistorialContrasenaRepositorio interface {
+	// ContrasenasAnteriores devuelve hasta cantidad hashes, del más nuevo al más viejo
+	ContrasenasAnteriores(usuarioID uint, cantidad int) ([]string, error)
+	// AgregarContrasenaAnterior guarda el hash de una contraseña que se dejó de usar y
+	// borra las más viejas, de forma que queden como mucho conservar
+	AgregarContrasenaAnterior(usuarioID uint, hash string, momento time.Time, conservar int) error
+}
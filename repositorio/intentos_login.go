@@ -0,0 +1,21 @@
+package repositorio
+
+import (
+	"taller6/modelos"
+	"time"
+)
+
+// IntentoLoginRepositorio guarda los inicios de sesión fallidos por cuenta y por IP.
+// Se guardan en el repositorio y no en memoria para que todas las instancias vean
+// los mismos contadores y el desbloqueo de un administrador valga en todas.
+type IntentoLoginRepositorio interface {
+	// BuscarIntentosLogin devuelve los fallos de la clave; si no hay, Fallos es 0
+	BuscarIntentosLogin(clave string) (*modelos.IntentosLogin, error)
+	// RegistrarFalloLogin suma un fallo a la clave y devuelve cómo quedó. Si el último
+	// fallo es anterior a olvidarAntesDe, la cuenta vuelve a empezar desde 1.
+	RegistrarFalloLogin(clave string, momento, olvidarAntesDe time.Time) (*modelos.IntentosLogin, error)
+	// ReiniciarIntentosLogin borra los fallos de la clave
+	ReiniciarIntentosLogin(clave string) error
+	// PurgarIntentosLogin borra las claves cuyo último fallo es anterior a antesDe
+	PurgarIntentosLogin(antesDe time.Time) error
+}
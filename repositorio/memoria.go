@@ -0,0 +1,361 @@
+package repositorio
+
+import (
+	"sort"
+	"sync"
+	"taller6/busqueda"
+	"taller6/modelos"
+	"time"
+)
+
+// UsuarioRepositorioMemoria guarda los usuarios en memoria. Sirve para pruebas
+// y para levantar el servidor sin base de datos; los datos se pierden al cerrar.
+type UsuarioRepositorioMemoria struct {
+	mu               sync.RWMutex
+	usuarios         map[uint]modelos.Usuario
+	siguiente        uint
+	roles            []modelos.Rol                     // Roles existentes (los mismos que crea la migración)
+	asignados        map[uint]map[uint]bool            // ID de usuario -> IDs de sus roles
+	tokens           map[string]*modelos.TokenRefresco // Hash -> token de refresco
+	siguienteToken   uint
+	revocados        map[string]tokenRevocado       // jti -> token de acceso revocado
+	revocadosDesde   map[uint]time.Time             // ID de usuario -> última revocación de todos sus tokens
+	clientes         map[string]modelos.ClienteOIDC // client_id -> cliente OIDC
+	siguienteCliente uint
+	codigos          map[string]*modelos.CodigoAutorizacion // Hash -> código de autorización
+	siguienteCodigo  uint
+	tokensUnUso      map[string]*modelos.TokenUnUso // Hash -> token de un solo uso
+	siguienteUnUso   uint
+	// ID de usuario -> segundo factor y códigos de recuperación
+	segundosFactores    map[uint]*modelos.SegundoFactor
+	codigosRecuperacion map[uint][]codigoRecuperacion
+	credenciales        map[uint]*modelos.CredencialWebAuthn // ID -> credencial WebAuthn
+	siguienteCredencial uint
+	intentosLogin       map[string]modelos.IntentosLogin // Clave -> inicios de sesión fallidos
+	historial           map[uint][]string                // ID de usuario -> hashes anteriores, del más viejo al más nuevo
+	indice              *busqueda.Indice                 // Nombre de usuario y correo, para BuscarTexto
+}
+
+// NuevoUsuarioRepositorioMemoria crea un repositorio en memoria vacío
+func NuevoUsuarioRepositorioMemoria() *UsuarioRepositorioMemoria {
+	return &UsuarioRepositorioMemoria{
+		usuarios:         make(map[uint]modelos.Usuario),
+		siguiente:        1,
+		roles:            modelos.RolesPorDefecto(),
+		asignados:        make(map[uint]map[uint]bool),
+		tokens:           make(map[string]*modelos.TokenRefresco),
+		siguienteToken:   1,
+		revocados:        make(map[string]tokenRevocado),
+		revocadosDesde:   make(map[uint]time.Time),
+		clientes:         make(map[string]modelos.ClienteOIDC),
+		siguienteCliente: 1,
+		codigos:          make(map[string]*modelos.CodigoAutorizacion),
+		siguienteCodigo:  1,
+		tokensUnUso:      make(map[string]*modelos.TokenUnUso),
+		siguienteUnUso:   1,
+
+		segundosFactores:    make(map[uint]*modelos.SegundoFactor),
+		codigosRecuperacion: make(map[uint][]codigoRecuperacion),
+		credenciales:        make(map[uint]*modelos.CredencialWebAuthn),
+		siguienteCredencial: 1,
+		intentosLogin:       make(map[string]modelos.IntentosLogin),
+		historial:           make(map[uint][]string),
+		indice:              busqueda.NuevoIndice(),
+	}
+}
+
+// Crear guarda el usuario asignándole el siguiente ID disponible
+func (r *UsuarioRepositorioMemoria) Crear(usuario *modelos.Usuario) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Mismas reglas que UsuariosSchema: nombre_usuario y correo son UNIQUE
+	if r.enUso(0, usuario.NombreUsuario, usuario.Correo) {
+		return ErrUsuarioDuplicado
+	}
+
+	if usuario.CreadoEn.IsZero() {
+		usuario.CreadoEn = time.Now()
+	}
+	usuario.ID = r.siguiente
+	r.siguiente++
+	r.usuarios[usuario.ID] = *usuario
+	r.indice.Agregar(usuario.ID, usuario.NombreUsuario, usuario.Correo)
+	return nil
+}
+
+// BuscarPorID trae una copia del usuario con ese ID
+func (r *UsuarioRepositorioMemoria) BuscarPorID(id uint) (*modelos.Usuario, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usuario, existe := r.activo(id)
+	if !existe {
+		return nil, ErrUsuarioNoEncontrado
+	}
+	return &usuario, nil
+}
+
+// BuscarPorNombre trae una copia del usuario con ese nombre de usuario
+func (r *UsuarioRepositorioMemoria) BuscarPorNombre(nombreUsuario string) (*modelos.Usuario, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, usuario := range r.usuarios {
+		if usuario.NombreUsuario == nombreUsuario && usuario.EliminadoEn == nil {
+			return &usuario, nil
+		}
+	}
+	return nil, ErrUsuarioNoEncontrado
+}
+
+// BuscarPorCorreo trae una copia del usuario con ese correo
+func (r *UsuarioRepositorioMemoria) BuscarPorCorreo(correo string) (*modelos.Usuario, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, usuario := range r.usuarios {
+		if usuario.Correo == correo && usuario.EliminadoEn == nil {
+			return &usuario, nil
+		}
+	}
+	return nil, ErrUsuarioNoEncontrado
+}
+
+// Listar devuelve todos los usuarios activos ordenados por ID
+func (r *UsuarioRepositorioMemoria) Listar() ([]modelos.Usuario, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usuarios := make([]modelos.Usuario, 0, len(r.usuarios))
+	for _, usuario := range r.usuarios {
+		if usuario.EliminadoEn == nil {
+			usuarios = append(usuarios, usuario)
+		}
+	}
+	sort.Slice(usuarios, func(i, j int) bool { return usuarios[i].ID < usuarios[j].ID })
+	return usuarios, nil
+}
+
+// Actualizar modifica solo los campos no vacíos respetando las claves únicas
+func (r *UsuarioRepositorioMemoria) Actualizar(id uint, cambios modelos.Usuario) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usuario, existe := r.activo(id)
+	if !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	if r.enUso(id, cambios.NombreUsuario, cambios.Correo) {
+		return ErrUsuarioDuplicado
+	}
+
+	if cambios.NombreUsuario != "" {
+		usuario.NombreUsuario = cambios.NombreUsuario
+	}
+	if cambios.Correo != "" {
+		// Un correo distinto hay que volver a verificarlo
+		if cambios.Correo != usuario.Correo {
+			usuario.CorreoVerificado = false
+		}
+		usuario.Correo = cambios.Correo
+	}
+	if cambios.Contrasena != "" {
+		usuario.Contrasena = cambios.Contrasena
+	}
+	r.usuarios[id] = usuario
+	r.indice.Agregar(id, usuario.NombreUsuario, usuario.Correo)
+	return nil
+}
+
+// VerificarCorreo marca el correo como verificado solo si el usuario todavía tiene ese correo
+func (r *UsuarioRepositorioMemoria) VerificarCorreo(id uint, correo string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usuario, existe := r.activo(id)
+	if !existe {
+		return false, ErrUsuarioNoEncontrado
+	}
+	if usuario.Correo != correo {
+		return false, nil
+	}
+	usuario.CorreoVerificado = true
+	r.usuarios[id] = usuario
+	return true, nil
+}
+
+// ReemplazarContrasena cambia el hash si sigue siendo el anterior
+func (r *UsuarioRepositorioMemoria) ReemplazarContrasena(id uint, anterior, nueva string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usuario, existe := r.activo(id)
+	if !existe || usuario.Contrasena != anterior {
+		return false, nil
+	}
+	usuario.Contrasena = nueva
+	r.usuarios[id] = usuario
+	return true, nil
+}
+
+// Eliminar marca la fecha de baja del usuario y lo saca del índice de búsqueda
+func (r *UsuarioRepositorioMemoria) Eliminar(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usuario, existe := r.activo(id)
+	if !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	ahora := time.Now()
+	usuario.EliminadoEn = &ahora
+	r.usuarios[id] = usuario
+	r.indice.Quitar(id)
+	return nil
+}
+
+// Restaurar borra la fecha de baja y vuelve a indexar al usuario
+func (r *UsuarioRepositorioMemoria) Restaurar(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usuario, existe := r.usuarios[id]
+	if !existe || usuario.EliminadoEn == nil {
+		return ErrUsuarioNoEncontrado
+	}
+	usuario.EliminadoEn = nil
+	r.usuarios[id] = usuario
+	r.indice.Agregar(id, usuario.NombreUsuario, usuario.Correo)
+	return nil
+}
+
+// PurgarEliminados borra los usuarios dados de baja antes de antesDe
+func (r *UsuarioRepositorioMemoria) PurgarEliminados(antesDe time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purgados := 0
+	for id, usuario := range r.usuarios {
+		if usuario.EliminadoEn != nil && usuario.EliminadoEn.Before(antesDe) {
+			r.borrar(id)
+			purgados++
+		}
+	}
+	return purgados, nil
+}
+
+// activo devuelve el usuario si existe y no está eliminado. Se debe llamar con el mutex tomado.
+func (r *UsuarioRepositorioMemoria) activo(id uint) (modelos.Usuario, bool) {
+	usuario, existe := r.usuarios[id]
+	return usuario, existe && usuario.EliminadoEn == nil
+}
+
+// borrar saca al usuario y todo lo asociado. Se debe llamar con el mutex tomado.
+func (r *UsuarioRepositorioMemoria) borrar(id uint) {
+	delete(r.usuarios, id)
+	r.indice.Quitar(id)
+	delete(r.asignados, id)
+	// Igual que ON DELETE CASCADE en la base
+	for hash, token := range r.tokens {
+		if token.UsuarioID == id {
+			delete(r.tokens, hash)
+		}
+	}
+	for jti, revocado := range r.revocados {
+		if revocado.usuarioID == id {
+			delete(r.revocados, jti)
+		}
+	}
+	delete(r.revocadosDesde, id)
+	for hash, codigo := range r.codigos {
+		if codigo.UsuarioID == id {
+			delete(r.codigos, hash)
+		}
+	}
+	for hash, token := range r.tokensUnUso {
+		if token.UsuarioID == id {
+			delete(r.tokensUnUso, hash)
+		}
+	}
+	delete(r.segundosFactores, id)
+	delete(r.codigosRecuperacion, id)
+	for idCredencial, credencial := range r.credenciales {
+		if credencial.UsuarioID == id {
+			delete(r.credenciales, idCredencial)
+		}
+	}
+	delete(r.historial, id)
+}
+
+// enUso indica si otro usuario (distinto de "excepto") ya tiene ese nombre o correo;
+// los eliminados también cuentan hasta que se purgan.
+// Al actualizar, un valor vacío significa "sin cambios" y no se compara; al crear
+// (excepto == 0, los IDs empiezan en 1) se compara igual que un UNIQUE de SQL.
+// Se debe llamar con el mutex tomado.
+func (r *UsuarioRepositorioMemoria) enUso(excepto uint, nombreUsuario, correo string) bool {
+	creando := excepto == 0
+	for id, usuario := range r.usuarios {
+		if id == excepto {
+			continue
+		}
+		if (creando || nombreUsuario != "") && usuario.NombreUsuario == nombreUsuario {
+			return true
+		}
+		if (creando || correo != "") && usuario.Correo == correo {
+			return true
+		}
+	}
+	return false
+}
+
+// ListarRoles devuelve los roles ordenados por nombre
+func (r *UsuarioRepositorioMemoria) ListarRoles() ([]modelos.Rol, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	roles := append([]modelos.Rol(nil), r.roles...)
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Nombre < roles[j].Nombre })
+	return roles, nil
+}
+
+// RolesDeUsuario devuelve los roles asignados al usuario ordenados por nombre
+func (r *UsuarioRepositorioMemoria) RolesDeUsuario(usuarioID uint) ([]modelos.Rol, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var roles []modelos.Rol
+	for _, rol := range r.roles {
+		if r.asignados[usuarioID][rol.ID] {
+			roles = append(roles, rol)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Nombre < roles[j].Nombre })
+	return roles, nil
+}
+
+// AsignarRoles reemplaza los roles del usuario
+func (r *UsuarioRepositorioMemoria) AsignarRoles(usuarioID uint, roles []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.activo(usuarioID); !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	ids := make(map[uint]bool, len(roles))
+	for _, nombre := range roles {
+		encontrado := false
+		for _, rol := range r.roles {
+			if rol.Nombre == nombre {
+				ids[rol.ID] = true
+				encontrado = true
+				break
+			}
+		}
+		if !encontrado {
+			return ErrRolNoEncontrado
+		}
+	}
+	r.asignados[usuarioID] = ids
+	return nil
+}
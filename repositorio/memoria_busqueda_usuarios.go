@@ -0,0 +1,13 @@
+package repositorio
+
+// BuscarTexto busca en el índice de trigramas que mantienen Crear, Actualizar y Eliminar
+func (r *UsuarioRepositorioMemoria) BuscarTexto(terminos []string, limite int) ([]UsuarioEncontrado, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	encontrados := make([]UsuarioEncontrado, 0)
+	for _, resultado := range r.indice.Buscar(terminos, limite) {
+		encontrados = append(encontrados, UsuarioEncontrado{Usuario: r.usuarios[resultado.ID], Puntaje: resultado.Puntaje})
+	}
+	return encontrados, nil
+}
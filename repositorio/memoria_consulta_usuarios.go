@@ -0,0 +1,56 @@
+package repositorio
+
+import (
+	"fmt"
+	"sort"
+	"taller6/modelos"
+)
+
+// ListarPagina filtra, ordena y corta la página. Recorre todos los usuarios, lo que en
+// memoria no es un problema.
+func (r *UsuarioRepositorioMemoria) ListarPagina(consulta ConsultaUsuarios) ([]modelos.Usuario, error) {
+	campo := consulta.campoOrden()
+	if !OrdenValido(campo) {
+		return nil, fmt.Errorf("campo de orden inválido: %q", campo)
+	}
+	// Negativo si a va antes que b en el orden pedido
+	comparar := func(a, b modelos.Usuario) int {
+		if consulta.Descendente {
+			return compararPorCampo(campo, b, a)
+		}
+		return compararPorCampo(campo, a, b)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usuarios := make([]modelos.Usuario, 0)
+	for _, usuario := range r.usuarios {
+		if !consulta.Filtro.cumpleFiltro(usuario) {
+			continue
+		}
+		if consulta.Despues != nil && comparar(usuario, *consulta.Despues) <= 0 {
+			continue
+		}
+		usuarios = append(usuarios, usuario)
+	}
+	sort.Slice(usuarios, func(i, j int) bool { return comparar(usuarios[i], usuarios[j]) < 0 })
+	if len(usuarios) > consulta.Limite {
+		usuarios = usuarios[:consulta.Limite]
+	}
+	return usuarios, nil
+}
+
+// Contar cuenta los usuarios que cumplen el filtro
+func (r *UsuarioRepositorioMemoria) Contar(filtro FiltroUsuarios) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	total := 0
+	for _, usuario := range r.usuarios {
+		if filtro.cumpleFiltro(usuario) {
+			total++
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,37 @@
+package repositorio
+
+import "time"
+
+// ContrasenasAnteriores devuelve una copia de los hashes más nuevos
+func (r *UsuarioRepositorioMemoria) ContrasenasAnteriores(usuarioID uint, cantidad int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	historial := r.historial[usuarioID]
+	var hashes []string
+	// El historial está del más viejo al más nuevo
+	for i := len(historial) - 1; i >= 0 && len(hashes) < cantidad; i-- {
+		hashes = append(hashes, historial[i])
+	}
+	return hashes, nil
+}
+
+// AgregarContrasenaAnterior agrega el hash al final y recorta los más viejos
+func (r *UsuarioRepositorioMemoria) AgregarContrasenaAnterior(usuarioID uint, hash string, _ time.Time, conservar int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[usuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	if conservar <= 0 {
+		delete(r.historial, usuarioID)
+		return nil
+	}
+	historial := append(r.historial[usuarioID], hash)
+	if len(historial) > conservar {
+		historial = append([]string(nil), historial[len(historial)-conservar:]...)
+	}
+	r.historial[usuarioID] = historial
+	return nil
+}
@@ -0,0 +1,54 @@
+package repositorio
+
+import (
+	"taller6/modelos"
+	"time"
+)
+
+// BuscarIntentosLogin devuelve una copia de los fallos de la clave
+func (r *UsuarioRepositorioMemoria) BuscarIntentosLogin(clave string) (*modelos.IntentosLogin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	intentos := r.intentosLogin[clave]
+	intentos.Clave = clave
+	return &intentos, nil
+}
+
+// RegistrarFalloLogin suma el fallo bajo el lock
+func (r *UsuarioRepositorioMemoria) RegistrarFalloLogin(clave string, momento, olvidarAntesDe time.Time) (*modelos.IntentosLogin, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	intentos := r.intentosLogin[clave]
+	if intentos.UltimoFallo.Before(olvidarAntesDe) {
+		intentos.Fallos = 0
+	}
+	intentos.Clave = clave
+	intentos.Fallos++
+	intentos.UltimoFallo = momento
+	r.intentosLogin[clave] = intentos
+	return &intentos, nil
+}
+
+// ReiniciarIntentosLogin borra los fallos de la clave
+func (r *UsuarioRepositorioMemoria) ReiniciarIntentosLogin(clave string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.intentosLogin, clave)
+	return nil
+}
+
+// PurgarIntentosLogin borra las claves con el último fallo anterior a antesDe
+func (r *UsuarioRepositorioMemoria) PurgarIntentosLogin(antesDe time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for clave, intentos := range r.intentosLogin {
+		if intentos.UltimoFallo.Before(antesDe) {
+			delete(r.intentosLogin, clave)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package repositorio
+
+import (
+	"sort"
+	"taller6/modelos"
+	"time"
+)
+
+// CrearClienteOIDC guarda una copia del cliente con el siguiente ID
+func (r *UsuarioRepositorioMemoria) CrearClienteOIDC(cliente *modelos.ClienteOIDC) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.clientes[cliente.ClienteID]; existe {
+		return ErrUsuarioDuplicado
+	}
+	if cliente.CreadoEn.IsZero() {
+		cliente.CreadoEn = time.Now()
+	}
+	cliente.ID = r.siguienteCliente
+	r.siguienteCliente++
+	copia := *cliente
+	copia.RedirectURIs = append([]string(nil), cliente.RedirectURIs...)
+	r.clientes[cliente.ClienteID] = copia
+	return nil
+}
+
+// BuscarClienteOIDC devuelve una copia del cliente con ese client_id
+func (r *UsuarioRepositorioMemoria) BuscarClienteOIDC(clienteID string) (*modelos.ClienteOIDC, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cliente, existe := r.clientes[clienteID]
+	if !existe {
+		return nil, ErrClienteNoEncontrado
+	}
+	cliente.RedirectURIs = append([]string(nil), cliente.RedirectURIs...)
+	return &cliente, nil
+}
+
+// ListarClientesOIDC devuelve todos los clientes ordenados por ID
+func (r *UsuarioRepositorioMemoria) ListarClientesOIDC() ([]modelos.ClienteOIDC, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clientes := make([]modelos.ClienteOIDC, 0, len(r.clientes))
+	for _, cliente := range r.clientes {
+		cliente.RedirectURIs = append([]string(nil), cliente.RedirectURIs...)
+		clientes = append(clientes, cliente)
+	}
+	sort.Slice(clientes, func(i, j int) bool { return clientes[i].ID < clientes[j].ID })
+	return clientes, nil
+}
+
+// EliminarClienteOIDC borra el cliente y sus códigos
+func (r *UsuarioRepositorioMemoria) EliminarClienteOIDC(clienteID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.clientes[clienteID]; !existe {
+		return ErrClienteNoEncontrado
+	}
+	delete(r.clientes, clienteID)
+	// Igual que ON DELETE CASCADE en la base
+	for hash, codigo := range r.codigos {
+		if codigo.ClienteID == clienteID {
+			delete(r.codigos, hash)
+		}
+	}
+	return nil
+}
+
+// GuardarCodigoAutorizacion guarda una copia del código con el siguiente ID
+func (r *UsuarioRepositorioMemoria) GuardarCodigoAutorizacion(codigo *modelos.CodigoAutorizacion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[codigo.UsuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	if _, existe := r.clientes[codigo.ClienteID]; !existe {
+		return ErrClienteNoEncontrado
+	}
+	codigo.ID = r.siguienteCodigo
+	r.siguienteCodigo++
+	copia := *codigo
+	r.codigos[codigo.Hash] = &copia
+	return nil
+}
+
+// BuscarCodigoAutorizacion devuelve una copia del código con ese hash
+func (r *UsuarioRepositorioMemoria) BuscarCodigoAutorizacion(hash string) (*modelos.CodigoAutorizacion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	codigo, existe := r.codigos[hash]
+	if !existe {
+		return nil, ErrCodigoNoEncontrado
+	}
+	copia := *codigo
+	return &copia, nil
+}
+
+// MarcarCodigoAutorizacionUsado marca el código como usado si todavía no lo estaba
+func (r *UsuarioRepositorioMemoria) MarcarCodigoAutorizacionUsado(id uint, momento time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, codigo := range r.codigos {
+		if codigo.ID == id {
+			if codigo.UsadoEn != nil {
+				return false, nil
+			}
+			codigo.UsadoEn = &momento
+			return true, nil
+		}
+	}
+	return false, nil
+}
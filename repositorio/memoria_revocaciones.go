@@ -0,0 +1,63 @@
+package repositorio
+
+import "time"
+
+// tokenRevocado es una fila de tokens_revocados
+type tokenRevocado struct {
+	usuarioID uint
+	expiraEn  time.Time
+}
+
+// RevocarTokenAcceso anota el jti como revocado
+func (r *UsuarioRepositorioMemoria) RevocarTokenAcceso(jti string, usuarioID uint, expiraEn time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[usuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	r.revocados[jti] = tokenRevocado{usuarioID: usuarioID, expiraEn: expiraEn}
+	return nil
+}
+
+// TokenAccesoRevocado indica si el jti fue revocado
+func (r *UsuarioRepositorioMemoria) TokenAccesoRevocado(jti string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, revocado := r.revocados[jti]
+	return revocado, nil
+}
+
+// RevocarTokensAccesoDeUsuario guarda el momento de la revocación
+func (r *UsuarioRepositorioMemoria) RevocarTokensAccesoDeUsuario(usuarioID uint, momento time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[usuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	r.revocadosDesde[usuarioID] = momento
+	return nil
+}
+
+// TokensAccesoRevocadosDesde devuelve la última revocación del usuario o la fecha cero
+func (r *UsuarioRepositorioMemoria) TokensAccesoRevocadosDesde(usuarioID uint) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.revocadosDesde[usuarioID], nil
+}
+
+// PurgarRevocacionesVencidas borra los jti de tokens ya expirados
+func (r *UsuarioRepositorioMemoria) PurgarRevocacionesVencidas(ahora time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for jti, revocado := range r.revocados {
+		if revocado.expiraEn.Before(ahora) {
+			delete(r.revocados, jti)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package repositorio
+
+import (
+	"taller6/modelos"
+	"time"
+)
+
+// codigoRecuperacion es una fila de codigos_recuperacion
+type codigoRecuperacion struct {
+	hash    string
+	usadoEn *time.Time
+}
+
+// GuardarSegundoFactor guarda una copia, reemplazando el segundo factor anterior
+func (r *UsuarioRepositorioMemoria) GuardarSegundoFactor(factor *modelos.SegundoFactor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[factor.UsuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	copia := *factor
+	r.segundosFactores[factor.UsuarioID] = &copia
+	return nil
+}
+
+// BuscarSegundoFactor devuelve una copia del segundo factor del usuario
+func (r *UsuarioRepositorioMemoria) BuscarSegundoFactor(usuarioID uint) (*modelos.SegundoFactor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	factor, existe := r.segundosFactores[usuarioID]
+	if !existe {
+		return nil, ErrSegundoFactorNoEncontrado
+	}
+	copia := *factor
+	return &copia, nil
+}
+
+// ConfirmarSegundoFactor guarda el momento de la confirmación
+func (r *UsuarioRepositorioMemoria) ConfirmarSegundoFactor(usuarioID uint, momento time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	factor, existe := r.segundosFactores[usuarioID]
+	if !existe {
+		return ErrSegundoFactorNoEncontrado
+	}
+	factor.ConfirmadoEn = &momento
+	return nil
+}
+
+// RegistrarPasoTOTP anota el paso solo si es posterior al último aceptado
+func (r *UsuarioRepositorioMemoria) RegistrarPasoTOTP(usuarioID uint, paso int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	factor, existe := r.segundosFactores[usuarioID]
+	if !existe || factor.UltimoPaso >= paso {
+		return false, nil
+	}
+	factor.UltimoPaso = paso
+	return true, nil
+}
+
+// EliminarSegundoFactor borra el segundo factor y los códigos de recuperación
+func (r *UsuarioRepositorioMemoria) EliminarSegundoFactor(usuarioID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.segundosFactores[usuarioID]; !existe {
+		return ErrSegundoFactorNoEncontrado
+	}
+	delete(r.segundosFactores, usuarioID)
+	delete(r.codigosRecuperacion, usuarioID)
+	return nil
+}
+
+// ReemplazarCodigosRecuperacion cambia todos los códigos del usuario
+func (r *UsuarioRepositorioMemoria) ReemplazarCodigosRecuperacion(usuarioID uint, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[usuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	codigos := make([]codigoRecuperacion, 0, len(hashes))
+	for _, hash := range hashes {
+		codigos = append(codigos, codigoRecuperacion{hash: hash})
+	}
+	r.codigosRecuperacion[usuarioID] = codigos
+	return nil
+}
+
+// UsarCodigoRecuperacion marca el código como usado si todavía no lo estaba
+func (r *UsuarioRepositorioMemoria) UsarCodigoRecuperacion(usuarioID uint, hash string, momento time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codigos := r.codigosRecuperacion[usuarioID]
+	for i := range codigos {
+		if codigos[i].hash == hash && codigos[i].usadoEn == nil {
+			codigos[i].usadoEn = &momento
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CodigosRecuperacionRestantes cuenta los códigos sin usar
+func (r *UsuarioRepositorioMemoria) CodigosRecuperacionRestantes(usuarioID uint) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cantidad := 0
+	for _, codigo := range r.codigosRecuperacion[usuarioID] {
+		if codigo.usadoEn == nil {
+			cantidad++
+		}
+	}
+	return cantidad, nil
+}
@@ -0,0 +1,13 @@
+package repositorio_test
+
+import (
+	"taller6/repositorio"
+	"taller6/repositorio/repositoriotest"
+	"testing"
+)
+
+func TestConformidadMemoria(t *testing.T) {
+	if err := repositoriotest.Verificar(repositorio.NuevoUsuarioRepositorioMemoria()); err != nil {
+		t.Error(err)
+	}
+}
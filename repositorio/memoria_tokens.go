@@ -0,0 +1,75 @@
+package repositorio
+
+import (
+	"taller6/modelos"
+	"time"
+)
+
+// GuardarTokenRefresco guarda una copia del token con el siguiente ID
+func (r *UsuarioRepositorioMemoria) GuardarTokenRefresco(token *modelos.TokenRefresco) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[token.UsuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	token.ID = r.siguienteToken
+	r.siguienteToken++
+	copia := *token
+	r.tokens[token.Hash] = &copia
+	return nil
+}
+
+// BuscarTokenRefresco devuelve una copia del token con ese hash
+func (r *UsuarioRepositorioMemoria) BuscarTokenRefresco(hash string) (*modelos.TokenRefresco, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, existe := r.tokens[hash]
+	if !existe {
+		return nil, ErrTokenNoEncontrado
+	}
+	copia := *token
+	return &copia, nil
+}
+
+// MarcarTokenRefrescoUsado marca el token como usado si todavía no lo estaba
+func (r *UsuarioRepositorioMemoria) MarcarTokenRefrescoUsado(id uint, momento time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.ID == id {
+			if token.UsadoEn != nil {
+				return false, nil
+			}
+			token.UsadoEn = &momento
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RevocarFamilia revoca los tokens vigentes de la familia
+func (r *UsuarioRepositorioMemoria) RevocarFamilia(familia string, momento time.Time) error {
+	r.revocarTokens(func(token *modelos.TokenRefresco) bool { return token.Familia == familia }, momento)
+	return nil
+}
+
+// RevocarTokensRefrescoDeUsuario revoca los tokens vigentes del usuario
+func (r *UsuarioRepositorioMemoria) RevocarTokensRefrescoDeUsuario(usuarioID uint, momento time.Time) error {
+	r.revocarTokens(func(token *modelos.TokenRefresco) bool { return token.UsuarioID == usuarioID }, momento)
+	return nil
+}
+
+// revocarTokens marca como revocados los tokens vigentes que cumplen el filtro
+func (r *UsuarioRepositorioMemoria) revocarTokens(filtro func(*modelos.TokenRefresco) bool, momento time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.RevocadoEn == nil && filtro(token) {
+			token.RevocadoEn = &momento
+		}
+	}
+}
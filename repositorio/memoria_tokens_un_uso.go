@@ -0,0 +1,64 @@
+package repositorio
+
+import (
+	"taller6/modelos"
+	"time"
+)
+
+// GuardarTokenUnUso guarda una copia del token con el siguiente ID
+func (r *UsuarioRepositorioMemoria) GuardarTokenUnUso(token *modelos.TokenUnUso) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[token.UsuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	token.ID = r.siguienteUnUso
+	r.siguienteUnUso++
+	copia := *token
+	r.tokensUnUso[token.Hash] = &copia
+	return nil
+}
+
+// BuscarTokenUnUso devuelve una copia del token con ese propósito y hash
+func (r *UsuarioRepositorioMemoria) BuscarTokenUnUso(proposito, hash string) (*modelos.TokenUnUso, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, existe := r.tokensUnUso[hash]
+	if !existe || token.Proposito != proposito {
+		return nil, ErrTokenNoEncontrado
+	}
+	copia := *token
+	return &copia, nil
+}
+
+// MarcarTokenUnUsoUsado marca el token como usado si todavía no lo estaba
+func (r *UsuarioRepositorioMemoria) MarcarTokenUnUsoUsado(id uint, momento time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokensUnUso {
+		if token.ID == id {
+			if token.UsadoEn != nil {
+				return false, nil
+			}
+			token.UsadoEn = &momento
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// InvalidarTokensUnUso marca como usados los tokens pendientes del usuario con ese propósito
+func (r *UsuarioRepositorioMemoria) InvalidarTokensUnUso(usuarioID uint, proposito string, momento time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokensUnUso {
+		if token.UsuarioID == usuarioID && token.Proposito == proposito && token.UsadoEn == nil {
+			token.UsadoEn = &momento
+		}
+	}
+	return nil
+}
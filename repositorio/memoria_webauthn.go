@@ -0,0 +1,89 @@
+package repositorio
+
+import (
+	"bytes"
+	"sort"
+	"taller6/modelos"
+	"time"
+)
+
+// GuardarCredencialWebAuthn guarda una copia con el siguiente ID
+func (r *UsuarioRepositorioMemoria) GuardarCredencialWebAuthn(credencial *modelos.CredencialWebAuthn) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, existe := r.usuarios[credencial.UsuarioID]; !existe {
+		return ErrUsuarioNoEncontrado
+	}
+	// Igual que el UNIQUE de credencial_id
+	for _, otra := range r.credenciales {
+		if bytes.Equal(otra.CredencialID, credencial.CredencialID) {
+			return ErrUsuarioDuplicado
+		}
+	}
+	if credencial.CreadoEn.IsZero() {
+		credencial.CreadoEn = time.Now()
+	}
+	credencial.ID = r.siguienteCredencial
+	r.siguienteCredencial++
+	copia := copiarCredencial(*credencial)
+	r.credenciales[credencial.ID] = &copia
+	return nil
+}
+
+// CredencialesWebAuthnDeUsuario devuelve copias de las credenciales del usuario
+func (r *UsuarioRepositorioMemoria) CredencialesWebAuthnDeUsuario(usuarioID uint) ([]modelos.CredencialWebAuthn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var credenciales []modelos.CredencialWebAuthn
+	for _, credencial := range r.credenciales {
+		if credencial.UsuarioID == usuarioID {
+			credenciales = append(credenciales, copiarCredencial(*credencial))
+		}
+	}
+	sort.Slice(credenciales, func(i, j int) bool { return credenciales[i].ID < credenciales[j].ID })
+	return credenciales, nil
+}
+
+// RegistrarUsoCredencialWebAuthn actualiza el contador si avanzó
+func (r *UsuarioRepositorioMemoria) RegistrarUsoCredencialWebAuthn(id uint, contador uint32, momento time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credencial, existe := r.credenciales[id]
+	if !existe {
+		return false, ErrCredencialNoEncontrada
+	}
+	if contador <= credencial.Contador && (contador != 0 || credencial.Contador != 0) {
+		return false, nil
+	}
+	credencial.Contador = contador
+	credencial.UsadoEn = &momento
+	return true, nil
+}
+
+// EliminarCredencialWebAuthn borra la credencial si es del usuario
+func (r *UsuarioRepositorioMemoria) EliminarCredencialWebAuthn(usuarioID, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credencial, existe := r.credenciales[id]
+	if !existe || credencial.UsuarioID != usuarioID {
+		return ErrCredencialNoEncontrada
+	}
+	delete(r.credenciales, id)
+	return nil
+}
+
+// copiarCredencial copia también los slices, para que quien llama no modifique lo guardado
+func copiarCredencial(credencial modelos.CredencialWebAuthn) modelos.CredencialWebAuthn {
+	credencial.CredencialID = append([]byte(nil), credencial.CredencialID...)
+	credencial.ClavePublica = append([]byte(nil), credencial.ClavePublica...)
+	credencial.Transportes = append([]string(nil), credencial.Transportes...)
+	if credencial.UsadoEn != nil {
+		usadoEn := *credencial.UsadoEn
+		credencial.UsadoEn = &usadoEn
+	}
+	return credencial
+}
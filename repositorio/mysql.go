@@ -0,0 +1,25 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Código de error de MySQL para claves UNIQUE duplicadas
+const errorMySQLDuplicado = 1062
+
+// NuevoUsuarioRepositorioMySQL crea el repositorio a partir de una conexión MySQL ya abierta
+func NuevoUsuarioRepositorioMySQL(bd *sql.DB) *UsuarioRepositorioSQL {
+	return &UsuarioRepositorioSQL{bd: bd, motor: motorSQL{traducirError: traducirErrorMySQL}}
+}
+
+// traducirErrorMySQL convierte las violaciones de UNIQUE en ErrUsuarioDuplicado
+func traducirErrorMySQL(err error) error {
+	var errMySQL *mysql.MySQLError
+	if errors.As(err, &errMySQL) && errMySQL.Number == errorMySQLDuplicado {
+		return ErrUsuarioDuplicado
+	}
+	return err
+}
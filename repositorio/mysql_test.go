@@ -0,0 +1,15 @@
+package repositorio_test
+
+import (
+	"taller6/migraciones"
+	"taller6/repositorio"
+	"taller6/repositorio/repositoriotest"
+	"testing"
+)
+
+func TestConformidadMySQL(t *testing.T) {
+	bd := baseDePrueba(t, dsnDePrueba(t, entornoDSNMySQL), migraciones.MySQL{})
+	if err := repositoriotest.Verificar(repositorio.NuevoUsuarioRepositorioMySQL(bd)); err != nil {
+		t.Error(err)
+	}
+}
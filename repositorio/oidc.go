@@ -0,0 +1,33 @@
+package repositorio
+
+import (
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// Errores de los clientes y códigos de autorización de OpenID Connect
+var (
+	ErrClienteNoEncontrado = errors.New("cliente OIDC no encontrado")
+	ErrCodigoNoEncontrado  = errors.New("código de autorización no encontrado")
+)
+
+// ClienteOIDCRepositorio guarda los clientes registrados y los códigos de autorización
+type ClienteOIDCRepositorio interface {
+	// CrearClienteOIDC guarda un cliente nuevo y completa su ID
+	CrearClienteOIDC(cliente *modelos.ClienteOIDC) error
+	// BuscarClienteOIDC devuelve el cliente con ese client_id o ErrClienteNoEncontrado
+	BuscarClienteOIDC(clienteID string) (*modelos.ClienteOIDC, error)
+	// ListarClientesOIDC devuelve todos los clientes ordenados por ID
+	ListarClientesOIDC() ([]modelos.ClienteOIDC, error)
+	// EliminarClienteOIDC borra el cliente y sus códigos pendientes
+	EliminarClienteOIDC(clienteID string) error
+
+	// GuardarCodigoAutorizacion guarda un código nuevo y completa su ID
+	GuardarCodigoAutorizacion(codigo *modelos.CodigoAutorizacion) error
+	// BuscarCodigoAutorizacion devuelve el código con ese hash o ErrCodigoNoEncontrado
+	BuscarCodigoAutorizacion(hash string) (*modelos.CodigoAutorizacion, error)
+	// MarcarCodigoAutorizacionUsado marca el código como usado solo si no lo estaba.
+	// Devuelve false si ya se había cambiado por tokens.
+	MarcarCodigoAutorizacionUsado(id uint, momento time.Time) (bool, error)
+}
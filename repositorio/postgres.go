@@ -0,0 +1,30 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Código SQLSTATE de PostgreSQL para claves UNIQUE duplicadas
+const errorPostgresDuplicado = "23505"
+
+// NuevoUsuarioRepositorioPostgres crea el repositorio a partir de una conexión PostgreSQL ya abierta
+func NuevoUsuarioRepositorioPostgres(bd *sql.DB) *UsuarioRepositorioSQL {
+	return &UsuarioRepositorioSQL{bd: bd, motor: motorSQL{
+		traducirError:       traducirErrorPostgres,
+		marcadoresNumerados: true,
+		usaReturning:        true,
+		trigramas:           true,
+	}}
+}
+
+// traducirErrorPostgres convierte las violaciones de UNIQUE en ErrUsuarioDuplicado
+func traducirErrorPostgres(err error) error {
+	var errPostgres *pgconn.PgError
+	if errors.As(err, &errPostgres) && errPostgres.Code == errorPostgresDuplicado {
+		return ErrUsuarioDuplicado
+	}
+	return err
+}
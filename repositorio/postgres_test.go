@@ -0,0 +1,15 @@
+package repositorio_test
+
+import (
+	"taller6/migraciones"
+	"taller6/repositorio"
+	"taller6/repositorio/repositoriotest"
+	"testing"
+)
+
+func TestConformidadPostgres(t *testing.T) {
+	bd := baseDePrueba(t, dsnDePrueba(t, entornoDSNPostgres), migraciones.Postgres{})
+	if err := repositoriotest.Verificar(repositorio.NuevoUsuarioRepositorioPostgres(bd)); err != nil {
+		t.Error(err)
+	}
+}
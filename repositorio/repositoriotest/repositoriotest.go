@@ -0,0 +1,1073 @@
+// Package repositoriotest tiene la batería de conformidad que toda
+// implementación de repositorio.UsuarioRepositorio debe cumplir, al estilo de
+// testing/fstest: cada backend (memoria, MySQL, SQLite, PostgreSQL) la ejecuta
+// sobre un repositorio vacío y recibe un error con todos los casos que fallaron.
+package repositoriotest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"taller6/busqueda"
+	"taller6/modelos"
+	"taller6/repositorio"
+	"time"
+)
+
+// Verificar ejecuta todos los casos sobre un repositorio vacío (con los roles
+// por defecto ya creados). Si el repositorio también implementa
+// repositorio.RolRepositorio se verifican los roles. Devuelve nil si el
+// repositorio se comporta igual que el resto de los backends.
+func Verificar(repo repositorio.UsuarioRepositorio) error {
+	v := &verificador{repo: repo}
+	v.crearYBuscar()
+	v.duplicados()
+	v.listar()
+	v.paginar()
+	v.buscarTexto()
+	v.actualizar()
+	if roles, ok := repo.(repositorio.RolRepositorio); ok {
+		v.roles(roles)
+	}
+	if tokens, ok := repo.(repositorio.TokenRefrescoRepositorio); ok {
+		v.tokensRefresco(tokens)
+	}
+	if revocaciones, ok := repo.(repositorio.RevocacionRepositorio); ok {
+		v.revocaciones(revocaciones)
+	}
+	if oidc, ok := repo.(repositorio.ClienteOIDCRepositorio); ok {
+		v.clientesOIDC(oidc)
+	}
+	if tokens, ok := repo.(repositorio.TokenUnUsoRepositorio); ok {
+		v.tokensUnUso(tokens)
+	}
+	if factores, ok := repo.(repositorio.SegundoFactorRepositorio); ok {
+		v.segundoFactor(factores)
+	}
+	if credenciales, ok := repo.(repositorio.CredencialWebAuthnRepositorio); ok {
+		v.credencialesWebAuthn(credenciales)
+	}
+	if intentos, ok := repo.(repositorio.IntentoLoginRepositorio); ok {
+		v.intentosLogin(intentos)
+	}
+	if historial, ok := repo.(repositorio.HistorialContrasenaRepositorio); ok {
+		v.historialContrasenas(historial)
+	}
+	v.fechasConZona()
+	v.eliminar()
+	return errors.Join(v.errs...)
+}
+
+// verificador acumula los errores de cada caso
+type verificador struct {
+	repo repositorio.UsuarioRepositorio
+	errs []error
+}
+
+// fallo registra un caso que no se cumplió
+func (v *verificador) fallo(caso, formato string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", caso, fmt.Sprintf(formato, args...)))
+}
+
+// crear guarda un usuario de prueba y registra el fallo si no se pudo
+func (v *verificador) crear(caso, nombre string) *modelos.Usuario {
+	usuario := &modelos.Usuario{
+		NombreUsuario: nombre,
+		Correo:        nombre + "@ejemplo.com",
+		Contrasena:    "hash-" + nombre,
+		CreadoEn:      time.Now().UTC().Truncate(time.Second),
+	}
+	if err := v.repo.Crear(usuario); err != nil {
+		v.fallo(caso, "Crear(%q) devolvió %v", nombre, err)
+		return nil
+	}
+	return usuario
+}
+
+func (v *verificador) crearYBuscar() {
+	const caso = "crear y buscar"
+	primero := v.crear(caso, "conf_ana")
+	segundo := v.crear(caso, "conf_beto")
+	if primero == nil || segundo == nil {
+		return
+	}
+	if primero.ID == 0 || segundo.ID <= primero.ID {
+		v.fallo(caso, "los IDs deben ser crecientes y distintos de cero, se obtuvo %d y %d", primero.ID, segundo.ID)
+	}
+
+	encontrado, err := v.repo.BuscarPorID(primero.ID)
+	if err != nil {
+		v.fallo(caso, "BuscarPorID(%d) devolvió %v", primero.ID, err)
+	} else {
+		v.compararUsuario(caso, primero, encontrado)
+	}
+
+	encontrado, err = v.repo.BuscarPorNombre(segundo.NombreUsuario)
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre(%q) devolvió %v", segundo.NombreUsuario, err)
+	} else {
+		v.compararUsuario(caso, segundo, encontrado)
+	}
+
+	encontrado, err = v.repo.BuscarPorCorreo(primero.Correo)
+	if err != nil {
+		v.fallo(caso, "BuscarPorCorreo(%q) devolvió %v", primero.Correo, err)
+	} else {
+		v.compararUsuario(caso, primero, encontrado)
+	}
+
+	if _, err := v.repo.BuscarPorID(segundo.ID + 1000); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "BuscarPorID de un ID inexistente devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+	if _, err := v.repo.BuscarPorNombre("conf_inexistente"); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "BuscarPorNombre de un nombre inexistente devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+	if _, err := v.repo.BuscarPorCorreo("inexistente@ejemplo.com"); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "BuscarPorCorreo de un correo inexistente devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+}
+
+func (v *verificador) duplicados() {
+	const caso = "duplicados"
+	original := v.crear(caso, "conf_carla")
+	if original == nil {
+		return
+	}
+
+	mismoNombre := &modelos.Usuario{NombreUsuario: original.NombreUsuario, Correo: "otro@ejemplo.com", Contrasena: "x"}
+	if err := v.repo.Crear(mismoNombre); !errors.Is(err, repositorio.ErrUsuarioDuplicado) {
+		v.fallo(caso, "crear con nombre repetido devolvió %v, se esperaba ErrUsuarioDuplicado", err)
+	}
+	mismoCorreo := &modelos.Usuario{NombreUsuario: "conf_otro", Correo: original.Correo, Contrasena: "x"}
+	if err := v.repo.Crear(mismoCorreo); !errors.Is(err, repositorio.ErrUsuarioDuplicado) {
+		v.fallo(caso, "crear con correo repetido devolvió %v, se esperaba ErrUsuarioDuplicado", err)
+	}
+}
+
+func (v *verificador) listar() {
+	const caso = "listar"
+	usuarios, err := v.repo.Listar()
+	if err != nil {
+		v.fallo(caso, "Listar devolvió %v", err)
+		return
+	}
+	if len(usuarios) != 3 {
+		v.fallo(caso, "se esperaban 3 usuarios, se obtuvo %d", len(usuarios))
+	}
+	for i := 1; i < len(usuarios); i++ {
+		if usuarios[i].ID <= usuarios[i-1].ID {
+			v.fallo(caso, "los usuarios deben venir ordenados por ID")
+			break
+		}
+	}
+}
+
+// paginar recorre el listado por páginas con distintos órdenes y filtros
+func (v *verificador) paginar() {
+	const caso = "paginar"
+	nombres := func(usuarios []modelos.Usuario) string {
+		var lista []string
+		for _, usuario := range usuarios {
+			lista = append(lista, usuario.NombreUsuario)
+		}
+		return strings.Join(lista, ",")
+	}
+
+	// Por nombre, de a dos: la segunda página sigue después del último de la primera
+	primera, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Orden: repositorio.OrdenNombreUsuario, Limite: 2})
+	if err != nil || nombres(primera) != "conf_ana,conf_beto" {
+		v.fallo(caso, "la primera página por nombre devolvió %q, %v", nombres(primera), err)
+		return
+	}
+	segunda, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Orden: repositorio.OrdenNombreUsuario, Limite: 2, Despues: &primera[1]})
+	if err != nil || nombres(segunda) != "conf_carla" {
+		v.fallo(caso, "la segunda página por nombre devolvió %q, %v", nombres(segunda), err)
+	}
+	descendente, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Orden: repositorio.OrdenID, Descendente: true, Limite: 10})
+	if err != nil || nombres(descendente) != "conf_carla,conf_beto,conf_ana" {
+		v.fallo(caso, "el listado por ID descendente devolvió %q, %v", nombres(descendente), err)
+	}
+
+	// Por fecha descendente, de a uno: varios usuarios pueden tener la misma fecha y el
+	// ID tiene que desempatar sin saltear ni repetir ninguno
+	var recorridos []modelos.Usuario
+	var despues *modelos.Usuario
+	for i := 0; i < 5; i++ {
+		pagina, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Orden: repositorio.OrdenCreadoEn, Descendente: true, Limite: 1, Despues: despues})
+		if err != nil {
+			v.fallo(caso, "ListarPagina por fecha devolvió %v", err)
+			return
+		}
+		if len(pagina) == 0 {
+			break
+		}
+		recorridos = append(recorridos, pagina[0])
+		despues = &pagina[0]
+	}
+	if len(recorridos) != 3 {
+		v.fallo(caso, "recorrer por fecha devolvió %q, se esperaban los 3 usuarios", nombres(recorridos))
+	}
+	for i := 1; i < len(recorridos); i++ {
+		anterior, actual := recorridos[i-1], recorridos[i]
+		if actual.CreadoEn.After(anterior.CreadoEn) || (actual.CreadoEn.Equal(anterior.CreadoEn) && actual.ID >= anterior.ID) {
+			v.fallo(caso, "el orden por fecha descendente no se respetó: %q", nombres(recorridos))
+			break
+		}
+	}
+
+	// Filtros: los prefijos no distinguen mayúsculas y los comodines de LIKE son literales
+	referencia := primera[0].CreadoEn
+	filtros := []struct {
+		filtro   repositorio.FiltroUsuarios
+		esperado string
+	}{
+		{repositorio.FiltroUsuarios{}, "conf_ana,conf_beto,conf_carla"},
+		{repositorio.FiltroUsuarios{PrefijoNombre: "CONF_B"}, "conf_beto"},
+		{repositorio.FiltroUsuarios{PrefijoNombre: "conf%"}, ""},
+		{repositorio.FiltroUsuarios{PrefijoCorreo: "conf_c"}, "conf_carla"},
+		{repositorio.FiltroUsuarios{PrefijoNombre: "conf", PrefijoCorreo: "conf_a"}, "conf_ana"},
+		{repositorio.FiltroUsuarios{CreadoDesde: referencia.Add(-time.Hour), CreadoHasta: referencia.Add(time.Hour)}, "conf_ana,conf_beto,conf_carla"},
+		{repositorio.FiltroUsuarios{CreadoDesde: referencia.Add(time.Hour)}, ""},
+		{repositorio.FiltroUsuarios{CreadoHasta: referencia.Add(-time.Hour)}, ""},
+	}
+	for _, prueba := range filtros {
+		usuarios, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Filtro: prueba.filtro, Orden: repositorio.OrdenNombreUsuario, Limite: 10})
+		if err != nil || nombres(usuarios) != prueba.esperado {
+			v.fallo(caso, "el filtro %+v devolvió %q, %v; se esperaba %q", prueba.filtro, nombres(usuarios), err, prueba.esperado)
+		}
+		total, err := v.repo.Contar(prueba.filtro)
+		if esperados := len(strings.Split(prueba.esperado, ",")); err != nil || (prueba.esperado == "" && total != 0) || (prueba.esperado != "" && total != esperados) {
+			v.fallo(caso, "Contar con el filtro %+v devolvió %d, %v", prueba.filtro, total, err)
+		}
+	}
+
+	if _, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Orden: "contrasena", Limite: 10}); err == nil {
+		v.fallo(caso, "ordenar por un campo no permitido no devolvió error")
+	}
+}
+
+// buscarTexto busca por partes del nombre y del correo sin importar mayúsculas ni tildes,
+// y comprueba que la búsqueda siga a las altas, los cambios y las bajas
+func (v *verificador) buscarTexto() {
+	const caso = "buscarTexto"
+	nombres := func(encontrados []repositorio.UsuarioEncontrado) string {
+		var lista []string
+		for _, encontrado := range encontrados {
+			lista = append(lista, encontrado.Usuario.NombreUsuario)
+		}
+		sort.Strings(lista)
+		return strings.Join(lista, ",")
+	}
+	buscar := func(consulta string, limite int, esperado string) {
+		encontrados, err := v.repo.BuscarTexto(busqueda.Terminos(consulta), limite)
+		if err != nil || nombres(encontrados) != esperado {
+			v.fallo(caso, "buscar %q devolvió %q, %v; se esperaba %q", consulta, nombres(encontrados), err, esperado)
+		}
+	}
+
+	buscar("conf", 10, "conf_ana,conf_beto,conf_carla")
+	buscar("CÁRLA", 10, "conf_carla")
+	buscar("arla", 10, "conf_carla")
+	buscar("beto ejemplo", 10, "conf_beto")
+	buscar("beto carla", 10, "")
+	buscar("zzzz", 10, "")
+	if encontrados, err := v.repo.BuscarTexto(busqueda.Terminos("conf"), 2); err != nil || len(encontrados) != 2 {
+		v.fallo(caso, "buscar con límite 2 devolvió %d resultados, %v", len(encontrados), err)
+	}
+
+	// El más parecido va primero: la palabra entera antes que una parte
+	usuario := v.crear(caso, "conf_mariana")
+	if usuario == nil {
+		return
+	}
+	encontrados, err := v.repo.BuscarTexto(busqueda.Terminos("ana"), 10)
+	if err != nil || len(encontrados) != 2 || encontrados[0].Usuario.NombreUsuario != "conf_ana" || encontrados[0].Puntaje < encontrados[1].Puntaje {
+		v.fallo(caso, "buscar \"ana\" no puso primero a conf_ana: %+v, %v", encontrados, err)
+	}
+
+	if err := v.repo.Actualizar(usuario.ID, modelos.Usuario{NombreUsuario: "conf_josé"}); err != nil {
+		v.fallo(caso, "Actualizar devolvió %v", err)
+	}
+	buscar("jose", 10, "conf_josé")
+	buscar("mariana", 10, "conf_josé") // Todavía por el correo
+	if err := v.repo.Eliminar(usuario.ID); err != nil {
+		v.fallo(caso, "Eliminar devolvió %v", err)
+	}
+	buscar("jose", 10, "")
+}
+
+func (v *verificador) actualizar() {
+	const caso = "actualizar"
+	usuario, err := v.repo.BuscarPorNombre("conf_ana")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	// Solo cambia el correo; el resto de los campos se mantiene
+	if err := v.repo.Actualizar(usuario.ID, modelos.Usuario{Correo: "ana.nueva@ejemplo.com"}); err != nil {
+		v.fallo(caso, "Actualizar devolvió %v", err)
+	}
+	actualizado, err := v.repo.BuscarPorID(usuario.ID)
+	if err != nil {
+		v.fallo(caso, "BuscarPorID después de actualizar devolvió %v", err)
+	} else {
+		esperado := *usuario
+		esperado.Correo = "ana.nueva@ejemplo.com"
+		v.compararUsuario(caso, &esperado, actualizado)
+	}
+
+	// Sin cambios solo verifica que exista
+	if err := v.repo.Actualizar(usuario.ID, modelos.Usuario{}); err != nil {
+		v.fallo(caso, "Actualizar sin cambios devolvió %v", err)
+	}
+	if err := v.repo.Actualizar(usuario.ID, modelos.Usuario{NombreUsuario: "conf_beto"}); !errors.Is(err, repositorio.ErrUsuarioDuplicado) {
+		v.fallo(caso, "Actualizar a un nombre en uso devolvió %v, se esperaba ErrUsuarioDuplicado", err)
+	}
+	if err := v.repo.Actualizar(usuario.ID+1000, modelos.Usuario{Correo: "nadie@ejemplo.com"}); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "Actualizar un ID inexistente devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+	v.verificarCorreo(usuario.ID)
+	v.reemplazarContrasena(usuario.ID)
+}
+
+// reemplazarContrasena comprueba que el reemplazo solo pase si el hash no cambió
+func (v *verificador) reemplazarContrasena(id uint) {
+	const caso = "reemplazar contraseña"
+	usuario, err := v.repo.BuscarPorID(id)
+	if err != nil {
+		v.fallo(caso, "BuscarPorID devolvió %v", err)
+		return
+	}
+
+	if ok, err := v.repo.ReemplazarContrasena(id, usuario.Contrasena, "hash-nuevo"); err != nil || !ok {
+		v.fallo(caso, "ReemplazarContrasena con el hash actual devolvió %v, %v", ok, err)
+	}
+	// El hash anterior ya no es el actual
+	if ok, err := v.repo.ReemplazarContrasena(id, usuario.Contrasena, "hash-pisado"); err != nil || ok {
+		v.fallo(caso, "ReemplazarContrasena con un hash viejo devolvió %v, %v; se esperaba false", ok, err)
+	}
+	if actual, err := v.repo.BuscarPorID(id); err != nil {
+		v.fallo(caso, "BuscarPorID devolvió %v", err)
+	} else if actual.Contrasena != "hash-nuevo" {
+		v.fallo(caso, "la contraseña quedó como %q, se esperaba hash-nuevo", actual.Contrasena)
+	}
+	if ok, err := v.repo.ReemplazarContrasena(id+1000, "hash-nuevo", "otro"); err != nil || ok {
+		v.fallo(caso, "ReemplazarContrasena de un ID inexistente devolvió %v, %v; se esperaba false", ok, err)
+	}
+	// Se deja como estaba para el resto de los casos
+	if _, err := v.repo.ReemplazarContrasena(id, "hash-nuevo", usuario.Contrasena); err != nil {
+		v.fallo(caso, "ReemplazarContrasena devolvió %v", err)
+	}
+}
+
+// verificarCorreo comprueba que la verificación sea del correo actual y se pierda al cambiarlo
+func (v *verificador) verificarCorreo(id uint) {
+	const caso = "verificar correo"
+	verificado := func() bool {
+		usuario, err := v.repo.BuscarPorID(id)
+		if err != nil {
+			v.fallo(caso, "BuscarPorID devolvió %v", err)
+			return false
+		}
+		return usuario.CorreoVerificado
+	}
+
+	if ok, err := v.repo.VerificarCorreo(id, "ana.nueva@ejemplo.com"); err != nil || !ok {
+		v.fallo(caso, "VerificarCorreo con el correo actual devolvió %v, %v", ok, err)
+	}
+	if !verificado() {
+		v.fallo(caso, "el correo no quedó verificado")
+	}
+	// Guardar el mismo correo no quita la verificación
+	if err := v.repo.Actualizar(id, modelos.Usuario{Correo: "ana.nueva@ejemplo.com"}); err != nil {
+		v.fallo(caso, "Actualizar con el mismo correo devolvió %v", err)
+	}
+	if !verificado() {
+		v.fallo(caso, "guardar el mismo correo quitó la verificación")
+	}
+	// Cambiarlo sí, y el enlace del correo anterior ya no sirve
+	if err := v.repo.Actualizar(id, modelos.Usuario{Correo: "ana@ejemplo.com"}); err != nil {
+		v.fallo(caso, "Actualizar el correo devolvió %v", err)
+	}
+	if verificado() {
+		v.fallo(caso, "cambiar el correo no quitó la verificación")
+	}
+	if ok, err := v.repo.VerificarCorreo(id, "ana.nueva@ejemplo.com"); err != nil || ok {
+		v.fallo(caso, "VerificarCorreo con el correo anterior devolvió %v, %v; se esperaba false", ok, err)
+	}
+	if _, err := v.repo.VerificarCorreo(id+1000, "nadie@ejemplo.com"); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "VerificarCorreo de un ID inexistente devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+}
+
+// eliminar da de baja un usuario, lo restaura, lo vuelve a dar de baja y lo purga
+func (v *verificador) eliminar() {
+	const caso = "eliminar"
+	usuario, err := v.repo.BuscarPorNombre("conf_carla")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+	roles, _ := v.repo.(repositorio.RolRepositorio)
+	if roles != nil {
+		if err := roles.AsignarRoles(usuario.ID, []string{modelos.RolSoporte}); err != nil {
+			v.fallo(caso, "AsignarRoles devolvió %v", err)
+		}
+	}
+
+	if err := v.repo.Eliminar(usuario.ID); err != nil {
+		v.fallo(caso, "Eliminar devolvió %v", err)
+	}
+	if _, err := v.repo.BuscarPorID(usuario.ID); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "el usuario eliminado sigue apareciendo por ID (%v)", err)
+	}
+	if _, err := v.repo.BuscarPorNombre("conf_carla"); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "el usuario eliminado sigue apareciendo por nombre (%v)", err)
+	}
+	if err := v.repo.Actualizar(usuario.ID, modelos.Usuario{Correo: "carla@ejemplo.com"}); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "Actualizar un usuario eliminado devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+	if err := v.repo.Eliminar(usuario.ID); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "eliminar dos veces devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+	if roles != nil {
+		if err := roles.AsignarRoles(usuario.ID, []string{modelos.RolAdmin}); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+			v.fallo(caso, "AsignarRoles a un usuario eliminado devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+		}
+	}
+	// El nombre sigue ocupado hasta la purga, así se puede restaurar sin conflictos
+	if err := v.repo.Crear(&modelos.Usuario{NombreUsuario: "conf_carla", Correo: "otra@ejemplo.com", Contrasena: "x"}); !errors.Is(err, repositorio.ErrUsuarioDuplicado) {
+		v.fallo(caso, "crear con el nombre de un eliminado devolvió %v, se esperaba ErrUsuarioDuplicado", err)
+	}
+	activos, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Limite: 100})
+	if err != nil {
+		v.fallo(caso, "ListarPagina devolvió %v", err)
+	}
+	for _, activo := range activos {
+		if activo.ID == usuario.ID {
+			v.fallo(caso, "el usuario eliminado aparece en el listado")
+		}
+	}
+	eliminados, err := v.repo.ListarPagina(repositorio.ConsultaUsuarios{Filtro: repositorio.FiltroUsuarios{Eliminados: true}, Limite: 100})
+	encontrado := false
+	for _, eliminado := range eliminados {
+		encontrado = encontrado || (eliminado.ID == usuario.ID && eliminado.EliminadoEn != nil)
+	}
+	if err != nil || !encontrado {
+		v.fallo(caso, "el listado de eliminados no tiene al usuario con su fecha de baja (%v)", err)
+	}
+
+	// Restaurado vuelve con sus datos y sus roles
+	if err := v.repo.Restaurar(usuario.ID); err != nil {
+		v.fallo(caso, "Restaurar devolvió %v", err)
+	}
+	if restaurado, err := v.repo.BuscarPorID(usuario.ID); err != nil {
+		v.fallo(caso, "BuscarPorID después de restaurar devolvió %v", err)
+	} else {
+		v.compararUsuario(caso, usuario, restaurado)
+	}
+	if roles != nil {
+		if asignados, err := roles.RolesDeUsuario(usuario.ID); err != nil || len(asignados) != 1 {
+			v.fallo(caso, "el usuario restaurado no conserva sus roles (%v, %v)", asignados, err)
+		}
+	}
+	if err := v.repo.Restaurar(usuario.ID); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "restaurar un usuario activo devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+
+	// La purga solo borra los eliminados antes de la fecha indicada
+	if err := v.repo.Eliminar(usuario.ID); err != nil {
+		v.fallo(caso, "Eliminar después de restaurar devolvió %v", err)
+	}
+	if purgados, err := v.repo.PurgarEliminados(time.Now().Add(-time.Hour)); err != nil || purgados != 0 {
+		v.fallo(caso, "PurgarEliminados antes de la baja borró %d, %v", purgados, err)
+	}
+	pendientes, err := v.repo.Contar(repositorio.FiltroUsuarios{Eliminados: true})
+	if err != nil {
+		v.fallo(caso, "Contar los eliminados devolvió %v", err)
+	}
+	if purgados, err := v.repo.PurgarEliminados(time.Now().Add(time.Hour)); err != nil || purgados != pendientes {
+		v.fallo(caso, "PurgarEliminados borró %d, %v; se esperaban %d", purgados, err, pendientes)
+	}
+	if err := v.repo.Restaurar(usuario.ID); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "restaurar un usuario purgado devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+	if roles != nil {
+		if asignados, err := roles.RolesDeUsuario(usuario.ID); err != nil || len(asignados) != 0 {
+			v.fallo(caso, "el usuario purgado conserva sus roles (%v, %v)", asignados, err)
+		}
+	}
+}
+
+func (v *verificador) roles(roles repositorio.RolRepositorio) {
+	const caso = "roles"
+	existentes, err := roles.ListarRoles()
+	if err != nil {
+		v.fallo(caso, "ListarRoles devolvió %v", err)
+		return
+	}
+	nombres, _ := modelos.NombresYPermisos(existentes)
+	esperados, _ := modelos.NombresYPermisos(modelos.RolesPorDefecto())
+	if fmt.Sprint(nombres) != fmt.Sprint(esperados) {
+		v.fallo(caso, "se esperaban los roles %v, se obtuvo %v", esperados, nombres)
+	}
+
+	usuario, err := v.repo.BuscarPorNombre("conf_carla")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+	if err := roles.AsignarRoles(usuario.ID, []string{modelos.RolSoporte, modelos.RolAdmin}); err != nil {
+		v.fallo(caso, "AsignarRoles devolvió %v", err)
+	}
+	if err := roles.AsignarRoles(usuario.ID, []string{modelos.RolSoporte}); err != nil {
+		v.fallo(caso, "AsignarRoles devolvió %v", err)
+	}
+	asignados, err := roles.RolesDeUsuario(usuario.ID)
+	if err != nil {
+		v.fallo(caso, "RolesDeUsuario devolvió %v", err)
+	} else if len(asignados) != 1 || asignados[0].Nombre != modelos.RolSoporte || len(asignados[0].Permisos) != 1 {
+		v.fallo(caso, "AsignarRoles debe reemplazar los roles, se obtuvo %+v", asignados)
+	}
+
+	if err := roles.AsignarRoles(usuario.ID, []string{"inexistente"}); !errors.Is(err, repositorio.ErrRolNoEncontrado) {
+		v.fallo(caso, "asignar un rol inexistente devolvió %v, se esperaba ErrRolNoEncontrado", err)
+	}
+	if err := roles.AsignarRoles(usuario.ID+1000, []string{modelos.RolSoporte}); !errors.Is(err, repositorio.ErrUsuarioNoEncontrado) {
+		v.fallo(caso, "asignar roles a un ID inexistente devolvió %v, se esperaba ErrUsuarioNoEncontrado", err)
+	}
+}
+
+func (v *verificador) tokensRefresco(tokens repositorio.TokenRefrescoRepositorio) {
+	const caso = "tokens de refresco"
+	usuario, err := v.repo.BuscarPorNombre("conf_beto")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	ahora := time.Now().UTC().Truncate(time.Second)
+	primero := &modelos.TokenRefresco{UsuarioID: usuario.ID, Familia: "familia-conf", Hash: "hash-conf-1", CreadoEn: ahora, ExpiraEn: ahora.Add(time.Hour)}
+	segundo := &modelos.TokenRefresco{UsuarioID: usuario.ID, Familia: "familia-conf", Hash: "hash-conf-2", CreadoEn: ahora, ExpiraEn: ahora.Add(time.Hour)}
+	for _, token := range []*modelos.TokenRefresco{primero, segundo} {
+		if err := tokens.GuardarTokenRefresco(token); err != nil || token.ID == 0 {
+			v.fallo(caso, "GuardarTokenRefresco devolvió %v (ID %d)", err, token.ID)
+			return
+		}
+	}
+
+	encontrado, err := tokens.BuscarTokenRefresco(primero.Hash)
+	if err != nil {
+		v.fallo(caso, "BuscarTokenRefresco devolvió %v", err)
+	} else if encontrado.ID != primero.ID || encontrado.Familia != primero.Familia || encontrado.UsadoEn != nil ||
+		encontrado.RevocadoEn != nil || !encontrado.ExpiraEn.Equal(primero.ExpiraEn) {
+		v.fallo(caso, "se esperaba %+v, se obtuvo %+v", *primero, *encontrado)
+	}
+	if _, err := tokens.BuscarTokenRefresco("hash-inexistente"); !errors.Is(err, repositorio.ErrTokenNoEncontrado) {
+		v.fallo(caso, "buscar un hash inexistente devolvió %v, se esperaba ErrTokenNoEncontrado", err)
+	}
+
+	// Solo el primer uso puede rotar el token
+	if rotado, err := tokens.MarcarTokenRefrescoUsado(primero.ID, ahora); err != nil || !rotado {
+		v.fallo(caso, "el primer MarcarTokenRefrescoUsado devolvió %v, %v", rotado, err)
+	}
+	if rotado, err := tokens.MarcarTokenRefrescoUsado(primero.ID, ahora); err != nil || rotado {
+		v.fallo(caso, "el segundo MarcarTokenRefrescoUsado devolvió %v, %v; se esperaba false", rotado, err)
+	}
+
+	if err := tokens.RevocarFamilia("familia-conf", ahora); err != nil {
+		v.fallo(caso, "RevocarFamilia devolvió %v", err)
+	}
+	if revocado, err := tokens.BuscarTokenRefresco(segundo.Hash); err != nil || revocado.RevocadoEn == nil {
+		v.fallo(caso, "el token de la familia revocada sigue vigente (%v)", err)
+	}
+	if err := tokens.RevocarTokensRefrescoDeUsuario(usuario.ID, ahora); err != nil {
+		v.fallo(caso, "RevocarTokensRefrescoDeUsuario devolvió %v", err)
+	}
+}
+
+// revocaciones verifica la revocación de tokens de acceso por jti y por usuario
+func (v *verificador) revocaciones(revocaciones repositorio.RevocacionRepositorio) {
+	const caso = "revocaciones"
+	usuario, err := v.repo.BuscarPorNombre("conf_beto")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	ahora := time.Now().UTC().Truncate(time.Second)
+	if err := revocaciones.RevocarTokenAcceso("jti-conf-vigente", usuario.ID, ahora.Add(time.Hour)); err != nil {
+		v.fallo(caso, "RevocarTokenAcceso devolvió %v", err)
+	}
+	// Revocar dos veces el mismo token (dos logouts seguidos) no es un error
+	if err := revocaciones.RevocarTokenAcceso("jti-conf-vigente", usuario.ID, ahora.Add(time.Hour)); err != nil {
+		v.fallo(caso, "revocar de nuevo el mismo jti devolvió %v", err)
+	}
+	if err := revocaciones.RevocarTokenAcceso("jti-conf-vencido", usuario.ID, ahora.Add(-time.Hour)); err != nil {
+		v.fallo(caso, "RevocarTokenAcceso devolvió %v", err)
+	}
+	if revocado, err := revocaciones.TokenAccesoRevocado("jti-conf-vigente"); err != nil || !revocado {
+		v.fallo(caso, "TokenAccesoRevocado del jti revocado devolvió %v, %v", revocado, err)
+	}
+	if revocado, err := revocaciones.TokenAccesoRevocado("jti-inexistente"); err != nil || revocado {
+		v.fallo(caso, "TokenAccesoRevocado de un jti sin revocar devolvió %v, %v", revocado, err)
+	}
+
+	// La purga solo olvida los tokens que ya expiraron
+	if err := revocaciones.PurgarRevocacionesVencidas(ahora); err != nil {
+		v.fallo(caso, "PurgarRevocacionesVencidas devolvió %v", err)
+	}
+	if revocado, _ := revocaciones.TokenAccesoRevocado("jti-conf-vencido"); revocado {
+		v.fallo(caso, "el jti vencido sigue después de purgar")
+	}
+	if revocado, _ := revocaciones.TokenAccesoRevocado("jti-conf-vigente"); !revocado {
+		v.fallo(caso, "la purga borró un jti vigente")
+	}
+
+	if desde, err := revocaciones.TokensAccesoRevocadosDesde(usuario.ID); err != nil || !desde.IsZero() {
+		v.fallo(caso, "TokensAccesoRevocadosDesde sin revocaciones devolvió %v, %v", desde, err)
+	}
+	// La segunda revocación reemplaza a la primera
+	for _, momento := range []time.Time{ahora.Add(-time.Minute), ahora} {
+		if err := revocaciones.RevocarTokensAccesoDeUsuario(usuario.ID, momento); err != nil {
+			v.fallo(caso, "RevocarTokensAccesoDeUsuario devolvió %v", err)
+		}
+	}
+	if desde, err := revocaciones.TokensAccesoRevocadosDesde(usuario.ID); err != nil || !desde.Equal(ahora) {
+		v.fallo(caso, "TokensAccesoRevocadosDesde devolvió %v, %v; se esperaba %v", desde, err, ahora)
+	}
+}
+
+// clientesOIDC verifica los clientes de OpenID Connect y sus códigos de autorización
+func (v *verificador) clientesOIDC(oidc repositorio.ClienteOIDCRepositorio) {
+	const caso = "clientes OIDC"
+	usuario, err := v.repo.BuscarPorNombre("conf_beto")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	cliente := &modelos.ClienteOIDC{ClienteID: "cliente-conf", Nombre: "Conformidad", SecretoHash: "hash-secreto",
+		RedirectURIs: []string{"https://a.ejemplo.com/cb", "http://localhost:3000/cb"}}
+	if err := oidc.CrearClienteOIDC(cliente); err != nil || cliente.ID == 0 {
+		v.fallo(caso, "CrearClienteOIDC devolvió %v (ID %d)", err, cliente.ID)
+		return
+	}
+	encontrado, err := oidc.BuscarClienteOIDC("cliente-conf")
+	if err != nil {
+		v.fallo(caso, "BuscarClienteOIDC devolvió %v", err)
+	} else if encontrado.ID != cliente.ID || encontrado.SecretoHash != cliente.SecretoHash ||
+		strings.Join(encontrado.RedirectURIs, " ") != strings.Join(cliente.RedirectURIs, " ") {
+		v.fallo(caso, "se esperaba %+v, se obtuvo %+v", *cliente, *encontrado)
+	}
+	if _, err := oidc.BuscarClienteOIDC("cliente-inexistente"); !errors.Is(err, repositorio.ErrClienteNoEncontrado) {
+		v.fallo(caso, "buscar un cliente inexistente devolvió %v, se esperaba ErrClienteNoEncontrado", err)
+	}
+	if clientes, err := oidc.ListarClientesOIDC(); err != nil || len(clientes) != 1 {
+		v.fallo(caso, "ListarClientesOIDC devolvió %d clientes y %v, se esperaba 1", len(clientes), err)
+	}
+
+	ahora := time.Now().UTC().Truncate(time.Second)
+	codigo := &modelos.CodigoAutorizacion{Hash: "hash-codigo-conf", ClienteID: "cliente-conf", UsuarioID: usuario.ID,
+		RedirectURI: "https://a.ejemplo.com/cb", Scope: "openid", Nonce: "n", DesafioPKCE: "desafio",
+		AutenticadoEn: ahora, ExpiraEn: ahora.Add(time.Minute)}
+	if err := oidc.GuardarCodigoAutorizacion(codigo); err != nil || codigo.ID == 0 {
+		v.fallo(caso, "GuardarCodigoAutorizacion devolvió %v (ID %d)", err, codigo.ID)
+		return
+	}
+	if leido, err := oidc.BuscarCodigoAutorizacion(codigo.Hash); err != nil {
+		v.fallo(caso, "BuscarCodigoAutorizacion devolvió %v", err)
+	} else if leido.ID != codigo.ID || leido.UsuarioID != usuario.ID || leido.DesafioPKCE != codigo.DesafioPKCE ||
+		leido.UsadoEn != nil || !leido.ExpiraEn.Equal(codigo.ExpiraEn) {
+		v.fallo(caso, "se esperaba %+v, se obtuvo %+v", *codigo, *leido)
+	}
+	// El código se cambia por tokens una sola vez
+	if usado, err := oidc.MarcarCodigoAutorizacionUsado(codigo.ID, ahora); err != nil || !usado {
+		v.fallo(caso, "el primer MarcarCodigoAutorizacionUsado devolvió %v, %v", usado, err)
+	}
+	if usado, err := oidc.MarcarCodigoAutorizacionUsado(codigo.ID, ahora); err != nil || usado {
+		v.fallo(caso, "el segundo MarcarCodigoAutorizacionUsado devolvió %v, %v; se esperaba false", usado, err)
+	}
+
+	// Al borrar el cliente se borran sus códigos
+	if err := oidc.EliminarClienteOIDC("cliente-conf"); err != nil {
+		v.fallo(caso, "EliminarClienteOIDC devolvió %v", err)
+	}
+	if _, err := oidc.BuscarCodigoAutorizacion(codigo.Hash); !errors.Is(err, repositorio.ErrCodigoNoEncontrado) {
+		v.fallo(caso, "el código sigue después de borrar el cliente (%v)", err)
+	}
+	if err := oidc.EliminarClienteOIDC("cliente-conf"); !errors.Is(err, repositorio.ErrClienteNoEncontrado) {
+		v.fallo(caso, "borrar un cliente inexistente devolvió %v, se esperaba ErrClienteNoEncontrado", err)
+	}
+}
+
+// tokensUnUso verifica los tokens de un solo uso que se envían por correo
+func (v *verificador) tokensUnUso(tokens repositorio.TokenUnUsoRepositorio) {
+	const caso = "tokens de un solo uso"
+	usuario, err := v.repo.BuscarPorNombre("conf_beto")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	ahora := time.Now().UTC().Truncate(time.Second)
+	nuevo := func(hash string) *modelos.TokenUnUso {
+		token := &modelos.TokenUnUso{UsuarioID: usuario.ID, Proposito: modelos.PropositoRestablecer, Hash: hash, CreadoEn: ahora, ExpiraEn: ahora.Add(time.Hour)}
+		if err := tokens.GuardarTokenUnUso(token); err != nil || token.ID == 0 {
+			v.fallo(caso, "GuardarTokenUnUso devolvió %v (ID %d)", err, token.ID)
+		}
+		return token
+	}
+	primero := nuevo("hash-un-uso-1")
+	verificacion := &modelos.TokenUnUso{UsuarioID: usuario.ID, Proposito: modelos.PropositoVerificar, Hash: "hash-un-uso-dato",
+		CreadoEn: ahora, ExpiraEn: ahora.Add(time.Hour), Dato: usuario.Correo}
+	if err := tokens.GuardarTokenUnUso(verificacion); err != nil {
+		v.fallo(caso, "GuardarTokenUnUso con dato devolvió %v", err)
+	} else if encontrado, err := tokens.BuscarTokenUnUso(modelos.PropositoVerificar, verificacion.Hash); err != nil || encontrado.Dato != usuario.Correo {
+		v.fallo(caso, "BuscarTokenUnUso devolvió %+v, %v; se esperaba el dato %q", encontrado, err, usuario.Correo)
+	}
+
+	encontrado, err := tokens.BuscarTokenUnUso(modelos.PropositoRestablecer, primero.Hash)
+	if err != nil {
+		v.fallo(caso, "BuscarTokenUnUso devolvió %v", err)
+	} else if encontrado.ID != primero.ID || encontrado.UsuarioID != usuario.ID || encontrado.UsadoEn != nil ||
+		!encontrado.ExpiraEn.Equal(primero.ExpiraEn) {
+		v.fallo(caso, "se esperaba %+v, se obtuvo %+v", *primero, *encontrado)
+	}
+	// Un token no sirve para otro propósito
+	if _, err := tokens.BuscarTokenUnUso("otro", primero.Hash); !errors.Is(err, repositorio.ErrTokenNoEncontrado) {
+		v.fallo(caso, "buscar con otro propósito devolvió %v, se esperaba ErrTokenNoEncontrado", err)
+	}
+
+	if usado, err := tokens.MarcarTokenUnUsoUsado(primero.ID, ahora); err != nil || !usado {
+		v.fallo(caso, "el primer MarcarTokenUnUsoUsado devolvió %v, %v", usado, err)
+	}
+	if usado, err := tokens.MarcarTokenUnUsoUsado(primero.ID, ahora); err != nil || usado {
+		v.fallo(caso, "el segundo MarcarTokenUnUsoUsado devolvió %v, %v; se esperaba false", usado, err)
+	}
+
+	// Invalidar deja sin efecto los pendientes
+	segundo := nuevo("hash-un-uso-2")
+	if err := tokens.InvalidarTokensUnUso(usuario.ID, modelos.PropositoRestablecer, ahora); err != nil {
+		v.fallo(caso, "InvalidarTokensUnUso devolvió %v", err)
+	}
+	if invalidado, err := tokens.BuscarTokenUnUso(modelos.PropositoRestablecer, segundo.Hash); err != nil || invalidado.UsadoEn == nil {
+		v.fallo(caso, "el token pendiente sigue vigente después de invalidar (%v)", err)
+	}
+}
+
+// segundoFactor verifica la inscripción del TOTP, la protección contra reusar un
+// código y los códigos de recuperación
+func (v *verificador) segundoFactor(factores repositorio.SegundoFactorRepositorio) {
+	const caso = "segundo factor"
+	usuario, err := v.repo.BuscarPorNombre("conf_beto")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	if _, err := factores.BuscarSegundoFactor(usuario.ID); !errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado) {
+		v.fallo(caso, "BuscarSegundoFactor sin inscripción devolvió %v, se esperaba ErrSegundoFactorNoEncontrado", err)
+	}
+	ahora := time.Now().UTC().Truncate(time.Second)
+	// Guardar dos veces reemplaza la inscripción pendiente
+	for _, secreto := range []string{"SECRETOVIEJO", "SECRETONUEVO"} {
+		if err := factores.GuardarSegundoFactor(&modelos.SegundoFactor{UsuarioID: usuario.ID, Secreto: secreto, CreadoEn: ahora}); err != nil {
+			v.fallo(caso, "GuardarSegundoFactor devolvió %v", err)
+		}
+	}
+	factor, err := factores.BuscarSegundoFactor(usuario.ID)
+	if err != nil {
+		v.fallo(caso, "BuscarSegundoFactor devolvió %v", err)
+		return
+	}
+	if factor.Secreto != "SECRETONUEVO" || factor.Activo() || factor.UltimoPaso != 0 {
+		v.fallo(caso, "se esperaba la inscripción nueva sin confirmar, se obtuvo %+v", *factor)
+	}
+	if err := factores.ConfirmarSegundoFactor(usuario.ID, ahora); err != nil {
+		v.fallo(caso, "ConfirmarSegundoFactor devolvió %v", err)
+	}
+	if factor, err := factores.BuscarSegundoFactor(usuario.ID); err != nil || !factor.Activo() {
+		v.fallo(caso, "el segundo factor no quedó activo (%v)", err)
+	}
+
+	// Un paso se acepta una sola vez y nunca uno anterior
+	for _, prueba := range []struct {
+		paso     int64
+		esperado bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		if aceptado, err := factores.RegistrarPasoTOTP(usuario.ID, prueba.paso); err != nil || aceptado != prueba.esperado {
+			v.fallo(caso, "RegistrarPasoTOTP(%d) devolvió %v, %v; se esperaba %v", prueba.paso, aceptado, err, prueba.esperado)
+		}
+	}
+
+	if err := factores.ReemplazarCodigosRecuperacion(usuario.ID, []string{"hash-rec-1", "hash-rec-2"}); err != nil {
+		v.fallo(caso, "ReemplazarCodigosRecuperacion devolvió %v", err)
+	}
+	if usado, err := factores.UsarCodigoRecuperacion(usuario.ID, "hash-rec-1", ahora); err != nil || !usado {
+		v.fallo(caso, "el primer UsarCodigoRecuperacion devolvió %v, %v", usado, err)
+	}
+	if usado, err := factores.UsarCodigoRecuperacion(usuario.ID, "hash-rec-1", ahora); err != nil || usado {
+		v.fallo(caso, "el segundo UsarCodigoRecuperacion devolvió %v, %v; se esperaba false", usado, err)
+	}
+	if restantes, err := factores.CodigosRecuperacionRestantes(usuario.ID); err != nil || restantes != 1 {
+		v.fallo(caso, "CodigosRecuperacionRestantes devolvió %d, %v; se esperaba 1", restantes, err)
+	}
+	// Reemplazar invalida los anteriores
+	if err := factores.ReemplazarCodigosRecuperacion(usuario.ID, []string{"hash-rec-3"}); err != nil {
+		v.fallo(caso, "ReemplazarCodigosRecuperacion devolvió %v", err)
+	}
+	if usado, err := factores.UsarCodigoRecuperacion(usuario.ID, "hash-rec-2", ahora); err != nil || usado {
+		v.fallo(caso, "un código reemplazado se pudo usar (%v)", err)
+	}
+
+	if err := factores.EliminarSegundoFactor(usuario.ID); err != nil {
+		v.fallo(caso, "EliminarSegundoFactor devolvió %v", err)
+	}
+	if _, err := factores.BuscarSegundoFactor(usuario.ID); !errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado) {
+		v.fallo(caso, "BuscarSegundoFactor después de eliminar devolvió %v", err)
+	}
+	if restantes, err := factores.CodigosRecuperacionRestantes(usuario.ID); err != nil || restantes != 0 {
+		v.fallo(caso, "quedaron %d códigos de recuperación después de eliminar (%v)", restantes, err)
+	}
+	if err := factores.EliminarSegundoFactor(usuario.ID); !errors.Is(err, repositorio.ErrSegundoFactorNoEncontrado) {
+		v.fallo(caso, "eliminar dos veces devolvió %v, se esperaba ErrSegundoFactorNoEncontrado", err)
+	}
+}
+
+// credencialesWebAuthn verifica el alta, el contador de firmas y la baja de las llaves de acceso
+func (v *verificador) credencialesWebAuthn(credenciales repositorio.CredencialWebAuthnRepositorio) {
+	const caso = "credenciales WebAuthn"
+	usuario, err := v.repo.BuscarPorNombre("conf_beto")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	ahora := time.Now().UTC().Truncate(time.Second)
+	// Bytes que no son texto válido, como los que envían los autenticadores
+	primera := &modelos.CredencialWebAuthn{
+		UsuarioID:      usuario.ID,
+		CredencialID:   []byte{0x00, 0xff, 0x10, 0x80},
+		ClavePublica:   []byte{0xa5, 0x01, 0x02, 0x03, 0x26},
+		TipoAtestacion: "none",
+		Transportes:    []string{"usb", "nfc"},
+		Contador:       5,
+		Nombre:         "Llave",
+		CreadoEn:       ahora,
+	}
+	if err := credenciales.GuardarCredencialWebAuthn(primera); err != nil || primera.ID == 0 {
+		v.fallo(caso, "GuardarCredencialWebAuthn devolvió %v (ID %d)", err, primera.ID)
+		return
+	}
+	repetida := *primera
+	repetida.ID = 0
+	if err := credenciales.GuardarCredencialWebAuthn(&repetida); !errors.Is(err, repositorio.ErrUsuarioDuplicado) {
+		v.fallo(caso, "guardar el mismo CredencialID dos veces devolvió %v, se esperaba ErrUsuarioDuplicado", err)
+	}
+	segunda := &modelos.CredencialWebAuthn{UsuarioID: usuario.ID, CredencialID: []byte("otra"), ClavePublica: []byte("clave"), CreadoEn: ahora}
+	if err := credenciales.GuardarCredencialWebAuthn(segunda); err != nil {
+		v.fallo(caso, "GuardarCredencialWebAuthn devolvió %v", err)
+		return
+	}
+
+	lista, err := credenciales.CredencialesWebAuthnDeUsuario(usuario.ID)
+	if err != nil || len(lista) != 2 {
+		v.fallo(caso, "CredencialesWebAuthnDeUsuario devolvió %d credenciales y %v, se esperaban 2", len(lista), err)
+		return
+	}
+	leida := lista[0]
+	if leida.ID != primera.ID || string(leida.CredencialID) != string(primera.CredencialID) ||
+		string(leida.ClavePublica) != string(primera.ClavePublica) || leida.TipoAtestacion != "none" ||
+		strings.Join(leida.Transportes, " ") != "usb nfc" || leida.Contador != 5 || leida.Nombre != "Llave" || leida.UsadoEn != nil {
+		v.fallo(caso, "se esperaba %+v, se obtuvo %+v", *primera, leida)
+	}
+	if len(lista[1].Transportes) != 0 {
+		v.fallo(caso, "sin transportes se leyó %q", lista[1].Transportes)
+	}
+
+	// El contador tiene que avanzar; con los dos en cero (autenticadores sin contador) se acepta
+	for _, prueba := range []struct {
+		id       uint
+		contador uint32
+		esperado bool
+	}{{primera.ID, 5, false}, {primera.ID, 3, false}, {primera.ID, 6, true}, {primera.ID, 0, false},
+		{segunda.ID, 0, true}, {segunda.ID, 0, true}} {
+		if aceptado, err := credenciales.RegistrarUsoCredencialWebAuthn(prueba.id, prueba.contador, ahora); err != nil || aceptado != prueba.esperado {
+			v.fallo(caso, "RegistrarUsoCredencialWebAuthn(%d, %d) devolvió %v, %v; se esperaba %v", prueba.id, prueba.contador, aceptado, err, prueba.esperado)
+		}
+	}
+	if lista, err := credenciales.CredencialesWebAuthnDeUsuario(usuario.ID); err != nil || len(lista) == 0 || lista[0].Contador != 6 || lista[0].UsadoEn == nil {
+		v.fallo(caso, "el uso no quedó registrado (%v)", err)
+	}
+
+	// Solo el dueño puede borrarla
+	if err := credenciales.EliminarCredencialWebAuthn(usuario.ID+1000, primera.ID); !errors.Is(err, repositorio.ErrCredencialNoEncontrada) {
+		v.fallo(caso, "eliminar la credencial de otro usuario devolvió %v, se esperaba ErrCredencialNoEncontrada", err)
+	}
+	for _, credencial := range []*modelos.CredencialWebAuthn{primera, segunda} {
+		if err := credenciales.EliminarCredencialWebAuthn(usuario.ID, credencial.ID); err != nil {
+			v.fallo(caso, "EliminarCredencialWebAuthn devolvió %v", err)
+		}
+	}
+	if err := credenciales.EliminarCredencialWebAuthn(usuario.ID, primera.ID); !errors.Is(err, repositorio.ErrCredencialNoEncontrada) {
+		v.fallo(caso, "eliminar dos veces devolvió %v, se esperaba ErrCredencialNoEncontrada", err)
+	}
+	if _, err := credenciales.RegistrarUsoCredencialWebAuthn(primera.ID, 10, ahora); !errors.Is(err, repositorio.ErrCredencialNoEncontrada) {
+		v.fallo(caso, "RegistrarUsoCredencialWebAuthn sobre una credencial borrada devolvió %v", err)
+	}
+}
+
+// intentosLogin verifica los contadores de inicios de sesión fallidos
+func (v *verificador) intentosLogin(intentos repositorio.IntentoLoginRepositorio) {
+	const caso = "intentosLogin"
+	ahora := time.Now().UTC().Truncate(time.Second)
+	olvidar := ahora.Add(-15 * time.Minute)
+
+	if actual, err := intentos.BuscarIntentosLogin("ip:conf"); err != nil || actual.Fallos != 0 {
+		v.fallo(caso, "BuscarIntentosLogin sin fallos devolvió %+v, %v", actual, err)
+	}
+	for i := 1; i <= 3; i++ {
+		actual, err := intentos.RegistrarFalloLogin("ip:conf", ahora, olvidar)
+		if err != nil || actual.Fallos != i || !actual.UltimoFallo.Equal(ahora) {
+			v.fallo(caso, "RegistrarFalloLogin número %d devolvió %+v, %v", i, actual, err)
+		}
+	}
+	if _, err := intentos.RegistrarFalloLogin("cuenta:conf", ahora, olvidar); err != nil {
+		v.fallo(caso, "RegistrarFalloLogin devolvió %v", err)
+	}
+
+	// Los fallos anteriores a olvidarAntesDe ya no cuentan
+	despues := ahora.Add(time.Hour)
+	if actual, err := intentos.RegistrarFalloLogin("ip:conf", despues, despues.Add(-15*time.Minute)); err != nil || actual.Fallos != 1 {
+		v.fallo(caso, "RegistrarFalloLogin después del olvido devolvió %+v, %v; se esperaba 1 fallo", actual, err)
+	}
+
+	// La purga borra solo las claves viejas
+	if err := intentos.PurgarIntentosLogin(ahora.Add(time.Minute)); err != nil {
+		v.fallo(caso, "PurgarIntentosLogin devolvió %v", err)
+	}
+	if actual, _ := intentos.BuscarIntentosLogin("cuenta:conf"); actual != nil && actual.Fallos != 0 {
+		v.fallo(caso, "la clave vieja sigue después de purgar: %+v", actual)
+	}
+	if actual, _ := intentos.BuscarIntentosLogin("ip:conf"); actual == nil || actual.Fallos != 1 {
+		v.fallo(caso, "la purga borró una clave reciente: %+v", actual)
+	}
+
+	if err := intentos.ReiniciarIntentosLogin("ip:conf"); err != nil {
+		v.fallo(caso, "ReiniciarIntentosLogin devolvió %v", err)
+	}
+	if actual, err := intentos.BuscarIntentosLogin("ip:conf"); err != nil || actual.Fallos != 0 {
+		v.fallo(caso, "BuscarIntentosLogin después de reiniciar devolvió %+v, %v", actual, err)
+	}
+}
+
+// historialContrasenas verifica que se guarden las más nuevas y se recorte el resto
+func (v *verificador) historialContrasenas(historial repositorio.HistorialContrasenaRepositorio) {
+	const caso = "historial de contraseñas"
+	usuario, err := v.repo.BuscarPorNombre("conf_beto")
+	if err != nil {
+		v.fallo(caso, "BuscarPorNombre devolvió %v", err)
+		return
+	}
+
+	if hashes, err := historial.ContrasenasAnteriores(usuario.ID, 5); err != nil || len(hashes) != 0 {
+		v.fallo(caso, "ContrasenasAnteriores sin historial devolvió %v, %v", hashes, err)
+	}
+	ahora := time.Now().UTC().Truncate(time.Second)
+	for _, hash := range []string{"hash1", "hash2", "hash3", "hash4"} {
+		if err := historial.AgregarContrasenaAnterior(usuario.ID, hash, ahora, 3); err != nil {
+			v.fallo(caso, "AgregarContrasenaAnterior devolvió %v", err)
+		}
+	}
+	if hashes, err := historial.ContrasenasAnteriores(usuario.ID, 5); err != nil || fmt.Sprint(hashes) != "[hash4 hash3 hash2]" {
+		v.fallo(caso, "ContrasenasAnteriores devolvió %v, %v; se esperaba [hash4 hash3 hash2]", hashes, err)
+	}
+	if hashes, err := historial.ContrasenasAnteriores(usuario.ID, 2); err != nil || fmt.Sprint(hashes) != "[hash4 hash3]" {
+		v.fallo(caso, "ContrasenasAnteriores con cantidad 2 devolvió %v, %v", hashes, err)
+	}
+
+	// Conservar 0 (historial desactivado) borra todo
+	if err := historial.AgregarContrasenaAnterior(usuario.ID, "hash5", ahora, 0); err != nil {
+		v.fallo(caso, "AgregarContrasenaAnterior sin conservar devolvió %v", err)
+	}
+	if hashes, err := historial.ContrasenasAnteriores(usuario.ID, 5); err != nil || len(hashes) != 0 {
+		v.fallo(caso, "quedaron hashes después de conservar 0: %v, %v", hashes, err)
+	}
+}
+
+// fechasConZona verifica que las fechas con una zona horaria distinta de UTC se guarden
+// como el mismo instante: el resto de los casos usan UTC, y un backend que descarte la
+// zona correría los vencimientos por la diferencia horaria
+func (v *verificador) fechasConZona() {
+	const caso = "fechas con zona"
+	oeste := time.FixedZone("UTC-3", -3*60*60)
+	este := time.FixedZone("UTC+9", 9*60*60)
+	ahora := time.Now().UTC().Truncate(time.Second)
+
+	usuario := &modelos.Usuario{NombreUsuario: "conf_zona", Correo: "conf_zona@ejemplo.com", Contrasena: "hash-conf_zona", CreadoEn: ahora.In(oeste)}
+	if err := v.repo.Crear(usuario); err != nil {
+		v.fallo(caso, "Crear devolvió %v", err)
+		return
+	}
+	if encontrado, err := v.repo.BuscarPorID(usuario.ID); err != nil {
+		v.fallo(caso, "BuscarPorID devolvió %v", err)
+	} else {
+		v.compararUsuario(caso, usuario, encontrado)
+	}
+
+	if tokens, ok := v.repo.(repositorio.TokenRefrescoRepositorio); ok {
+		token := &modelos.TokenRefresco{UsuarioID: usuario.ID, Familia: "familia-zona", Hash: "hash-zona", CreadoEn: ahora.In(este), ExpiraEn: ahora.Add(time.Hour).In(este)}
+		if err := tokens.GuardarTokenRefresco(token); err != nil {
+			v.fallo(caso, "GuardarTokenRefresco devolvió %v", err)
+		} else if encontrado, err := tokens.BuscarTokenRefresco(token.Hash); err != nil || !encontrado.ExpiraEn.Equal(token.ExpiraEn) {
+			v.fallo(caso, "BuscarTokenRefresco devolvió %v; se esperaba que expire en %v (%v)", encontrado, token.ExpiraEn, err)
+		}
+	}
+
+	// Un token que vence en un minuto, guardado al oeste, no está vencido para una purga
+	// que mide la hora al este
+	if revocaciones, ok := v.repo.(repositorio.RevocacionRepositorio); ok {
+		if err := revocaciones.RevocarTokenAcceso("jti-conf-zona", usuario.ID, ahora.Add(time.Minute).In(oeste)); err != nil {
+			v.fallo(caso, "RevocarTokenAcceso devolvió %v", err)
+		}
+		if err := revocaciones.PurgarRevocacionesVencidas(ahora.In(este)); err != nil {
+			v.fallo(caso, "PurgarRevocacionesVencidas devolvió %v", err)
+		}
+		if revocado, err := revocaciones.TokenAccesoRevocado("jti-conf-zona"); err != nil || !revocado {
+			v.fallo(caso, "la purga borró un jti vigente guardado en otra zona (%v, %v)", revocado, err)
+		}
+	}
+
+	// Un fallo de hace un segundo, guardado al oeste, sigue contando para un olvido
+	// medido al este, y la purga no lo borra
+	if intentos, ok := v.repo.(repositorio.IntentoLoginRepositorio); ok {
+		if _, err := intentos.RegistrarFalloLogin("ip:conf-zona", ahora.In(oeste), ahora.Add(-time.Minute).In(oeste)); err != nil {
+			v.fallo(caso, "RegistrarFalloLogin devolvió %v", err)
+		}
+		despues := ahora.Add(time.Second).In(este)
+		if actual, err := intentos.RegistrarFalloLogin("ip:conf-zona", despues, despues.Add(-time.Minute)); err != nil || actual.Fallos != 2 {
+			v.fallo(caso, "RegistrarFalloLogin en otra zona devolvió %+v, %v; se esperaban 2 fallos", actual, err)
+		}
+		if err := intentos.PurgarIntentosLogin(ahora.Add(-time.Minute).In(este)); err != nil {
+			v.fallo(caso, "PurgarIntentosLogin devolvió %v", err)
+		}
+		if actual, err := intentos.BuscarIntentosLogin("ip:conf-zona"); err != nil || actual.Fallos != 2 {
+			v.fallo(caso, "la purga borró un fallo reciente guardado en otra zona: %+v, %v", actual, err)
+		}
+	}
+}
+
+// compararUsuario verifica que se lea lo mismo que se guardó, incluida la fecha
+func (v *verificador) compararUsuario(caso string, esperado, obtenido *modelos.Usuario) {
+	if obtenido.ID != esperado.ID || obtenido.NombreUsuario != esperado.NombreUsuario ||
+		obtenido.Correo != esperado.Correo || obtenido.Contrasena != esperado.Contrasena ||
+		obtenido.CorreoVerificado != esperado.CorreoVerificado {
+		v.fallo(caso, "se esperaba %+v, se obtuvo %+v", *esperado, *obtenido)
+	}
+	if diferencia := obtenido.CreadoEn.Sub(esperado.CreadoEn); diferencia > time.Second || diferencia < -time.Second {
+		v.fallo(caso, "creado_en se guardó como %v, se esperaba %v", obtenido.CreadoEn, esperado.CreadoEn)
+	}
+}
@@ -0,0 +1,20 @@
+package repositorio
+
+import "time"
+
+// RevocacionRepositorio guarda los tokens de acceso revocados antes de expirar.
+// Los tokens de acceso no se guardan al emitirse: solo se anota su jti al revocarlos
+// o, para revocar todos los de un usuario, el momento a partir del cual vuelven a valer.
+type RevocacionRepositorio interface {
+	// RevocarTokenAcceso anota el jti como revocado hasta que el token expire
+	RevocarTokenAcceso(jti string, usuarioID uint, expiraEn time.Time) error
+	// TokenAccesoRevocado indica si el jti fue revocado
+	TokenAccesoRevocado(jti string) (bool, error)
+	// RevocarTokensAccesoDeUsuario invalida todos los tokens del usuario emitidos hasta ese momento
+	RevocarTokensAccesoDeUsuario(usuarioID uint, momento time.Time) error
+	// TokensAccesoRevocadosDesde devuelve el momento de la última revocación del usuario,
+	// o la fecha cero si nunca se revocaron sus tokens
+	TokensAccesoRevocadosDesde(usuarioID uint) (time.Time, error)
+	// PurgarRevocacionesVencidas borra los jti de tokens que ya expiraron
+	PurgarRevocacionesVencidas(ahora time.Time) error
+}
@@ -0,0 +1,35 @@
+package repositorio
+
+import (
+	"errors"
+	"taller6/modelos"
+)
+
+// ErrRolNoEncontrado se devuelve al asignar un rol que no existe
+var ErrRolNoEncontrado = errors.New("rol no encontrado")
+
+// RolRepositorio define las operaciones sobre roles y su asignación a usuarios
+type RolRepositorio interface {
+	// ListarRoles devuelve todos los roles con sus permisos
+	ListarRoles() ([]modelos.Rol, error)
+	// RolesDeUsuario devuelve los roles asignados al usuario
+	RolesDeUsuario(usuarioID uint) ([]modelos.Rol, error)
+	// AsignarRoles reemplaza los roles del usuario por los indicados (por nombre).
+	// Devuelve ErrUsuarioNoEncontrado o ErrRolNoEncontrado si alguno no existe.
+	AsignarRoles(usuarioID uint, roles []string) error
+}
+
+// Repositorio reúne todas las operaciones de almacenamiento; lo implementan
+// tanto UsuarioRepositorioSQL como UsuarioRepositorioMemoria
+type Repositorio interface {
+	UsuarioRepositorio
+	RolRepositorio
+	TokenRefrescoRepositorio
+	RevocacionRepositorio
+	ClienteOIDCRepositorio
+	TokenUnUsoRepositorio
+	SegundoFactorRepositorio
+	CredencialWebAuthnRepositorio
+	IntentoLoginRepositorio
+	HistorialContrasenaRepositorio
+}
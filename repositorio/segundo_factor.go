@@ -0,0 +1,31 @@
+package repositorio
+
+import (
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// ErrSegundoFactorNoEncontrado indica que el usuario no inició la inscripción del segundo factor
+var ErrSegundoFactorNoEncontrado = errors.New("segundo factor no encontrado")
+
+// SegundoFactorRepositorio guarda el TOTP de cada usuario y sus códigos de recuperación
+type SegundoFactorRepositorio interface {
+	// GuardarSegundoFactor crea el segundo factor del usuario, reemplazando el que tuviera
+	GuardarSegundoFactor(factor *modelos.SegundoFactor) error
+	// BuscarSegundoFactor devuelve el segundo factor del usuario o ErrSegundoFactorNoEncontrado
+	BuscarSegundoFactor(usuarioID uint) (*modelos.SegundoFactor, error)
+	// ConfirmarSegundoFactor marca el segundo factor como activo
+	ConfirmarSegundoFactor(usuarioID uint, momento time.Time) error
+	// RegistrarPasoTOTP anota el paso del código aceptado solo si es posterior al último.
+	// Devuelve false si el código ya se había usado.
+	RegistrarPasoTOTP(usuarioID uint, paso int64) (bool, error)
+	// EliminarSegundoFactor borra el segundo factor y los códigos de recuperación
+	EliminarSegundoFactor(usuarioID uint) error
+	// ReemplazarCodigosRecuperacion borra los códigos del usuario y guarda estos hashes
+	ReemplazarCodigosRecuperacion(usuarioID uint, hashes []string) error
+	// UsarCodigoRecuperacion marca el código como usado si existe y no se había usado
+	UsarCodigoRecuperacion(usuarioID uint, hash string, momento time.Time) (bool, error)
+	// CodigosRecuperacionRestantes cuenta los códigos sin usar del usuario
+	CodigosRecuperacionRestantes(usuarioID uint) (int, error)
+}
@@ -0,0 +1,328 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"taller6/modelos"
+	"time"
+)
+
+// Formato en el que MySQL y SQLite devuelven las fechas como texto
+const formatoFechaSQL = "2006-01-02 15:04:05"
+
+// columnasUsuario son las columnas que lee escanearUsuario, en su orden
+const columnasUsuario = "id, nombre_usuario, correo, contrasena, creado_en, correo_verificado, eliminado_en"
+
+// UsuarioRepositorioSQL guarda los usuarios en una base SQL. Las consultas se
+// escriben una sola vez con "?"; lo que cambia entre motores está en "motorSQL".
+type UsuarioRepositorioSQL struct {
+	bd    *sql.DB
+	motor motorSQL
+	// indice es el índice de trigramas para BuscarTexto; nil en MySQL y PostgreSQL, que
+	// buscan en la base
+	indice *indiceUsuarios
+}
+
+// motorSQL describe las diferencias entre los motores soportados
+type motorSQL struct {
+	// traducirError convierte los errores propios del driver (por ejemplo
+	// las violaciones de UNIQUE) en los errores del paquete
+	traducirError func(error) error
+	// marcadoresNumerados reescribe los "?" como "$1, $2, ..." (PostgreSQL)
+	marcadoresNumerados bool
+	// usaReturning obtiene el ID con "RETURNING id" porque el driver no implementa LastInsertId
+	usaReturning bool
+	// fechasComoTexto compara las fechas con julianday() porque se guardan como texto
+	// con la zona horaria de quien las escribió, y el texto no ordena bien entre zonas (SQLite)
+	fechasComoTexto bool
+	// trigramas busca el texto con pg_trgm en lugar de FULLTEXT o del índice del proceso (PostgreSQL)
+	trigramas bool
+}
+
+// Crear inserta un usuario nuevo en la tabla usuarios
+func (r *UsuarioRepositorioSQL) Crear(usuario *modelos.Usuario) error {
+	// Verificar si el usuario o correo ya existen; los eliminados también cuentan, como en el UNIQUE
+	var existeUsuario int
+	consultaVerificacion := `SELECT COUNT(*) FROM usuarios WHERE nombre_usuario = ? OR correo = ?`
+	err := r.queryRow(consultaVerificacion, usuario.NombreUsuario, usuario.Correo).Scan(&existeUsuario)
+	if err != nil {
+		return err
+	}
+	if existeUsuario > 0 {
+		return ErrUsuarioDuplicado
+	}
+
+	if usuario.CreadoEn.IsZero() {
+		usuario.CreadoEn = time.Now()
+	}
+
+	consulta := `INSERT INTO usuarios (nombre_usuario, correo, contrasena, creado_en, correo_verificado) VALUES (?, ?, ?, ?, ?)`
+	id, err := r.insertar(consulta, usuario.NombreUsuario, usuario.Correo, usuario.Contrasena, usuario.CreadoEn, usuario.CorreoVerificado)
+	if err != nil {
+		return err
+	}
+	usuario.ID = id
+	r.indexar(usuario)
+	return nil
+}
+
+// BuscarPorID trae un usuario por su ID
+func (r *UsuarioRepositorioSQL) BuscarPorID(id uint) (*modelos.Usuario, error) {
+	consulta := `SELECT ` + columnasUsuario + ` FROM usuarios WHERE id = ? AND eliminado_en IS NULL`
+	return escanearUsuario(r.queryRow(consulta, id))
+}
+
+// BuscarPorNombre trae un usuario por su nombre de usuario
+func (r *UsuarioRepositorioSQL) BuscarPorNombre(nombreUsuario string) (*modelos.Usuario, error) {
+	consulta := `SELECT ` + columnasUsuario + ` FROM usuarios WHERE nombre_usuario = ? AND eliminado_en IS NULL`
+	return escanearUsuario(r.queryRow(consulta, nombreUsuario))
+}
+
+// BuscarPorCorreo trae un usuario por su correo
+func (r *UsuarioRepositorioSQL) BuscarPorCorreo(correo string) (*modelos.Usuario, error) {
+	consulta := `SELECT ` + columnasUsuario + ` FROM usuarios WHERE correo = ? AND eliminado_en IS NULL`
+	return escanearUsuario(r.queryRow(consulta, correo))
+}
+
+// Listar trae todos los usuarios activos de la tabla
+func (r *UsuarioRepositorioSQL) Listar() ([]modelos.Usuario, error) {
+	rows, err := r.query("SELECT " + columnasUsuario + " FROM usuarios WHERE eliminado_en IS NULL ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usuarios []modelos.Usuario
+	for rows.Next() {
+		usuario, err := escanearUsuario(rows)
+		if err != nil {
+			return nil, err
+		}
+		usuarios = append(usuarios, *usuario)
+	}
+	return usuarios, rows.Err()
+}
+
+// Actualizar construye la consulta de actualización solo con los campos presentes
+func (r *UsuarioRepositorioSQL) Actualizar(id uint, cambios modelos.Usuario) error {
+	consulta := "UPDATE usuarios SET "
+	args := []interface{}{}
+
+	if cambios.NombreUsuario != "" {
+		consulta += "nombre_usuario = ?, "
+		args = append(args, cambios.NombreUsuario)
+	}
+	if cambios.Correo != "" {
+		// Un correo distinto hay que volver a verificarlo. Va antes de "correo = ?"
+		// porque MySQL evalúa el SET de izquierda a derecha.
+		consulta += "correo_verificado = CASE WHEN correo = ? THEN correo_verificado ELSE FALSE END, correo = ?, "
+		args = append(args, cambios.Correo, cambios.Correo)
+	}
+	if cambios.Contrasena != "" {
+		consulta += "contrasena = ?, "
+		args = append(args, cambios.Contrasena)
+	}
+
+	// Si no hay nada para cambiar solo verificamos que el usuario exista
+	if len(args) == 0 {
+		_, err := r.BuscarPorID(id)
+		return err
+	}
+
+	// Eliminar la última coma y espacio
+	consulta = consulta[:len(consulta)-2]
+	consulta += " WHERE id = ? AND eliminado_en IS NULL"
+	args = append(args, id)
+
+	resultado, err := r.exec(consulta, args...)
+	if err != nil {
+		return r.motor.traducirError(err)
+	}
+	// MySQL informa 0 filas afectadas si los valores no cambiaron, así que confirmamos que exista
+	if filas, err := resultado.RowsAffected(); err == nil && filas == 0 {
+		_, err := r.BuscarPorID(id)
+		return err
+	}
+	if cambios.NombreUsuario != "" || cambios.Correo != "" {
+		r.reindexar(id)
+	}
+	return nil
+}
+
+// VerificarCorreo marca el correo como verificado solo si el usuario todavía tiene ese correo
+func (r *UsuarioRepositorioSQL) VerificarCorreo(id uint, correo string) (bool, error) {
+	resultado, err := r.exec(`UPDATE usuarios SET correo_verificado = TRUE WHERE id = ? AND correo = ? AND eliminado_en IS NULL`, id, correo)
+	if err != nil {
+		return false, err
+	}
+	// Como en Actualizar, MySQL informa 0 filas si ya estaba verificado
+	if filas, err := resultado.RowsAffected(); err == nil && filas == 0 {
+		usuario, err := r.BuscarPorID(id)
+		if err != nil {
+			return false, err
+		}
+		return usuario.Correo == correo && usuario.CorreoVerificado, nil
+	}
+	return true, nil
+}
+
+// ReemplazarContrasena actualiza con la contraseña anterior en el WHERE, así un cambio
+// simultáneo no se pisa
+func (r *UsuarioRepositorioSQL) ReemplazarContrasena(id uint, anterior, nueva string) (bool, error) {
+	resultado, err := r.exec(`UPDATE usuarios SET contrasena = ? WHERE id = ? AND contrasena = ? AND eliminado_en IS NULL`, nueva, id, anterior)
+	if err != nil {
+		return false, err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return filas > 0, nil
+}
+
+// Eliminar marca la fecha de baja del usuario; las filas asociadas quedan hasta la purga
+func (r *UsuarioRepositorioSQL) Eliminar(id uint) error {
+	resultado, err := r.exec(`UPDATE usuarios SET eliminado_en = ? WHERE id = ? AND eliminado_en IS NULL`, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if filas, err := resultado.RowsAffected(); err == nil && filas == 0 {
+		return ErrUsuarioNoEncontrado
+	}
+	r.desindexar(id)
+	return nil
+}
+
+// Restaurar borra la fecha de baja del usuario
+func (r *UsuarioRepositorioSQL) Restaurar(id uint) error {
+	resultado, err := r.exec(`UPDATE usuarios SET eliminado_en = NULL WHERE id = ? AND eliminado_en IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	if filas, err := resultado.RowsAffected(); err == nil && filas == 0 {
+		return ErrUsuarioNoEncontrado
+	}
+	r.reindexar(id)
+	return nil
+}
+
+// PurgarEliminados borra las filas de los usuarios; lo asociado se va con ON DELETE CASCADE
+func (r *UsuarioRepositorioSQL) PurgarEliminados(antesDe time.Time) (int, error) {
+	resultado, err := r.exec(`DELETE FROM usuarios WHERE eliminado_en IS NOT NULL AND `+r.fecha("eliminado_en")+` < `+r.fecha("?"), antesDe)
+	if err != nil {
+		return 0, err
+	}
+	filas, err := resultado.RowsAffected()
+	return int(filas), err
+}
+
+// insertar ejecuta un INSERT y devuelve el ID generado, con "RETURNING id" en
+// los motores cuyo driver no implementa LastInsertId
+func (r *UsuarioRepositorioSQL) insertar(consulta string, args ...interface{}) (uint, error) {
+	if r.motor.usaReturning {
+		var id int64
+		if err := r.queryRow(consulta+" RETURNING id", args...).Scan(&id); err != nil {
+			return 0, r.motor.traducirError(err)
+		}
+		return uint(id), nil
+	}
+
+	resultado, err := r.exec(consulta, args...)
+	if err != nil {
+		return 0, r.motor.traducirError(err)
+	}
+
+	// Obtener el ID de la fila recién insertada
+	id, err := resultado.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+// exec ejecuta una sentencia adaptando los marcadores al motor
+func (r *UsuarioRepositorioSQL) exec(consulta string, args ...interface{}) (sql.Result, error) {
+	return r.bd.Exec(r.adaptar(consulta), args...)
+}
+
+// query ejecuta una consulta adaptando los marcadores al motor
+func (r *UsuarioRepositorioSQL) query(consulta string, args ...interface{}) (*sql.Rows, error) {
+	return r.bd.Query(r.adaptar(consulta), args...)
+}
+
+// queryRow ejecuta una consulta de una fila adaptando los marcadores al motor
+func (r *UsuarioRepositorioSQL) queryRow(consulta string, args ...interface{}) *sql.Row {
+	return r.bd.QueryRow(r.adaptar(consulta), args...)
+}
+
+// adaptar reescribe los "?" como "$1, $2, ..." en los motores que lo necesitan.
+// Las consultas de este paquete no tienen "?" dentro de textos.
+func (r *UsuarioRepositorioSQL) adaptar(consulta string) string {
+	if !r.motor.marcadoresNumerados {
+		return consulta
+	}
+	var resultado strings.Builder
+	n := 0
+	for _, caracter := range consulta {
+		if caracter == '?' {
+			n++
+			resultado.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		resultado.WriteRune(caracter)
+	}
+	return resultado.String()
+}
+
+// escaner abarca tanto *sql.Row como *sql.Rows
+type escaner interface {
+	Scan(dest ...interface{}) error
+}
+
+// escanearUsuario lee una fila de usuario
+func escanearUsuario(fila escaner) (*modelos.Usuario, error) {
+	var usuario modelos.Usuario
+	var eliminadoEn time.Time
+	err := fila.Scan(&usuario.ID, &usuario.NombreUsuario, &usuario.Correo, &usuario.Contrasena, (*fechaSQL)(&usuario.CreadoEn),
+		&usuario.CorreoVerificado, (*fechaSQL)(&eliminadoEn))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUsuarioNoEncontrado
+		}
+		return nil, err
+	}
+	// fechaSQL deja la fecha en cero si la columna es NULL
+	if !eliminadoEn.IsZero() {
+		usuario.EliminadoEn = &eliminadoEn
+	}
+	return &usuario, nil
+}
+
+// fechaSQL escanea fechas que los drivers devuelven como time.Time (PostgreSQL,
+// SQLite) o como texto (MySQL sin parseTime devuelve "2006-01-02 15:04:05")
+type fechaSQL time.Time
+
+// Scan implementa sql.Scanner
+func (f *fechaSQL) Scan(valor interface{}) error {
+	switch v := valor.(type) {
+	case time.Time:
+		*f = fechaSQL(v)
+	case []byte:
+		return f.Scan(string(v))
+	case string:
+		// Convertimos la cadena a time.Time porque en uint rompia
+		fecha, err := time.Parse(formatoFechaSQL, v)
+		if err != nil {
+			return err
+		}
+		*f = fechaSQL(fecha)
+	case nil:
+		*f = fechaSQL(time.Time{})
+	default:
+		return fmt.Errorf("tipo de fecha no soportado: %T", valor)
+	}
+	return nil
+}
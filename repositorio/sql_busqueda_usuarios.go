@@ -0,0 +1,238 @@
+package repositorio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"taller6/busqueda"
+	"taller6/modelos"
+)
+
+// indiceUsuarios es el índice de trigramas de SQLite, que no tiene búsqueda de texto que
+// sirva para partes de palabras. Se carga con los usuarios activos en la primera búsqueda
+// y después lo mantienen las escrituras de este proceso: lo que cambien otros procesos
+// sobre la misma base no se ve hasta reiniciar. SQLite es de una sola instancia, así que
+// no debería pasar. Los resultados se vuelven a leer de la base, así que un usuario
+// eliminado nunca aparece.
+type indiceUsuarios struct {
+	mu      sync.Mutex
+	cargado bool
+	indice  *busqueda.Indice
+}
+
+// BuscarTexto usa pg_trgm en PostgreSQL, el índice FULLTEXT en MySQL y el índice de
+// trigramas del proceso en SQLite
+func (r *UsuarioRepositorioSQL) BuscarTexto(terminos []string, limite int) ([]UsuarioEncontrado, error) {
+	if len(terminos) == 0 {
+		return []UsuarioEncontrado{}, nil
+	}
+	if r.motor.trigramas {
+		return r.buscarTrigramas(terminos, limite)
+	}
+	if r.indice == nil {
+		return r.buscarFullText(terminos, limite)
+	}
+
+	indice, err := r.cargarIndice()
+	if err != nil {
+		return nil, err
+	}
+	resultados := indice.Buscar(terminos, limite)
+	if len(resultados) == 0 {
+		return []UsuarioEncontrado{}, nil
+	}
+
+	ids := make([]interface{}, len(resultados))
+	for i, resultado := range resultados {
+		ids[i] = resultado.ID
+	}
+	marcadores := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	rows, err := r.query("SELECT "+columnasUsuario+" FROM usuarios WHERE id IN ("+marcadores+") AND eliminado_en IS NULL", ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	usuarios := make(map[uint]modelos.Usuario, len(ids))
+	for rows.Next() {
+		usuario, err := escanearUsuario(rows)
+		if err != nil {
+			return nil, err
+		}
+		usuarios[usuario.ID] = *usuario
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Se respeta el orden del índice
+	encontrados := make([]UsuarioEncontrado, 0, len(resultados))
+	for _, resultado := range resultados {
+		if usuario, existe := usuarios[resultado.ID]; existe {
+			encontrados = append(encontrados, UsuarioEncontrado{Usuario: usuario, Puntaje: resultado.Puntaje})
+		}
+	}
+	return encontrados, nil
+}
+
+// buscarFullText busca con el índice FULLTEXT (parser ngram) de la migración 0013. Cada
+// término va entre comillas y con "+": con ngram una frase encuentra la secuencia en
+// cualquier parte de la palabra, y el "+" exige que estén todos. Las mayúsculas y las
+// tildes las ignora la colación de la tabla (utf8mb4_0900_ai_ci por defecto en MySQL 8).
+func (r *UsuarioRepositorioSQL) buscarFullText(terminos []string, limite int) ([]UsuarioEncontrado, error) {
+	// Los términos solo tienen letras y dígitos (ver busqueda.Terminos), no hay nada que escapar
+	var expresion strings.Builder
+	for _, termino := range terminos {
+		fmt.Fprintf(&expresion, `+"%s" `, termino)
+	}
+
+	consulta := `SELECT ` + columnasUsuario + `, MATCH(nombre_usuario, correo) AGAINST (? IN BOOLEAN MODE) AS puntaje
+		FROM usuarios WHERE MATCH(nombre_usuario, correo) AGAINST (? IN BOOLEAN MODE) AND eliminado_en IS NULL
+		ORDER BY puntaje DESC, id LIMIT ?`
+	rows, err := r.query(consulta, expresion.String(), expresion.String(), limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	encontrados := make([]UsuarioEncontrado, 0)
+	for rows.Next() {
+		var puntaje float64
+		usuario, err := escanearUsuario(conPuntaje{fila: rows, puntaje: &puntaje})
+		if err != nil {
+			return nil, err
+		}
+		encontrados = append(encontrados, UsuarioEncontrado{Usuario: *usuario, Puntaje: puntaje})
+	}
+	return encontrados, rows.Err()
+}
+
+// buscarTrigramas busca con pg_trgm sobre normalizar_busqueda() (migración 0015), que pasa
+// el texto a minúsculas y le saca las tildes como busqueda.Normalizar. El puntaje de cada
+// término es su word_similarity con el campo que más se le parece, y tiene que llegar a
+// busqueda.UmbralSimilitud, igual que en el índice del proceso. El filtro "<%" sobre el
+// nombre y el correo juntos (nunca se parecen menos que cada uno por separado) es el que
+// usa el índice GIN; su umbral se configura solo para esta transacción.
+func (r *UsuarioRepositorioSQL) buscarTrigramas(terminos []string, limite int) ([]UsuarioEncontrado, error) {
+	tx, err := r.bd.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	umbral := strconv.FormatFloat(busqueda.UmbralSimilitud, 'f', -1, 64)
+	if _, err := tx.Exec(r.adaptar(`SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)`), umbral); err != nil {
+		return nil, err
+	}
+
+	// parecidoN es el puntaje del término N; la consulta de afuera exige el umbral en todos
+	// y los promedia
+	var parecidos, filtros, condiciones, suma []string
+	var args, argsFiltros []interface{}
+	for i, termino := range terminos {
+		columna := fmt.Sprintf("parecido%d", i)
+		parecidos = append(parecidos, `GREATEST(word_similarity(?, normalizar_busqueda(nombre_usuario)), word_similarity(?, normalizar_busqueda(correo))) AS `+columna)
+		args = append(args, termino, termino)
+		filtros = append(filtros, `? <% normalizar_busqueda(nombre_usuario || ' ' || correo)`)
+		argsFiltros = append(argsFiltros, termino)
+		condiciones = append(condiciones, columna+` >= `+umbral)
+		suma = append(suma, columna)
+	}
+	args = append(append(args, argsFiltros...), limite)
+
+	consulta := `SELECT ` + columnasUsuario + `, (` + strings.Join(suma, " + ") + `) / ` + strconv.Itoa(len(terminos)) + ` AS puntaje
+		FROM (SELECT ` + columnasUsuario + `, ` + strings.Join(parecidos, ", ") + `
+			FROM usuarios WHERE eliminado_en IS NULL AND ` + strings.Join(filtros, " AND ") + `) AS candidatos
+		WHERE ` + strings.Join(condiciones, " AND ") + `
+		ORDER BY puntaje DESC, id LIMIT ?`
+	rows, err := tx.Query(r.adaptar(consulta), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	encontrados := make([]UsuarioEncontrado, 0)
+	for rows.Next() {
+		var puntaje float64
+		usuario, err := escanearUsuario(conPuntaje{fila: rows, puntaje: &puntaje})
+		if err != nil {
+			return nil, err
+		}
+		encontrados = append(encontrados, UsuarioEncontrado{Usuario: *usuario, Puntaje: puntaje})
+	}
+	return encontrados, rows.Err()
+}
+
+// conPuntaje agrega la columna del puntaje al final de una fila de usuario
+type conPuntaje struct {
+	fila    escaner
+	puntaje *float64
+}
+
+// Scan implementa escaner
+func (c conPuntaje) Scan(dest ...interface{}) error {
+	return c.fila.Scan(append(dest, c.puntaje)...)
+}
+
+// cargarIndice devuelve el índice de trigramas, armándolo con todos los usuarios la
+// primera vez. Si falla se vuelve a intentar en la próxima búsqueda. El lock se tiene
+// durante toda la carga: una escritura que termina mientras se lee la tabla espera en
+// mantenerIndice y se aplica sobre el índice ya cargado, así no se pierde.
+func (r *UsuarioRepositorioSQL) cargarIndice() (*busqueda.Indice, error) {
+	r.indice.mu.Lock()
+	defer r.indice.mu.Unlock()
+
+	if !r.indice.cargado {
+		usuarios, err := r.Listar()
+		if err != nil {
+			return nil, err
+		}
+		r.indice.indice = busqueda.NuevoIndice()
+		for _, usuario := range usuarios {
+			r.indice.indice.Agregar(usuario.ID, usuario.NombreUsuario, usuario.Correo)
+		}
+		r.indice.cargado = true
+	}
+	return r.indice.indice, nil
+}
+
+// mantenerIndice aplica un cambio al índice de trigramas con el lock tomado. Si todavía
+// no se cargó no hace nada: la carga, que empieza después, ya lee los datos nuevos.
+func (r *UsuarioRepositorioSQL) mantenerIndice(cambio func(indice *busqueda.Indice)) {
+	if r.indice == nil {
+		return
+	}
+	r.indice.mu.Lock()
+	defer r.indice.mu.Unlock()
+	if r.indice.cargado {
+		cambio(r.indice.indice)
+	}
+}
+
+// indexar agrega o actualiza el usuario en el índice de trigramas
+func (r *UsuarioRepositorioSQL) indexar(usuario *modelos.Usuario) {
+	r.mantenerIndice(func(indice *busqueda.Indice) {
+		indice.Agregar(usuario.ID, usuario.NombreUsuario, usuario.Correo)
+	})
+}
+
+// reindexar vuelve a leer el usuario y lo actualiza en el índice de trigramas. La lectura
+// va con el lock tomado para que dos cambios seguidos no se apliquen en el orden inverso.
+func (r *UsuarioRepositorioSQL) reindexar(id uint) {
+	r.mantenerIndice(func(indice *busqueda.Indice) {
+		usuario, err := r.BuscarPorID(id)
+		switch {
+		case err == nil:
+			indice.Agregar(usuario.ID, usuario.NombreUsuario, usuario.Correo)
+		case errors.Is(err, ErrUsuarioNoEncontrado):
+			indice.Quitar(id)
+		}
+	})
+}
+
+// desindexar saca al usuario del índice de trigramas
+func (r *UsuarioRepositorioSQL) desindexar(id uint) {
+	r.mantenerIndice(func(indice *busqueda.Indice) {
+		indice.Quitar(id)
+	})
+}
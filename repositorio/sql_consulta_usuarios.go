@@ -0,0 +1,133 @@
+package repositorio
+
+import (
+	"fmt"
+	"strings"
+	"taller6/modelos"
+)
+
+// ListarPagina arma la consulta con el filtro y la condición del cursor. Con un índice
+// sobre el campo de orden, cada página cuesta lo mismo sin importar cuántas van.
+func (r *UsuarioRepositorioSQL) ListarPagina(consulta ConsultaUsuarios) ([]modelos.Usuario, error) {
+	campo := consulta.campoOrden()
+	if !OrdenValido(campo) {
+		return nil, fmt.Errorf("campo de orden inválido: %q", campo)
+	}
+	condiciones, args := r.condicionesFiltro(consulta.Filtro)
+
+	columna, direccion, comparacion := r.expresionOrden(campo), "ASC", ">"
+	if consulta.Descendente {
+		direccion, comparacion = "DESC", "<"
+	}
+	if despues := consulta.Despues; despues != nil {
+		if campo == OrdenID {
+			condiciones = append(condiciones, "id "+comparacion+" ?")
+			args = append(args, despues.ID)
+		} else {
+			// (campo, id) > (valor, id) escrito a mano, que lo entienden todos los motores
+			valor := r.marcadorOrden(campo)
+			condiciones = append(condiciones, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s ?))",
+				columna, comparacion, valor, columna, valor, comparacion))
+			args = append(args, valorOrden(campo, *despues), valorOrden(campo, *despues), despues.ID)
+		}
+	}
+
+	sentencia := "SELECT " + columnasUsuario + " FROM usuarios WHERE " + strings.Join(condiciones, " AND ")
+	if campo == OrdenID {
+		sentencia += " ORDER BY id " + direccion
+	} else {
+		sentencia += fmt.Sprintf(" ORDER BY %s %s, id %s", columna, direccion, direccion)
+	}
+	sentencia += " LIMIT ?"
+	args = append(args, consulta.Limite)
+
+	rows, err := r.query(sentencia, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usuarios []modelos.Usuario
+	for rows.Next() {
+		usuario, err := escanearUsuario(rows)
+		if err != nil {
+			return nil, err
+		}
+		usuarios = append(usuarios, *usuario)
+	}
+	return usuarios, rows.Err()
+}
+
+// Contar cuenta los usuarios que cumplen el filtro
+func (r *UsuarioRepositorioSQL) Contar(filtro FiltroUsuarios) (int, error) {
+	condiciones, args := r.condicionesFiltro(filtro)
+	sentencia := "SELECT COUNT(*) FROM usuarios WHERE " + strings.Join(condiciones, " AND ")
+	var total int
+	err := r.queryRow(sentencia, args...).Scan(&total)
+	return total, err
+}
+
+// condicionesFiltro arma las condiciones del WHERE; siempre hay al menos la de los
+// eliminados. Los prefijos se comparan en minúsculas porque LIKE distingue mayúsculas
+// en PostgreSQL y no en MySQL ni SQLite.
+func (r *UsuarioRepositorioSQL) condicionesFiltro(filtro FiltroUsuarios) ([]string, []interface{}) {
+	condiciones := []string{"eliminado_en IS NULL"}
+	if filtro.Eliminados {
+		condiciones = []string{"eliminado_en IS NOT NULL"}
+	}
+	var args []interface{}
+	if filtro.PrefijoNombre != "" {
+		condiciones = append(condiciones, "LOWER(nombre_usuario) LIKE ? ESCAPE '!'")
+		args = append(args, escaparLike(strings.ToLower(filtro.PrefijoNombre))+"%")
+	}
+	if filtro.PrefijoCorreo != "" {
+		condiciones = append(condiciones, "LOWER(correo) LIKE ? ESCAPE '!'")
+		args = append(args, escaparLike(strings.ToLower(filtro.PrefijoCorreo))+"%")
+	}
+	if !filtro.CreadoDesde.IsZero() {
+		condiciones = append(condiciones, r.fecha("creado_en")+" >= "+r.fecha("?"))
+		args = append(args, filtro.CreadoDesde)
+	}
+	if !filtro.CreadoHasta.IsZero() {
+		condiciones = append(condiciones, r.fecha("creado_en")+" < "+r.fecha("?"))
+		args = append(args, filtro.CreadoHasta)
+	}
+	return condiciones, args
+}
+
+// fecha envuelve una columna o un marcador de fecha para compararlo (ver fechasComoTexto)
+func (r *UsuarioRepositorioSQL) fecha(expresion string) string {
+	if r.motor.fechasComoTexto {
+		return "julianday(" + expresion + ")"
+	}
+	return expresion
+}
+
+// expresionOrden es la columna del campo de orden tal como se compara y se ordena
+func (r *UsuarioRepositorioSQL) expresionOrden(campo string) string {
+	if campo == OrdenCreadoEn {
+		return r.fecha(campo)
+	}
+	return campo
+}
+
+// marcadorOrden es el marcador del valor del cursor, comparable con expresionOrden
+func (r *UsuarioRepositorioSQL) marcadorOrden(campo string) string {
+	if campo == OrdenCreadoEn {
+		return r.fecha("?")
+	}
+	return "?"
+}
+
+// valorOrden devuelve el valor del usuario en el campo de orden
+func valorOrden(campo string, usuario modelos.Usuario) interface{} {
+	switch campo {
+	case OrdenNombreUsuario:
+		return usuario.NombreUsuario
+	case OrdenCorreo:
+		return usuario.Correo
+	case OrdenCreadoEn:
+		return usuario.CreadoEn
+	}
+	return usuario.ID
+}
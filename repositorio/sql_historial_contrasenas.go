@@ -0,0 +1,67 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ContrasenasAnteriores trae los hashes más nuevos. Se ordena por id y no por fecha
+// porque dos cambios en el mismo segundo tendrían la misma creado_en.
+func (r *UsuarioRepositorioSQL) ContrasenasAnteriores(usuarioID uint, cantidad int) ([]string, error) {
+	if cantidad <= 0 {
+		return nil, nil
+	}
+	filas, err := r.query(`SELECT hash FROM historial_contrasenas WHERE usuario_id = ? ORDER BY id DESC LIMIT ?`, usuarioID, cantidad)
+	if err != nil {
+		return nil, err
+	}
+	defer filas.Close()
+
+	var hashes []string
+	for filas.Next() {
+		var hash string
+		if err := filas.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, filas.Err()
+}
+
+// AgregarContrasenaAnterior inserta el hash y borra lo que sobra en la misma transacción.
+// MySQL no acepta LIMIT dentro de un IN, así que primero se busca el id más viejo que
+// se conserva y después se borra todo lo anterior.
+func (r *UsuarioRepositorioSQL) AgregarContrasenaAnterior(usuarioID uint, hash string, momento time.Time, conservar int) error {
+	tx, err := r.bd.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if conservar <= 0 {
+		if _, err := tx.Exec(r.adaptar(`DELETE FROM historial_contrasenas WHERE usuario_id = ?`), usuarioID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if _, err := tx.Exec(r.adaptar(`INSERT INTO historial_contrasenas (usuario_id, hash, creado_en) VALUES (?, ?, ?)`),
+		usuarioID, hash, momento); err != nil {
+		return r.motor.traducirError(err)
+	}
+	var limite int64
+	err = tx.QueryRow(r.adaptar(`SELECT id FROM historial_contrasenas WHERE usuario_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?`),
+		usuarioID, conservar-1).Scan(&limite)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Todavía hay menos de conservar
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(r.adaptar(`DELETE FROM historial_contrasenas WHERE usuario_id = ? AND id < ?`), usuarioID, limite); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
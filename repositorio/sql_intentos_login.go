@@ -0,0 +1,55 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// BuscarIntentosLogin trae los fallos de la clave
+func (r *UsuarioRepositorioSQL) BuscarIntentosLogin(clave string) (*modelos.IntentosLogin, error) {
+	intentos := modelos.IntentosLogin{Clave: clave}
+	err := r.queryRow(`SELECT fallos, ultimo_fallo FROM intentos_login WHERE clave = ?`, clave).
+		Scan(&intentos.Fallos, (*fechaSQL)(&intentos.UltimoFallo))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return &intentos, nil
+}
+
+// RegistrarFalloLogin suma el fallo con un UPDATE, así los pedidos simultáneos no se
+// pisan. Si no hay fila la inserta y, si otro pedido la insertó en el medio, vuelve a
+// actualizar, igual que RevocarTokensAccesoDeUsuario.
+func (r *UsuarioRepositorioSQL) RegistrarFalloLogin(clave string, momento, olvidarAntesDe time.Time) (*modelos.IntentosLogin, error) {
+	actualizar := `UPDATE intentos_login SET fallos = CASE WHEN ` + r.fecha("ultimo_fallo") + ` < ` + r.fecha("?") + ` THEN 1 ELSE fallos + 1 END, ultimo_fallo = ? WHERE clave = ?`
+	resultado, err := r.exec(actualizar, olvidarAntesDe, momento, clave)
+	if err != nil {
+		return nil, err
+	}
+	// fallos siempre cambia, así que MySQL también cuenta la fila
+	if filas, err := resultado.RowsAffected(); err != nil {
+		return nil, err
+	} else if filas == 0 {
+		_, err = r.exec(`INSERT INTO intentos_login (clave, fallos, ultimo_fallo) VALUES (?, 1, ?)`, clave, momento)
+		if err != nil && errors.Is(r.motor.traducirError(err), ErrUsuarioDuplicado) {
+			_, err = r.exec(actualizar, olvidarAntesDe, momento, clave)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.BuscarIntentosLogin(clave)
+}
+
+// ReiniciarIntentosLogin borra la fila de la clave
+func (r *UsuarioRepositorioSQL) ReiniciarIntentosLogin(clave string) error {
+	_, err := r.exec(`DELETE FROM intentos_login WHERE clave = ?`, clave)
+	return err
+}
+
+// PurgarIntentosLogin borra los fallos viejos, que ya no bloquean a nadie
+func (r *UsuarioRepositorioSQL) PurgarIntentosLogin(antesDe time.Time) error {
+	_, err := r.exec(`DELETE FROM intentos_login WHERE `+r.fecha("ultimo_fallo")+` < `+r.fecha("?"), antesDe)
+	return err
+}
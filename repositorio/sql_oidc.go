@@ -0,0 +1,125 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"taller6/modelos"
+	"time"
+)
+
+// CrearClienteOIDC inserta el cliente con sus redirect URI separadas por espacios
+func (r *UsuarioRepositorioSQL) CrearClienteOIDC(cliente *modelos.ClienteOIDC) error {
+	if cliente.CreadoEn.IsZero() {
+		cliente.CreadoEn = time.Now()
+	}
+	consulta := `INSERT INTO clientes_oidc (cliente_id, nombre, secreto_hash, redirect_uris, creado_en) VALUES (?, ?, ?, ?, ?)`
+	id, err := r.insertar(consulta, cliente.ClienteID, cliente.Nombre, cliente.SecretoHash, strings.Join(cliente.RedirectURIs, " "), cliente.CreadoEn)
+	if err != nil {
+		return err
+	}
+	cliente.ID = id
+	return nil
+}
+
+// BuscarClienteOIDC trae un cliente por su client_id
+func (r *UsuarioRepositorioSQL) BuscarClienteOIDC(clienteID string) (*modelos.ClienteOIDC, error) {
+	consulta := `SELECT id, cliente_id, nombre, secreto_hash, redirect_uris, creado_en FROM clientes_oidc WHERE cliente_id = ?`
+	cliente, err := escanearClienteOIDC(r.queryRow(consulta, clienteID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrClienteNoEncontrado
+	}
+	return cliente, err
+}
+
+// ListarClientesOIDC trae todos los clientes
+func (r *UsuarioRepositorioSQL) ListarClientesOIDC() ([]modelos.ClienteOIDC, error) {
+	rows, err := r.query(`SELECT id, cliente_id, nombre, secreto_hash, redirect_uris, creado_en FROM clientes_oidc ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clientes []modelos.ClienteOIDC
+	for rows.Next() {
+		cliente, err := escanearClienteOIDC(rows)
+		if err != nil {
+			return nil, err
+		}
+		clientes = append(clientes, *cliente)
+	}
+	return clientes, rows.Err()
+}
+
+// EliminarClienteOIDC borra el cliente; sus códigos se borran por ON DELETE CASCADE
+func (r *UsuarioRepositorioSQL) EliminarClienteOIDC(clienteID string) error {
+	resultado, err := r.exec(`DELETE FROM clientes_oidc WHERE cliente_id = ?`, clienteID)
+	if err != nil {
+		return err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if filas == 0 {
+		return ErrClienteNoEncontrado
+	}
+	return nil
+}
+
+// escanearClienteOIDC lee una fila de clientes_oidc
+func escanearClienteOIDC(fila escaner) (*modelos.ClienteOIDC, error) {
+	var cliente modelos.ClienteOIDC
+	var redirectURIs string
+	err := fila.Scan(&cliente.ID, &cliente.ClienteID, &cliente.Nombre, &cliente.SecretoHash, &redirectURIs, (*fechaSQL)(&cliente.CreadoEn))
+	if err != nil {
+		return nil, err
+	}
+	cliente.RedirectURIs = strings.Fields(redirectURIs)
+	return &cliente, nil
+}
+
+// GuardarCodigoAutorizacion inserta el hash de un código de autorización
+func (r *UsuarioRepositorioSQL) GuardarCodigoAutorizacion(codigo *modelos.CodigoAutorizacion) error {
+	consulta := `INSERT INTO codigos_autorizacion (hash, cliente_id, usuario_id, redirect_uri, scope, nonce, desafio_pkce, autenticado_en, expira_en)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	id, err := r.insertar(consulta, codigo.Hash, codigo.ClienteID, codigo.UsuarioID, codigo.RedirectURI, codigo.Scope,
+		codigo.Nonce, codigo.DesafioPKCE, codigo.AutenticadoEn, codigo.ExpiraEn)
+	if err != nil {
+		return err
+	}
+	codigo.ID = id
+	return nil
+}
+
+// BuscarCodigoAutorizacion trae un código por su hash
+func (r *UsuarioRepositorioSQL) BuscarCodigoAutorizacion(hash string) (*modelos.CodigoAutorizacion, error) {
+	consulta := `SELECT id, hash, cliente_id, usuario_id, redirect_uri, scope, nonce, desafio_pkce, autenticado_en, expira_en, usado_en
+		FROM codigos_autorizacion WHERE hash = ?`
+	var codigo modelos.CodigoAutorizacion
+	var usadoEn time.Time
+	err := r.queryRow(consulta, hash).Scan(&codigo.ID, &codigo.Hash, &codigo.ClienteID, &codigo.UsuarioID, &codigo.RedirectURI,
+		&codigo.Scope, &codigo.Nonce, &codigo.DesafioPKCE, (*fechaSQL)(&codigo.AutenticadoEn), (*fechaSQL)(&codigo.ExpiraEn), (*fechaSQL)(&usadoEn))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCodigoNoEncontrado
+		}
+		return nil, err
+	}
+	codigo.UsadoEn = fechaOpcional(usadoEn)
+	return &codigo, nil
+}
+
+// MarcarCodigoAutorizacionUsado marca el código con un UPDATE condicional, igual que
+// MarcarTokenRefrescoUsado, para que dos pedidos no puedan cambiar el mismo código
+func (r *UsuarioRepositorioSQL) MarcarCodigoAutorizacionUsado(id uint, momento time.Time) (bool, error) {
+	resultado, err := r.exec(`UPDATE codigos_autorizacion SET usado_en = ? WHERE id = ? AND usado_en IS NULL`, momento, id)
+	if err != nil {
+		return false, err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return filas == 1, nil
+}
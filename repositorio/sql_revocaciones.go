@@ -0,0 +1,62 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RevocarTokenAcceso inserta el jti; revocar dos veces el mismo token no es un error
+func (r *UsuarioRepositorioSQL) RevocarTokenAcceso(jti string, usuarioID uint, expiraEn time.Time) error {
+	_, err := r.exec(`INSERT INTO tokens_revocados (jti, usuario_id, expira_en) VALUES (?, ?, ?)`, jti, usuarioID, expiraEn)
+	if err != nil && errors.Is(r.motor.traducirError(err), ErrUsuarioDuplicado) {
+		return nil
+	}
+	return err
+}
+
+// TokenAccesoRevocado busca el jti entre los revocados
+func (r *UsuarioRepositorioSQL) TokenAccesoRevocado(jti string) (bool, error) {
+	var encontrado string
+	err := r.queryRow(`SELECT jti FROM tokens_revocados WHERE jti = ?`, jti).Scan(&encontrado)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RevocarTokensAccesoDeUsuario guarda el momento de la revocación. Primero intenta
+// actualizar la fila existente y, si no hay, la inserta; si otro pedido la insertó
+// en el medio, vuelve a actualizar.
+func (r *UsuarioRepositorioSQL) RevocarTokensAccesoDeUsuario(usuarioID uint, momento time.Time) error {
+	actualizar := `UPDATE revocaciones_usuario SET revocado_en = ? WHERE usuario_id = ?`
+	resultado, err := r.exec(actualizar, momento, usuarioID)
+	if err != nil {
+		return err
+	}
+	// MySQL cuenta 0 filas si el valor no cambió; el INSERT duplicado lo resuelve igual
+	if filas, err := resultado.RowsAffected(); err != nil || filas > 0 {
+		return err
+	}
+	_, err = r.exec(`INSERT INTO revocaciones_usuario (usuario_id, revocado_en) VALUES (?, ?)`, usuarioID, momento)
+	if err != nil && errors.Is(r.motor.traducirError(err), ErrUsuarioDuplicado) {
+		_, err = r.exec(actualizar, momento, usuarioID)
+	}
+	return err
+}
+
+// TokensAccesoRevocadosDesde trae la última revocación del usuario
+func (r *UsuarioRepositorioSQL) TokensAccesoRevocadosDesde(usuarioID uint) (time.Time, error) {
+	var revocadoEn time.Time
+	err := r.queryRow(`SELECT revocado_en FROM revocaciones_usuario WHERE usuario_id = ?`, usuarioID).Scan((*fechaSQL)(&revocadoEn))
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return revocadoEn, err
+}
+
+// PurgarRevocacionesVencidas borra los jti de tokens ya expirados, que no hace falta recordar
+func (r *UsuarioRepositorioSQL) PurgarRevocacionesVencidas(ahora time.Time) error {
+	_, err := r.exec(`DELETE FROM tokens_revocados WHERE `+r.fecha("expira_en")+` < `+r.fecha("?"), ahora)
+	return err
+}
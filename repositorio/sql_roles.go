@@ -0,0 +1,90 @@
+package repositorio
+
+import (
+	"strings"
+	"taller6/modelos"
+)
+
+// ListarRoles trae todos los roles con sus permisos
+func (r *UsuarioRepositorioSQL) ListarRoles() ([]modelos.Rol, error) {
+	return r.consultarRoles(`SELECT id, nombre, permisos FROM roles ORDER BY nombre`)
+}
+
+// RolesDeUsuario trae los roles asignados al usuario
+func (r *UsuarioRepositorioSQL) RolesDeUsuario(usuarioID uint) ([]modelos.Rol, error) {
+	consulta := `SELECT r.id, r.nombre, r.permisos FROM roles r
+		JOIN usuario_roles ur ON ur.rol_id = r.id
+		WHERE ur.usuario_id = ? ORDER BY r.nombre`
+	return r.consultarRoles(consulta, usuarioID)
+}
+
+// AsignarRoles reemplaza, dentro de una transacción, los roles del usuario. Como el resto
+// de las operaciones, trata a un usuario eliminado como inexistente.
+func (r *UsuarioRepositorioSQL) AsignarRoles(usuarioID uint, roles []string) error {
+	// Los roles se leen antes de abrir la transacción: SQLite usa una sola conexión
+	existentes, err := r.ListarRoles()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.bd.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// El usuario se busca dentro de la transacción, así no se le asignan roles a uno que
+	// se eliminó mientras tanto
+	var activo int
+	if err := tx.QueryRow(r.adaptar(`SELECT COUNT(*) FROM usuarios WHERE id = ? AND eliminado_en IS NULL`), usuarioID).Scan(&activo); err != nil {
+		return err
+	}
+	if activo == 0 {
+		return ErrUsuarioNoEncontrado
+	}
+
+	// Traducimos los nombres a IDs y verificamos que existan todos
+	idPorNombre := make(map[string]uint, len(existentes))
+	for _, rol := range existentes {
+		idPorNombre[rol.Nombre] = rol.ID
+	}
+	ids := make(map[uint]bool, len(roles))
+	for _, nombre := range roles {
+		id, existe := idPorNombre[nombre]
+		if !existe {
+			return ErrRolNoEncontrado
+		}
+		ids[id] = true
+	}
+
+	if _, err := tx.Exec(r.adaptar(`DELETE FROM usuario_roles WHERE usuario_id = ?`), usuarioID); err != nil {
+		return err
+	}
+	for id := range ids {
+		if _, err := tx.Exec(r.adaptar(`INSERT INTO usuario_roles (usuario_id, rol_id) VALUES (?, ?)`), usuarioID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// consultarRoles ejecuta una consulta que devuelve id, nombre y permisos de roles
+func (r *UsuarioRepositorioSQL) consultarRoles(consulta string, args ...interface{}) ([]modelos.Rol, error) {
+	rows, err := r.query(consulta, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []modelos.Rol
+	for rows.Next() {
+		var rol modelos.Rol
+		var permisos string // Los permisos se guardan separados por espacios
+		if err := rows.Scan(&rol.ID, &rol.Nombre, &permisos); err != nil {
+			return nil, err
+		}
+		rol.Permisos = strings.Fields(permisos)
+		roles = append(roles, rol)
+	}
+	return roles, rows.Err()
+}
@@ -0,0 +1,130 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// GuardarSegundoFactor borra el segundo factor anterior del usuario e inserta el nuevo
+func (r *UsuarioRepositorioSQL) GuardarSegundoFactor(factor *modelos.SegundoFactor) error {
+	tx, err := r.bd.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.adaptar(`DELETE FROM segundo_factor WHERE usuario_id = ?`), factor.UsuarioID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(r.adaptar(`INSERT INTO segundo_factor (usuario_id, secreto, creado_en, confirmado_en, ultimo_paso) VALUES (?, ?, ?, ?, ?)`),
+		factor.UsuarioID, factor.Secreto, factor.CreadoEn, factor.ConfirmadoEn, factor.UltimoPaso)
+	if err != nil {
+		return r.motor.traducirError(err)
+	}
+	return tx.Commit()
+}
+
+// BuscarSegundoFactor trae el segundo factor del usuario
+func (r *UsuarioRepositorioSQL) BuscarSegundoFactor(usuarioID uint) (*modelos.SegundoFactor, error) {
+	consulta := `SELECT usuario_id, secreto, creado_en, confirmado_en, ultimo_paso FROM segundo_factor WHERE usuario_id = ?`
+	var factor modelos.SegundoFactor
+	var confirmadoEn time.Time
+	err := r.queryRow(consulta, usuarioID).Scan(&factor.UsuarioID, &factor.Secreto, (*fechaSQL)(&factor.CreadoEn),
+		(*fechaSQL)(&confirmadoEn), &factor.UltimoPaso)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSegundoFactorNoEncontrado
+		}
+		return nil, err
+	}
+	factor.ConfirmadoEn = fechaOpcional(confirmadoEn)
+	return &factor, nil
+}
+
+// ConfirmarSegundoFactor guarda el momento de la confirmación
+func (r *UsuarioRepositorioSQL) ConfirmarSegundoFactor(usuarioID uint, momento time.Time) error {
+	resultado, err := r.exec(`UPDATE segundo_factor SET confirmado_en = ? WHERE usuario_id = ?`, momento, usuarioID)
+	if err != nil {
+		return err
+	}
+	if filas, err := resultado.RowsAffected(); err == nil && filas == 0 {
+		return ErrSegundoFactorNoEncontrado
+	}
+	return nil
+}
+
+// RegistrarPasoTOTP usa un UPDATE condicional, así dos pedidos con el mismo código no pasan los dos
+func (r *UsuarioRepositorioSQL) RegistrarPasoTOTP(usuarioID uint, paso int64) (bool, error) {
+	resultado, err := r.exec(`UPDATE segundo_factor SET ultimo_paso = ? WHERE usuario_id = ? AND ultimo_paso < ?`, paso, usuarioID, paso)
+	if err != nil {
+		return false, err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return filas == 1, nil
+}
+
+// EliminarSegundoFactor borra el segundo factor y los códigos de recuperación del usuario
+func (r *UsuarioRepositorioSQL) EliminarSegundoFactor(usuarioID uint) error {
+	tx, err := r.bd.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.adaptar(`DELETE FROM codigos_recuperacion WHERE usuario_id = ?`), usuarioID); err != nil {
+		return err
+	}
+	resultado, err := tx.Exec(r.adaptar(`DELETE FROM segundo_factor WHERE usuario_id = ?`), usuarioID)
+	if err != nil {
+		return err
+	}
+	if filas, err := resultado.RowsAffected(); err == nil && filas == 0 {
+		return ErrSegundoFactorNoEncontrado
+	}
+	return tx.Commit()
+}
+
+// ReemplazarCodigosRecuperacion cambia todos los códigos del usuario en una transacción
+func (r *UsuarioRepositorioSQL) ReemplazarCodigosRecuperacion(usuarioID uint, hashes []string) error {
+	tx, err := r.bd.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.adaptar(`DELETE FROM codigos_recuperacion WHERE usuario_id = ?`), usuarioID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(r.adaptar(`INSERT INTO codigos_recuperacion (usuario_id, hash) VALUES (?, ?)`), usuarioID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UsarCodigoRecuperacion marca el código con un UPDATE condicional
+func (r *UsuarioRepositorioSQL) UsarCodigoRecuperacion(usuarioID uint, hash string, momento time.Time) (bool, error) {
+	resultado, err := r.exec(`UPDATE codigos_recuperacion SET usado_en = ? WHERE usuario_id = ? AND hash = ? AND usado_en IS NULL`,
+		momento, usuarioID, hash)
+	if err != nil {
+		return false, err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return filas > 0, nil
+}
+
+// CodigosRecuperacionRestantes cuenta los códigos sin usar
+func (r *UsuarioRepositorioSQL) CodigosRecuperacionRestantes(usuarioID uint) (int, error) {
+	var cantidad int
+	err := r.queryRow(`SELECT COUNT(*) FROM codigos_recuperacion WHERE usuario_id = ? AND usado_en IS NULL`, usuarioID).Scan(&cantidad)
+	return cantidad, err
+}
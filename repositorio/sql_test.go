@@ -0,0 +1,68 @@
+package repositorio_test
+
+import (
+	"database/sql"
+	"math"
+	"os"
+	"taller6/base_datos"
+	"taller6/migraciones"
+	"testing"
+)
+
+// Variables de entorno con el dsn de las bases de MySQL y PostgreSQL en las que se
+// ejecuta la batería de conformidad. Sin ellas esas pruebas se saltean. Las bases tienen
+// que ser descartables: la prueba revierte todas las migraciones antes y después.
+const (
+	entornoDSNMySQL    = "TALLER6_PRUEBA_MYSQL_DSN"
+	entornoDSNPostgres = "TALLER6_PRUEBA_POSTGRES_DSN"
+)
+
+// dsnDePrueba devuelve el dsn de la variable de entorno o saltea la prueba si no está
+func dsnDePrueba(t *testing.T, variable string) string {
+	t.Helper()
+	dsn := os.Getenv(variable)
+	if dsn == "" {
+		t.Skipf("%s no está definida", variable)
+	}
+	return dsn
+}
+
+// baseDePrueba abre la base del dsn y la deja con todas las migraciones aplicadas y sin
+// datos. Al terminar la prueba revierte las migraciones y cierra la conexión.
+func baseDePrueba(t *testing.T, dsn string, dialecto migraciones.Dialecto) *sql.DB {
+	t.Helper()
+	motor, dsnDriver, err := base_datos.SepararDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver := motor
+	if motor == base_datos.MotorPostgres {
+		driver = "pgx"
+	}
+	bd, err := sql.Open(driver, dsnDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if motor == base_datos.MotorSQLite {
+		bd.SetMaxOpenConns(1)
+	}
+
+	migrador, err := migraciones.Nuevo(bd, dialecto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lo que haya quedado de una ejecución anterior que se cortó
+	if _, err := migrador.Bajar(math.MaxInt); err != nil {
+		t.Fatal("no se pudieron revertir las migraciones:", err)
+	}
+	if _, err := migrador.Subir(); err != nil {
+		t.Fatal("no se pudieron aplicar las migraciones:", err)
+	}
+	t.Cleanup(func() {
+		if _, err := migrador.Bajar(math.MaxInt); err != nil {
+			t.Error("no se pudieron revertir las migraciones:", err)
+		}
+		bd.Close()
+	})
+	return bd
+}
@@ -0,0 +1,73 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// GuardarTokenRefresco inserta el hash de un token de refresco
+func (r *UsuarioRepositorioSQL) GuardarTokenRefresco(token *modelos.TokenRefresco) error {
+	consulta := `INSERT INTO tokens_refresco (usuario_id, familia, hash, creado_en, expira_en) VALUES (?, ?, ?, ?, ?)`
+	args := []interface{}{token.UsuarioID, token.Familia, token.Hash, token.CreadoEn, token.ExpiraEn}
+	id, err := r.insertar(consulta, args...)
+	if err != nil {
+		return err
+	}
+	token.ID = id
+	return nil
+}
+
+// BuscarTokenRefresco trae un token por su hash
+func (r *UsuarioRepositorioSQL) BuscarTokenRefresco(hash string) (*modelos.TokenRefresco, error) {
+	consulta := `SELECT id, usuario_id, familia, hash, creado_en, expira_en, usado_en, revocado_en
+		FROM tokens_refresco WHERE hash = ?`
+	var token modelos.TokenRefresco
+	var usadoEn, revocadoEn time.Time
+	err := r.queryRow(consulta, hash).Scan(&token.ID, &token.UsuarioID, &token.Familia, &token.Hash,
+		(*fechaSQL)(&token.CreadoEn), (*fechaSQL)(&token.ExpiraEn), (*fechaSQL)(&usadoEn), (*fechaSQL)(&revocadoEn))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNoEncontrado
+		}
+		return nil, err
+	}
+	token.UsadoEn = fechaOpcional(usadoEn)
+	token.RevocadoEn = fechaOpcional(revocadoEn)
+	return &token, nil
+}
+
+// MarcarTokenRefrescoUsado marca el token como usado con un UPDATE condicional,
+// así dos pedidos simultáneos con el mismo token no pueden rotarlo los dos
+func (r *UsuarioRepositorioSQL) MarcarTokenRefrescoUsado(id uint, momento time.Time) (bool, error) {
+	resultado, err := r.exec(`UPDATE tokens_refresco SET usado_en = ? WHERE id = ? AND usado_en IS NULL`, momento, id)
+	if err != nil {
+		return false, err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return filas == 1, nil
+}
+
+// RevocarFamilia revoca los tokens vigentes de la familia
+func (r *UsuarioRepositorioSQL) RevocarFamilia(familia string, momento time.Time) error {
+	_, err := r.exec(`UPDATE tokens_refresco SET revocado_en = ? WHERE familia = ? AND revocado_en IS NULL`, momento, familia)
+	return err
+}
+
+// RevocarTokensRefrescoDeUsuario revoca los tokens vigentes del usuario
+func (r *UsuarioRepositorioSQL) RevocarTokensRefrescoDeUsuario(usuarioID uint, momento time.Time) error {
+	_, err := r.exec(`UPDATE tokens_refresco SET revocado_en = ? WHERE usuario_id = ? AND revocado_en IS NULL`, momento, usuarioID)
+	return err
+}
+
+// fechaOpcional convierte la fecha cero (columna NULL) en nil
+func fechaOpcional(fecha time.Time) *time.Time {
+	if fecha.IsZero() {
+		return nil
+	}
+	return &fecha
+}
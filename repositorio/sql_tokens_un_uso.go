@@ -0,0 +1,57 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// GuardarTokenUnUso inserta el hash de un token de un solo uso
+func (r *UsuarioRepositorioSQL) GuardarTokenUnUso(token *modelos.TokenUnUso) error {
+	consulta := `INSERT INTO tokens_un_uso (usuario_id, proposito, hash, creado_en, expira_en, dato) VALUES (?, ?, ?, ?, ?, ?)`
+	id, err := r.insertar(consulta, token.UsuarioID, token.Proposito, token.Hash, token.CreadoEn, token.ExpiraEn, token.Dato)
+	if err != nil {
+		return err
+	}
+	token.ID = id
+	return nil
+}
+
+// BuscarTokenUnUso trae un token por su propósito y su hash
+func (r *UsuarioRepositorioSQL) BuscarTokenUnUso(proposito, hash string) (*modelos.TokenUnUso, error) {
+	consulta := `SELECT id, usuario_id, proposito, hash, creado_en, expira_en, usado_en, dato
+		FROM tokens_un_uso WHERE proposito = ? AND hash = ?`
+	var token modelos.TokenUnUso
+	var usadoEn time.Time
+	err := r.queryRow(consulta, proposito, hash).Scan(&token.ID, &token.UsuarioID, &token.Proposito, &token.Hash,
+		(*fechaSQL)(&token.CreadoEn), (*fechaSQL)(&token.ExpiraEn), (*fechaSQL)(&usadoEn), &token.Dato)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNoEncontrado
+		}
+		return nil, err
+	}
+	token.UsadoEn = fechaOpcional(usadoEn)
+	return &token, nil
+}
+
+// MarcarTokenUnUsoUsado marca el token con un UPDATE condicional, así no se puede usar dos veces
+func (r *UsuarioRepositorioSQL) MarcarTokenUnUsoUsado(id uint, momento time.Time) (bool, error) {
+	resultado, err := r.exec(`UPDATE tokens_un_uso SET usado_en = ? WHERE id = ? AND usado_en IS NULL`, momento, id)
+	if err != nil {
+		return false, err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return filas == 1, nil
+}
+
+// InvalidarTokensUnUso marca como usados los tokens pendientes del usuario con ese propósito
+func (r *UsuarioRepositorioSQL) InvalidarTokensUnUso(usuarioID uint, proposito string, momento time.Time) error {
+	_, err := r.exec(`UPDATE tokens_un_uso SET usado_en = ? WHERE usuario_id = ? AND proposito = ? AND usado_en IS NULL`,
+		momento, usuarioID, proposito)
+	return err
+}
@@ -0,0 +1,116 @@
+package repositorio
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"taller6/modelos"
+	"time"
+)
+
+// El ID de la credencial y la clave pública son binarios; se guardan en base64url para
+// usar la misma columna de texto en los tres motores
+var codificacionCredencial = base64.RawURLEncoding
+
+// GuardarCredencialWebAuthn inserta la credencial con sus transportes separados por espacios
+func (r *UsuarioRepositorioSQL) GuardarCredencialWebAuthn(credencial *modelos.CredencialWebAuthn) error {
+	if credencial.CreadoEn.IsZero() {
+		credencial.CreadoEn = time.Now()
+	}
+	consulta := `INSERT INTO credenciales_webauthn (usuario_id, credencial_id, clave_publica, tipo_atestacion, transportes, contador, nombre, creado_en, usado_en) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	id, err := r.insertar(consulta, credencial.UsuarioID, codificacionCredencial.EncodeToString(credencial.CredencialID),
+		codificacionCredencial.EncodeToString(credencial.ClavePublica), credencial.TipoAtestacion,
+		strings.Join(credencial.Transportes, " "), credencial.Contador, credencial.Nombre, credencial.CreadoEn, credencial.UsadoEn)
+	if err != nil {
+		return err
+	}
+	credencial.ID = id
+	return nil
+}
+
+// CredencialesWebAuthnDeUsuario trae las credenciales del usuario
+func (r *UsuarioRepositorioSQL) CredencialesWebAuthnDeUsuario(usuarioID uint) ([]modelos.CredencialWebAuthn, error) {
+	consulta := `SELECT id, usuario_id, credencial_id, clave_publica, tipo_atestacion, transportes, contador, nombre, creado_en, usado_en
+		FROM credenciales_webauthn WHERE usuario_id = ? ORDER BY id`
+	rows, err := r.query(consulta, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credenciales []modelos.CredencialWebAuthn
+	for rows.Next() {
+		credencial, err := escanearCredencialWebAuthn(rows)
+		if err != nil {
+			return nil, err
+		}
+		credenciales = append(credenciales, *credencial)
+	}
+	return credenciales, rows.Err()
+}
+
+// RegistrarUsoCredencialWebAuthn usa un UPDATE condicional, así dos pedidos con la misma
+// firma no pasan los dos
+func (r *UsuarioRepositorioSQL) RegistrarUsoCredencialWebAuthn(id uint, contador uint32, momento time.Time) (bool, error) {
+	resultado, err := r.exec(`UPDATE credenciales_webauthn SET contador = ?, usado_en = ? WHERE id = ? AND (contador < ? OR (contador = 0 AND ? = 0))`,
+		contador, momento, id, contador, contador)
+	if err != nil {
+		return false, err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if filas == 1 {
+		return true, nil
+	}
+
+	// MySQL no cuenta las filas que quedan iguales: con los dos contadores en cero y
+	// el mismo segundo en usado_en el UPDATE coincidió aunque informe 0
+	var guardado uint32
+	if err := r.queryRow(`SELECT contador FROM credenciales_webauthn WHERE id = ?`, id).Scan(&guardado); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrCredencialNoEncontrada
+		}
+		return false, err
+	}
+	return contador == 0 && guardado == 0, nil
+}
+
+// EliminarCredencialWebAuthn borra la credencial solo si es del usuario
+func (r *UsuarioRepositorioSQL) EliminarCredencialWebAuthn(usuarioID, id uint) error {
+	resultado, err := r.exec(`DELETE FROM credenciales_webauthn WHERE id = ? AND usuario_id = ?`, id, usuarioID)
+	if err != nil {
+		return err
+	}
+	filas, err := resultado.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if filas == 0 {
+		return ErrCredencialNoEncontrada
+	}
+	return nil
+}
+
+// escanearCredencialWebAuthn lee una fila de credenciales_webauthn
+func escanearCredencialWebAuthn(fila escaner) (*modelos.CredencialWebAuthn, error) {
+	var credencial modelos.CredencialWebAuthn
+	var credencialID, clavePublica, transportes string
+	var usadoEn time.Time
+	err := fila.Scan(&credencial.ID, &credencial.UsuarioID, &credencialID, &clavePublica, &credencial.TipoAtestacion,
+		&transportes, &credencial.Contador, &credencial.Nombre, (*fechaSQL)(&credencial.CreadoEn), (*fechaSQL)(&usadoEn))
+	if err != nil {
+		return nil, err
+	}
+	if credencial.CredencialID, err = codificacionCredencial.DecodeString(credencialID); err != nil {
+		return nil, err
+	}
+	if credencial.ClavePublica, err = codificacionCredencial.DecodeString(clavePublica); err != nil {
+		return nil, err
+	}
+	credencial.Transportes = strings.Fields(transportes)
+	credencial.UsadoEn = fechaOpcional(usadoEn)
+	return &credencial, nil
+}
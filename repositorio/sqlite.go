@@ -0,0 +1,31 @@
+package repositorio
+
+import (
+	"database/sql"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// NuevoUsuarioRepositorioSQLite crea el repositorio a partir de una conexión SQLite ya abierta
+func NuevoUsuarioRepositorioSQLite(bd *sql.DB) *UsuarioRepositorioSQL {
+	return &UsuarioRepositorioSQL{
+		bd:     bd,
+		motor:  motorSQL{traducirError: traducirErrorSQLite, fechasComoTexto: true},
+		indice: &indiceUsuarios{},
+	}
+}
+
+// traducirErrorSQLite convierte las violaciones de UNIQUE y de clave primaria en ErrUsuarioDuplicado
+// (MySQL y PostgreSQL usan el mismo código para las dos)
+func traducirErrorSQLite(err error) error {
+	var errSQLite *sqlite.Error
+	if errors.As(err, &errSQLite) {
+		switch errSQLite.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return ErrUsuarioDuplicado
+		}
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+package repositorio_test
+
+import (
+	"path/filepath"
+	"taller6/migraciones"
+	"taller6/repositorio"
+	"taller6/repositorio/repositoriotest"
+	"testing"
+)
+
+func TestConformidadSQLite(t *testing.T) {
+	bd := baseDePrueba(t, "sqlite://"+filepath.Join(t.TempDir(), "taller6.db"), migraciones.SQLite{})
+	if err := repositoriotest.Verificar(repositorio.NuevoUsuarioRepositorioSQLite(bd)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,25 @@
+package repositorio
+
+import (
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// ErrTokenNoEncontrado se devuelve cuando no existe un token con ese hash
+var ErrTokenNoEncontrado = errors.New("token no encontrado")
+
+// TokenRefrescoRepositorio guarda los tokens de refresco (solo su hash)
+type TokenRefrescoRepositorio interface {
+	// GuardarTokenRefresco guarda un token nuevo y completa su ID
+	GuardarTokenRefresco(token *modelos.TokenRefresco) error
+	// BuscarTokenRefresco devuelve el token con ese hash o ErrTokenNoEncontrado
+	BuscarTokenRefresco(hash string) (*modelos.TokenRefresco, error)
+	// MarcarTokenRefrescoUsado marca el token como usado solo si no lo estaba.
+	// Devuelve false si otro pedido ya lo había usado (posible reutilización).
+	MarcarTokenRefrescoUsado(id uint, momento time.Time) (bool, error)
+	// RevocarFamilia revoca todos los tokens de la familia que sigan vigentes
+	RevocarFamilia(familia string, momento time.Time) error
+	// RevocarTokensRefrescoDeUsuario revoca todos los tokens del usuario
+	RevocarTokensRefrescoDeUsuario(usuarioID uint, momento time.Time) error
+}
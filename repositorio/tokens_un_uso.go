@@ -0,0 +1,21 @@
+package repositorio
+
+import (
+	"taller6/modelos"
+	"time"
+)
+
+// TokenUnUsoRepositorio guarda los tokens de un solo uso (enlaces de los correos y
+// sesiones de WebAuthn)
+type TokenUnUsoRepositorio interface {
+	// GuardarTokenUnUso guarda un token nuevo y completa su ID
+	GuardarTokenUnUso(token *modelos.TokenUnUso) error
+	// BuscarTokenUnUso devuelve el token con ese propósito y hash o ErrTokenNoEncontrado
+	BuscarTokenUnUso(proposito, hash string) (*modelos.TokenUnUso, error)
+	// MarcarTokenUnUsoUsado marca el token como usado solo si no lo estaba.
+	// Devuelve false si ya se había usado.
+	MarcarTokenUnUsoUsado(id uint, momento time.Time) (bool, error)
+	// InvalidarTokensUnUso marca como usados los tokens pendientes del usuario con ese
+	// propósito, para que al pedir uno nuevo dejen de valer los anteriores
+	InvalidarTokensUnUso(usuarioID uint, proposito string, momento time.Time) error
+}
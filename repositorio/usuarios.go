@@ -0,0 +1,135 @@
+package repositorio
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"taller6/contrasenas"
+	"taller6/modelos"
+	"time"
+)
+
+// Errores comunes que devuelven todas las implementaciones del repositorio
+var (
+	ErrUsuarioNoEncontrado = errors.New("usuario no encontrado")
+	ErrUsuarioDuplicado    = errors.New("el nombre de usuario o el correo ya existen")
+)
+
+// UsuarioRepositorio define las operaciones de almacenamiento de usuarios.
+// Los manejadores dependen de esta interfaz y no de una base de datos concreta.
+type UsuarioRepositorio interface {
+	// Crear guarda un usuario nuevo y completa su ID. Devuelve ErrUsuarioDuplicado
+	// si el nombre de usuario o el correo ya están en uso.
+	Crear(usuario *modelos.Usuario) error
+	// BuscarPorID devuelve el usuario con ese ID o ErrUsuarioNoEncontrado. Como todas
+	// las operaciones salvo Restaurar y PurgarEliminados, trata a los usuarios
+	// eliminados como si no existieran.
+	BuscarPorID(id uint) (*modelos.Usuario, error)
+	// BuscarPorNombre devuelve el usuario con ese nombre de usuario o ErrUsuarioNoEncontrado
+	BuscarPorNombre(nombreUsuario string) (*modelos.Usuario, error)
+	// BuscarPorCorreo devuelve el usuario con ese correo o ErrUsuarioNoEncontrado
+	BuscarPorCorreo(correo string) (*modelos.Usuario, error)
+	// Listar devuelve todos los usuarios activos
+	Listar() ([]modelos.Usuario, error)
+	// ListarPagina devuelve hasta consulta.Limite usuarios que cumplen el filtro, en el
+	// orden pedido y a partir del cursor
+	ListarPagina(consulta ConsultaUsuarios) ([]modelos.Usuario, error)
+	// Contar devuelve cuántos usuarios cumplen el filtro
+	Contar(filtro FiltroUsuarios) (int, error)
+	// BuscarTexto devuelve hasta "limite" usuarios cuyo nombre de usuario o correo coincide
+	// con todos los términos (ya normalizados con busqueda.Terminos), del más parecido al
+	// menos. No distingue mayúsculas ni tildes y encuentra partes de palabras.
+	BuscarTexto(terminos []string, limite int) ([]UsuarioEncontrado, error)
+	// Actualizar modifica solo los campos no vacíos de "cambios" (la contraseña ya debe venir encriptada)
+	Actualizar(id uint, cambios modelos.Usuario) error
+	// VerificarCorreo marca como verificado el correo del usuario si sigue siendo "correo".
+	// Devuelve false si el usuario lo cambió después de pedir la verificación.
+	VerificarCorreo(id uint, correo string) (bool, error)
+	// ReemplazarContrasena cambia el hash de la contraseña solo si todavía es "anterior".
+	// Devuelve false si el usuario ya no existe o cambió la contraseña mientras tanto.
+	ReemplazarContrasena(id uint, anterior, nueva string) (bool, error)
+	// Eliminar da de baja al usuario con ese ID: deja de aparecer, pero conserva sus datos
+	// y sus roles, y su nombre de usuario y su correo siguen ocupados, hasta que se purga
+	Eliminar(id uint) error
+	// Restaurar vuelve a activar un usuario eliminado. Devuelve ErrUsuarioNoEncontrado si
+	// no hay un usuario eliminado con ese ID.
+	Restaurar(id uint) error
+	// PurgarEliminados borra de verdad, con todo lo asociado, los usuarios eliminados
+	// antes de "antesDe" y devuelve cuántos borró
+	PurgarEliminados(antesDe time.Time) (int, error)
+}
+
+// CrearUsuarioAdmin crea el usuario "admin" con la contraseña indicada si no existe
+// y se asegura de que tenga el rol admin. Si alguien lo eliminó y todavía no se purgó,
+// lo restaura: el nombre sigue ocupado y no se podría crear otro.
+func CrearUsuarioAdmin(repo Repositorio, contrasenaAdmin string, hasheador contrasenas.Hasheador) {
+	admin, err := repo.BuscarPorNombre("admin")
+	if errors.Is(err, ErrUsuarioNoEncontrado) {
+		admin, err = buscarEliminado(repo, "admin")
+		if err == nil {
+			if err := repo.Restaurar(admin.ID); err != nil {
+				log.Fatalf("Error al restaurar el usuario administrador: %v", err)
+			}
+			fmt.Println("Usuario administrador restaurado")
+		}
+	}
+	if errors.Is(err, ErrUsuarioNoEncontrado) {
+		// Hasheamos la contraseña del administrador
+		contrasenaEncriptada, err := hasheador.Hashear(contrasenaAdmin)
+		if err != nil {
+			log.Fatalf("Error al encriptar la contraseña de admin: %v", err)
+		}
+
+		// Creamos el usuario administrador
+		admin = &modelos.Usuario{
+			NombreUsuario: "admin",
+			Correo:        "",
+			Contrasena:    contrasenaEncriptada,
+			CreadoEn:      time.Now(),
+			// No tiene correo que verificar y tiene que poder entrar aunque se exija
+			CorreoVerificado: true,
+		}
+		if err := repo.Crear(admin); err != nil {
+			log.Fatalf("Error al crear el usuario administrador: %v", err)
+		}
+		fmt.Println("Usuario administrador creado exitosamente")
+	} else if err != nil {
+		log.Fatalf("Error al buscar el usuario administrador: %v", err)
+	}
+
+	// Le asignamos el rol admin si todavía no lo tiene, conservando los demás
+	roles, err := repo.RolesDeUsuario(admin.ID)
+	if err != nil {
+		log.Fatalf("Error al consultar los roles del administrador: %v", err)
+	}
+	nombres, _ := modelos.NombresYPermisos(roles)
+	for _, nombre := range nombres {
+		if nombre == modelos.RolAdmin {
+			return
+		}
+	}
+	if err := repo.AsignarRoles(admin.ID, append(nombres, modelos.RolAdmin)); err != nil {
+		log.Fatalf("Error al asignar el rol admin: %v", err)
+	}
+}
+
+// buscarEliminado busca entre los usuarios eliminados el que tiene exactamente ese nombre.
+// El filtro es por prefijo, así que se recorren las páginas hasta encontrarlo.
+func buscarEliminado(repo Repositorio, nombreUsuario string) (*modelos.Usuario, error) {
+	consulta := ConsultaUsuarios{Filtro: FiltroUsuarios{PrefijoNombre: nombreUsuario, Eliminados: true}, Limite: 100}
+	for {
+		pagina, err := repo.ListarPagina(consulta)
+		if err != nil {
+			return nil, err
+		}
+		for i := range pagina {
+			if pagina[i].NombreUsuario == nombreUsuario {
+				return &pagina[i], nil
+			}
+		}
+		if len(pagina) < consulta.Limite {
+			return nil, ErrUsuarioNoEncontrado
+		}
+		consulta.Despues = &pagina[len(pagina)-1]
+	}
+}
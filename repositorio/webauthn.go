@@ -0,0 +1,25 @@
+package repositorio
+
+import (
+	"errors"
+	"taller6/modelos"
+	"time"
+)
+
+// ErrCredencialNoEncontrada indica que el usuario no tiene una credencial WebAuthn con ese ID
+var ErrCredencialNoEncontrada = errors.New("credencial WebAuthn no encontrada")
+
+// CredencialWebAuthnRepositorio guarda las llaves de acceso de los usuarios
+type CredencialWebAuthnRepositorio interface {
+	// GuardarCredencialWebAuthn guarda una credencial nueva y completa su ID.
+	// Devuelve ErrUsuarioDuplicado si ya hay una con el mismo CredencialID.
+	GuardarCredencialWebAuthn(credencial *modelos.CredencialWebAuthn) error
+	// CredencialesWebAuthnDeUsuario devuelve las credenciales del usuario ordenadas por ID
+	CredencialesWebAuthnDeUsuario(usuarioID uint) ([]modelos.CredencialWebAuthn, error)
+	// RegistrarUsoCredencialWebAuthn guarda el contador de firmas y el momento del uso solo
+	// si el contador avanzó (o el autenticador no lleva la cuenta y los dos son cero).
+	// Devuelve false si no avanzó, lo que indica un autenticador clonado o una respuesta repetida.
+	RegistrarUsoCredencialWebAuthn(id uint, contador uint32, momento time.Time) (bool, error)
+	// EliminarCredencialWebAuthn borra la credencial si es del usuario o devuelve ErrCredencialNoEncontrada
+	EliminarCredencialWebAuthn(usuarioID, id uint) error
+}